@@ -0,0 +1,271 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/ast"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/dependencies/testing"
+	"github.com/influxdata/flux/fluxinit"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/memory"
+	"github.com/spf13/cobra"
+)
+
+type benchFlags struct {
+	testNames     []string
+	paths         []string
+	skipTestCases []string
+	count         int
+	out           string
+	baseline      string
+	alpha         float64
+}
+
+func BenchCommand(setup TestSetupFunc) *cobra.Command {
+	var flags benchFlags
+	benchCommand := &cobra.Command{
+		Use:   "bench",
+		Short: "Run flux benchmarks",
+		Long:  "Run flux test cases as benchmarks and compare them with a baseline",
+		Run: func(cmd *cobra.Command, args []string) {
+			fluxinit.FluxInit()
+			if err := runFluxBenchmarks(setup, flags); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		},
+	}
+	benchCommand.Flags().StringSliceVarP(&flags.paths, "path", "p", nil, "The root level directory for all packages.")
+	benchCommand.Flags().StringSliceVar(&flags.testNames, "test", []string{}, "The name of a specific test to benchmark.")
+	benchCommand.Flags().StringSliceVar(&flags.skipTestCases, "skip", []string{}, "Comma-separated list of test cases to skip.")
+	benchCommand.Flags().IntVarP(&flags.count, "count", "n", 10, "The number of times each benchmark is run.")
+	benchCommand.Flags().StringVar(&flags.out, "out", "", "Write the results of the benchmarks as JSON to this file.")
+	benchCommand.Flags().StringVar(&flags.baseline, "baseline", "", "Compare the results with the JSON results of a previous run in this file.")
+	benchCommand.Flags().Float64Var(&flags.alpha, "alpha", 0.05, "The significance level of the comparison with the baseline.")
+	return benchCommand
+}
+
+func init() {
+	benchCommand := BenchCommand(NewTestExecutor)
+	rootCmd.AddCommand(benchCommand)
+}
+
+// runFluxBenchmarks runs the benchmarks, writes their results and
+// compares them with the baseline.
+func runFluxBenchmarks(setup TestSetupFunc, flags benchFlags) error {
+	if len(flags.paths) == 0 {
+		flags.paths = []string{"."}
+	}
+
+	// Read the baseline first so a missing file does not waste a run.
+	var baseline *BenchReport
+	if flags.baseline != "" {
+		r, err := readBenchReport(flags.baseline)
+		if err != nil {
+			return err
+		}
+		baseline = r
+	}
+
+	runner := NewTestRunner(NewTestReporter(0))
+	if err := runner.Gather(flags.paths, flags.testNames); err != nil {
+		return err
+	}
+
+	executor, err := setup(context.Background())
+	if err != nil {
+		return err
+	}
+	defer func() { _ = executor.Close() }()
+
+	report, err := runner.Bench(executor, BenchOptions{
+		SkipTestCases: flags.skipTestCases,
+		Count:         flags.count,
+	})
+	if err != nil {
+		return err
+	}
+	if flags.out != "" {
+		if err := writeReportFile(flags.out, report.WriteJSON); err != nil {
+			return err
+		}
+	}
+
+	if baseline != nil {
+		err = compareBenchReports(os.Stdout, baseline, report, flags.alpha)
+	} else {
+		err = writeBenchSummary(os.Stdout, report)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, b := range report.Benchmarks {
+		if b.Error != "" {
+			return errors.New(codes.FailedPrecondition, "some benchmarks failed")
+		}
+	}
+	return nil
+}
+
+// BenchExecutor is a TestExecutor that can measure a run of a program.
+// It is required to run benchmarks.
+type BenchExecutor interface {
+	TestExecutor
+	Bench(pkg *ast.Package) (BenchRun, error)
+}
+
+// BenchOptions configures how a TestRunner runs benchmarks.
+type BenchOptions struct {
+	// SkipTestCases holds the names of the tests that are not run.
+	SkipTestCases []string
+	// Count is the number of times each benchmark is run.
+	Count int
+}
+
+// BenchReport holds the results of a benchmark run.
+type BenchReport struct {
+	Benchmarks []*Benchmark `json:"benchmarks"`
+}
+
+// Benchmark holds the runs of a test case that was benchmarked.
+// A benchmark that failed holds the error of the failed run.
+type Benchmark struct {
+	Name  string     `json:"name"`
+	File  string     `json:"file"`
+	Runs  []BenchRun `json:"runs"`
+	Error string     `json:"error,omitempty"`
+}
+
+// BenchRun is the measurement of a single run of a benchmark.
+type BenchRun struct {
+	// Duration is the wall time of the query.
+	Duration time.Duration `json:"duration"`
+	// Rows is the number of rows the query produced.
+	Rows int64 `json:"rows"`
+	// RowsPerSec is the number of rows produced per second.
+	RowsPerSec float64 `json:"rows_per_sec"`
+	// MaxAllocated is the peak memory of the query allocator in bytes.
+	MaxAllocated int64 `json:"max_allocated"`
+}
+
+// WriteJSON writes the report as JSON.
+func (r *BenchReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+func readBenchReport(name string) (*BenchReport, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var r BenchReport
+	if err := json.NewDecoder(f).Decode(&r); err != nil {
+		return nil, errors.Wrapf(err, codes.Invalid, "failed to read benchmark results from %s", name)
+	}
+	return &r, nil
+}
+
+// Bench runs each test as a benchmark. The output of a test that compares
+// it with testing.diff is read without comparing it, like testing.benchmark.
+// The benchmarks run one at a time, so they do not compete for resources.
+func (t *TestRunner) Bench(executor TestExecutor, opts BenchOptions) (*BenchReport, error) {
+	e, ok := executor.(BenchExecutor)
+	if !ok {
+		return nil, errors.New(codes.Unimplemented, "test executor does not support benchmarks")
+	}
+	count := opts.Count
+	if count < 1 {
+		count = 1
+	}
+
+	report := &BenchReport{}
+	for _, test := range t.tests {
+		if contains(opts.SkipTestCases, test.name) {
+			test.skipped = true
+			continue
+		}
+		b := &Benchmark{Name: test.Name(), File: test.File()}
+		pkg := test.benchPackage()
+		for i := 0; i < count; i++ {
+			run, err := e.Bench(pkg)
+			if err != nil {
+				b.Error = err.Error()
+				break
+			}
+			b.Runs = append(b.Runs, run)
+		}
+		report.Benchmarks = append(report.Benchmarks, b)
+	}
+	return report, nil
+}
+
+// benchPackage returns the package of the test that is run as a benchmark.
+// A test that calls testing.diff yields its output instead.
+// Any other test is run as is.
+func (t *Test) benchPackage() *ast.Package {
+	if len(t.ast.Files) == 0 {
+		return t.ast
+	}
+	stmtIdx, got, _, err := findDiff(t.ast.Files[len(t.ast.Files)-1])
+	if err != nil {
+		return t.ast
+	}
+	return yieldGot(t.ast, stmtIdx, got)
+}
+
+// Bench runs the test package and measures the query.
+// The compilation of the program is not part of the measurement.
+func (e testExecutor) Bench(pkg *ast.Package) (BenchRun, error) {
+	ctx, program, err := e.compile(context.Background(), pkg, testing.FrameworkConfig{})
+	if err != nil {
+		return BenchRun{}, err
+	}
+
+	alloc := &memory.Allocator{}
+	start := time.Now()
+	query, err := program.Start(ctx, alloc)
+	if err != nil {
+		return BenchRun{}, errors.Wrap(err, codes.Inherit, "error while executing program")
+	}
+	defer query.Done()
+
+	var rows int64
+	results := flux.NewResultIteratorFromQuery(query)
+	for results.More() {
+		if err := results.Next().Tables().Do(func(tbl flux.Table) error {
+			return tbl.Do(func(cr flux.ColReader) error {
+				rows += int64(cr.Len())
+				return nil
+			})
+		}); err != nil {
+			results.Release()
+			return BenchRun{}, err
+		}
+	}
+	results.Release()
+	if err := results.Err(); err != nil {
+		return BenchRun{}, err
+	}
+
+	run := BenchRun{
+		Duration:     time.Since(start),
+		Rows:         rows,
+		MaxAllocated: alloc.MaxAllocated(),
+	}
+	if s := run.Duration.Seconds(); s > 0 {
+		run.RowsPerSec = float64(rows) / s
+	}
+	return run, nil
+}
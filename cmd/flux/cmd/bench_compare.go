@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"text/tabwriter"
+	"time"
+
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// benchMetric is a measurement of a benchmark that is summarized and compared.
+type benchMetric struct {
+	name   string
+	value  func(r BenchRun) float64
+	format func(v float64) string
+}
+
+var benchMetrics = []benchMetric{
+	{
+		name:   "time/op",
+		value:  func(r BenchRun) float64 { return float64(r.Duration) },
+		format: func(v float64) string { return time.Duration(v).Round(time.Microsecond).String() },
+	},
+	{
+		name:   "rows/sec",
+		value:  func(r BenchRun) float64 { return r.RowsPerSec },
+		format: func(v float64) string { return fmt.Sprintf("%.0f", v) },
+	},
+	{
+		name:   "peak-mem",
+		value:  func(r BenchRun) float64 { return float64(r.MaxAllocated) },
+		format: formatBytes,
+	},
+}
+
+func (m benchMetric) values(b *Benchmark) []float64 {
+	xs := make([]float64, len(b.Runs))
+	for i, r := range b.Runs {
+		xs[i] = m.value(r)
+	}
+	return xs
+}
+
+// summary formats the mean and the relative standard deviation of the values.
+// The deviation is left out for a single value.
+func (m benchMetric) summary(xs []float64) string {
+	if len(xs) == 0 {
+		return "-"
+	}
+	mean, stddev := meanStdDev(xs)
+	if len(xs) < 2 || mean == 0 {
+		return m.format(mean)
+	}
+	return fmt.Sprintf("%s ±%.0f%%", m.format(mean), 100*stddev/math.Abs(mean))
+}
+
+// writeBenchSummary writes a table with the mean of each metric of the benchmarks.
+func writeBenchSummary(w io.Writer, report *BenchReport) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprint(tw, "name\truns")
+	for _, m := range benchMetrics {
+		fmt.Fprintf(tw, "\t%s", m.name)
+	}
+	fmt.Fprintln(tw)
+	for _, b := range report.Benchmarks {
+		fmt.Fprintf(tw, "%s\t%d", benchKey(b), len(b.Runs))
+		if b.Error != "" {
+			fmt.Fprintf(tw, "\tfail: %s\n", firstLine(b.Error))
+			continue
+		}
+		for _, m := range benchMetrics {
+			fmt.Fprintf(tw, "\t%s", m.summary(m.values(b)))
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+// compareBenchReports writes a table for each metric that compares
+// the benchmarks with the baseline. A change is reported when the
+// p-value of Welch's t-test is below alpha, otherwise it is shown as ~.
+func compareBenchReports(w io.Writer, baseline, report *BenchReport, alpha float64) error {
+	old := make(map[string]*Benchmark, len(baseline.Benchmarks))
+	for _, b := range baseline.Benchmarks {
+		old[benchKey(b)] = b
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for i, m := range benchMetrics {
+		if i > 0 {
+			fmt.Fprintln(tw)
+		}
+		fmt.Fprintf(tw, "name\told %s\tnew %s\tdelta\n", m.name, m.name)
+		for _, b := range report.Benchmarks {
+			key := benchKey(b)
+			if b.Error != "" {
+				fmt.Fprintf(tw, "%s\t\t\tfail: %s\n", key, firstLine(b.Error))
+				continue
+			}
+			ys := m.values(b)
+			prev, ok := old[key]
+			if !ok || prev.Error != "" {
+				fmt.Fprintf(tw, "%s\t-\t%s\t-\n", key, m.summary(ys))
+				continue
+			}
+			xs := m.values(prev)
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", key, m.summary(xs), m.summary(ys), benchDelta(xs, ys, alpha))
+		}
+	}
+	return tw.Flush()
+}
+
+// benchDelta formats the relative change of the mean with the p-value.
+func benchDelta(xs, ys []float64, alpha float64) string {
+	p := welchTTest(xs, ys)
+	if math.IsNaN(p) {
+		return "~"
+	}
+	if p >= alpha {
+		return fmt.Sprintf("~ (p=%.3f)", p)
+	}
+	mx, _ := meanStdDev(xs)
+	my, _ := meanStdDev(ys)
+	if mx == 0 {
+		return fmt.Sprintf("+Inf%% (p=%.3f)", p)
+	}
+	return fmt.Sprintf("%+.2f%% (p=%.3f)", 100*(my-mx)/math.Abs(mx), p)
+}
+
+func benchKey(b *Benchmark) string {
+	if b.File == "" {
+		return b.Name
+	}
+	return b.File + ":" + b.Name
+}
+
+func meanStdDev(xs []float64) (mean, stddev float64) {
+	for _, x := range xs {
+		mean += x
+	}
+	mean /= float64(len(xs))
+	if len(xs) < 2 {
+		return mean, 0
+	}
+	for _, x := range xs {
+		stddev += (x - mean) * (x - mean)
+	}
+	return mean, math.Sqrt(stddev / float64(len(xs)-1))
+}
+
+// welchTTest returns the two-sided p-value of Welch's t-test for the
+// hypothesis that the samples have the same mean.
+// It returns NaN if either sample has fewer than two values.
+func welchTTest(xs, ys []float64) float64 {
+	nx, ny := float64(len(xs)), float64(len(ys))
+	if nx < 2 || ny < 2 {
+		return math.NaN()
+	}
+	mx, sx := meanStdDev(xs)
+	my, sy := meanStdDev(ys)
+	vx, vy := sx*sx/nx, sy*sy/ny
+	if vx+vy == 0 {
+		// Without variance, any difference of the means is significant.
+		if mx == my {
+			return 1
+		}
+		return 0
+	}
+
+	t := (mx - my) / math.Sqrt(vx+vy)
+	df := (vx + vy) * (vx + vy) / (vx*vx/(nx-1) + vy*vy/(ny-1))
+	dist := distuv.StudentsT{Mu: 0, Sigma: 1, Nu: df}
+	return 2 * dist.Survival(math.Abs(t))
+}
+
+func formatBytes(v float64) string {
+	const unit = 1024
+	if v < unit {
+		return fmt.Sprintf("%.0fB", v)
+	}
+	exp := 0
+	for n := v / unit; n >= unit && exp < 3; n /= unit {
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", v/math.Pow(unit, float64(exp+1)), "KMGT"[exp])
+}
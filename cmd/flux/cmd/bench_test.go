@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux/ast"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+// fakeBenchExecutor measures tests with the runs registered for the name of their file.
+type fakeBenchExecutor struct {
+	fakeExecutor
+	runs map[string][]BenchRun
+}
+
+func (e *fakeBenchExecutor) Bench(pkg *ast.Package) (BenchRun, error) {
+	file := pkg.Files[len(pkg.Files)-1].Name
+	runs := e.runs[file]
+	if len(runs) == 0 {
+		return BenchRun{}, errors.Newf(codes.Internal, "no more runs for %s", file)
+	}
+	e.runs[file] = runs[1:]
+	return runs[0], nil
+}
+
+func TestTestRunner_Bench(t *testing.T) {
+	run := func(d time.Duration) BenchRun {
+		return BenchRun{Duration: d, Rows: 10, RowsPerSec: 10 / d.Seconds(), MaxAllocated: 1024}
+	}
+	executor := &fakeBenchExecutor{runs: map[string][]BenchRun{
+		"a_test.flux": {run(time.Millisecond), run(2 * time.Millisecond), run(3 * time.Millisecond)},
+		"b_test.flux": {run(time.Millisecond)},
+	}}
+
+	runner := NewTestRunner(NewTestReporter(0))
+	runner.tests = []*Test{
+		newFakeTest("a", "a_test.flux"),
+		newFakeTest("b", "b_test.flux"),
+		newFakeTest("c", "c_test.flux"),
+	}
+	if _, err := runner.Bench(&fakeExecutor{}, BenchOptions{}); err == nil {
+		t.Fatal("expected error for an executor without benchmark support")
+	} else if want, got := codes.Unimplemented, errors.Code(err); want != got {
+		t.Errorf("unexpected error code -want/+got:\n\t- %v\n\t+ %v", want, got)
+	}
+
+	report, err := runner.Bench(executor, BenchOptions{
+		SkipTestCases: []string{"c"},
+		Count:         3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &BenchReport{Benchmarks: []*Benchmark{
+		{
+			Name: "a",
+			File: "a_test.flux",
+			Runs: []BenchRun{run(time.Millisecond), run(2 * time.Millisecond), run(3 * time.Millisecond)},
+		},
+		{
+			Name:  "b",
+			File:  "b_test.flux",
+			Runs:  []BenchRun{run(time.Millisecond)},
+			Error: "no more runs for b_test.flux",
+		},
+	}}
+	if !cmp.Equal(want, report) {
+		t.Errorf("unexpected report -want/+got:\n%s", cmp.Diff(want, report))
+	}
+	if !runner.tests[2].skipped {
+		t.Error("expected test c to be skipped")
+	}
+
+	// The report is read back from its JSON.
+	name := t.TempDir() + "/bench.json"
+	if err := writeReportFile(name, report.WriteJSON); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readBenchReport(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(want, got) {
+		t.Errorf("unexpected report from JSON -want/+got:\n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestTest_BenchPackage(t *testing.T) {
+	got := &ast.Identifier{Name: "got"}
+	diff := &ast.ExpressionStatement{Expression: &ast.PipeExpression{
+		Argument: &ast.CallExpression{
+			Callee: &ast.MemberExpression{
+				Object:   &ast.Identifier{Name: "testing"},
+				Property: &ast.Identifier{Name: "diff"},
+			},
+			Arguments: []ast.Expression{&ast.ObjectExpression{Properties: []*ast.Property{
+				{Key: &ast.Identifier{Name: "got"}, Value: got},
+				{Key: &ast.Identifier{Name: "want"}, Value: &ast.Identifier{Name: "want"}},
+			}}},
+		},
+		Call: &ast.CallExpression{Callee: &ast.Identifier{Name: "yield"}},
+	}}
+	test := NewTest("a", &ast.Package{Files: []*ast.File{{
+		Name: "a_test.flux",
+		Body: []ast.Statement{diff},
+	}}})
+
+	pkg := test.benchPackage()
+	stmt, ok := pkg.Files[0].Body[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("unexpected statement %T", pkg.Files[0].Body[0])
+	}
+	pipe, ok := stmt.Expression.(*ast.PipeExpression)
+	if !ok {
+		t.Fatalf("unexpected expression %T", stmt.Expression)
+	}
+	if id, ok := pipe.Argument.(*ast.Identifier); !ok || id.Name != "got" {
+		t.Errorf("expected the got stream to be yielded, got %v", pipe.Argument)
+	}
+	if callee, ok := pipe.Call.Callee.(*ast.Identifier); !ok || callee.Name != "yield" {
+		t.Errorf("expected a call to yield, got %v", pipe.Call.Callee)
+	}
+	if test.ast.Files[0].Body[0] != diff {
+		t.Error("expected the test package to be unchanged")
+	}
+
+	// A test without a diff runs as is.
+	test = NewTest("b", &ast.Package{Files: []*ast.File{{Name: "b_test.flux"}}})
+	if pkg := test.benchPackage(); pkg != test.ast {
+		t.Error("expected the test package to run as is")
+	}
+}
+
+func TestWelchTTest(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		xs, ys []float64
+		want   float64
+	}{
+		{
+			name: "different means",
+			xs:   []float64{1, 2, 3, 4, 5},
+			ys:   []float64{2, 4, 6, 8, 10},
+			want: 0.1075,
+		},
+		{
+			name: "same samples",
+			xs:   []float64{1, 2, 3},
+			ys:   []float64{1, 2, 3},
+			want: 1,
+		},
+		{
+			name: "no variance",
+			xs:   []float64{1, 1, 1},
+			ys:   []float64{2, 2, 2},
+			want: 0,
+		},
+		{
+			name: "single value",
+			xs:   []float64{1},
+			ys:   []float64{2, 2, 2},
+			want: math.NaN(),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := welchTTest(tc.xs, tc.ys)
+			if math.IsNaN(tc.want) {
+				if !math.IsNaN(got) {
+					t.Fatalf("unexpected p-value -want/+got:\n\t- NaN\n\t+ %v", got)
+				}
+				return
+			}
+			if math.Abs(tc.want-got) > 1e-4 {
+				t.Fatalf("unexpected p-value -want/+got:\n\t- %v\n\t+ %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestCompareBenchReports(t *testing.T) {
+	runs := func(ds ...time.Duration) []BenchRun {
+		rs := make([]BenchRun, len(ds))
+		for i, d := range ds {
+			rs[i] = BenchRun{Duration: d, Rows: 1000, RowsPerSec: 1000 / d.Seconds(), MaxAllocated: 2048}
+		}
+		return rs
+	}
+	baseline := &BenchReport{Benchmarks: []*Benchmark{
+		{Name: "a", File: "a_test.flux", Runs: runs(10*time.Millisecond, 11*time.Millisecond, 9*time.Millisecond)},
+		{Name: "b", File: "a_test.flux", Runs: runs(10*time.Millisecond, 11*time.Millisecond, 9*time.Millisecond)},
+	}}
+	report := &BenchReport{Benchmarks: []*Benchmark{
+		{Name: "a", File: "a_test.flux", Runs: runs(20*time.Millisecond, 21*time.Millisecond, 19*time.Millisecond)},
+		{Name: "b", File: "a_test.flux", Runs: runs(10*time.Millisecond, 12*time.Millisecond, 9*time.Millisecond)},
+		{Name: "c", File: "a_test.flux", Runs: runs(time.Millisecond)},
+		{Name: "d", File: "a_test.flux", Error: "boom\nmore"},
+	}}
+
+	var buf bytes.Buffer
+	if err := compareBenchReports(&buf, baseline, report, 0.05); err != nil {
+		t.Fatal(err)
+	}
+	want := `name           old time/op  new time/op    delta
+a_test.flux:a  10ms ±10%    20ms ±5%       +100.00% (p=0.000)
+a_test.flux:b  10ms ±10%    10.333ms ±15%  ~ (p=0.770)
+a_test.flux:c  -            1ms            -
+a_test.flux:d                              fail: boom
+
+name           old rows/sec  new rows/sec  delta
+a_test.flux:a  100673 ±10%   50084 ±5%     -50.25% (p=0.010)
+a_test.flux:b  100673 ±10%   98148 ±14%    ~ (p=0.814)
+a_test.flux:c  -             1000000       -
+a_test.flux:d                              fail: boom
+
+name           old peak-mem  new peak-mem  delta
+a_test.flux:a  2.0KiB ±0%    2.0KiB ±0%    ~ (p=1.000)
+a_test.flux:b  2.0KiB ±0%    2.0KiB ±0%    ~ (p=1.000)
+a_test.flux:c  -             2.0KiB        -
+a_test.flux:d                              fail: boom
+`
+	if got := buf.String(); want != got {
+		t.Errorf("unexpected comparison -want/+got:\n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for _, tc := range []struct {
+		v    float64
+		want string
+	}{
+		{v: 512, want: "512B"},
+		{v: 2048, want: "2.0KiB"},
+		{v: 1.5 * 1024 * 1024, want: "1.5MiB"},
+		{v: 3 * 1024 * 1024 * 1024, want: "3.0GiB"},
+	} {
+		if got := formatBytes(tc.v); got != tc.want {
+			t.Errorf("unexpected format of %v -want/+got:\n\t- %s\n\t+ %s", tc.v, tc.want, got)
+		}
+	}
+}
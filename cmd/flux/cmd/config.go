@@ -0,0 +1,375 @@
+package cmd
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/dependencies"
+	"github.com/influxdata/flux/dependencies/filesystem"
+	"github.com/influxdata/flux/dependencies/http"
+	influxdbdeps "github.com/influxdata/flux/dependencies/influxdb"
+	"github.com/influxdata/flux/dependencies/materialized"
+	"github.com/influxdata/flux/dependencies/secret"
+	"github.com/influxdata/flux/dependencies/url"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/memory"
+	"github.com/influxdata/flux/plan"
+	"github.com/influxdata/flux/stdlib/influxdata/influxdb"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
+)
+
+const DefaultInfluxDBHost = "http://localhost:8086"
+
+// configEnvVar names the environment variable that points to
+// the config file when the --config flag is not set.
+const configEnvVar = "FLUX_CONFIG"
+
+// The InfluxDB providers that can be configured.
+const (
+	influxdbProviderHTTP   = "http"
+	influxdbProviderMemory = "memory"
+)
+
+// The secret providers that can be configured.
+const (
+	secretProviderNone  = "none"
+	secretProviderEnv   = "env"
+	secretProviderFile  = "file"
+	secretProviderVault = "vault"
+)
+
+// The environment variables read by the vault
+// secret provider when the config leaves them empty.
+const (
+	vaultAddrEnvVar  = "VAULT_ADDR"
+	vaultTokenEnvVar = "VAULT_TOKEN"
+)
+
+// The URL validator policies that can be configured.
+const (
+	urlPolicyAllowAll    = "allow-all"
+	urlPolicyDenyPrivate = "deny-private"
+	urlPolicyList        = "list"
+)
+
+// config holds the configuration of the dependencies
+// used to execute Flux scripts from the command line.
+type config struct {
+	InfluxDB struct {
+		Host  string `yaml:"host"`
+		Org   string `yaml:"org"`
+		OrgID string `yaml:"org_id"`
+		Token string `yaml:"token"`
+		// Provider selects whether from() and to() use an InfluxDB
+		// instance or buckets that are kept in memory.
+		Provider string `yaml:"provider"`
+		// Data is the directory the memory provider loads
+		// its buckets from and saves them to, if any.
+		Data string `yaml:"data"`
+	} `yaml:"influxdb"`
+	Secrets struct {
+		Provider string `yaml:"provider"`
+		// Path is the file or directory read by the file provider.
+		Path  string `yaml:"path"`
+		Vault struct {
+			Address   string        `yaml:"address"`
+			Token     string        `yaml:"token"`
+			TokenFile string        `yaml:"token_file"`
+			Namespace string        `yaml:"namespace"`
+			Mount     string        `yaml:"mount"`
+			Prefix    string        `yaml:"prefix"`
+			Path      string        `yaml:"path"`
+			TTL       time.Duration `yaml:"ttl"`
+		} `yaml:"vault"`
+	} `yaml:"secrets"`
+	Filesystem struct {
+		Root string `yaml:"root"`
+	} `yaml:"filesystem"`
+	URLValidator struct {
+		Policy string `yaml:"policy"`
+		// The lists below are used by the list policy.
+		AllowedSchemes  []string `yaml:"allowed_schemes"`
+		AllowedHosts    []string `yaml:"allowed_hosts"`
+		DeniedHosts     []string `yaml:"denied_hosts"`
+		AllowedNetworks []string `yaml:"allowed_networks"`
+		DeniedNetworks  []string `yaml:"denied_networks"`
+		AllowedPorts    []string `yaml:"allowed_ports"`
+		DenyPrivate     bool     `yaml:"deny_private"`
+	} `yaml:"url_validator"`
+	Materialized struct {
+		// State is the directory the state of materialized views
+		// is saved to. The state is kept in memory if it is empty.
+		State string `yaml:"state"`
+	} `yaml:"materialized"`
+	// MemoryLimit is the maximum number of bytes
+	// a query may allocate. Zero means no limit.
+	MemoryLimit int64 `yaml:"memory_limit"`
+}
+
+func defaultConfig() config {
+	var conf config
+	conf.InfluxDB.Host = DefaultInfluxDBHost
+	conf.InfluxDB.Provider = influxdbProviderHTTP
+	conf.Secrets.Provider = secretProviderNone
+	conf.URLValidator.Policy = urlPolicyAllowAll
+	return conf
+}
+
+var configFlags struct {
+	path string
+	// conf holds the values of the flags that override the config file.
+	conf config
+}
+
+func init() {
+	flags := rootCmd.PersistentFlags()
+	flags.StringVar(&configFlags.path, "config", "", "Path to a YAML config file for the dependencies (default $"+configEnvVar+")")
+	flags.StringVar(&configFlags.conf.InfluxDB.Host, "influxdb-host", "", "Default InfluxDB host used by from() and to()")
+	flags.StringVar(&configFlags.conf.InfluxDB.Org, "influxdb-org", "", "Default InfluxDB organization name")
+	flags.StringVar(&configFlags.conf.InfluxDB.OrgID, "influxdb-org-id", "", "Default InfluxDB organization ID")
+	flags.StringVar(&configFlags.conf.InfluxDB.Token, "influxdb-token", "", "Default InfluxDB token")
+	flags.StringVar(&configFlags.conf.InfluxDB.Provider, "influxdb-provider", "", "InfluxDB provider used by from() and to(), one of: http, memory")
+	flags.StringVar(&configFlags.conf.InfluxDB.Data, "influxdb-data", "", "Directory of line protocol and CSV files holding the buckets of the memory provider")
+	flags.StringVar(&configFlags.conf.Secrets.Provider, "secrets", "", "Secret provider used by secrets.get(), one of: none, env, file, vault")
+	flags.StringVar(&configFlags.conf.Secrets.Path, "secrets-path", "", "File or directory read by the file secret provider")
+	flags.StringVar(&configFlags.conf.Filesystem.Root, "fs-root", "", "Restrict file access to this directory")
+	flags.StringVar(&configFlags.conf.URLValidator.Policy, "url-policy", "", "URL validator policy, one of: allow-all, deny-private, list")
+	flags.StringVar(&configFlags.conf.Materialized.State, "materialized-state", "", "Directory the state of materialized views is saved to")
+	flags.Int64Var(&configFlags.conf.MemoryLimit, "memory-limit", 0, "Maximum number of bytes a query may allocate")
+}
+
+// loadConfig reads the config file, if any,
+// and applies the flags that were set on top of it.
+func loadConfig(flags *pflag.FlagSet) (config, error) {
+	conf := defaultConfig()
+	path := configFlags.path
+	if path == "" {
+		path = os.Getenv(configEnvVar)
+	}
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return config{}, err
+		}
+		if err := yaml.UnmarshalStrict(data, &conf); err != nil {
+			return config{}, errors.Wrapf(err, codes.Invalid, "invalid config file %q", path)
+		}
+	}
+
+	overrides := []struct {
+		flag string
+		dst  *string
+		src  string
+	}{
+		{flag: "influxdb-host", dst: &conf.InfluxDB.Host, src: configFlags.conf.InfluxDB.Host},
+		{flag: "influxdb-org", dst: &conf.InfluxDB.Org, src: configFlags.conf.InfluxDB.Org},
+		{flag: "influxdb-org-id", dst: &conf.InfluxDB.OrgID, src: configFlags.conf.InfluxDB.OrgID},
+		{flag: "influxdb-token", dst: &conf.InfluxDB.Token, src: configFlags.conf.InfluxDB.Token},
+		{flag: "influxdb-provider", dst: &conf.InfluxDB.Provider, src: configFlags.conf.InfluxDB.Provider},
+		{flag: "influxdb-data", dst: &conf.InfluxDB.Data, src: configFlags.conf.InfluxDB.Data},
+		{flag: "secrets", dst: &conf.Secrets.Provider, src: configFlags.conf.Secrets.Provider},
+		{flag: "secrets-path", dst: &conf.Secrets.Path, src: configFlags.conf.Secrets.Path},
+		{flag: "fs-root", dst: &conf.Filesystem.Root, src: configFlags.conf.Filesystem.Root},
+		{flag: "url-policy", dst: &conf.URLValidator.Policy, src: configFlags.conf.URLValidator.Policy},
+		{flag: "materialized-state", dst: &conf.Materialized.State, src: configFlags.conf.Materialized.State},
+	}
+	for _, o := range overrides {
+		if flags.Changed(o.flag) {
+			*o.dst = o.src
+		}
+	}
+	if flags.Changed("memory-limit") {
+		conf.MemoryLimit = configFlags.conf.MemoryLimit
+	}
+
+	if conf.InfluxDB.Org != "" && conf.InfluxDB.OrgID != "" {
+		return config{}, errors.New(codes.Invalid, "cannot specify both an InfluxDB org and org ID")
+	}
+	if conf.MemoryLimit < 0 {
+		return config{}, errors.New(codes.Invalid, "memory limit must not be negative")
+	}
+	return conf, nil
+}
+
+// newDependencies builds the flux dependencies described by the config.
+func newDependencies(conf config) (dependencies.Dependencies, error) {
+	deps := dependencies.NewDefaultDependencies(conf.InfluxDB.Host)
+
+	validator, err := newURLValidator(conf)
+	if err != nil {
+		return deps, err
+	}
+	deps.Deps.Deps.URLValidator = validator
+	deps.Deps.Deps.HTTPClient = http.NewLimitedDefaultClient(validator)
+
+	secrets, err := newSecretService(conf)
+	if err != nil {
+		return deps, err
+	}
+	deps.Deps.Deps.SecretService = secrets
+
+	if conf.Filesystem.Root != "" {
+		fs, err := filesystem.NewRootFS(conf.Filesystem.Root)
+		if err != nil {
+			return deps, err
+		}
+		deps.Deps.Deps.FilesystemService = fs
+	}
+
+	provider, err := newInfluxDBProvider(conf)
+	if err != nil {
+		return deps, err
+	}
+	deps = deps.WithInfluxDBProvider(provider)
+
+	if conf.Materialized.State != "" {
+		store, err := materialized.NewDirStateStore(conf.Materialized.State)
+		if err != nil {
+			return deps, err
+		}
+		deps = deps.WithMaterializedStateStore(store)
+	}
+	return deps, nil
+}
+
+func newInfluxDBProvider(conf config) (influxdbdeps.Provider, error) {
+	defaultConfig := influxdbdeps.Config{
+		Host:  conf.InfluxDB.Host,
+		Org:   influxdbdeps.NameOrID{Name: conf.InfluxDB.Org, ID: conf.InfluxDB.OrgID},
+		Token: conf.InfluxDB.Token,
+	}
+	switch provider := conf.InfluxDB.Provider; provider {
+	case influxdbProviderHTTP:
+		return &influxdbdeps.HttpProvider{DefaultConfig: defaultConfig}, nil
+	case influxdbProviderMemory:
+		if conf.InfluxDB.Data == "" {
+			return &influxdbdeps.MemoryProvider{DefaultConfig: defaultConfig}, nil
+		}
+		return influxdbdeps.OpenMemoryProvider(conf.InfluxDB.Data, defaultConfig)
+	default:
+		return nil, errors.Newf(codes.Invalid, "unknown influxdb provider %q", provider)
+	}
+}
+
+func newURLValidator(conf config) (url.Validator, error) {
+	switch policy := conf.URLValidator.Policy; policy {
+	case urlPolicyAllowAll:
+		return url.PassValidator{}, nil
+	case urlPolicyDenyPrivate:
+		return url.PrivateIPValidator{}, nil
+	case urlPolicyList:
+		uv := conf.URLValidator
+		return url.NewListValidator(url.ListConfig{
+			AllowedSchemes:  uv.AllowedSchemes,
+			AllowedHosts:    uv.AllowedHosts,
+			DeniedHosts:     uv.DeniedHosts,
+			AllowedNetworks: uv.AllowedNetworks,
+			DeniedNetworks:  uv.DeniedNetworks,
+			AllowedPorts:    uv.AllowedPorts,
+			DenyPrivate:     uv.DenyPrivate,
+		})
+	default:
+		return nil, errors.Newf(codes.Invalid, "unknown url validator policy %q", policy)
+	}
+}
+
+func newSecretService(conf config) (secret.Service, error) {
+	switch provider := conf.Secrets.Provider; provider {
+	case secretProviderNone:
+		return secret.EmptySecretService{}, nil
+	case secretProviderEnv:
+		return secret.EnvironmentSecretService{}, nil
+	case secretProviderFile:
+		if conf.Secrets.Path == "" {
+			return nil, errors.New(codes.Invalid, "the file secret provider requires a secrets path")
+		}
+		return secret.NewFileSecretService(conf.Secrets.Path)
+	case secretProviderVault:
+		return newVaultSecretService(conf)
+	default:
+		return nil, errors.Newf(codes.Invalid, "unknown secret provider %q", provider)
+	}
+}
+
+func newVaultSecretService(conf config) (secret.Service, error) {
+	vc := conf.Secrets.Vault
+	if vc.Address == "" {
+		vc.Address = os.Getenv(vaultAddrEnvVar)
+	}
+	if vc.Address == "" {
+		return nil, errors.New(codes.Invalid, "the vault secret provider requires an address")
+	}
+	if vc.Token == "" && vc.TokenFile != "" {
+		data, err := ioutil.ReadFile(vc.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+		vc.Token = strings.TrimSpace(string(data))
+	}
+	if vc.Token == "" {
+		vc.Token = os.Getenv(vaultTokenEnvVar)
+	}
+	if vc.TTL < 0 {
+		return nil, errors.New(codes.Invalid, "vault secret ttl must not be negative")
+	}
+	return &secret.VaultSecretService{
+		Address:   vc.Address,
+		Token:     vc.Token,
+		Namespace: vc.Namespace,
+		Mount:     vc.Mount,
+		Prefix:    vc.Prefix,
+		Path:      vc.Path,
+		TTL:       vc.TTL,
+	}, nil
+}
+
+// newAllocator returns an allocator that enforces the configured memory limit.
+func newAllocator(conf config) *memory.Allocator {
+	alloc := &memory.Allocator{}
+	if conf.MemoryLimit > 0 {
+		limit := conf.MemoryLimit
+		alloc.Limit = &limit
+	}
+	return alloc
+}
+
+var registerRulesOnce sync.Once
+
+// registerDefaultFromAttributes makes from() and to() without an explicit
+// host use the configured InfluxDB instance.
+// The planner rules are global so they can only be registered once.
+func registerDefaultFromAttributes(conf config) {
+	registerRulesOnce.Do(func() {
+		attrs := influxdb.DefaultFromAttributes{
+			Host: &conf.InfluxDB.Host,
+		}
+		if conf.InfluxDB.Org != "" || conf.InfluxDB.OrgID != "" {
+			attrs.Org = &influxdb.NameOrID{Name: conf.InfluxDB.Org, ID: conf.InfluxDB.OrgID}
+		}
+		if conf.InfluxDB.Token != "" {
+			attrs.Token = &conf.InfluxDB.Token
+		}
+		plan.RegisterLogicalRules(attrs)
+	})
+}
+
+// injectDependencies loads the configuration and injects
+// the dependencies it describes into the context.
+func injectDependencies(ctx context.Context) (context.Context, flux.Dependencies, config, error) {
+	conf, err := loadConfig(rootCmd.PersistentFlags())
+	if err != nil {
+		return nil, nil, config{}, err
+	}
+	deps, err := newDependencies(conf)
+	if err != nil {
+		return nil, nil, config{}, err
+	}
+	return deps.Inject(ctx), deps, conf, nil
+}
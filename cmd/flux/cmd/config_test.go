@@ -0,0 +1,249 @@
+package cmd
+
+import (
+	"io/ioutil"
+	neturl "net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	influxdbdeps "github.com/influxdata/flux/dependencies/influxdb"
+	"github.com/influxdata/flux/dependencies/secret"
+	"github.com/influxdata/flux/dependencies/url"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flux-config-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	path := filepath.Join(dir, "config.yml")
+	if err := ioutil.WriteFile(path, []byte(`
+influxdb:
+  host: http://influxdb:8086
+  org: my-org
+  token: file-token
+secrets:
+  provider: env
+url_validator:
+  policy: deny-private
+memory_limit: 1048576
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(old struct {
+		path string
+		conf config
+	}) {
+		configFlags = old
+	}(configFlags)
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.StringVar(&configFlags.path, "config", "", "")
+	flags.StringVar(&configFlags.conf.InfluxDB.Token, "influxdb-token", "", "")
+	if err := flags.Parse([]string{"--config", path, "--influxdb-token", "flag-token"}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := loadConfig(flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := defaultConfig()
+	want.InfluxDB.Host = "http://influxdb:8086"
+	want.InfluxDB.Org = "my-org"
+	want.InfluxDB.Token = "flag-token"
+	want.Secrets.Provider = "env"
+	want.URLValidator.Policy = "deny-private"
+	want.MemoryLimit = 1048576
+	if !cmp.Equal(want, got) {
+		t.Fatalf("unexpected config -want/+got:\n%s", cmp.Diff(want, got))
+	}
+
+	deps, err := newDependencies(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := deps.URLValidator(); v != (url.PrivateIPValidator{}) {
+		t.Errorf("unexpected url validator %T", v)
+	}
+	if s, _ := deps.SecretService(); s != (secret.EnvironmentSecretService{}) {
+		t.Errorf("unexpected secret service %T", s)
+	}
+	if alloc := newAllocator(got); alloc.Limit == nil || *alloc.Limit != 1048576 {
+		t.Errorf("unexpected allocator limit %v", alloc.Limit)
+	}
+}
+
+func TestLoadConfig_VaultSecrets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flux-config-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	tokenFile := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("file-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config.yml")
+	if err := ioutil.WriteFile(path, []byte(`
+secrets:
+  provider: vault
+  vault:
+    address: https://vault:8200
+    token_file: `+tokenFile+`
+    mount: kv
+    prefix: team
+    path: flux
+    ttl: 5m
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(old struct {
+		path string
+		conf config
+	}) {
+		configFlags = old
+	}(configFlags)
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.StringVar(&configFlags.path, "config", "", "")
+	if err := flags.Parse([]string{"--config", path}); err != nil {
+		t.Fatal(err)
+	}
+	conf, err := loadConfig(flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ss, err := newSecretService(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := ss.(*secret.VaultSecretService)
+	if !ok {
+		t.Fatalf("unexpected secret service %T", ss)
+	}
+	want := &secret.VaultSecretService{
+		Address: "https://vault:8200",
+		Token:   "file-token",
+		Mount:   "kv",
+		Prefix:  "team",
+		Path:    "flux",
+		TTL:     5 * time.Minute,
+	}
+	if !cmp.Equal(want, got, cmpopts.IgnoreUnexported(secret.VaultSecretService{})) {
+		t.Fatalf("unexpected vault secret service -want/+got:\n%s", cmp.Diff(want, got, cmpopts.IgnoreUnexported(secret.VaultSecretService{})))
+	}
+}
+
+func TestNewURLValidator_List(t *testing.T) {
+	conf := defaultConfig()
+	if err := yaml.UnmarshalStrict([]byte(`
+url_validator:
+  policy: list
+  allowed_schemes: [https]
+  allowed_hosts: ["*.example.com"]
+  allowed_ports: ["443"]
+`), &conf); err != nil {
+		t.Fatal(err)
+	}
+	v, err := newURLValidator(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rawURL, valid := range map[string]bool{
+		"https://api.example.com":      true,
+		"http://api.example.com":       false,
+		"https://api.example.com:8443": false,
+		"https://example.org":          false,
+	} {
+		u, err := neturl.Parse(rawURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := v.Validate(u); valid && err != nil {
+			t.Errorf("unexpected validation error for %s: %v", rawURL, err)
+		} else if !valid && err == nil {
+			t.Errorf("expected validation error for %s got nil", rawURL)
+		}
+	}
+}
+
+func TestNewInfluxDBProvider(t *testing.T) {
+	conf := defaultConfig()
+	conf.InfluxDB.Org = "my-org"
+	if p, err := newInfluxDBProvider(conf); err != nil {
+		t.Fatal(err)
+	} else if _, ok := p.(*influxdbdeps.HttpProvider); !ok {
+		t.Errorf("unexpected influxdb provider %T", p)
+	}
+
+	conf.InfluxDB.Provider = "memory"
+	conf.InfluxDB.Data = filepath.Join(t.TempDir(), "data")
+	p, err := newInfluxDBProvider(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mp, ok := p.(*influxdbdeps.MemoryProvider)
+	if !ok {
+		t.Fatalf("unexpected influxdb provider %T", p)
+	}
+	if want, got := "my-org", mp.DefaultConfig.Org.Name; want != got {
+		t.Errorf("unexpected default org -want/+got:\n\t- %v\n\t+ %v", want, got)
+	}
+	if _, err := os.Stat(conf.InfluxDB.Data); err != nil {
+		t.Errorf("expected the data directory to be created: %v", err)
+	}
+}
+
+func TestNewDependencies_MaterializedState(t *testing.T) {
+	conf := defaultConfig()
+	conf.Materialized.State = filepath.Join(t.TempDir(), "state")
+	if _, err := newDependencies(conf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(conf.Materialized.State); err != nil {
+		t.Errorf("expected the state directory to be created: %v", err)
+	}
+}
+
+func TestLoadConfig_Invalid(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		conf func(*config)
+	}{
+		{name: "secret provider", conf: func(c *config) { c.Secrets.Provider = "unknown" }},
+		{name: "url policy", conf: func(c *config) { c.URLValidator.Policy = "unknown" }},
+		{name: "file secrets without path", conf: func(c *config) { c.Secrets.Provider = "file" }},
+		{name: "file secrets missing", conf: func(c *config) {
+			c.Secrets.Provider = "file"
+			c.Secrets.Path = "/this/path/does/not/exist"
+		}},
+		{name: "vault secrets without address", conf: func(c *config) { c.Secrets.Provider = "vault" }},
+		{name: "url list", conf: func(c *config) {
+			c.URLValidator.Policy = "list"
+			c.URLValidator.AllowedPorts = []string{"http"}
+		}},
+		{name: "filesystem root", conf: func(c *config) { c.Filesystem.Root = "/this/path/does/not/exist" }},
+		{name: "influxdb provider", conf: func(c *config) { c.InfluxDB.Provider = "unknown" }},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			conf := defaultConfig()
+			tc.conf(&conf)
+			if _, err := newDependencies(conf); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
@@ -3,11 +3,22 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/influxdata/flux"
-	"github.com/influxdata/flux/dependencies"
+	"github.com/influxdata/flux/codes"
 	"github.com/influxdata/flux/fluxinit"
+	"github.com/influxdata/flux/internal/encoding"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/lang"
+	"github.com/influxdata/flux/memory"
 	"github.com/influxdata/flux/repl"
+	"github.com/influxdata/flux/runtime"
 	"github.com/spf13/cobra"
 )
 
@@ -15,34 +26,117 @@ import (
 var executeCmd = &cobra.Command{
 	Use:   "execute",
 	Short: "Execute a Flux script",
-	Long:  "Execute a Flux script from string or file (use @ as prefix to the file)",
-	Args:  cobra.ExactArgs(1),
-	RunE:  execute,
+	Long: `Execute a Flux script from string or file (use @ as prefix to the file)
+
+Values passed with --var are injected into the script before it runs.
+A value is interpreted as a boolean, integer, float, time or duration literal
+when possible and as a string otherwise. Quote the value to force a string.`,
+	Args: cobra.ExactArgs(1),
+	RunE: execute,
+}
+
+var executeFlags struct {
+	format    string
+	output    string
+	now       string
+	profilers []string
+	vars      []string
 }
 
 func init() {
 	rootCmd.AddCommand(executeCmd)
+	executeCmd.Flags().StringVarP(&executeFlags.format, "format", "f", encoding.Table, "Output format, one of: "+strings.Join(encoding.Formats, ", "))
+	executeCmd.Flags().StringVarP(&executeFlags.output, "output", "o", "", "Write the results to a file instead of stdout")
+	executeCmd.Flags().StringVar(&executeFlags.now, "now", "", "Override the now time of the script (RFC3339)")
+	executeCmd.Flags().StringSliceVar(&executeFlags.profilers, "profile", nil, "Enable the given profilers and append their results to the output (query, operator)")
+	executeCmd.Flag("profile").NoOptDefVal = "query,operator"
+	executeCmd.Flags().StringArrayVar(&executeFlags.vars, "var", nil, "Define a variable for the script as name=value (can be repeated)")
 }
 
-const DefaultInfluxDBHost = "http://localhost:8086"
+func execute(cmd *cobra.Command, args []string) error {
+	script, err := repl.LoadQuery(args[0])
+	if err != nil {
+		return err
+	}
+	enc, err := encoding.NewMultiResultEncoder(executeFlags.format)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if executeFlags.now != "" {
+		now, err = time.Parse(time.RFC3339Nano, executeFlags.now)
+		if err != nil {
+			return fmt.Errorf("invalid now time %q: %w", executeFlags.now, err)
+		}
+	}
+	extern, err := buildExtern(executeFlags.vars, executeFlags.profilers)
+	if err != nil {
+		return err
+	}
 
-func injectDependencies(ctx context.Context) (context.Context, flux.Dependencies) {
+	fluxinit.FluxInit()
+	ctx, _, conf, err := injectDependencies(context.Background())
+	if err != nil {
+		return err
+	}
 
-	deps := dependencies.NewDefaultDependencies(DefaultInfluxDBHost)
-	return deps.Inject(ctx), deps
-}
+	var opts []lang.CompileOption
+	if extern != nil {
+		hdl, err := externHandle(extern)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, lang.WithExtern(hdl))
+	}
 
-func execute(cmd *cobra.Command, args []string) error {
-	fluxinit.FluxInit()
-	ctx, deps := injectDependencies(context.Background())
-	r := repl.New(ctx, deps)
-	if fluxError, err := r.Input(args[0]); err != nil {
-		if fluxError != nil {
-			fluxError.Print()
-		} else {
-			fmt.Println(err)
+	run := func(w io.Writer) error {
+		if err := executeScript(ctx, w, script, now, enc, newAllocator(conf), opts...); err != nil {
+			return errors.Wrap(err, codes.Inherit, "failed to execute query")
 		}
-		return fmt.Errorf("failed to execute query")
+		return nil
+	}
+	if executeFlags.output == "" {
+		return run(os.Stdout)
+	}
+	return writeOutputFile(executeFlags.output, run)
+}
+
+// writeOutputFile writes the output of fn to a temporary file and renames it
+// to the path once fn succeeds, so an error does not truncate an existing file.
+func writeOutputFile(path string, fn func(w io.Writer) error) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	if err := fn(f); err != nil {
+		_ = f.Close()
+		return err
 	}
-	return nil
+	if err := f.Chmod(0644); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// executeScript runs the script and encodes its results to w.
+func executeScript(ctx context.Context, w io.Writer, script string, now time.Time, enc flux.MultiResultEncoder, alloc *memory.Allocator, opts ...lang.CompileOption) error {
+	prog, err := lang.Compile(script, runtime.Default, now, opts...)
+	if err != nil {
+		return err
+	}
+	q, err := prog.Start(ctx, alloc)
+	if err != nil {
+		return err
+	}
+	results := flux.NewResultIteratorFromQuery(q)
+	defer results.Release()
+
+	_, err = enc.Encode(w, results)
+	return err
 }
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteOutputFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "results.csv")
+	if err := ioutil.WriteFile(path, []byte("previous"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed query leaves the existing file untouched.
+	wantErr := errors.New("compilation failed")
+	if err := writeOutputFile(path, func(w io.Writer) error {
+		_, _ = io.WriteString(w, "partial")
+		return wantErr
+	}); err != wantErr {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, err := ioutil.ReadFile(path); err != nil {
+		t.Fatal(err)
+	} else if want := "previous"; string(got) != want {
+		t.Errorf("unexpected file content after an error -want/+got:\n\t- %q\n\t+ %q", want, got)
+	}
+
+	if err := writeOutputFile(path, func(w io.Writer) error {
+		_, err := io.WriteString(w, "results")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ioutil.ReadFile(path); err != nil {
+		t.Fatal(err)
+	} else if want := "results"; string(got) != want {
+		t.Errorf("unexpected file content -want/+got:\n\t- %q\n\t+ %q", want, got)
+	}
+
+	// The temporary files are removed.
+	if files, err := ioutil.ReadDir(dir); err != nil {
+		t.Fatal(err)
+	} else if len(files) != 1 {
+		t.Errorf("expected only the output file in the directory, got %d files", len(files))
+	}
+}
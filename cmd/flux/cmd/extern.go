@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/ast"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/parser"
+	"github.com/influxdata/flux/runtime"
+)
+
+var (
+	identRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	floatRegexp = regexp.MustCompile(`^([0-9]+\.?[0-9]*|\.[0-9]+)([eE][-+]?[0-9]+)?$`)
+)
+
+// buildExtern creates the extern file that is evaluated before the script.
+// It assigns each name=value pair in vars and enables the given profilers.
+// It returns nil when there is nothing to inject.
+func buildExtern(vars, profilers []string) (*ast.File, error) {
+	if len(vars) == 0 && len(profilers) == 0 {
+		return nil, nil
+	}
+	file := &ast.File{
+		Package: &ast.PackageClause{Name: &ast.Identifier{Name: "main"}},
+	}
+	if len(profilers) > 0 {
+		file.Imports = append(file.Imports, &ast.ImportDeclaration{
+			Path: &ast.StringLiteral{Value: "profiler"},
+		})
+		elements := make([]ast.Expression, len(profilers))
+		for i, p := range profilers {
+			elements[i] = &ast.StringLiteral{Value: p}
+		}
+		file.Body = append(file.Body, &ast.OptionStatement{
+			Assignment: &ast.MemberAssignment{
+				Member: &ast.MemberExpression{
+					Object:   &ast.Identifier{Name: "profiler"},
+					Property: &ast.Identifier{Name: "enabledProfilers"},
+				},
+				Init: &ast.ArrayExpression{Elements: elements},
+			},
+		})
+	}
+	for _, v := range vars {
+		stmt, err := parseVar(v)
+		if err != nil {
+			return nil, err
+		}
+		file.Body = append(file.Body, stmt)
+	}
+	return file, nil
+}
+
+// externHandle converts the extern file into a handle for the runtime.
+func externHandle(file *ast.File) (flux.ASTHandle, error) {
+	data, err := json.Marshal(&ast.Package{
+		Package: "main",
+		Files:   []*ast.File{file},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return runtime.Default.JSONToHandle(data)
+}
+
+// parseVar parses a name=value pair into a variable assignment.
+// The value is interpreted as a boolean, integer, float, time or duration
+// literal when possible and as a string otherwise.
+// A value in double quotes is always a string.
+func parseVar(s string) (*ast.VariableAssignment, error) {
+	eq := strings.Index(s, "=")
+	if eq < 0 {
+		return nil, errors.Newf(codes.Invalid, "invalid variable %q, expected name=value", s)
+	}
+	name, value := s[:eq], s[eq+1:]
+	if !identRegexp.MatchString(name) {
+		return nil, errors.Newf(codes.Invalid, "invalid variable name %q", name)
+	}
+	init, err := parseVarValue(value)
+	if err != nil {
+		return nil, errors.Wrapf(err, codes.Invalid, "invalid value for variable %q", name)
+	}
+	return &ast.VariableAssignment{
+		ID:   &ast.Identifier{Name: name},
+		Init: init,
+	}, nil
+}
+
+func parseVarValue(v string) (ast.Expression, error) {
+	if len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"' {
+		lit, err := parser.ParseString(v)
+		if err != nil {
+			return nil, err
+		}
+		return &ast.StringLiteral{Value: lit.Value}, nil
+	}
+
+	switch v {
+	case "true", "false":
+		return &ast.BooleanLiteral{Value: v == "true"}, nil
+	}
+
+	// Negative numbers are represented with a unary expression
+	// in the same way the parser produces them.
+	negate := func(e ast.Expression, negative bool) ast.Expression {
+		if !negative {
+			return e
+		}
+		return &ast.UnaryExpression{Operator: ast.SubtractionOperator, Argument: e}
+	}
+	abs, negative := strings.TrimPrefix(v, "-"), strings.HasPrefix(v, "-")
+	if i, err := strconv.ParseInt(abs, 10, 64); err == nil && !strings.HasPrefix(abs, "+") {
+		return negate(&ast.IntegerLiteral{Value: i}, negative), nil
+	}
+	if floatRegexp.MatchString(abs) {
+		if f, err := strconv.ParseFloat(abs, 64); err == nil {
+			return negate(&ast.FloatLiteral{Value: f}, negative), nil
+		}
+	}
+	if t, err := parser.ParseTime(v); err == nil {
+		return &ast.DateTimeLiteral{Value: t.Value}, nil
+	}
+	if d, err := parser.ParseSignedDuration(v); err == nil && v != "" {
+		return &ast.DurationLiteral{Values: d.Values}, nil
+	}
+	return &ast.StringLiteral{Value: v}, nil
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux/ast"
+	"github.com/influxdata/flux/ast/asttest"
+)
+
+func TestParseVar(t *testing.T) {
+	testCases := []struct {
+		in      string
+		want    ast.Expression
+		wantErr bool
+	}{
+		{in: "x=5", want: &ast.IntegerLiteral{Value: 5}},
+		{in: "x=-5", want: &ast.UnaryExpression{Operator: ast.SubtractionOperator, Argument: &ast.IntegerLiteral{Value: 5}}},
+		{in: "x=1.5", want: &ast.FloatLiteral{Value: 1.5}},
+		{in: "x=true", want: &ast.BooleanLiteral{Value: true}},
+		{in: "x=1h30m", want: &ast.DurationLiteral{Values: []ast.Duration{{Magnitude: 1, Unit: "h"}, {Magnitude: 30, Unit: "m"}}}},
+		{in: "x=-1h", want: &ast.DurationLiteral{Values: []ast.Duration{{Magnitude: -1, Unit: "h"}}}},
+		{in: "x=2021-01-01T00:00:00Z", want: &ast.DateTimeLiteral{Value: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)}},
+		{in: "x=cpu", want: &ast.StringLiteral{Value: "cpu"}},
+		{in: "x=a=b", want: &ast.StringLiteral{Value: "a=b"}},
+		{in: `x="5"`, want: &ast.StringLiteral{Value: "5"}},
+		{in: "x=", want: &ast.StringLiteral{Value: ""}},
+		{in: "x=NaN", want: &ast.StringLiteral{Value: "NaN"}},
+		{in: "x", wantErr: true},
+		{in: "1x=5", wantErr: true},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := parseVar(tc.in)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.ID.Name != "x" {
+				t.Errorf("unexpected name %q", got.ID.Name)
+			}
+			if !cmp.Equal(tc.want, got.Init, asttest.IgnoreBaseNodeOptions...) {
+				t.Errorf("unexpected value -want/+got:\n%s", cmp.Diff(tc.want, got.Init, asttest.IgnoreBaseNodeOptions...))
+			}
+		})
+	}
+}
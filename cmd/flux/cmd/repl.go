@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 
 	"github.com/influxdata/flux/fluxinit"
 	"github.com/influxdata/flux/repl"
@@ -12,15 +14,48 @@ import (
 var replCmd = &cobra.Command{
 	Use:   "repl",
 	Short: "Launch a Flux REPL",
-	Long:  "Launch a Flux REPL (Read-Eval-Print-Loop)",
-	Run: func(cmd *cobra.Command, args []string) {
+	Long: `Launch a Flux REPL (Read-Eval-Print-Loop)
+
+Expressions that are not complete at the end of a line, such as a trailing |>
+or an unclosed bracket, continue on the next line. An empty line evaluates
+the input entered so far.
+
+Commands:
+  :load <file>  Evaluate the Flux source in a file
+  :save <file>  Write every statement evaluated in this session to a file
+  :reset        Remove every value defined in this session from the scope
+  :help         Print the available commands`,
+	RunE: func(cmd *cobra.Command, args []string) error {
 		fluxinit.FluxInit()
-		ctx, deps := injectDependencies(context.Background())
-		r := repl.New(ctx, deps)
+		ctx, deps, conf, err := injectDependencies(context.Background())
+		if err != nil {
+			return err
+		}
+		opts := []repl.Option{repl.WithAllocatorLimit(conf.MemoryLimit)}
+		if replFlags.historyFile != "" {
+			opts = append(opts, repl.WithHistoryFile(replFlags.historyFile))
+		}
+		r := repl.New(ctx, deps, opts...)
 		r.Run()
+		return nil
 	},
 }
 
+var replFlags struct {
+	historyFile string
+}
+
 func init() {
 	rootCmd.AddCommand(replCmd)
+	replCmd.Flags().StringVar(&replFlags.historyFile, "history-file", defaultHistoryFile(), "File used to persist the REPL history. Set to an empty string to disable history.")
+}
+
+// defaultHistoryFile returns the path of the history dotfile
+// in the home directory of the current user.
+func defaultHistoryFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".flux_history")
 }
@@ -12,6 +12,16 @@ var rootCmd = &cobra.Command{
 	Use:   "flux",
 	Short: "A Flux CLI",
 	Long:  `More to come later.`,
+	// Every command that plans a query must see the configured
+	// InfluxDB defaults, so they are registered before any command runs.
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		conf, err := loadConfig(cmd.Root().PersistentFlags())
+		if err != nil {
+			return err
+		}
+		registerDefaultFromAttributes(conf)
+		return nil
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -7,23 +7,28 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/influxdata/flux"
 	"github.com/influxdata/flux/ast"
 	"github.com/influxdata/flux/ast/testcase"
 	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/csv"
 	"github.com/influxdata/flux/dependencies/filesystem"
 	"github.com/influxdata/flux/dependencies/testing"
 	"github.com/influxdata/flux/execute/executetest"
 	"github.com/influxdata/flux/execute/table"
 	"github.com/influxdata/flux/fluxinit"
 	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/interpreter"
 	"github.com/influxdata/flux/lang"
 	"github.com/influxdata/flux/memory"
 	"github.com/influxdata/flux/parser"
@@ -32,10 +37,19 @@ import (
 )
 
 type testFlags struct {
-	testNames     []string
-	paths         []string
-	skipTestCases []string
-	verbosity     int
+	testNames       []string
+	paths           []string
+	skipTestCases   []string
+	verbosity       int
+	parallel        int
+	timeout         time.Duration
+	update          bool
+	updateSnapshots bool
+	junitReport     string
+	jsonReport      string
+	coverage        bool
+	coverText       string
+	coverLCOV       string
 }
 
 func TestCommand(setup TestSetupFunc) *cobra.Command {
@@ -53,6 +67,15 @@ func TestCommand(setup TestSetupFunc) *cobra.Command {
 	testCommand.Flags().StringSliceVar(&flags.testNames, "test", []string{}, "The name of a specific test to run.")
 	testCommand.Flags().StringSliceVar(&flags.skipTestCases, "skip", []string{}, "Comma-separated list of test cases to skip.")
 	testCommand.Flags().CountVarP(&flags.verbosity, "verbose", "v", "verbose (-v, or -vv)")
+	testCommand.Flags().IntVarP(&flags.parallel, "parallel", "j", 1, "The number of tests to run in parallel.")
+	testCommand.Flags().DurationVar(&flags.timeout, "timeout", 5*time.Minute, "Fail tests that run longer than this duration, 0 disables the timeout.")
+	testCommand.Flags().BoolVar(&flags.update, "update", false, "Rewrite the want CSV of failing tests with their actual output.")
+	testCommand.Flags().BoolVar(&flags.updateSnapshots, "update-snapshots", false, "Save the physical plans of tests that expect a plan snapshot as their snapshots.")
+	testCommand.Flags().StringVar(&flags.junitReport, "junit", "", "Write a JUnit XML report of the test run to this file.")
+	testCommand.Flags().StringVar(&flags.jsonReport, "json", "", "Write a JSON report of the test run to this file.")
+	testCommand.Flags().BoolVar(&flags.coverage, "coverage", false, "Record which functions, branches and options the tests evaluate and print a summary.")
+	testCommand.Flags().StringVar(&flags.coverText, "coverage-text", "", "Write the coverage of every function, branch and option to this file.")
+	testCommand.Flags().StringVar(&flags.coverLCOV, "coverage-lcov", "", "Write the coverage to this file in the lcov format.")
 	return testCommand
 }
 
@@ -66,6 +89,14 @@ func runFluxTests(setup TestSetupFunc, flags testFlags) {
 	if len(flags.paths) == 0 {
 		flags.paths = []string{"."}
 	}
+	if flags.update || flags.updateSnapshots {
+		for _, path := range flags.paths {
+			if isArchive(path) {
+				fmt.Printf("cannot update tests in archive: %s\n", path)
+				os.Exit(1)
+			}
+		}
+	}
 
 	reporter := NewTestReporter(flags.verbosity)
 	runner := NewTestRunner(reporter)
@@ -74,23 +105,90 @@ func runFluxTests(setup TestSetupFunc, flags testFlags) {
 		os.Exit(1)
 	}
 
-	executor, err := setup(context.Background())
+	// The executor records the coverage if the context has one.
+	ctx := context.Background()
+	var coverage *interpreter.Coverage
+	if flags.coverage || flags.coverText != "" || flags.coverLCOV != "" {
+		coverage = interpreter.NewCoverage()
+		ctx = coverage.Inject(ctx)
+	}
+
+	executor, err := setup(ctx)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 	defer func() { _ = executor.Close() }()
 
-	runner.Run(executor, flags.verbosity, flags.skipTestCases)
+	if err := runner.Run(executor, TestRunOptions{
+		SkipTestCases:   flags.skipTestCases,
+		Parallel:        flags.parallel,
+		Timeout:         flags.timeout,
+		Update:          flags.update,
+		UpdateSnapshots: flags.updateSnapshots,
+	}); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if flags.junitReport != "" {
+		if err := writeReportFile(flags.junitReport, func(w io.Writer) error {
+			return writeJUnitReport(w, runner.tests)
+		}); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	if flags.jsonReport != "" {
+		if err := writeReportFile(flags.jsonReport, func(w io.Writer) error {
+			return writeJSONReport(w, runner.tests)
+		}); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	if coverage != nil {
+		if err := writeCoverageReports(coverage, flags); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
 	runner.Finish()
 }
 
+// writeCoverageReports writes the coverage reports requested by the flags.
+func writeCoverageReports(coverage *interpreter.Coverage, flags testFlags) error {
+	if flags.coverage {
+		if err := writeCoverageSummary(os.Stdout, coverage); err != nil {
+			return err
+		}
+	}
+	if flags.coverText != "" {
+		if err := writeReportFile(flags.coverText, func(w io.Writer) error {
+			return writeCoverageText(w, coverage)
+		}); err != nil {
+			return err
+		}
+	}
+	if flags.coverLCOV != "" {
+		if err := writeReportFile(flags.coverLCOV, func(w io.Writer) error {
+			return writeCoverageLCOV(w, coverage)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Test wraps the functionality of a single testcase statement,
 // to handle its execution and its pass/fail state.
 type Test struct {
 	name string
 	ast  *ast.Package
 	err  error
+
+	skipped  bool
+	updated  bool
+	duration time.Duration
 }
 
 // NewTest creates a new Test instance from an ast.Package.
@@ -106,6 +204,14 @@ func (t *Test) Name() string {
 	return t.name
 }
 
+// Get the name of the file that defines the Test.
+func (t *Test) File() string {
+	if t.ast == nil || len(t.ast.Files) == 0 {
+		return ""
+	}
+	return t.ast.Files[len(t.ast.Files)-1].Name
+}
+
 // Get the error from the test, if one exists.
 func (t *Test) Error() error {
 	return t.err
@@ -113,7 +219,56 @@ func (t *Test) Error() error {
 
 // Run the test, saving the error to the err property of the struct.
 func (t *Test) Run(executor TestExecutor) {
-	t.err = executor.Run(t.ast)
+	t.run(executor, TestRunOptions{})
+}
+
+// run runs the test and fails it if it does not finish within the timeout of the options.
+// A timeout of zero waits for the test to finish.
+func (t *Test) run(executor TestExecutor, opts TestRunOptions) {
+	start := time.Now()
+	t.err = runTimeout(opts.Timeout, func(ctx context.Context) error {
+		if e, ok := executor.(TestFrameworkExecutor); ok {
+			return e.RunWithFramework(ctx, t.ast, t.framework(opts.UpdateSnapshots))
+		}
+		return executor.Run(t.ast)
+	})
+	t.duration = time.Since(start)
+}
+
+// framework returns the testing framework configuration of the test.
+// Plan snapshots are stored in the testdata directory next to the test file.
+func (t *Test) framework(updateSnapshots bool) testing.FrameworkConfig {
+	return testing.FrameworkConfig{
+		Name:            t.name,
+		Snapshots:       testing.SnapshotDir(filepath.Join(filepath.Dir(t.File()), "testdata")),
+		UpdateSnapshots: updateSnapshots,
+	}
+}
+
+// runTimeout returns the error of fn, or an error if fn has not returned after the timeout.
+// The context passed to fn is canceled at the timeout and runTimeout returns without
+// waiting for fn, since executors that do not receive the context cannot be stopped.
+// A test that hangs keeps running in the background until the process exits.
+func runTimeout(timeout time.Duration, fn func(ctx context.Context) error) error {
+	if timeout <= 0 {
+		return fn(context.Background())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fn(ctx)
+	}()
+	select {
+	case err := <-done:
+		if ctx.Err() == context.DeadlineExceeded {
+			break
+		}
+		return err
+	case <-ctx.Done():
+	}
+	return errors.Newf(codes.DeadlineExceeded, "test timed out after %s", timeout)
 }
 
 // contains checks a slice of strings for a given string.
@@ -177,7 +332,7 @@ func (t *TestRunner) Gather(roots []string, names []string) error {
 			if err != nil {
 				return err
 			}
-			baseAST := parser.ParseSource(string(q))
+			baseAST := parser.ParseSourceFile(file, string(q))
 			if len(baseAST.Files) > 0 {
 				baseAST.Files[0].Name = file
 			}
@@ -467,6 +622,10 @@ func gatherFromFile(filename string) ([]string, fs, testcase.TestModules, error)
 	return []string{filename}, systemfs{}, modules, nil
 }
 
+func isArchive(filename string) bool {
+	return strings.HasSuffix(filename, ".tar.gz") || strings.HasSuffix(filename, ".tar") || strings.HasSuffix(filename, ".zip")
+}
+
 func isTestFile(fi os.FileInfo, filename string) bool {
 	return !fi.IsDir() && strings.HasSuffix(filename, "_test.flux")
 }
@@ -527,19 +686,91 @@ func findParentTestRoot(path string) (string, filesystem.Service, bool, error) {
 	return "", nil, false, nil
 }
 
+// TestRunOptions configures how a TestRunner runs the tests.
+type TestRunOptions struct {
+	// SkipTestCases holds the names of the tests that are not run.
+	SkipTestCases []string
+	// Parallel is the number of tests that run at the same time.
+	// The executor must support concurrent runs if this is greater than one.
+	Parallel int
+	// Timeout fails tests that have not finished after this duration.
+	// A timeout of zero waits for every test to finish.
+	Timeout time.Duration
+	// Update rewrites the want CSV of tests that fail with a TestDiffError
+	// with their actual output. The executor must implement TestOutputExecutor.
+	Update bool
+	// UpdateSnapshots saves the physical plans of tests that expect
+	// a plan snapshot as their snapshots.
+	// The executor must implement TestFrameworkExecutor.
+	UpdateSnapshots bool
+}
+
 // Run runs all tests, reporting their results.
-func (t *TestRunner) Run(executor TestExecutor, verbosity int, skipTestCases []string) {
+func (t *TestRunner) Run(executor TestExecutor, opts TestRunOptions) error {
+	var updater TestOutputExecutor
+	if opts.Update {
+		e, ok := executor.(TestOutputExecutor)
+		if !ok {
+			return errors.New(codes.Unimplemented, "test executor does not support updating tests")
+		}
+		updater = e
+	}
+	if opts.UpdateSnapshots {
+		if _, ok := executor.(TestFrameworkExecutor); !ok {
+			return errors.New(codes.Unimplemented, "test executor does not support updating plan snapshots")
+		}
+	}
+
 	skipMap := make(map[string]struct{})
-	for _, n := range skipTestCases {
+	for _, n := range opts.SkipTestCases {
 		skipMap[n] = struct{}{}
 	}
+	parallel := opts.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	var (
+		mu    sync.Mutex
+		edits []sourceEdit
+		wg    sync.WaitGroup
+		tests = make(chan *Test)
+	)
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for test := range tests {
+				test.run(executor, opts)
+				var edit *sourceEdit
+				if updater != nil && isTestDiff(test.err) {
+					if e, err := test.update(updater); err != nil {
+						test.err = errors.Wrapf(test.err, codes.Inherit, "cannot update test: %s", err)
+					} else {
+						test.err, test.updated, edit = nil, true, &e
+					}
+				}
+
+				mu.Lock()
+				if edit != nil {
+					edits = append(edits, *edit)
+				}
+				t.reporter.ReportTestRun(test)
+				mu.Unlock()
+			}
+		}()
+	}
 	for _, test := range t.tests {
 		if _, ok := skipMap[test.name]; ok {
+			test.skipped = true
 			continue
 		}
-		test.Run(executor)
-		t.reporter.ReportTestRun(test)
+		tests <- test
 	}
+	close(tests)
+	wg.Wait()
+
+	return applySourceEdits(edits)
 }
 
 // Finish summarizes the test run, and returns the
@@ -570,12 +801,16 @@ func (t *TestReporter) ReportTestRun(test *Test) {
 	if t.verbosity == 0 {
 		if test.Error() != nil {
 			fmt.Print("x")
+		} else if test.updated {
+			fmt.Print("u")
 		} else {
 			fmt.Print(".")
 		}
 	} else {
 		if err := test.Error(); err != nil {
 			fmt.Printf("%s...fail: %s\n", test.Name(), err)
+		} else if test.updated {
+			fmt.Printf("%s...updated\n", test.Name())
 		} else {
 			fmt.Printf("%s...success\n", test.Name())
 		}
@@ -584,10 +819,14 @@ func (t *TestReporter) ReportTestRun(test *Test) {
 
 // Summarize summarizes the test run.
 func (t *TestReporter) Summarize(tests []*Test) {
-	failures := 0
+	failures, skipped, updated := 0, 0, 0
 	for _, test := range tests {
-		if test.Error() != nil {
+		if test.skipped {
+			skipped = skipped + 1
+		} else if test.Error() != nil {
 			failures = failures + 1
+		} else if test.updated {
+			updated = updated + 1
 		}
 	}
 	if failures > 0 {
@@ -598,7 +837,13 @@ func (t *TestReporter) Summarize(tests []*Test) {
 			}
 		}
 	}
-	fmt.Printf("\n---\nRan %d tests with %d failure(s)\n", len(tests), failures)
+	fmt.Printf("\n---\nRan %d tests with %d failure(s)\n", len(tests)-skipped, failures)
+	if skipped > 0 {
+		fmt.Printf("Skipped %d test(s)\n", skipped)
+	}
+	if updated > 0 {
+		fmt.Printf("Updated %d test(s)\n", updated)
+	}
 }
 
 type TestSetupFunc func(ctx context.Context) (TestExecutor, error)
@@ -608,30 +853,89 @@ type TestExecutor interface {
 	io.Closer
 }
 
+// TestFrameworkExecutor is a TestExecutor that runs tests with the configuration
+// of the testing framework for each test. It is required to compare the physical
+// plans of tests with their snapshots.
+//
+// The context is canceled when the test times out,
+// and RunWithFramework should return once it is done.
+type TestFrameworkExecutor interface {
+	TestExecutor
+	RunWithFramework(ctx context.Context, pkg *ast.Package, cfg testing.FrameworkConfig) error
+}
+
+// TestDiffError is the error of a test whose results differ
+// from its expected results according to testing.diff.
+// Only tests that fail with this error are updated.
+type TestDiffError struct {
+	// Diff is the output of testing.diff.
+	Diff string
+}
+
+func (e *TestDiffError) Error() string {
+	return e.Diff
+}
+
+// isTestDiff reports whether a test failed because its results
+// differ from its expected results.
+func isTestDiff(err error) bool {
+	var diff *TestDiffError
+	return stderrors.As(err, &diff)
+}
+
+// NewTestExecutor creates a TestExecutor that runs tests in this process.
+// It records the coverage of the tests if the context has an interpreter.Coverage.
 func NewTestExecutor(ctx context.Context) (TestExecutor, error) {
-	return testExecutor{}, nil
+	return testExecutor{coverage: interpreter.GetCoverage(ctx)}, nil
 }
 
-type testExecutor struct{}
+type testExecutor struct {
+	coverage *interpreter.Coverage
+}
 
-func (testExecutor) Run(pkg *ast.Package) error {
+// compile compiles the program of a test package and returns it
+// with the context it must be started with.
+func (e testExecutor) compile(ctx context.Context, pkg *ast.Package, cfg testing.FrameworkConfig) (context.Context, flux.Program, error) {
 	jsonAST, err := json.Marshal(pkg)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	c := lang.ASTCompiler{AST: jsonAST}
 
-	ctx := executetest.NewTestExecuteDependencies().Inject(context.Background())
-	ctx = testing.Inject(ctx)
+	ctx = executetest.NewTestExecuteDependencies().Inject(ctx)
+	ctx = cfg.Inject(ctx)
+	if e.coverage != nil {
+		ctx = e.coverage.Inject(ctx)
+	}
 	program, err := c.Compile(ctx, runtime.Default)
 	if err != nil {
-		return errors.Wrap(err, codes.Invalid, "failed to compile")
+		return nil, nil, errors.Wrap(err, codes.Invalid, "failed to compile")
 	}
+	return ctx, program, nil
+}
 
-	alloc := &memory.Allocator{}
+// start compiles and starts the program of a test package
+// with the testing framework configuration.
+func (e testExecutor) start(ctx context.Context, pkg *ast.Package, cfg testing.FrameworkConfig, alloc *memory.Allocator) (flux.Query, error) {
+	ctx, program, err := e.compile(ctx, pkg, cfg)
+	if err != nil {
+		return nil, err
+	}
 	query, err := program.Start(ctx, alloc)
 	if err != nil {
-		return errors.Wrap(err, codes.Inherit, "error while executing program")
+		return nil, errors.Wrap(err, codes.Inherit, "error while executing program")
+	}
+	return query, nil
+}
+
+func (e testExecutor) Run(pkg *ast.Package) error {
+	return e.RunWithFramework(context.Background(), pkg, testing.FrameworkConfig{})
+}
+
+func (e testExecutor) RunWithFramework(ctx context.Context, pkg *ast.Package, cfg testing.FrameworkConfig) error {
+	query, err := e.start(ctx, pkg, cfg, &memory.Allocator{})
+	if err != nil {
+		return err
 	}
 	defer query.Done()
 
@@ -655,11 +959,28 @@ func (testExecutor) Run(pkg *ast.Package) error {
 
 	err = results.Err()
 	if err == nil && output.Len() > 0 {
-		err = errors.New(codes.FailedPrecondition, output.String())
+		err = errors.Wrap(&TestDiffError{Diff: output.String()}, codes.FailedPrecondition)
 	}
 	return err
 }
 
+// Output writes the results of the test package as annotated CSV.
+func (e testExecutor) Output(pkg *ast.Package, w io.Writer) error {
+	query, err := e.start(context.Background(), pkg, testing.FrameworkConfig{}, &memory.Allocator{})
+	if err != nil {
+		return err
+	}
+	defer query.Done()
+
+	results := flux.NewResultIteratorFromQuery(query)
+	defer results.Release()
+	enc := csv.NewMultiResultEncoder(csv.DefaultEncoderConfig())
+	if _, err := enc.Encode(w, results); err != nil {
+		return err
+	}
+	return results.Err()
+}
+
 func (testExecutor) Close() error { return nil }
 
 type fs interface {
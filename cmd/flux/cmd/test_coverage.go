@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/influxdata/flux/interpreter"
+)
+
+// coveredBlocks returns the blocks of the coverage grouped by file.
+// Test files are left out since they are not the code under test.
+func coveredBlocks(cov *interpreter.Coverage) ([]string, map[string][]interpreter.CoverageBlock) {
+	var files []string
+	byFile := make(map[string][]interpreter.CoverageBlock)
+	for _, b := range cov.Blocks() {
+		if strings.HasSuffix(b.File, "_test.flux") {
+			continue
+		}
+		if _, ok := byFile[b.File]; !ok {
+			files = append(files, b.File)
+		}
+		byFile[b.File] = append(byFile[b.File], b)
+	}
+	return files, byFile
+}
+
+type coverageCounts struct {
+	functions, functionsHit int
+	branches, branchesHit   int
+	options, optionsHit     int
+}
+
+func (c *coverageCounts) add(b interpreter.CoverageBlock) {
+	hit := 0
+	if b.Count > 0 {
+		hit = 1
+	}
+	switch b.Kind {
+	case interpreter.CoverageFunction:
+		c.functions++
+		c.functionsHit += hit
+	case interpreter.CoverageConsequent, interpreter.CoverageAlternate:
+		c.branches++
+		c.branchesHit += hit
+	case interpreter.CoverageOption:
+		c.options++
+		c.optionsHit += hit
+	}
+}
+
+func (c coverageCounts) String() string {
+	return fmt.Sprintf("functions %s, branches %s, options %s",
+		percent(c.functionsHit, c.functions),
+		percent(c.branchesHit, c.branches),
+		percent(c.optionsHit, c.options),
+	)
+}
+
+func percent(hit, total int) string {
+	if total == 0 {
+		return "0/0"
+	}
+	return fmt.Sprintf("%d/%d (%.1f%%)", hit, total, 100*float64(hit)/float64(total))
+}
+
+// writeCoverageSummary writes the share of evaluated blocks of each file.
+func writeCoverageSummary(w io.Writer, cov *interpreter.Coverage) error {
+	files, byFile := coveredBlocks(cov)
+	var total coverageCounts
+	if _, err := fmt.Fprintf(w, "\ncoverage:\n"); err != nil {
+		return err
+	}
+	for _, file := range files {
+		var counts coverageCounts
+		for _, b := range byFile[file] {
+			counts.add(b)
+			total.add(b)
+		}
+		if _, err := fmt.Fprintf(w, "\t%s: %s\n", file, counts); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "\ttotal: %s\n", total)
+	return err
+}
+
+// writeCoverageText writes every block with its location and evaluation count,
+// one block per line.
+func writeCoverageText(w io.Writer, cov *interpreter.Coverage) error {
+	files, byFile := coveredBlocks(cov)
+	for _, file := range files {
+		for _, b := range byFile[file] {
+			kind := b.Kind.String()
+			if b.Name != "" {
+				kind += " " + b.Name
+			}
+			if _, err := fmt.Fprintf(w, "%s:%v-%v %s %d\n", b.File, b.Start, b.End, kind, b.Count); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeCoverageLCOV writes the coverage in the lcov tracefile format.
+// Functions are reported as functions, conditional expressions as
+// branches and the start line of every block as a line.
+func writeCoverageLCOV(w io.Writer, cov *interpreter.Coverage) error {
+	files, byFile := coveredBlocks(cov)
+	for _, file := range files {
+		var (
+			b     strings.Builder
+			lines []int
+			hits  = make(map[int]int64)
+		)
+		line := func(n int, count int64) {
+			if c, ok := hits[n]; !ok {
+				lines = append(lines, n)
+				hits[n] = count
+			} else if count > c {
+				hits[n] = count
+			}
+		}
+
+		fmt.Fprintf(&b, "TN:\nSF:%s\n", file)
+
+		var fns, fnsHit int
+		for _, blk := range byFile[file] {
+			if blk.Kind != interpreter.CoverageFunction {
+				continue
+			}
+			name := fmt.Sprintf("anonymous@%v", blk.Start)
+			if blk.Name != "" {
+				name = fmt.Sprintf("%s@%v", blk.Name, blk.Start)
+			}
+			fmt.Fprintf(&b, "FN:%d,%s\nFNDA:%d,%s\n", blk.Start.Line, name, blk.Count, name)
+			fns++
+			if blk.Count > 0 {
+				fnsHit++
+			}
+		}
+		fmt.Fprintf(&b, "FNF:%d\nFNH:%d\n", fns, fnsHit)
+
+		var branches, branchesHit, conditional int
+		blocks := byFile[file]
+		for i, blk := range blocks {
+			switch blk.Kind {
+			case interpreter.CoverageFunction, interpreter.CoverageOption:
+				line(blk.Start.Line, blk.Count)
+			case interpreter.CoverageConsequent:
+				// The alternate of a conditional expression follows its consequent.
+				if i+1 >= len(blocks) || blocks[i+1].Kind != interpreter.CoverageAlternate {
+					continue
+				}
+				then, els := blk.Count, blocks[i+1].Count
+				line(blk.Start.Line, then+els)
+				for branch, count := range []int64{then, els} {
+					taken := "-"
+					if then+els > 0 {
+						taken = fmt.Sprint(count)
+					}
+					fmt.Fprintf(&b, "BRDA:%d,%d,%d,%s\n", blk.Start.Line, conditional, branch, taken)
+					branches++
+					if count > 0 {
+						branchesHit++
+					}
+				}
+				conditional++
+			}
+		}
+		fmt.Fprintf(&b, "BRF:%d\nBRH:%d\n", branches, branchesHit)
+
+		var linesHit int
+		for _, n := range lines {
+			fmt.Fprintf(&b, "DA:%d,%d\n", n, hits[n])
+			if hits[n] > 0 {
+				linesHit++
+			}
+		}
+		fmt.Fprintf(&b, "LF:%d\nLH:%d\nend_of_record\n", len(lines), linesHit)
+
+		if _, err := io.WriteString(w, b.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
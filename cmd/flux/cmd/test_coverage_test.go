@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux/ast"
+	"github.com/influxdata/flux/interpreter"
+	"github.com/influxdata/flux/semantic"
+	"github.com/influxdata/flux/values"
+)
+
+// newTestCoverage evaluates this package, with an extra function in a test file.
+//
+//	f = () => 1
+//	option o = 1
+//	if true then 1 else 2
+//	if false then 1 else 2
+func newTestCoverage(t *testing.T) *interpreter.Coverage {
+	loc := func(file string, line, startCol, endCol int) semantic.Loc {
+		return semantic.Loc{
+			File:  file,
+			Start: ast.Position{Line: line, Column: startCol},
+			End:   ast.Position{Line: line, Column: endCol},
+		}
+	}
+	fn := func(l semantic.Loc) *semantic.FunctionExpression {
+		return &semantic.FunctionExpression{
+			Loc:        l,
+			Parameters: &semantic.FunctionParameters{},
+			Block: &semantic.Block{
+				Body: []semantic.Statement{&semantic.ReturnStatement{Argument: &semantic.IntegerLiteral{Value: 1}}},
+			},
+		}
+	}
+	cond := func(l semantic.Loc, test bool) semantic.Statement {
+		return &semantic.ExpressionStatement{Expression: &semantic.ConditionalExpression{
+			Loc:        l,
+			Test:       &semantic.BooleanLiteral{Value: test},
+			Consequent: &semantic.IntegerLiteral{Value: 1},
+			Alternate:  &semantic.IntegerLiteral{Value: 2},
+		}}
+	}
+	pkg := &semantic.Package{
+		Package: "main",
+		Files: []*semantic.File{{
+			Body: []semantic.Statement{
+				&semantic.NativeVariableAssignment{
+					Identifier: &semantic.Identifier{Name: semantic.NewSymbol("f")},
+					Init:       fn(loc("lib.flux", 1, 5, 12)),
+				},
+				&semantic.OptionStatement{
+					Loc: loc("lib.flux", 2, 1, 13),
+					Assignment: &semantic.NativeVariableAssignment{
+						Identifier: &semantic.Identifier{Name: semantic.NewSymbol("o")},
+						Init:       &semantic.IntegerLiteral{Value: 1},
+					},
+				},
+				cond(loc("lib.flux", 3, 1, 22), true),
+				cond(loc("lib.flux", 4, 1, 23), false),
+				&semantic.NativeVariableAssignment{
+					Identifier: &semantic.Identifier{Name: semantic.NewSymbol("helper")},
+					Init:       fn(loc("lib_test.flux", 1, 10, 17)),
+				},
+			},
+		}},
+	}
+
+	coverage := interpreter.NewCoverage()
+	itrp := interpreter.NewInterpreter(nil, nil)
+	if _, err := itrp.Eval(coverage.Inject(context.Background()), pkg, values.NewScope(), nil); err != nil {
+		t.Fatal(err)
+	}
+	return coverage
+}
+
+func TestCoverageReports(t *testing.T) {
+	coverage := newTestCoverage(t)
+
+	var summary bytes.Buffer
+	if err := writeCoverageSummary(&summary, coverage); err != nil {
+		t.Fatal(err)
+	}
+	wantSummary := `
+coverage:
+	lib.flux: functions 0/1 (0.0%), branches 2/4 (50.0%), options 1/1 (100.0%)
+	total: functions 0/1 (0.0%), branches 2/4 (50.0%), options 1/1 (100.0%)
+`
+	if got := summary.String(); wantSummary != got {
+		t.Errorf("unexpected summary -want/+got:\n%s", cmp.Diff(wantSummary, got))
+	}
+
+	var text bytes.Buffer
+	if err := writeCoverageText(&text, coverage); err != nil {
+		t.Fatal(err)
+	}
+	wantText := `lib.flux:1:5-1:12 function f 0
+lib.flux:2:1-2:13 option 1
+lib.flux:3:1-3:22 then 1
+lib.flux:3:1-3:22 else 0
+lib.flux:4:1-4:23 then 0
+lib.flux:4:1-4:23 else 1
+`
+	if got := text.String(); wantText != got {
+		t.Errorf("unexpected text report -want/+got:\n%s", cmp.Diff(wantText, got))
+	}
+
+	var lcov bytes.Buffer
+	if err := writeCoverageLCOV(&lcov, coverage); err != nil {
+		t.Fatal(err)
+	}
+	wantLCOV := `TN:
+SF:lib.flux
+FN:1,f@1:5
+FNDA:0,f@1:5
+FNF:1
+FNH:0
+BRDA:3,0,0,1
+BRDA:3,0,1,0
+BRDA:4,1,0,0
+BRDA:4,1,1,1
+BRF:4
+BRH:2
+DA:1,0
+DA:2,1
+DA:3,1
+DA:4,1
+LF:4
+LH:3
+end_of_record
+`
+	if got := lcov.String(); wantLCOV != got {
+		t.Errorf("unexpected lcov report -want/+got:\n%s", cmp.Diff(wantLCOV, got))
+	}
+}
@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"os"
+)
+
+// junitTestSuites is the root element of a JUnit XML report.
+// Each test file is reported as a test suite.
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     float64          `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     float64         `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnitReport writes the results of the tests as JUnit XML.
+func writeJUnitReport(w io.Writer, tests []*Test) error {
+	var report junitTestSuites
+	suites := make(map[string]int)
+	for _, test := range tests {
+		file := test.File()
+		idx, ok := suites[file]
+		if !ok {
+			idx = len(report.Suites)
+			suites[file] = idx
+			report.Suites = append(report.Suites, junitTestSuite{Name: file})
+		}
+		suite := &report.Suites[idx]
+
+		tc := junitTestCase{
+			Name:      test.Name(),
+			Classname: file,
+			Time:      test.duration.Seconds(),
+		}
+		switch {
+		case test.skipped:
+			tc.Skipped = &struct{}{}
+			suite.Skipped++
+		case test.Error() != nil:
+			msg := test.Error().Error()
+			tc.Failure = &junitFailure{Message: firstLine(msg), Text: msg}
+			suite.Failures++
+		}
+		suite.Tests++
+		suite.Time += tc.Time
+		suite.Cases = append(suite.Cases, tc)
+	}
+	for _, suite := range report.Suites {
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Skipped += suite.Skipped
+		report.Time += suite.Time
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// jsonTestReport is the JSON report of a test run.
+type jsonTestReport struct {
+	Tests   []jsonTestResult `json:"tests"`
+	Passed  int              `json:"passed"`
+	Failed  int              `json:"failed"`
+	Skipped int              `json:"skipped"`
+	Updated int              `json:"updated"`
+}
+
+type jsonTestResult struct {
+	Name    string  `json:"name"`
+	File    string  `json:"file"`
+	Status  string  `json:"status"`
+	Elapsed float64 `json:"elapsed"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// writeJSONReport writes the results of the tests as JSON.
+// The status of a test is one of pass, fail, skip or update.
+func writeJSONReport(w io.Writer, tests []*Test) error {
+	report := jsonTestReport{Tests: make([]jsonTestResult, 0, len(tests))}
+	for _, test := range tests {
+		r := jsonTestResult{
+			Name:    test.Name(),
+			File:    test.File(),
+			Elapsed: test.duration.Seconds(),
+		}
+		switch {
+		case test.skipped:
+			r.Status = "skip"
+			report.Skipped++
+		case test.Error() != nil:
+			r.Status = "fail"
+			r.Error = test.Error().Error()
+			report.Failed++
+		case test.updated:
+			r.Status = "update"
+			report.Updated++
+		default:
+			r.Status = "pass"
+			report.Passed++
+		}
+		report.Tests = append(report.Tests, r)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// writeReportFile creates the file and writes a report to it.
+func writeReportFile(name string, write func(io.Writer) error) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func firstLine(s string) string {
+	for i, c := range s {
+		if c == '\n' {
+			return s[:i]
+		}
+	}
+	return s
+}
@@ -3,13 +3,22 @@ package cmd
 import (
 	"archive/tar"
 	"archive/zip"
+	"bytes"
 	"context"
+	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux/ast"
+	"github.com/influxdata/flux/codes"
 	"github.com/influxdata/flux/dependencies/filesystem"
+	ftesting "github.com/influxdata/flux/dependencies/testing"
 	"github.com/influxdata/flux/internal/errors"
 )
 
@@ -211,3 +220,468 @@ func testGatherFromArchive(t *testing.T, fn gatherFunc, filename string) {
 		}
 	}
 }
+
+// fakeExecutor runs tests by calling the function registered for the name of their file.
+type fakeExecutor struct {
+	tests  map[string]func() error
+	output string
+}
+
+func (e *fakeExecutor) Run(pkg *ast.Package) error {
+	return e.tests[pkg.Files[len(pkg.Files)-1].Name]()
+}
+
+func (e *fakeExecutor) Output(pkg *ast.Package, w io.Writer) error {
+	_, err := io.WriteString(w, e.output)
+	return err
+}
+
+func (e *fakeExecutor) Close() error { return nil }
+
+func newFakeTest(name, file string) *Test {
+	test := NewTest(name, &ast.Package{Files: []*ast.File{{Name: file}}})
+	return &test
+}
+
+func TestTestRunner_Parallel(t *testing.T) {
+	var running, maxRunning int32
+	wait := func() error {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return nil
+	}
+	executor := &fakeExecutor{tests: map[string]func() error{
+		"a_test.flux": wait,
+		"b_test.flux": wait,
+		"c_test.flux": wait,
+		"d_test.flux": func() error { return errors.New(codes.FailedPrecondition, "diff") },
+	}}
+
+	runner := NewTestRunner(NewTestReporter(0))
+	runner.tests = []*Test{
+		newFakeTest("a", "a_test.flux"),
+		newFakeTest("b", "b_test.flux"),
+		newFakeTest("c", "c_test.flux"),
+		newFakeTest("d", "d_test.flux"),
+		newFakeTest("e", "e_test.flux"),
+	}
+	if err := runner.Run(executor, TestRunOptions{
+		SkipTestCases: []string{"e"},
+		Parallel:      3,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := atomic.LoadInt32(&maxRunning); got < 2 {
+		t.Errorf("expected tests to run in parallel, at most %d ran at the same time", got)
+	}
+	for _, test := range runner.tests[:3] {
+		if test.Error() != nil {
+			t.Errorf("unexpected error in test %s: %s", test.Name(), test.Error())
+		}
+	}
+	if runner.tests[3].Error() == nil {
+		t.Error("expected test d to fail")
+	}
+	if !runner.tests[4].skipped {
+		t.Error("expected test e to be skipped")
+	}
+}
+
+func TestTestRunner_Timeout(t *testing.T) {
+	// The tests of the framework executor are canceled at the timeout.
+	canceled := make(chan struct{}, 2)
+	framework := &fakeFrameworkExecutor{run: func(ctx context.Context) error {
+		<-ctx.Done()
+		canceled <- struct{}{}
+		return ctx.Err()
+	}}
+	// The tests of an executor that does not receive the context never return.
+	hang := make(chan struct{})
+	defer close(hang)
+	hung := func() error {
+		<-hang
+		return nil
+	}
+	executor := &fakeExecutor{tests: map[string]func() error{
+		"a_test.flux": hung,
+		"b_test.flux": hung,
+	}}
+
+	for _, tc := range []struct {
+		name     string
+		executor TestExecutor
+	}{
+		{name: "framework", executor: framework},
+		{name: "hung", executor: executor},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			runner := NewTestRunner(NewTestReporter(0))
+			runner.tests = []*Test{
+				newFakeTest("a", "a_test.flux"),
+				newFakeTest("b", "b_test.flux"),
+			}
+			if err := runner.Run(tc.executor, TestRunOptions{Timeout: 10 * time.Millisecond}); err != nil {
+				t.Fatal(err)
+			}
+			for _, test := range runner.tests {
+				if err := test.Error(); err == nil {
+					t.Fatalf("expected test %s to time out", test.Name())
+				} else if want, got := codes.DeadlineExceeded, errors.Code(err); want != got {
+					t.Errorf("unexpected error code -want/+got:\n\t- %v\n\t+ %v", want, got)
+				}
+			}
+		})
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-canceled:
+		case <-time.After(time.Second):
+			t.Fatal("expected the tests of the framework executor to be canceled")
+		}
+	}
+}
+
+func TestTestRunner_Update(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flux-test-update")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	const src = `want = csv.from(csv: "old")
+testing.diff(got: got, want: want) |> yield()
+`
+	lit := &ast.StringLiteral{
+		BaseNode: ast.BaseNode{Loc: &ast.SourceLocation{
+			Start:  ast.Position{Line: 1, Column: 22},
+			End:    ast.Position{Line: 1, Column: 27},
+			Source: `"old"`,
+		}},
+		Value: "old",
+	}
+	call := func(object, property string, props ...*ast.Property) *ast.CallExpression {
+		var callee ast.Expression = &ast.Identifier{Name: property}
+		if object != "" {
+			callee = &ast.MemberExpression{
+				Object:   &ast.Identifier{Name: object},
+				Property: &ast.Identifier{Name: property},
+			}
+		}
+		return &ast.CallExpression{
+			Callee:    callee,
+			Arguments: []ast.Expression{&ast.ObjectExpression{Properties: props}},
+		}
+	}
+	prop := func(key string, value ast.Expression) *ast.Property {
+		return &ast.Property{Key: &ast.Identifier{Name: key}, Value: value}
+	}
+	newTest := func(name string) *Test {
+		fpath := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(fpath, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		test := NewTest(name, &ast.Package{Files: []*ast.File{{
+			Name: fpath,
+			Body: []ast.Statement{
+				&ast.VariableAssignment{ID: &ast.Identifier{Name: "want"}, Init: call("csv", "from", prop("csv", lit))},
+				&ast.ExpressionStatement{Expression: &ast.PipeExpression{
+					Argument: call("testing", "diff",
+						prop("got", &ast.Identifier{Name: "got"}),
+						prop("want", &ast.Identifier{Name: "want"}),
+					),
+					Call: call("", "yield"),
+				}},
+			},
+		}}})
+		return &test
+	}
+
+	executor := &fakeExecutor{
+		tests: map[string]func() error{
+			filepath.Join(dir, "diff_test.flux"): func() error {
+				return errors.Wrap(&TestDiffError{Diff: "diff"}, codes.FailedPrecondition)
+			},
+			filepath.Join(dir, "error_test.flux"): func() error {
+				return errors.New(codes.Invalid, "runtime error")
+			},
+		},
+		output: "new",
+	}
+	runner := NewTestRunner(NewTestReporter(0))
+	runner.tests = []*Test{newTest("diff_test.flux"), newTest("error_test.flux")}
+	if err := runner.Run(executor, TestRunOptions{Update: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the test that failed with a diff is updated.
+	for _, tc := range []struct {
+		test    *Test
+		updated bool
+	}{
+		{test: runner.tests[0], updated: true},
+		{test: runner.tests[1], updated: false},
+	} {
+		if want, got := tc.updated, tc.test.updated; want != got {
+			t.Errorf("unexpected update of test %s -want/+got:\n\t- %v\n\t+ %v", tc.test.Name(), want, got)
+		}
+		data, err := ioutil.ReadFile(tc.test.File())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, got := tc.updated, string(data) != src; want != got {
+			t.Errorf("unexpected rewrite of test %s -want/+got:\n\t- %v\n\t+ %v", tc.test.Name(), want, got)
+		}
+	}
+	if runner.tests[1].Error() == nil {
+		t.Error("expected the test that failed without a diff to keep its error")
+	}
+}
+
+func TestTestRunner_UpdateSnapshots(t *testing.T) {
+	runner := NewTestRunner(NewTestReporter(0))
+	runner.tests = []*Test{newFakeTest("a", "testdata/a_test.flux")}
+	if err := runner.Run(&fakeExecutor{}, TestRunOptions{UpdateSnapshots: true}); err == nil {
+		t.Fatal("expected error for an executor without framework support")
+	} else if want, got := codes.Unimplemented, errors.Code(err); want != got {
+		t.Errorf("unexpected error code -want/+got:\n\t- %v\n\t+ %v", want, got)
+	}
+
+	executor := &fakeFrameworkExecutor{}
+	if err := runner.Run(executor, TestRunOptions{UpdateSnapshots: true}); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := "a", executor.config.Name; want != got {
+		t.Errorf("unexpected test name -want/+got:\n\t- %s\n\t+ %s", want, got)
+	}
+	if want, got := ftesting.SnapshotDir("testdata/testdata"), executor.config.Snapshots; want != got {
+		t.Errorf("unexpected snapshot store -want/+got:\n\t- %v\n\t+ %v", want, got)
+	}
+	if !executor.config.UpdateSnapshots {
+		t.Error("expected snapshots to be updated")
+	}
+}
+
+// fakeFrameworkExecutor records the framework configuration of the last test it ran
+// and runs every test with the run function, if it is set.
+type fakeFrameworkExecutor struct {
+	fakeExecutor
+	run func(ctx context.Context) error
+
+	mu     sync.Mutex
+	config ftesting.FrameworkConfig
+}
+
+func (e *fakeFrameworkExecutor) RunWithFramework(ctx context.Context, pkg *ast.Package, cfg ftesting.FrameworkConfig) error {
+	e.mu.Lock()
+	e.config = cfg
+	e.mu.Unlock()
+	if e.run != nil {
+		return e.run(ctx)
+	}
+	return nil
+}
+
+func TestWriteReports(t *testing.T) {
+	tests := []*Test{
+		newFakeTest("a", "a_test.flux"),
+		newFakeTest("b", "a_test.flux"),
+		newFakeTest("c", "c_test.flux"),
+	}
+	tests[0].duration = 1500 * time.Millisecond
+	tests[1].err = errors.New(codes.FailedPrecondition, "first line\nsecond line")
+	tests[2].skipped = true
+
+	var junit bytes.Buffer
+	if err := writeJUnitReport(&junit, tests); err != nil {
+		t.Fatal(err)
+	}
+	wantJUnit := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites tests="3" failures="1" skipped="1" time="1.5">
+  <testsuite name="a_test.flux" tests="2" failures="1" skipped="0" time="1.5">
+    <testcase name="a" classname="a_test.flux" time="1.5"></testcase>
+    <testcase name="b" classname="a_test.flux" time="0">
+      <failure message="first line">first line&#xA;second line</failure>
+    </testcase>
+  </testsuite>
+  <testsuite name="c_test.flux" tests="1" failures="0" skipped="1" time="0">
+    <testcase name="c" classname="c_test.flux" time="0">
+      <skipped></skipped>
+    </testcase>
+  </testsuite>
+</testsuites>
+`
+	if got := junit.String(); wantJUnit != got {
+		t.Errorf("unexpected JUnit report -want/+got:\n%s", cmp.Diff(wantJUnit, got))
+	}
+
+	var report bytes.Buffer
+	if err := writeJSONReport(&report, tests); err != nil {
+		t.Fatal(err)
+	}
+	wantJSON := `{
+  "tests": [
+    {
+      "name": "a",
+      "file": "a_test.flux",
+      "status": "pass",
+      "elapsed": 1.5
+    },
+    {
+      "name": "b",
+      "file": "a_test.flux",
+      "status": "fail",
+      "elapsed": 0,
+      "error": "first line\nsecond line"
+    },
+    {
+      "name": "c",
+      "file": "c_test.flux",
+      "status": "skip",
+      "elapsed": 0
+    }
+  ],
+  "passed": 1,
+  "failed": 1,
+  "skipped": 1,
+  "updated": 0
+}
+`
+	if got := report.String(); wantJSON != got {
+		t.Errorf("unexpected JSON report -want/+got:\n%s", cmp.Diff(wantJSON, got))
+	}
+}
+
+func TestTestUpdate(t *testing.T) {
+	// The AST of this source, with the locations of the want CSV literals.
+	src := `outData = "
+old
+"
+testcase a {
+    got = csv.from(csv: inData)
+    testing.diff(got: got, want: csv.from(csv: outData)) |> yield()
+}
+testcase b {
+    want = testing.loadMem(csv: "old")
+    testing.diff(got: csv.from(csv: inData), want) |> yield()
+}
+`
+	outData := &ast.StringLiteral{
+		BaseNode: ast.BaseNode{Loc: &ast.SourceLocation{
+			Start:  ast.Position{Line: 1, Column: 11},
+			End:    ast.Position{Line: 3, Column: 2},
+			Source: "\"\nold\n\"",
+		}},
+		Value: "\nold\n",
+	}
+	inline := &ast.StringLiteral{
+		BaseNode: ast.BaseNode{Loc: &ast.SourceLocation{
+			Start:  ast.Position{Line: 9, Column: 33},
+			End:    ast.Position{Line: 9, Column: 38},
+			Source: `"old"`,
+		}},
+		Value: "old",
+	}
+	member := func(object, property string) *ast.MemberExpression {
+		return &ast.MemberExpression{
+			Object:   &ast.Identifier{Name: object},
+			Property: &ast.Identifier{Name: property},
+		}
+	}
+	call := func(callee ast.Expression, props ...*ast.Property) *ast.CallExpression {
+		return &ast.CallExpression{
+			Callee:    callee,
+			Arguments: []ast.Expression{&ast.ObjectExpression{Properties: props}},
+		}
+	}
+	prop := func(key string, value ast.Expression) *ast.Property {
+		return &ast.Property{Key: &ast.Identifier{Name: key}, Value: value}
+	}
+	csvFrom := call(member("csv", "from"), prop("csv", &ast.Identifier{Name: "inData"}))
+	yield := call(&ast.Identifier{Name: "yield"})
+
+	a := NewTest("a", &ast.Package{Files: []*ast.File{{
+		Name: "a_test.flux",
+		Body: []ast.Statement{
+			&ast.VariableAssignment{ID: &ast.Identifier{Name: "outData"}, Init: outData},
+			&ast.VariableAssignment{ID: &ast.Identifier{Name: "got"}, Init: csvFrom},
+			&ast.ExpressionStatement{Expression: &ast.PipeExpression{
+				Argument: call(member("testing", "diff"),
+					prop("got", &ast.Identifier{Name: "got"}),
+					prop("want", call(member("csv", "from"), prop("csv", &ast.Identifier{Name: "outData"}))),
+				),
+				Call: yield,
+			}},
+		},
+	}}})
+	b := NewTest("b", &ast.Package{Files: []*ast.File{{
+		Name: "a_test.flux",
+		Body: []ast.Statement{
+			&ast.VariableAssignment{ID: &ast.Identifier{Name: "outData"}, Init: outData},
+			&ast.VariableAssignment{ID: &ast.Identifier{Name: "want"}, Init: call(member("testing", "loadMem"), prop("csv", inline))},
+			&ast.ExpressionStatement{Expression: &ast.PipeExpression{
+				Argument: call(member("testing", "diff"), prop("got", csvFrom), prop("want", nil)),
+				Call:     yield,
+			}},
+		},
+	}}})
+
+	executor := &fakeExecutor{output: "#datatype,string,long,double\r\n,result,table,_value\r\n,_result,0,1.5\r\n\r\n"}
+	var edits []sourceEdit
+	for _, test := range []*Test{&a, &b} {
+		edit, err := test.update(executor)
+		if err != nil {
+			t.Fatalf("unexpected error updating test %s: %s", test.Name(), err)
+		}
+		edits = append(edits, edit)
+	}
+
+	got, err := editSource([]byte(src), edits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `outData = "
+#datatype,string,long,double
+,result,table,_value
+,_result,0,1.5
+"
+testcase a {
+    got = csv.from(csv: inData)
+    testing.diff(got: got, want: csv.from(csv: outData)) |> yield()
+}
+testcase b {
+    want = testing.loadMem(csv: "#datatype,string,long,double
+,result,table,_value
+,_result,0,1.5")
+    testing.diff(got: csv.from(csv: inData), want) |> yield()
+}
+`
+	if !cmp.Equal(want, string(got)) {
+		t.Errorf("unexpected source -want/+got:\n%s", cmp.Diff(want, string(got)))
+	}
+
+	// Tests that share their want CSV must produce the same output.
+	conflict := edits[0]
+	conflict.text = `"other"`
+	if _, err := editSource([]byte(src), []sourceEdit{edits[0], conflict}); err == nil {
+		t.Error("expected error for conflicting edits")
+	}
+	// The source must not have changed since the test was parsed.
+	if _, err := editSource([]byte("outData = 1"), edits[:1]); err == nil {
+		t.Error("expected error editing a changed source")
+	}
+}
+
+func TestWantLiteral(t *testing.T) {
+	if want, got := `"a,\"b\",\\,\${c}"`, wantLiteral("a,\"b\",\\,${c}\r\n\r\n", "x"); want != got {
+		t.Errorf("unexpected literal -want/+got:\n\t- %s\n\t+ %s", want, got)
+	}
+}
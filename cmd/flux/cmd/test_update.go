@@ -0,0 +1,310 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/influxdata/flux/ast"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+// TestOutputExecutor is a TestExecutor that can also write the results of a program
+// as annotated CSV. It is required to update the expected output of tests.
+type TestOutputExecutor interface {
+	TestExecutor
+	Output(pkg *ast.Package, w io.Writer) error
+}
+
+// sourceEdit replaces the bytes of a file between two positions.
+type sourceEdit struct {
+	file       string
+	start, end ast.Position
+	// old is the source that is replaced, used to verify the position.
+	old  string
+	text string
+}
+
+// update runs the test with its actual output as the result and returns
+// the edit that replaces the CSV of the expected output with it.
+//
+// Only tests that compare their output with testing.diff to a want
+// that is read with csv.from or testing.loadMem from a string literal can be updated.
+func (t *Test) update(executor TestOutputExecutor) (sourceEdit, error) {
+	if len(t.ast.Files) == 0 {
+		return sourceEdit{}, errors.New(codes.Internal, "test has no files")
+	}
+	// The testcase body is the last file of the test package.
+	file := t.ast.Files[len(t.ast.Files)-1]
+
+	stmtIdx, got, want, err := findDiff(file)
+	if err != nil {
+		return sourceEdit{}, err
+	}
+	lit, err := findWantCSV(file, want, stmtIdx)
+	if err != nil {
+		return sourceEdit{}, err
+	}
+	if lit.Loc == nil {
+		return sourceEdit{}, errors.New(codes.FailedPrecondition, "cannot locate the want csv in the test source")
+	}
+
+	// Yield the output of the test instead of the diff.
+	pkg := yieldGot(t.ast, stmtIdx, got)
+	var buf bytes.Buffer
+	if err := executor.Output(pkg, &buf); err != nil {
+		return sourceEdit{}, errors.Wrap(err, codes.Inherit, "failed to compute the test output")
+	}
+	return sourceEdit{
+		file:  file.Name,
+		start: lit.Loc.Start,
+		end:   lit.Loc.End,
+		old:   lit.Loc.Source,
+		text:  wantLiteral(buf.String(), lit.Value),
+	}, nil
+}
+
+// yieldGot returns a copy of the test package that yields
+// the output of the test instead of the diff at the statement index.
+func yieldGot(pkg *ast.Package, stmtIdx int, got ast.Expression) *ast.Package {
+	pkg = pkg.Copy().(*ast.Package)
+	f := pkg.Files[len(pkg.Files)-1]
+	f.Body[stmtIdx] = &ast.ExpressionStatement{
+		Expression: &ast.PipeExpression{
+			Argument: got,
+			Call: &ast.CallExpression{
+				Callee: &ast.Identifier{Name: "yield"},
+				Arguments: []ast.Expression{&ast.ObjectExpression{
+					Properties: []*ast.Property{{
+						Key:   &ast.Identifier{Name: "name"},
+						Value: &ast.StringLiteral{Value: "_result"},
+					}},
+				}},
+			},
+		},
+	}
+	return pkg
+}
+
+// findDiff finds the statement that calls testing.diff and returns its index
+// with the expressions of the got and want arguments.
+func findDiff(file *ast.File) (int, ast.Expression, ast.Expression, error) {
+	idx := -1
+	var call *ast.CallExpression
+	var pipe ast.Expression
+	for i, stmt := range file.Body {
+		es, ok := stmt.(*ast.ExpressionStatement)
+		if !ok {
+			continue
+		}
+		c, p := findDiffCall(es.Expression)
+		if c == nil {
+			continue
+		}
+		if idx >= 0 {
+			return 0, nil, nil, errors.New(codes.FailedPrecondition, "cannot update a test with multiple calls to testing.diff")
+		}
+		idx, call, pipe = i, c, p
+	}
+	if idx < 0 {
+		return 0, nil, nil, errors.New(codes.FailedPrecondition, "cannot update a test without a call to testing.diff")
+	}
+
+	var got, want ast.Expression
+	got = pipe
+	if len(call.Arguments) == 1 {
+		if obj, ok := call.Arguments[0].(*ast.ObjectExpression); ok {
+			for _, p := range obj.Properties {
+				// A property without a value is shorthand for an identifier with its key.
+				v := p.Value
+				if v == nil {
+					v = &ast.Identifier{Name: p.Key.Key()}
+				}
+				switch p.Key.Key() {
+				case "got":
+					got = v
+				case "want":
+					want = v
+				}
+			}
+		}
+	}
+	if got == nil || want == nil {
+		return 0, nil, nil, errors.New(codes.FailedPrecondition, "cannot find the got and want arguments of testing.diff")
+	}
+	return idx, got, want, nil
+}
+
+// findDiffCall returns the call to testing.diff within the expression and the
+// expression that is piped into it, if any.
+// The call may be followed by other calls, such as yield.
+func findDiffCall(expr ast.Expression) (*ast.CallExpression, ast.Expression) {
+	switch e := expr.(type) {
+	case *ast.CallExpression:
+		if isMember(e.Callee, "testing", "diff") {
+			return e, nil
+		}
+	case *ast.PipeExpression:
+		if isMember(e.Call.Callee, "testing", "diff") {
+			return e.Call, e.Argument
+		}
+		return findDiffCall(e.Argument)
+	}
+	return nil, nil
+}
+
+// findWantCSV returns the string literal with the CSV that the want expression is read from.
+// Identifiers are resolved to the last assignment before the statement at index end.
+func findWantCSV(file *ast.File, want ast.Expression, end int) (*ast.StringLiteral, error) {
+	want = resolve(file, want, end)
+	call, ok := want.(*ast.CallExpression)
+	if !ok || !(isMember(call.Callee, "csv", "from") || isMember(call.Callee, "testing", "loadMem")) {
+		return nil, errors.New(codes.FailedPrecondition, "cannot update a test whose want is not read with csv.from or testing.loadMem")
+	}
+	if len(call.Arguments) == 1 {
+		if obj, ok := call.Arguments[0].(*ast.ObjectExpression); ok {
+			for _, p := range obj.Properties {
+				if p.Key.Key() != "csv" {
+					continue
+				}
+				var v ast.Expression = p.Value
+				if v == nil {
+					v = &ast.Identifier{Name: "csv"}
+				}
+				if lit, ok := resolve(file, v, end).(*ast.StringLiteral); ok {
+					return lit, nil
+				}
+			}
+		}
+	}
+	return nil, errors.New(codes.FailedPrecondition, "cannot update a test whose want csv is not a string literal")
+}
+
+// resolve follows identifiers to the expressions they are assigned.
+func resolve(file *ast.File, expr ast.Expression, end int) ast.Expression {
+	for {
+		id, ok := expr.(*ast.Identifier)
+		if !ok {
+			return expr
+		}
+		var init ast.Expression
+		for _, stmt := range file.Body[:end] {
+			if va, ok := stmt.(*ast.VariableAssignment); ok && va.ID.Name == id.Name {
+				init = va.Init
+			}
+		}
+		if init == nil {
+			return expr
+		}
+		expr = init
+	}
+}
+
+func isMember(expr ast.Expression, object, property string) bool {
+	m, ok := expr.(*ast.MemberExpression)
+	if !ok {
+		return false
+	}
+	obj, ok := m.Object.(*ast.Identifier)
+	return ok && obj.Name == object && m.Property.Key() == property
+}
+
+// wantLiteral formats the annotated CSV as a Flux string literal
+// that has the same leading and trailing newlines as the previous value.
+func wantLiteral(csv, prev string) string {
+	s := strings.TrimSpace(strings.Replace(csv, "\r\n", "\n", -1))
+	if strings.HasPrefix(prev, "\n") {
+		s = "\n" + s
+	}
+	if strings.HasSuffix(prev, "\n") {
+		s += "\n"
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "${", `\${`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// applySourceEdits applies the edits to the files they refer to.
+func applySourceEdits(edits []sourceEdit) error {
+	byFile := make(map[string][]sourceEdit)
+	for _, e := range edits {
+		byFile[e.file] = append(byFile[e.file], e)
+	}
+	for name, edits := range byFile {
+		src, err := ioutil.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		src, err = editSource(src, edits)
+		if err != nil {
+			return errors.Wrapf(err, codes.Inherit, "failed to update %s", name)
+		}
+		if err := ioutil.WriteFile(name, src, 0666); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// editSource applies the edits to the source. Edits of the same range
+// must have the same text, since several tests can share their expected output.
+func editSource(src []byte, edits []sourceEdit) ([]byte, error) {
+	type span struct {
+		start, end int
+		text       string
+	}
+	spans := make([]span, 0, len(edits))
+	for _, e := range edits {
+		start, end := offset(src, e.start), offset(src, e.end)
+		if start < 0 || end < start {
+			return nil, errors.Newf(codes.FailedPrecondition, "invalid source range %v-%v", e.start, e.end)
+		}
+		if e.old != "" && string(src[start:end]) != e.old {
+			return nil, errors.Newf(codes.FailedPrecondition, "source at %v-%v has changed", e.start, e.end)
+		}
+		spans = append(spans, span{start: start, end: end, text: e.text})
+	}
+	sort.Slice(spans, func(i, j int) bool {
+		return spans[i].start < spans[j].start
+	})
+
+	var buf bytes.Buffer
+	last := 0
+	for i, s := range spans {
+		if i > 0 && s.start < spans[i-1].end {
+			if s.start == spans[i-1].start && s.end == spans[i-1].end {
+				if s.text != spans[i-1].text {
+					return nil, errors.New(codes.FailedPrecondition, "tests that share their expected output have different results")
+				}
+				continue
+			}
+			return nil, errors.New(codes.FailedPrecondition, "overlapping source edits")
+		}
+		buf.Write(src[last:s.start])
+		buf.WriteString(s.text)
+		last = s.end
+	}
+	buf.Write(src[last:])
+	return buf.Bytes(), nil
+}
+
+// offset returns the byte offset of the position within the source,
+// or -1 if the source does not contain the position.
+func offset(src []byte, pos ast.Position) int {
+	line, off := 1, 0
+	for line < pos.Line {
+		i := bytes.IndexByte(src[off:], '\n')
+		if i < 0 {
+			return -1
+		}
+		off += i + 1
+		line++
+	}
+	off += pos.Column - 1
+	if off > len(src) {
+		return -1
+	}
+	return off
+}
@@ -3,7 +3,6 @@ package main
 import (
 	"github.com/influxdata/flux/cmd/flux/cmd"
 	"github.com/influxdata/flux/plan"
-	"github.com/influxdata/flux/stdlib/influxdata/influxdb"
 	"github.com/influxdata/flux/stdlib/universe"
 
 	// Register the sqlite3 database driver.
@@ -11,10 +10,9 @@ import (
 )
 
 func main() {
+	// The rule that applies the configured InfluxDB defaults
+	// is registered by the root command once the configuration is loaded.
 	plan.RegisterLogicalRules(
-		influxdb.DefaultFromAttributes{
-			Host: func(v string) *string { return &v }(cmd.DefaultInfluxDBHost),
-		},
 		universe.MergeFiltersRule{},
 		universe.OptimizeWindowRule{},
 	)
@@ -58,13 +58,16 @@ func Compile(scope Scope, f *semantic.FunctionExpression, in semantic.MonoType)
 		}
 	}
 
-	root, err := compile(f.Block, subst)
+	cov := new(coverage)
+	root, err := compile(f.Block, subst, cov)
 	if err != nil {
 		return nil, errors.Wrapf(err, codes.Inherit, "cannot compile @ %v", f.Location())
 	}
 	return compiledFn{
 		root:        root,
 		parentScope: scope,
+		loc:         f.Loc,
+		coverage:    cov,
 	}, nil
 }
 
@@ -390,12 +393,12 @@ func apply(sub map[uint64]semantic.MonoType, props []semantic.PropertyType, t se
 }
 
 // compile recursively compiles semantic nodes into evaluators.
-func compile(n semantic.Node, subst map[uint64]semantic.MonoType) (Evaluator, error) {
+func compile(n semantic.Node, subst map[uint64]semantic.MonoType, cov *coverage) (Evaluator, error) {
 	switch n := n.(type) {
 	case *semantic.Block:
 		body := make([]Evaluator, len(n.Body))
 		for i, s := range n.Body {
-			node, err := compile(s, subst)
+			node, err := compile(s, subst, cov)
 			if err != nil {
 				return nil, err
 			}
@@ -408,7 +411,7 @@ func compile(n semantic.Node, subst map[uint64]semantic.MonoType) (Evaluator, er
 	case *semantic.ExpressionStatement:
 		return nil, errors.New(codes.Internal, "statement does nothing, side effects are not supported by the compiler")
 	case *semantic.ReturnStatement:
-		node, err := compile(n.Argument, subst)
+		node, err := compile(n.Argument, subst, cov)
 		if err != nil {
 			return nil, err
 		}
@@ -416,7 +419,7 @@ func compile(n semantic.Node, subst map[uint64]semantic.MonoType) (Evaluator, er
 			Evaluator: node,
 		}, nil
 	case *semantic.NativeVariableAssignment:
-		node, err := compile(n.Init, subst)
+		node, err := compile(n.Init, subst, cov)
 		if err != nil {
 			return nil, err
 		}
@@ -429,7 +432,7 @@ func compile(n semantic.Node, subst map[uint64]semantic.MonoType) (Evaluator, er
 		properties := make(map[string]Evaluator, len(n.Properties))
 
 		for _, p := range n.Properties {
-			node, err := compile(p.Value, subst)
+			node, err := compile(p.Value, subst, cov)
 			if err != nil {
 				return nil, err
 			}
@@ -438,7 +441,7 @@ func compile(n semantic.Node, subst map[uint64]semantic.MonoType) (Evaluator, er
 
 		var extends *identifierEvaluator
 		if n.With != nil {
-			node, err := compile(n.With, subst)
+			node, err := compile(n.With, subst, cov)
 			if err != nil {
 				return nil, err
 			}
@@ -460,7 +463,7 @@ func compile(n semantic.Node, subst map[uint64]semantic.MonoType) (Evaluator, er
 		if len(n.Elements) > 0 {
 			elements = make([]Evaluator, len(n.Elements))
 			for i, e := range n.Elements {
-				node, err := compile(e, subst)
+				node, err := compile(e, subst, cov)
 				if err != nil {
 					return nil, err
 				}
@@ -477,11 +480,11 @@ func compile(n semantic.Node, subst map[uint64]semantic.MonoType) (Evaluator, er
 			Val Evaluator
 		}, len(n.Elements))
 		for i, item := range n.Elements {
-			key, err := compile(item.Key, subst)
+			key, err := compile(item.Key, subst, cov)
 			if err != nil {
 				return nil, err
 			}
-			val, err := compile(item.Val, subst)
+			val, err := compile(item.Val, subst, cov)
 			if err != nil {
 				return nil, err
 			}
@@ -500,7 +503,7 @@ func compile(n semantic.Node, subst map[uint64]semantic.MonoType) (Evaluator, er
 			name: n.Name.Name(),
 		}, nil
 	case *semantic.MemberExpression:
-		object, err := compile(n.Object, subst)
+		object, err := compile(n.Object, subst, cov)
 		if err != nil {
 			return nil, err
 		}
@@ -512,11 +515,11 @@ func compile(n semantic.Node, subst map[uint64]semantic.MonoType) (Evaluator, er
 			nullable: isNullable(t),
 		}, nil
 	case *semantic.IndexExpression:
-		arr, err := compile(n.Array, subst)
+		arr, err := compile(n.Array, subst, cov)
 		if err != nil {
 			return nil, err
 		}
-		idx, err := compile(n.Index, subst)
+		idx, err := compile(n.Index, subst, cov)
 		if err != nil {
 			return nil, err
 		}
@@ -528,7 +531,7 @@ func compile(n semantic.Node, subst map[uint64]semantic.MonoType) (Evaluator, er
 	case *semantic.StringExpression:
 		parts := make([]Evaluator, len(n.Parts))
 		for i, p := range n.Parts {
-			e, err := compile(p, subst)
+			e, err := compile(p, subst, cov)
 			if err != nil {
 				return nil, err
 			}
@@ -542,7 +545,7 @@ func compile(n semantic.Node, subst map[uint64]semantic.MonoType) (Evaluator, er
 			value: n.Value,
 		}, nil
 	case *semantic.InterpolatedPart:
-		e, err := compile(n.Expression, subst)
+		e, err := compile(n.Expression, subst, cov)
 		if err != nil {
 			return nil, err
 		}
@@ -586,7 +589,7 @@ func compile(n semantic.Node, subst map[uint64]semantic.MonoType) (Evaluator, er
 			duration: v,
 		}, nil
 	case *semantic.UnaryExpression:
-		node, err := compile(n.Argument, subst)
+		node, err := compile(n.Argument, subst, cov)
 		if err != nil {
 			return nil, err
 		}
@@ -596,11 +599,11 @@ func compile(n semantic.Node, subst map[uint64]semantic.MonoType) (Evaluator, er
 			op:   n.Operator,
 		}, nil
 	case *semantic.LogicalExpression:
-		l, err := compile(n.Left, subst)
+		l, err := compile(n.Left, subst, cov)
 		if err != nil {
 			return nil, err
 		}
-		r, err := compile(n.Right, subst)
+		r, err := compile(n.Right, subst, cov)
 		if err != nil {
 			return nil, err
 		}
@@ -610,15 +613,15 @@ func compile(n semantic.Node, subst map[uint64]semantic.MonoType) (Evaluator, er
 			right:    r,
 		}, nil
 	case *semantic.ConditionalExpression:
-		test, err := compile(n.Test, subst)
+		test, err := compile(n.Test, subst, cov)
 		if err != nil {
 			return nil, err
 		}
-		c, err := compile(n.Consequent, subst)
+		c, err := compile(n.Consequent, subst, cov)
 		if err != nil {
 			return nil, err
 		}
-		a, err := compile(n.Alternate, subst)
+		a, err := compile(n.Alternate, subst, cov)
 		if err != nil {
 			return nil, err
 		}
@@ -626,14 +629,16 @@ func compile(n semantic.Node, subst map[uint64]semantic.MonoType) (Evaluator, er
 			test:       test,
 			consequent: c,
 			alternate:  a,
+			loc:        n.Loc,
+			coverage:   cov,
 		}, nil
 	case *semantic.BinaryExpression:
-		l, err := compile(n.Left, subst)
+		l, err := compile(n.Left, subst, cov)
 		if err != nil {
 			return nil, err
 		}
 		lt := l.Type().Nature()
-		r, err := compile(n.Right, subst)
+		r, err := compile(n.Right, subst, cov)
 		if err != nil {
 			return nil, err
 		}
@@ -658,7 +663,7 @@ func compile(n semantic.Node, subst map[uint64]semantic.MonoType) (Evaluator, er
 			f:     f,
 		}, nil
 	case *semantic.CallExpression:
-		args, err := compile(n.Arguments, subst)
+		args, err := compile(n.Arguments, subst, cov)
 		if err != nil {
 			return nil, err
 		}
@@ -672,13 +677,13 @@ func compile(n semantic.Node, subst map[uint64]semantic.MonoType) (Evaluator, er
 				// This should be caught during type inference
 				return nil, errors.Newf(codes.Internal, "callee lacks a pipe argument, but one was provided")
 			}
-			pipe, err := compile(n.Pipe, subst)
+			pipe, err := compile(n.Pipe, subst, cov)
 			if err != nil {
 				return nil, err
 			}
 			args.(*objEvaluator).properties[string(pipeArg.Name())] = pipe
 		}
-		callee, err := compile(n.Callee, subst)
+		callee, err := compile(n.Callee, subst, cov)
 		if err != nil {
 			return nil, err
 		}
@@ -712,7 +717,7 @@ func compile(n semantic.Node, subst map[uint64]semantic.MonoType) (Evaluator, er
 				// Search for default value
 				for _, d := range n.Defaults.Properties {
 					if d.Key.Key() == k {
-						d, err := compile(d.Value, subst)
+						d, err := compile(d.Value, subst, cov)
 						if err != nil {
 							return nil, err
 						}
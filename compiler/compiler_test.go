@@ -8,10 +8,13 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/ast"
 	"github.com/influxdata/flux/codes"
 	"github.com/influxdata/flux/compiler"
 	_ "github.com/influxdata/flux/fluxinit/static"
 	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/interpreter"
+	"github.com/influxdata/flux/libflux/go/libflux"
 	"github.com/influxdata/flux/runtime"
 	"github.com/influxdata/flux/semantic"
 	"github.com/influxdata/flux/semantic/semantictest"
@@ -853,6 +856,42 @@ func TestCompiler_ReturnType(t *testing.T) {
 	}
 }
 
+func TestCompile_Coverage(t *testing.T) {
+	pkg, err := runtime.AnalyzePackage(libflux.Parse("a.flux", `(r) => if r.x then 1 else 2`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn := pkg.Files[0].Body[0].(*semantic.ExpressionStatement).Expression.(*semantic.FunctionExpression)
+	f, err := compiler.Compile(nil, fn, semantic.NewObjectType([]semantic.PropertyType{
+		{Key: []byte("r"), Value: semantic.NewObjectType([]semantic.PropertyType{
+			{Key: []byte("x"), Value: semantic.BasicBool},
+		})},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	coverage := interpreter.NewCoverage()
+	ctx := coverage.Inject(context.Background())
+	for _, x := range []bool{true, true, false} {
+		input := values.NewObjectWithValues(map[string]values.Value{
+			"r": values.NewObjectWithValues(map[string]values.Value{"x": values.NewBool(x)}),
+		})
+		if _, err := f.Eval(ctx, input); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []interpreter.CoverageBlock{
+		{Kind: interpreter.CoverageFunction, File: "a.flux", Start: ast.Position{Line: 1, Column: 1}, End: ast.Position{Line: 1, Column: 29}, Count: 3},
+		{Kind: interpreter.CoverageConsequent, File: "a.flux", Start: ast.Position{Line: 1, Column: 8}, End: ast.Position{Line: 1, Column: 29}, Count: 2},
+		{Kind: interpreter.CoverageAlternate, File: "a.flux", Start: ast.Position{Line: 1, Column: 8}, End: ast.Position{Line: 1, Column: 29}, Count: 1},
+	}
+	if got := coverage.Blocks(); !cmp.Equal(want, got) {
+		t.Errorf("unexpected coverage -want/+got:\n%s", cmp.Diff(want, got))
+	}
+}
+
 func TestToScopeNil(t *testing.T) {
 	if compiler.ToScope(nil) != nil {
 		t.Fatal("ToScope made non-nil scope from a nil base")
@@ -6,6 +6,7 @@ import (
 	"log"
 	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/influxdata/flux/ast"
 	"github.com/influxdata/flux/codes"
@@ -28,6 +29,26 @@ type Evaluator interface {
 type compiledFn struct {
 	root        Evaluator
 	parentScope Scope
+	// loc is the location of the function, which is recorded
+	// in the coverage of the context when it is called.
+	loc      semantic.Loc
+	coverage *coverage
+}
+
+// coverage holds the coverage that a compiled function and its
+// conditional expressions record their evaluation to. It is looked up
+// in the context when the function is first called, so evaluating
+// a row does not look it up again.
+type coverage struct {
+	once     sync.Once
+	coverage *interpreter.Coverage
+}
+
+// init looks up the coverage of the context on the first call.
+func (c *coverage) init(ctx context.Context) {
+	c.once.Do(func() {
+		c.coverage = interpreter.GetCoverage(ctx)
+	})
 }
 
 // Type returns the return type of the compiled function.
@@ -36,6 +57,9 @@ func (c compiledFn) Type() semantic.MonoType {
 }
 
 func (c compiledFn) Eval(ctx context.Context, input values.Object) (values.Value, error) {
+	c.coverage.init(ctx)
+	c.coverage.coverage.Record(interpreter.CoverageFunction, c.loc)
+
 	inputScope := nestScope(c.parentScope)
 	input.Range(func(k string, v values.Value) {
 		inputScope.Set(k, v)
@@ -321,6 +345,9 @@ type conditionalEvaluator struct {
 	test       Evaluator
 	consequent Evaluator
 	alternate  Evaluator
+	loc        semantic.Loc
+	// coverage is initialized by the function that contains the expression.
+	coverage *coverage
 }
 
 func (e *conditionalEvaluator) Type() semantic.MonoType {
@@ -337,8 +364,10 @@ func (e *conditionalEvaluator) Eval(ctx context.Context, scope Scope) (values.Va
 	}
 
 	if t.IsNull() || !t.Bool() {
+		e.coverage.coverage.Record(interpreter.CoverageAlternate, e.loc)
 		return eval(ctx, e.alternate, scope)
 	} else {
+		e.coverage.coverage.Record(interpreter.CoverageConsequent, e.loc)
 		return eval(ctx, e.consequent, scope)
 	}
 }
@@ -7,6 +7,7 @@ import (
 	"github.com/influxdata/flux/dependencies/bigtable"
 	"github.com/influxdata/flux/dependencies/filesystem"
 	"github.com/influxdata/flux/dependencies/influxdb"
+	"github.com/influxdata/flux/dependencies/materialized"
 	"github.com/influxdata/flux/dependencies/mqtt"
 )
 
@@ -15,10 +16,26 @@ type Dependencies struct {
 	influxdb influxdb.Dependency
 	bigtable bigtable.Dependency
 	mqtt     mqtt.Dependency
+
+	materialized materialized.Dependency
 }
 
 func (d Dependencies) Inject(ctx context.Context) context.Context {
-	return d.mqtt.Inject(d.bigtable.Inject(d.influxdb.Inject(d.Deps.Inject(ctx))))
+	return d.materialized.Inject(d.mqtt.Inject(d.bigtable.Inject(d.influxdb.Inject(d.Deps.Inject(ctx)))))
+}
+
+// WithInfluxDBProvider returns a copy of the dependencies
+// that reads from and writes to InfluxDB using the given Provider.
+func (d Dependencies) WithInfluxDBProvider(p influxdb.Provider) Dependencies {
+	d.influxdb = influxdb.Dependency{Provider: p}
+	return d
+}
+
+// WithMaterializedStateStore returns a copy of the dependencies
+// that keeps the state of materialized views in the given StateStore.
+func (d Dependencies) WithMaterializedStateStore(s materialized.StateStore) Dependencies {
+	d.materialized = materialized.Dependency{Store: s}
+	return d
 }
 
 func NewDefaultDependencies(defaultInfluxDBHost string) Dependencies {
@@ -43,6 +60,10 @@ func NewDefaultDependencies(defaultInfluxDBHost string) Dependencies {
 		mqtt: mqtt.Dependency{
 			Dialer: mqtt.DefaultDialer{},
 		},
+
+		materialized: materialized.Dependency{
+			Store: &materialized.MemoryStateStore{},
+		},
 	}
 }
 
@@ -63,5 +84,9 @@ func NewErrorDependencies() Dependencies {
 		mqtt: mqtt.Dependency{
 			Dialer: mqtt.ErrorDialer{},
 		},
+
+		materialized: materialized.Dependency{
+			Store: materialized.ErrorStateStore{},
+		},
 	}
 }
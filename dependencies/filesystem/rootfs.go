@@ -0,0 +1,106 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+// NewRootFS returns a Service that can only open files within root.
+//
+// Relative paths are resolved against root and absolute paths
+// must point inside of root. Paths that leave root, either through
+// ".." elements or through symbolic links, are rejected.
+func NewRootFS(root string) (Service, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return nil, err
+	}
+	return rootFS{root: resolved}, nil
+}
+
+type rootFS struct {
+	root string
+}
+
+func (fs rootFS) Open(fpath string) (File, error) {
+	p := fpath
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(fs.root, p)
+	}
+	p = filepath.Clean(p)
+
+	// Paths that resolve outside of the root are rejected without opening them.
+	resolved, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		if os.IsNotExist(err) && fs.contains(p) {
+			return nil, err
+		}
+		return nil, fs.errOutside(fpath)
+	}
+	if !fs.contains(resolved) {
+		return nil, fs.errOutside(fpath)
+	}
+
+	// A link may change between the check above and the open,
+	// so the opened file is validated again against its path.
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	if err := fs.validate(f, p); err != nil {
+		_ = f.Close()
+		if errors.Code(err) == codes.PermissionDenied {
+			return nil, fs.errOutside(fpath)
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+// validate checks that the opened file f is the file
+// that the path p resolves to within the root.
+func (fs rootFS) validate(f *os.File, p string) error {
+	opened, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	resolved, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return errors.Wrap(err, codes.PermissionDenied, "cannot resolve path")
+	}
+	if !fs.contains(resolved) {
+		return errors.New(codes.PermissionDenied, "path is outside of the root")
+	}
+	fi, err := os.Stat(resolved)
+	if err != nil {
+		return errors.Wrap(err, codes.PermissionDenied, "cannot stat path")
+	}
+	if !os.SameFile(opened, fi) {
+		return errors.New(codes.PermissionDenied, "path changed while it was opened")
+	}
+	return nil
+}
+
+// contains reports whether the cleaned absolute path p is within the root.
+func (fs rootFS) contains(p string) bool {
+	if p == fs.root {
+		return true
+	}
+	prefix := fs.root
+	if !strings.HasSuffix(prefix, string(filepath.Separator)) {
+		prefix += string(filepath.Separator)
+	}
+	return strings.HasPrefix(p, prefix)
+}
+
+func (fs rootFS) errOutside(fpath string) error {
+	return errors.Newf(codes.PermissionDenied, "path %q is outside of the allowed filesystem root", fpath)
+}
@@ -0,0 +1,75 @@
+package filesystem_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/dependencies/filesystem"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+func TestRootFS_Open(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flux-rootfs-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	root := filepath.Join(dir, "root")
+	if err := os.MkdirAll(filepath.Join(root, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{filepath.Join(root, "sub", "in.csv"), filepath.Join(dir, "out.csv")} {
+		if err := ioutil.WriteFile(name, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(dir, "out.csv"), filepath.Join(root, "link.csv")); err != nil {
+		t.Fatal(err)
+	}
+
+	fs, err := filesystem.NewRootFS(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		path string
+		code codes.Code
+	}{
+		{name: "relative", path: "sub/in.csv"},
+		{name: "absolute", path: filepath.Join(root, "sub", "in.csv")},
+		{name: "dot dot inside", path: "sub/../sub/in.csv"},
+		{name: "dot dot outside", path: "../out.csv", code: codes.PermissionDenied},
+		{name: "absolute outside", path: filepath.Join(dir, "out.csv"), code: codes.PermissionDenied},
+		{name: "symlink outside", path: "link.csv", code: codes.PermissionDenied},
+		{name: "missing outside", path: "../missing.csv", code: codes.PermissionDenied},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := fs.Open(tc.path)
+			if tc.code != codes.Inherit {
+				if err == nil {
+					_ = f.Close()
+					t.Fatal("expected error")
+				}
+				if got := errors.Code(err); got != tc.code {
+					t.Fatalf("unexpected error code -want/+got:\n\t- %v\n\t+ %v", tc.code, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = f.Close()
+		})
+	}
+
+	if _, err := fs.Open("sub/missing.csv"); !os.IsNotExist(err) {
+		t.Errorf("expected not exist error for missing file inside root, got %v", err)
+	}
+}
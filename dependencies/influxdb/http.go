@@ -2,6 +2,7 @@ package influxdb
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"io"
@@ -20,6 +21,7 @@ import (
 	"github.com/influxdata/flux/codes"
 	"github.com/influxdata/flux/csv"
 	"github.com/influxdata/flux/dependencies/http"
+	"github.com/influxdata/flux/internal/encoding"
 	"github.com/influxdata/flux/internal/errors"
 	"github.com/influxdata/flux/semantic"
 	"github.com/influxdata/flux/values"
@@ -50,6 +52,23 @@ func (h HttpProvider) ReaderFor(ctx context.Context, conf Config, bounds flux.Bo
 	}, nil
 }
 
+var _ TransformedReaderProvider = HttpProvider{}
+
+// TransformedReaderFor returns a Reader that pipes the read
+// into the transformations within the remote query.
+func (h HttpProvider) TransformedReaderFor(ctx context.Context, conf Config, bounds flux.Bounds, predicateSet PredicateSet, transformations Transformations) (Reader, error) {
+	c, err := h.clientFor(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+	return filteredHttpReader{
+		HttpClient:      c,
+		Bounds:          bounds,
+		PredicateSet:    predicateSet,
+		Transformations: transformations,
+	}, nil
+}
+
 func (h HttpProvider) SeriesCardinalityReaderFor(ctx context.Context, conf Config, bounds flux.Bounds, predicateSet PredicateSet) (Reader, error) {
 	// If any of the predicates use keep empty then they are not
 	// valid for series cardinality reader.
@@ -140,6 +159,9 @@ type HttpClient struct {
 // Query will create a new http.Request, send it to the server, then
 // decode the request as a flux.TableIterator and invoke the function with
 // each flux.Table.
+//
+// The response is decoded as it is read, so a table is only read from
+// the server when the function reads it.
 func (h *HttpClient) Query(ctx context.Context, f func(table flux.Table) error, file *ast.File, now time.Time, mem memory.Allocator) error {
 	req, err := h.newRequest(ctx, file, now)
 	if err != nil {
@@ -149,14 +171,53 @@ func (h *HttpClient) Query(ctx context.Context, f func(table flux.Table) error,
 	resp, err := h.Client.Do(req)
 	if err != nil {
 		return err
-	} else if resp.StatusCode != 200 {
-		data, err := ioutil.ReadAll(resp.Body)
+	}
+	return h.processResponse(resp, f, mem)
+}
+
+// maxErrorBodySize is the maximum size of an error response that is read.
+const maxErrorBodySize = 1 << 20
+
+// processResponse decodes the response body with the content encoding
+// and content type chosen by the server.
+func (h *HttpClient) processResponse(resp *stdhttp.Response, f func(flux.Table) error, mem memory.Allocator) error {
+	defer func() { _ = resp.Body.Close() }()
+
+	var body io.Reader = resp.Body
+	switch enc := resp.Header.Get("Content-Encoding"); enc {
+	case "", "identity":
+	case "gzip":
+		gr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return errors.Wrap(err, codes.Invalid, "error when reading gzip response body")
+		}
+		defer func() { _ = gr.Close() }()
+		body = gr
+	default:
+		return errors.Newf(codes.Invalid, "unsupported content encoding %q", enc)
+	}
+
+	if resp.StatusCode != 200 {
+		data, err := ioutil.ReadAll(io.LimitReader(body, maxErrorBodySize))
 		if err != nil {
 			return errors.Newf(codes.Invalid, "error when reading response body: %s", err)
 		}
 		return h.parseError(data)
 	}
-	return h.processResult(resp.Body, f, mem)
+
+	if mediaType(resp.Header.Get("Content-Type")) == encoding.ArrowContentType {
+		return h.processArrowResult(body, f, mem)
+	}
+	return h.processResult(ioutil.NopCloser(body), f, mem)
+}
+
+// mediaType returns the media type of a Content-Type header
+// without its parameters.
+func mediaType(contentType string) string {
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
 }
 
 // newFile constructs a new ast.File with the default values filled in.
@@ -249,6 +310,10 @@ func (h *HttpClient) newRequest(ctx context.Context, file *ast.File, now time.Ti
 		req.Header.Set("Authorization", "Token "+token)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	// The server may answer with the annotated csv requested in the
+	// dialect, or with arrow when it supports it.
+	req.Header.Set("Accept", encoding.ArrowContentType+", text/csv;q=0.9")
+	req.Header.Set("Accept-Encoding", "gzip")
 	return req.WithContext(ctx), nil
 }
 
@@ -299,6 +364,30 @@ func (h *HttpClient) processResult(r io.ReadCloser, f func(flux.Table) error, me
 	return results.Err()
 }
 
+// errSkipResults is returned to stop decoding
+// when the first result has been read.
+var errSkipResults = errors.New(codes.Canceled, "skip remaining results")
+
+// processArrowResult reads a single arrow encoded result from the io.Reader.
+// Produced tables are passed to the function. If there is more than one
+// result, this method will discard any additional results.
+func (h *HttpClient) processArrowResult(r io.Reader, f func(flux.Table) error, mem memory.Allocator) error {
+	var name *string
+	dec := encoding.NewArrowDecoder(mem)
+	err := dec.Decode(r, func(result string, tbl flux.Table) error {
+		if name == nil {
+			name = &result
+		} else if *name != result {
+			return errSkipResults
+		}
+		return f(tbl)
+	})
+	if err == errSkipResults {
+		return nil
+	}
+	return err
+}
+
 // parseError will parse an influxdb error.
 func (h *HttpClient) parseError(p []byte) error {
 	var e interface{}
@@ -346,8 +435,9 @@ func (h *HttpClient) includeImport(imports map[string]*ast.ImportDeclaration, na
 
 type filteredHttpReader struct {
 	*HttpClient
-	Bounds       flux.Bounds
-	PredicateSet PredicateSet
+	Bounds          flux.Bounds
+	PredicateSet    PredicateSet
+	Transformations Transformations
 }
 
 func (h filteredHttpReader) Read(ctx context.Context, f func(flux.Table) error, mem memory.Allocator) error {
@@ -393,6 +483,12 @@ func (h filteredHttpReader) Read(ctx context.Context, f func(flux.Table) error,
 			},
 		}
 	}
+	for _, t := range h.Transformations {
+		query = &ast.PipeExpression{
+			Argument: query,
+			Call:     t.Call,
+		}
+	}
 
 	file := h.newFile(imports)
 	file.Body = []ast.Statement{
@@ -2,6 +2,7 @@ package influxdb_test
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"fmt"
 	"io"
@@ -13,9 +14,15 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/ast"
 	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/csv"
 	"github.com/influxdata/flux/dependencies/dependenciestest"
 	"github.com/influxdata/flux/dependencies/influxdb"
+	"github.com/influxdata/flux/execute/executetest"
+	"github.com/influxdata/flux/internal/encoding"
+	"github.com/influxdata/flux/memory"
+	"github.com/influxdata/flux/values"
 	protocol "github.com/influxdata/line-protocol"
 )
 
@@ -231,3 +238,168 @@ func TestHttpWriter_Write_Error(t *testing.T) {
 		}
 	}
 }
+
+func TestHttpClient_Query(t *testing.T) {
+	csvBody := `#datatype,string,long,dateTime:RFC3339,string,double
+#group,false,false,false,true,false
+#default,_result,,,,
+,result,table,_time,host,_value
+,,0,2020-01-01T00:00:00Z,a,1
+,,1,2020-01-01T00:00:00Z,b,2
+
+#datatype,string,long,double
+#group,false,false,false
+#default,other,,
+,result,table,_value
+,,0,3
+`
+	want := []*executetest.Table{
+		{
+			KeyCols: []string{"host"},
+			ColMeta: []flux.ColMeta{
+				{Label: "_time", Type: flux.TTime},
+				{Label: "host", Type: flux.TString},
+				{Label: "_value", Type: flux.TFloat},
+			},
+			Data: [][]interface{}{
+				{values.ConvertTime(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)), "a", 1.0},
+			},
+		},
+		{
+			KeyCols: []string{"host"},
+			ColMeta: []flux.ColMeta{
+				{Label: "_time", Type: flux.TTime},
+				{Label: "host", Type: flux.TString},
+				{Label: "_value", Type: flux.TFloat},
+			},
+			Data: [][]interface{}{
+				{values.ConvertTime(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)), "b", 2.0},
+			},
+		},
+	}
+	executetest.NormalizeTables(want)
+
+	// The arrow body holds the same results as the csv body.
+	arrowBody := func() []byte {
+		dec := csv.NewMultiResultDecoder(csv.ResultDecoderConfig{})
+		results, err := dec.Decode(ioutil.NopCloser(strings.NewReader(csvBody)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		enc, err := encoding.NewMultiResultEncoder(encoding.Arrow)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if _, err := enc.Encode(&buf, results); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}()
+	gzipped := func(p []byte) []byte {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(p); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	for _, tt := range []struct {
+		name   string
+		header http.Header
+		body   []byte
+	}{
+		{
+			name:   "csv",
+			header: http.Header{"Content-Type": []string{"text/csv; charset=utf-8"}},
+			body:   []byte(csvBody),
+		},
+		{
+			name: "gzip csv",
+			header: http.Header{
+				"Content-Type":     []string{"text/csv; charset=utf-8"},
+				"Content-Encoding": []string{"gzip"},
+			},
+			body: gzipped([]byte(csvBody)),
+		},
+		{
+			name:   "arrow",
+			header: http.Header{"Content-Type": []string{encoding.ArrowContentType}},
+			body:   arrowBody,
+		},
+		{
+			name: "gzip arrow",
+			header: http.Header{
+				"Content-Type":     []string{encoding.ArrowContentType},
+				"Content-Encoding": []string{"gzip"},
+			},
+			body: gzipped(arrowBody),
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			roundTripper := &RoundTrip{
+				RequestValidator: func(req *http.Request) error {
+					if want, got := "gzip", req.Header.Get("Accept-Encoding"); want != got {
+						return fmt.Errorf("unexpected Accept-Encoding header -want/+got:\n\t- %v\n\t+ %v", want, got)
+					}
+					if got := req.Header.Get("Accept"); !strings.HasPrefix(got, encoding.ArrowContentType) {
+						return fmt.Errorf("expected arrow to be accepted, got Accept header %q", got)
+					}
+					return nil
+				},
+				HandlerFn: func(req *http.Request) (*http.Response, error) {
+					return &http.Response{
+						StatusCode: 200,
+						Body:       ioutil.NopCloser(bytes.NewReader(tt.body)),
+						Header:     tt.header,
+					}, nil
+				},
+			}
+			client := &influxdb.HttpClient{
+				Client: &http.Client{Transport: roundTripper},
+				Config: influxdb.Config{
+					Host:   "http://myhost.com:8085",
+					Org:    influxdb.NameOrID{Name: "myorg"},
+					Bucket: influxdb.NameOrID{Name: "mybucket"},
+				},
+			}
+			file := &ast.File{
+				Package: &ast.PackageClause{Name: &ast.Identifier{Name: "main"}},
+				Body: []ast.Statement{
+					&ast.ExpressionStatement{
+						Expression: &ast.CallExpression{Callee: &ast.Identifier{Name: "from"}},
+					},
+				},
+			}
+
+			mem := &memory.Allocator{}
+			var got []*executetest.Table
+			if err := client.Query(context.Background(), func(tbl flux.Table) error {
+				tb, err := executetest.ConvertTable(tbl)
+				if err != nil {
+					return err
+				}
+				got = append(got, tb)
+				return nil
+			}, file, time.Now(), mem); err != nil {
+				t.Fatal(err)
+			}
+			if err := roundTripper.RequestValidatorError; err != nil {
+				t.Error(err)
+			}
+			if n := mem.Allocated(); n != 0 {
+				t.Errorf("caught memory leak: %d bytes were not released", n)
+			}
+
+			executetest.NormalizeTables(got)
+			if !cmp.Equal(want, got) {
+				t.Errorf("unexpected tables -want/+got:\n%s", cmp.Diff(want, got))
+			}
+		})
+	}
+}
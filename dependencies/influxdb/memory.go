@@ -0,0 +1,788 @@
+package influxdb
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apache/arrow/go/arrow/memory"
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/array"
+	"github.com/influxdata/flux/arrow"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/compiler"
+	"github.com/influxdata/flux/csv"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/execute/table"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/values"
+	protocol "github.com/influxdata/line-protocol"
+)
+
+// The file extensions of the files read by OpenMemoryProvider.
+const (
+	lineProtocolExt = ".lp"
+	csvExt          = ".csv"
+)
+
+// MemoryProvider is an implementation of the Provider that stores
+// points in memory, so scripts that read from and write to influxdb
+// can run without an influxdb instance.
+//
+// Points are stored by organization and bucket, which are identified
+// by the ID or name in the configuration. The organization of the
+// DefaultConfig is used if a configuration has no organization.
+// The host and token are ignored. A bucket is created when points
+// are written to it.
+//
+// The zero value is an empty provider that is ready to use.
+type MemoryProvider struct {
+	DefaultConfig Config
+
+	// dir is the directory the buckets are saved to, if any.
+	dir string
+
+	mu      sync.RWMutex
+	buckets map[memoryBucketKey]*memoryBucket
+}
+
+var _ Provider = (*MemoryProvider)(nil)
+
+// OpenMemoryProvider returns a MemoryProvider that saves each bucket
+// as a line protocol file named after the bucket in the directory.
+// Buckets of the default organization are saved in the directory
+// and buckets of other organizations in a subdirectory named after
+// the organization.
+//
+// The line protocol and annotated CSV files in the directory are loaded
+// into the buckets named after them, so data can be prepared by
+// placing files in the directory. Points written to a bucket
+// are appended to its line protocol file.
+func OpenMemoryProvider(dir string, defaultConfig Config) (*MemoryProvider, error) {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+	p := &MemoryProvider{DefaultConfig: defaultConfig}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	org := defaultConfig.Org.IdOrName()
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			if err := p.loadFile(org, filepath.Join(dir, entry.Name())); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		name, err := url.PathUnescape(entry.Name())
+		if err != nil {
+			continue
+		}
+		files, err := ioutil.ReadDir(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			if f.IsDir() {
+				continue
+			}
+			if err := p.loadFile(name, filepath.Join(dir, entry.Name(), f.Name())); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Set the directory after loading so the files are not written again.
+	p.dir = dir
+	return p, nil
+}
+
+// loadFile loads a line protocol or annotated CSV file into the bucket named after it.
+// Other files are ignored.
+func (p *MemoryProvider) loadFile(org, name string) error {
+	ext := filepath.Ext(name)
+	if ext != lineProtocolExt && ext != csvExt {
+		return nil
+	}
+	bucket, err := url.PathUnescape(strings.TrimSuffix(filepath.Base(name), ext))
+	if err != nil {
+		return nil
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	var metrics []protocol.Metric
+	if ext == lineProtocolExt {
+		metrics, err = parseLineProtocol(f)
+	} else {
+		metrics, err = parseCSV(f)
+	}
+	if err != nil {
+		return errors.Wrapf(err, codes.Inherit, "failed to load %s", name)
+	}
+	return p.write(memoryBucketKey{org: org, bucket: bucket}, metrics)
+}
+
+// LoadLineProtocol writes the points of the line protocol
+// to the bucket of the configuration.
+// Timestamps are read with nanosecond precision.
+func (p *MemoryProvider) LoadLineProtocol(conf Config, r io.Reader) error {
+	key, err := p.bucketKey(conf)
+	if err != nil {
+		return err
+	}
+	metrics, err := parseLineProtocol(r)
+	if err != nil {
+		return err
+	}
+	return p.write(key, metrics)
+}
+
+// LoadCSV writes the rows of the annotated CSV to the bucket
+// of the configuration. The tables must have the _measurement,
+// _field, _time and _value columns. The other string columns
+// that do not start with an underscore are the tags of the points.
+// Rows with a null value are skipped.
+func (p *MemoryProvider) LoadCSV(conf Config, r io.Reader) error {
+	key, err := p.bucketKey(conf)
+	if err != nil {
+		return err
+	}
+	metrics, err := parseCSV(r)
+	if err != nil {
+		return err
+	}
+	return p.write(key, metrics)
+}
+
+func (p *MemoryProvider) ReaderFor(ctx context.Context, conf Config, bounds flux.Bounds, predicateSet PredicateSet) (Reader, error) {
+	key, err := p.bucketKey(conf)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.checkBucket(key); err != nil {
+		return nil, err
+	}
+	return memoryReader{
+		provider:     p,
+		key:          key,
+		Bounds:       bounds,
+		PredicateSet: predicateSet,
+	}, nil
+}
+
+func (p *MemoryProvider) SeriesCardinalityReaderFor(ctx context.Context, conf Config, bounds flux.Bounds, predicateSet PredicateSet) (Reader, error) {
+	// If any of the predicates use keep empty then they are not
+	// valid for series cardinality reader.
+	for _, pred := range predicateSet {
+		if pred.KeepEmpty {
+			return nil, errors.New(codes.Unimplemented, "keep empty filter option is not allowed for the series cardinality reader")
+		}
+	}
+
+	key, err := p.bucketKey(conf)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.checkBucket(key); err != nil {
+		return nil, err
+	}
+	return seriesCardinalityMemoryReader{
+		memoryReader: memoryReader{
+			provider:     p,
+			key:          key,
+			Bounds:       bounds,
+			PredicateSet: predicateSet,
+		},
+	}, nil
+}
+
+func (p *MemoryProvider) WriterFor(ctx context.Context, conf Config) (Writer, error) {
+	key, err := p.bucketKey(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &memoryWriter{provider: p, key: key}, nil
+}
+
+// bucketKey returns the organization and bucket of the configuration
+// with the defaults filled in.
+func (p *MemoryProvider) bucketKey(conf Config) (memoryBucketKey, error) {
+	if conf.Org.IsZero() {
+		conf.Org = p.DefaultConfig.Org
+	}
+	if conf.Bucket.IsZero() {
+		conf.Bucket = p.DefaultConfig.Bucket
+	}
+	if conf.Bucket.IsZero() {
+		return memoryBucketKey{}, errors.New(codes.Invalid, "influxdb memory provider requires a bucket to be specified")
+	}
+	return memoryBucketKey{
+		org:    conf.Org.IdOrName(),
+		bucket: conf.Bucket.IdOrName(),
+	}, nil
+}
+
+func (p *MemoryProvider) checkBucket(key memoryBucketKey) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if _, ok := p.buckets[key]; !ok {
+		return errors.Newf(codes.NotFound, "bucket %q not found", key.bucket)
+	}
+	return nil
+}
+
+// write stores the points of the metrics in the bucket and saves them to the
+// file of the bucket if the provider has a directory. The metrics are only
+// written if none of their fields conflicts with the type of an existing series.
+func (p *MemoryProvider) write(key memoryBucketKey, metrics []protocol.Metric) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	b := p.buckets[key]
+	if b == nil {
+		b = &memoryBucket{series: make(map[string]*memorySeries)}
+	}
+
+	// Check the types of the fields before any point is written.
+	types := make(map[string]flux.ColType)
+	for _, m := range metrics {
+		tags := sortedTags(m)
+		for _, f := range m.FieldList() {
+			typ, ok := fieldType(f.Value)
+			if !ok {
+				return errors.Newf(codes.Invalid, "unsupported type %T for field %q", f.Value, f.Key)
+			}
+			sk := seriesKey(m.Name(), tags, f.Key)
+			want, ok := types[sk]
+			if !ok {
+				if s := b.series[sk]; s != nil {
+					want, ok = s.typ, true
+				}
+			}
+			if ok && want != typ {
+				return errors.Newf(codes.Invalid, "field type conflict: field %q of measurement %q is of type %s, got %s", f.Key, m.Name(), want, typ)
+			}
+			types[sk] = typ
+		}
+	}
+
+	if p.dir != "" {
+		if err := p.save(key, metrics); err != nil {
+			return err
+		}
+	}
+
+	if p.buckets == nil {
+		p.buckets = make(map[memoryBucketKey]*memoryBucket)
+	}
+	p.buckets[key] = b
+
+	written := make(map[*memorySeries]bool)
+	for _, m := range metrics {
+		tags := sortedTags(m)
+		ts := m.Time().UnixNano()
+		for _, f := range m.FieldList() {
+			sk := seriesKey(m.Name(), tags, f.Key)
+			s := b.series[sk]
+			if s == nil {
+				s = &memorySeries{
+					measurement: m.Name(),
+					tags:        tags,
+					field:       f.Key,
+					typ:         types[sk],
+				}
+				b.series[sk] = s
+			}
+			s.times = append(s.times, ts)
+			s.values = append(s.values, values.New(f.Value))
+			written[s] = true
+		}
+	}
+	for s := range written {
+		s.sort()
+	}
+	return nil
+}
+
+// save appends the metrics to the line protocol file of the bucket.
+func (p *MemoryProvider) save(key memoryBucketKey, metrics []protocol.Metric) error {
+	dir := p.dir
+	if key.org != p.DefaultConfig.Org.IdOrName() {
+		dir = filepath.Join(dir, url.PathEscape(key.org))
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	enc := protocol.NewEncoder(&buf)
+	enc.SetFieldTypeSupport(protocol.UintSupport)
+	for _, m := range metrics {
+		if _, err := enc.Encode(m); err != nil {
+			return err
+		}
+	}
+
+	name := filepath.Join(dir, url.PathEscape(key.bucket)+lineProtocolExt)
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// points returns a copy of the points of each series
+// of the bucket between start and stop, ordered by series key.
+func (p *MemoryProvider) points(key memoryBucketKey, start, stop int64) []memoryPoints {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	b := p.buckets[key]
+	if b == nil {
+		return nil
+	}
+	keys := make([]string, 0, len(b.series))
+	for sk := range b.series {
+		keys = append(keys, sk)
+	}
+	sort.Strings(keys)
+
+	points := make([]memoryPoints, 0, len(keys))
+	for _, sk := range keys {
+		s := b.series[sk]
+		i := sort.Search(len(s.times), func(i int) bool { return s.times[i] >= start })
+		j := sort.Search(len(s.times), func(i int) bool { return s.times[i] >= stop })
+		if i >= j {
+			continue
+		}
+		points = append(points, memoryPoints{
+			series: s,
+			times:  append([]int64(nil), s.times[i:j]...),
+			values: append([]values.Value(nil), s.values[i:j]...),
+		})
+	}
+	return points
+}
+
+type memoryBucketKey struct {
+	org, bucket string
+}
+
+type memoryBucket struct {
+	series map[string]*memorySeries
+}
+
+// memorySeries holds the points of a field of a series ordered by time.
+// Only the times and values change after the series is created.
+type memorySeries struct {
+	measurement string
+	tags        []*protocol.Tag
+	field       string
+	typ         flux.ColType
+	times       []int64
+	values      []values.Value
+}
+
+// sort orders the points by time. Of the points with the same time,
+// the last one that was written is kept.
+func (s *memorySeries) sort() {
+	sort.Stable(s)
+	n := 0
+	for i := range s.times {
+		if n > 0 && s.times[n-1] == s.times[i] {
+			n--
+		}
+		s.times[n], s.values[n] = s.times[i], s.values[i]
+		n++
+	}
+	s.times, s.values = s.times[:n], s.values[:n]
+}
+
+func (s *memorySeries) Len() int           { return len(s.times) }
+func (s *memorySeries) Less(i, j int) bool { return s.times[i] < s.times[j] }
+func (s *memorySeries) Swap(i, j int) {
+	s.times[i], s.times[j] = s.times[j], s.times[i]
+	s.values[i], s.values[j] = s.values[j], s.values[i]
+}
+
+// memoryPoints holds a copy of some points of a series.
+type memoryPoints struct {
+	series *memorySeries
+	times  []int64
+	values []values.Value
+}
+
+type memoryReader struct {
+	provider     *MemoryProvider
+	key          memoryBucketKey
+	Bounds       flux.Bounds
+	PredicateSet PredicateSet
+}
+
+func (r memoryReader) Read(ctx context.Context, f func(flux.Table) error, mem memory.Allocator) error {
+	start, stop := r.timeRange()
+	fns := r.predicates()
+	for _, points := range r.provider.points(r.key, int64(start), int64(stop)) {
+		buf, err := r.filter(ctx, points, start, stop, fns, mem)
+		if err != nil {
+			return err
+		} else if buf == nil {
+			continue
+		}
+		if err := f(table.FromBuffer(buf)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// timeRange returns the start and stop of the bounds.
+// A bounds without a stop ends now.
+func (r memoryReader) timeRange() (start, stop values.Time) {
+	now := r.Bounds.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	start = values.ConvertTime(r.Bounds.Start.Time(now))
+	stop = values.ConvertTime(now)
+	if !r.Bounds.Stop.IsZero() {
+		stop = values.ConvertTime(r.Bounds.Stop.Time(now))
+	}
+	return start, stop
+}
+
+func (r memoryReader) predicates() []*execute.RowPredicateFn {
+	fns := make([]*execute.RowPredicateFn, len(r.PredicateSet))
+	for i, p := range r.PredicateSet {
+		fns[i] = execute.NewRowPredicateFn(p.Fn, compiler.ToScope(p.Scope))
+	}
+	return fns
+}
+
+// filter returns the table of the points with the rows that pass all predicates,
+// or nil if the table is dropped. Like filter, a predicate that removes all rows
+// drops the table unless the predicate keeps empty tables.
+func (r memoryReader) filter(ctx context.Context, points memoryPoints, start, stop values.Time, fns []*execute.RowPredicateFn, mem memory.Allocator) (*arrow.TableBuffer, error) {
+	key, cols := seriesTable(points.series, start, stop)
+	rows := make([]int, len(points.times))
+	for i := range rows {
+		rows[i] = i
+	}
+	buf := newMemoryBuffer(key, cols, points, rows, mem)
+	if len(fns) == 0 {
+		return buf, nil
+	}
+
+	for i, fn := range fns {
+		prepared, err := fn.Prepare(cols)
+		if err != nil {
+			buf.Release()
+			return nil, err
+		}
+		n := 0
+		for _, row := range rows {
+			ok, err := prepared.EvalRow(ctx, row, buf)
+			if err != nil {
+				buf.Release()
+				return nil, errors.Wrap(err, codes.Inherit, "failed to evaluate filter function")
+			}
+			if ok {
+				rows[n] = row
+				n++
+			}
+		}
+		rows = rows[:n]
+		if n == 0 && !r.PredicateSet[i].KeepEmpty {
+			buf.Release()
+			return nil, nil
+		}
+	}
+	if len(rows) == len(points.times) {
+		return buf, nil
+	}
+	buf.Release()
+	return newMemoryBuffer(key, cols, points, rows, mem), nil
+}
+
+// seriesTable returns the group key and columns of the table of a series,
+// which are the same as the tables read from influxdb.
+func seriesTable(s *memorySeries, start, stop values.Time) (flux.GroupKey, []flux.ColMeta) {
+	keyCols := []flux.ColMeta{
+		{Label: execute.DefaultStartColLabel, Type: flux.TTime},
+		{Label: execute.DefaultStopColLabel, Type: flux.TTime},
+		{Label: "_field", Type: flux.TString},
+		{Label: "_measurement", Type: flux.TString},
+	}
+	keyValues := []values.Value{
+		values.NewTime(start),
+		values.NewTime(stop),
+		values.NewString(s.field),
+		values.NewString(s.measurement),
+	}
+	cols := []flux.ColMeta{
+		keyCols[0],
+		keyCols[1],
+		{Label: execute.DefaultTimeColLabel, Type: flux.TTime},
+		{Label: execute.DefaultValueColLabel, Type: s.typ},
+		keyCols[2],
+		keyCols[3],
+	}
+	for _, tag := range s.tags {
+		col := flux.ColMeta{Label: tag.Key, Type: flux.TString}
+		keyCols = append(keyCols, col)
+		keyValues = append(keyValues, values.NewString(tag.Value))
+		cols = append(cols, col)
+	}
+	return execute.NewGroupKey(keyCols, keyValues), cols
+}
+
+// newMemoryBuffer builds a buffer with the rows of the points.
+func newMemoryBuffer(key flux.GroupKey, cols []flux.ColMeta, points memoryPoints, rows []int, mem memory.Allocator) *arrow.TableBuffer {
+	vs := make([]array.Interface, len(cols))
+	for j, c := range cols {
+		switch c.Label {
+		case execute.DefaultTimeColLabel:
+			b := array.NewIntBuilder(mem)
+			b.Resize(len(rows))
+			for _, i := range rows {
+				b.Append(points.times[i])
+			}
+			vs[j] = b.NewArray()
+		case execute.DefaultValueColLabel:
+			b := arrow.NewBuilder(c.Type, mem)
+			b.Resize(len(rows))
+			for _, i := range rows {
+				// The builder has the type of the series.
+				_ = arrow.AppendValue(b, points.values[i])
+			}
+			vs[j] = b.NewArray()
+		default:
+			vs[j] = arrow.Repeat(c.Type, key.LabelValue(c.Label), len(rows), mem)
+		}
+	}
+	return &arrow.TableBuffer{
+		GroupKey: key,
+		Columns:  cols,
+		Values:   vs,
+	}
+}
+
+type seriesCardinalityMemoryReader struct {
+	memoryReader
+}
+
+// Read produces a table with the number of series
+// that have points within the bounds that pass the predicates.
+func (r seriesCardinalityMemoryReader) Read(ctx context.Context, f func(flux.Table) error, mem memory.Allocator) error {
+	start, stop := r.timeRange()
+	fns := r.predicates()
+	var n int64
+	for _, points := range r.provider.points(r.key, int64(start), int64(stop)) {
+		buf, err := r.filter(ctx, points, start, stop, fns, mem)
+		if err != nil {
+			return err
+		} else if buf == nil {
+			continue
+		}
+		buf.Release()
+		n++
+	}
+
+	key := execute.NewGroupKey(nil, nil)
+	return f(table.FromBuffer(&arrow.TableBuffer{
+		GroupKey: key,
+		Columns:  []flux.ColMeta{{Label: execute.DefaultValueColLabel, Type: flux.TInt}},
+		Values:   []array.Interface{array.IntRepeat(n, false, 1, mem)},
+	}))
+}
+
+// memoryWriter writes the points of each batch of metrics to the bucket.
+type memoryWriter struct {
+	provider *MemoryProvider
+	key      memoryBucketKey
+}
+
+var _ Writer = &memoryWriter{}
+
+func (w *memoryWriter) Write(metrics ...protocol.Metric) error {
+	return w.provider.write(w.key, metrics)
+}
+
+func (w *memoryWriter) Close() error {
+	return nil
+}
+
+func parseLineProtocol(r io.Reader) ([]protocol.Metric, error) {
+	parser := protocol.NewStreamParser(r)
+	var metrics []protocol.Metric
+	for {
+		m, err := parser.Next()
+		if err == protocol.EOF {
+			return metrics, nil
+		} else if err != nil {
+			return nil, errors.Wrap(err, codes.Invalid, "invalid line protocol")
+		}
+		metrics = append(metrics, m)
+	}
+}
+
+// parseCSV reads each row of the annotated CSV as a metric with a single field.
+func parseCSV(r io.Reader) ([]protocol.Metric, error) {
+	dec := csv.NewMultiResultDecoder(csv.ResultDecoderConfig{})
+	results, err := dec.Decode(ioutil.NopCloser(r))
+	if err != nil {
+		return nil, err
+	}
+	defer results.Release()
+
+	var metrics []protocol.Metric
+	for results.More() {
+		if err := results.Next().Tables().Do(func(tbl flux.Table) error {
+			ms, err := tableMetrics(tbl)
+			if err != nil {
+				return err
+			}
+			metrics = append(metrics, ms...)
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	results.Release()
+	if err := results.Err(); err != nil {
+		return nil, err
+	}
+	return metrics, nil
+}
+
+func tableMetrics(tbl flux.Table) ([]protocol.Metric, error) {
+	cols := tbl.Cols()
+	idx := make(map[string]int, 4)
+	for _, label := range []string{"_measurement", "_field", execute.DefaultTimeColLabel, execute.DefaultValueColLabel} {
+		j := execute.ColIdx(label, cols)
+		if j < 0 {
+			return nil, errors.Newf(codes.Invalid, "table is missing the %q column", label)
+		}
+		idx[label] = j
+	}
+	if cols[idx["_measurement"]].Type != flux.TString || cols[idx["_field"]].Type != flux.TString {
+		return nil, errors.New(codes.Invalid, "the _measurement and _field columns must be strings")
+	} else if cols[idx[execute.DefaultTimeColLabel]].Type != flux.TTime {
+		return nil, errors.New(codes.Invalid, "the _time column must be a time")
+	}
+	var tagCols []int
+	for j, c := range cols {
+		if c.Type == flux.TString && !strings.HasPrefix(c.Label, "_") {
+			tagCols = append(tagCols, j)
+		}
+	}
+
+	var metrics []protocol.Metric
+	err := tbl.Do(func(cr flux.ColReader) error {
+		for i := 0; i < cr.Len(); i++ {
+			v := execute.ValueForRow(cr, i, idx[execute.DefaultValueColLabel])
+			if v.IsNull() {
+				continue
+			}
+			field, err := fieldValue(v)
+			if err != nil {
+				return err
+			}
+			tags := make(map[string]string, len(tagCols))
+			for _, j := range tagCols {
+				if tv := execute.ValueForRow(cr, i, j); !tv.IsNull() {
+					tags[cols[j].Label] = tv.Str()
+				}
+			}
+			measurement := execute.ValueForRow(cr, i, idx["_measurement"])
+			fieldKey := execute.ValueForRow(cr, i, idx["_field"])
+			ts := execute.ValueForRow(cr, i, idx[execute.DefaultTimeColLabel])
+			if measurement.IsNull() || fieldKey.IsNull() || ts.IsNull() {
+				return errors.New(codes.Invalid, "the _measurement, _field and _time of a row must not be null")
+			}
+			m, err := protocol.New(measurement.Str(), tags, map[string]interface{}{fieldKey.Str(): field}, ts.Time().Time())
+			if err != nil {
+				return err
+			}
+			metrics = append(metrics, m)
+		}
+		return nil
+	})
+	return metrics, err
+}
+
+func fieldValue(v values.Value) (interface{}, error) {
+	switch v.Type().Nature() {
+	case flux.SemanticType(flux.TInt).Nature():
+		return v.Int(), nil
+	case flux.SemanticType(flux.TUInt).Nature():
+		return v.UInt(), nil
+	case flux.SemanticType(flux.TFloat).Nature():
+		return v.Float(), nil
+	case flux.SemanticType(flux.TString).Nature():
+		return v.Str(), nil
+	case flux.SemanticType(flux.TBool).Nature():
+		return v.Bool(), nil
+	default:
+		return nil, errors.Newf(codes.Invalid, "unsupported field type %s", v.Type())
+	}
+}
+
+func fieldType(v interface{}) (flux.ColType, bool) {
+	switch v.(type) {
+	case int64:
+		return flux.TInt, true
+	case uint64:
+		return flux.TUInt, true
+	case float64:
+		return flux.TFloat, true
+	case string:
+		return flux.TString, true
+	case bool:
+		return flux.TBool, true
+	default:
+		return flux.TInvalid, false
+	}
+}
+
+func sortedTags(m protocol.Metric) []*protocol.Tag {
+	tags := append([]*protocol.Tag(nil), m.TagList()...)
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Key < tags[j].Key })
+	return tags
+}
+
+// seriesKey returns the key of a field of a series. Series keys
+// sort by measurement, then by tags and then by field.
+func seriesKey(measurement string, tags []*protocol.Tag, field string) string {
+	var sb strings.Builder
+	sb.WriteString(measurement)
+	for _, tag := range tags {
+		sb.WriteByte(0)
+		sb.WriteString(tag.Key)
+		sb.WriteByte(0)
+		sb.WriteString(tag.Value)
+	}
+	sb.WriteByte(1)
+	sb.WriteString(field)
+	return sb.String()
+}
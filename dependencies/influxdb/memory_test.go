@@ -0,0 +1,283 @@
+package influxdb_test
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/dependencies/influxdb"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/execute/executetest"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/memory"
+	"github.com/influxdata/flux/values"
+	protocol "github.com/influxdata/line-protocol"
+)
+
+var memoryConfig = influxdb.Config{
+	Org:    influxdb.NameOrID{Name: "myorg"},
+	Bucket: influxdb.NameOrID{Name: "mybucket"},
+}
+
+func readMemory(t *testing.T, r influxdb.Reader) []*executetest.Table {
+	t.Helper()
+	mem := &memory.Allocator{}
+	var tables []*executetest.Table
+	if err := r.Read(context.Background(), func(tbl flux.Table) error {
+		tb, err := executetest.ConvertTable(tbl)
+		if err != nil {
+			return err
+		}
+		tables = append(tables, tb)
+		return nil
+	}, mem); err != nil {
+		t.Fatal(err)
+	}
+	if got := mem.Allocated(); got != 0 {
+		t.Errorf("caught memory leak: %d bytes were not released", got)
+	}
+	executetest.NormalizeTables(tables)
+	return tables
+}
+
+func mustParseTime(t *testing.T, s string) values.Time {
+	t.Helper()
+	ts, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return values.ConvertTime(ts)
+}
+
+func TestMemoryProvider_ReaderFor(t *testing.T) {
+	p := &influxdb.MemoryProvider{DefaultConfig: influxdb.Config{Org: memoryConfig.Org}}
+	if err := p.LoadLineProtocol(influxdb.Config{Bucket: memoryConfig.Bucket}, strings.NewReader(`
+cpu,host=b usage=1 1577836800000000000
+cpu,host=a usage=2 1577836810000000000
+cpu,host=a usage=1 1577836800000000000
+cpu,host=a usage=3 1577836810000000000
+cpu,host=a usage=4 1577836820000000000
+mem used=10i 1577836800000000000
+`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := p.ReaderFor(context.Background(), influxdb.Config{
+		Bucket: influxdb.NameOrID{Name: "missing"},
+	}, flux.Bounds{}, nil); err == nil {
+		t.Fatal("expected error for a missing bucket")
+	} else if want, got := codes.NotFound, errors.Code(err); want != got {
+		t.Errorf("unexpected error code -want/+got:\n\t- %v\n\t+ %v", want, got)
+	}
+
+	start, stop := mustParseTime(t, "2020-01-01T00:00:00Z"), mustParseTime(t, "2020-01-01T00:00:20Z")
+	r, err := p.ReaderFor(context.Background(), memoryConfig, flux.Bounds{
+		Start: flux.Time{Absolute: start.Time()},
+		Stop:  flux.Time{Absolute: stop.Time()},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cols := func(typ flux.ColType, tags ...string) []flux.ColMeta {
+		cols := []flux.ColMeta{
+			{Label: "_start", Type: flux.TTime},
+			{Label: "_stop", Type: flux.TTime},
+			{Label: "_time", Type: flux.TTime},
+			{Label: "_value", Type: typ},
+			{Label: "_field", Type: flux.TString},
+			{Label: "_measurement", Type: flux.TString},
+		}
+		for _, tag := range tags {
+			cols = append(cols, flux.ColMeta{Label: tag, Type: flux.TString})
+		}
+		return cols
+	}
+	want := []*executetest.Table{
+		{
+			KeyCols: []string{"_start", "_stop", "_field", "_measurement", "host"},
+			ColMeta: cols(flux.TFloat, "host"),
+			Data: [][]interface{}{
+				// The last point written at a time is kept.
+				{start, stop, mustParseTime(t, "2020-01-01T00:00:00Z"), 1.0, "usage", "cpu", "a"},
+				{start, stop, mustParseTime(t, "2020-01-01T00:00:10Z"), 3.0, "usage", "cpu", "a"},
+			},
+		},
+		{
+			KeyCols: []string{"_start", "_stop", "_field", "_measurement", "host"},
+			ColMeta: cols(flux.TFloat, "host"),
+			Data: [][]interface{}{
+				{start, stop, mustParseTime(t, "2020-01-01T00:00:00Z"), 1.0, "usage", "cpu", "b"},
+			},
+		},
+		{
+			KeyCols: []string{"_start", "_stop", "_field", "_measurement"},
+			ColMeta: cols(flux.TInt),
+			Data: [][]interface{}{
+				{start, stop, mustParseTime(t, "2020-01-01T00:00:00Z"), int64(10), "used", "mem"},
+			},
+		},
+	}
+	executetest.NormalizeTables(want)
+	if got := readMemory(t, r); !cmp.Equal(want, got) {
+		t.Errorf("unexpected tables -want/+got:\n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestMemoryProvider_FieldTypeConflict(t *testing.T) {
+	p := &influxdb.MemoryProvider{}
+	w, err := p.WriterFor(context.Background(), memoryConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := time.Unix(0, 0)
+	m := func(v interface{}) protocol.Metric {
+		m, err := protocol.New("cpu", nil, map[string]interface{}{"usage": v}, ts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+	if err := w.Write(m(1.0)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The batch is rejected as a whole.
+	if err := w.Write(m(2.0), m(int64(3))); err == nil {
+		t.Fatal("expected error for a field type conflict")
+	} else if want, got := codes.Invalid, errors.Code(err); want != got {
+		t.Errorf("unexpected error code -want/+got:\n\t- %v\n\t+ %v", want, got)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := p.ReaderFor(context.Background(), memoryConfig, flux.Bounds{
+		Start: flux.Time{Absolute: ts},
+		Stop:  flux.Time{Absolute: ts.Add(time.Second)},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tables := readMemory(t, r)
+	if len(tables) != 1 || len(tables[0].Data) != 1 {
+		t.Fatalf("expected a single row, got %v", tables)
+	}
+	j := execute.ColIdx("_value", tables[0].ColMeta)
+	if want, got := 1.0, tables[0].Data[0][j]; want != got {
+		t.Errorf("unexpected value -want/+got:\n\t- %v\n\t+ %v", want, got)
+	}
+}
+
+func TestMemoryProvider_LoadCSV(t *testing.T) {
+	p := &influxdb.MemoryProvider{}
+	if err := p.LoadCSV(memoryConfig, strings.NewReader(`#datatype,string,long,dateTime:RFC3339,string,string,string,double
+#group,false,false,false,true,true,true,false
+#default,_result,,,,,,
+,result,table,_time,_measurement,_field,host,_value
+,,0,2020-01-01T00:00:00Z,cpu,usage,a,1
+,,0,2020-01-01T00:00:10Z,cpu,usage,a,
+,,1,2020-01-01T00:00:00Z,cpu,usage,b,2
+`)); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := p.SeriesCardinalityReaderFor(context.Background(), memoryConfig, flux.Bounds{
+		Start: flux.Time{Absolute: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+		Stop:  flux.Time{Absolute: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*executetest.Table{{
+		ColMeta: []flux.ColMeta{{Label: "_value", Type: flux.TInt}},
+		Data:    [][]interface{}{{int64(2)}},
+	}}
+	executetest.NormalizeTables(want)
+	if got := readMemory(t, r); !cmp.Equal(want, got) {
+		t.Errorf("unexpected tables -want/+got:\n%s", cmp.Diff(want, got))
+	}
+
+	if err := p.LoadCSV(memoryConfig, strings.NewReader(`#datatype,string,long,dateTime:RFC3339,double
+#group,false,false,false,false
+#default,_result,,,
+,result,table,_time,_value
+,,0,2020-01-01T00:00:00Z,1
+`)); err == nil {
+		t.Fatal("expected error for a table without measurement")
+	} else if want, got := codes.Invalid, errors.Code(err); want != got {
+		t.Errorf("unexpected error code -want/+got:\n\t- %v\n\t+ %v", want, got)
+	}
+}
+
+func TestOpenMemoryProvider(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "loaded.lp"), []byte("cpu usage=1 0\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := influxdb.OpenMemoryProvider(dir, influxdb.Config{Org: memoryConfig.Org})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, conf := range []influxdb.Config{
+		{Bucket: memoryConfig.Bucket},
+		{Org: influxdb.NameOrID{Name: "other"}, Bucket: memoryConfig.Bucket},
+	} {
+		w, err := p.WriterFor(context.Background(), conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := protocol.New("mem", map[string]string{"host": "a"}, map[string]interface{}{"used": uint64(10)}, time.Unix(0, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Write(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The loaded file is not written again.
+	for name, want := range map[string]string{
+		"loaded.lp":         "cpu usage=1 0\n",
+		"mybucket.lp":       "mem,host=a used=10u 0\n",
+		"other/mybucket.lp": "mem,host=a used=10u 0\n",
+	} {
+		got, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want != string(got) {
+			t.Errorf("unexpected content of %s -want/+got:\n\t- %q\n\t+ %q", name, want, got)
+		}
+	}
+
+	// A provider that is opened again holds the same buckets.
+	p, err = influxdb.OpenMemoryProvider(dir, influxdb.Config{Org: memoryConfig.Org})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bounds := flux.Bounds{
+		Start: flux.Time{Absolute: time.Unix(0, 0)},
+		Stop:  flux.Time{Absolute: time.Unix(1, 0)},
+	}
+	for _, conf := range []influxdb.Config{
+		{Bucket: influxdb.NameOrID{Name: "loaded"}},
+		memoryConfig,
+		{Org: influxdb.NameOrID{Name: "other"}, Bucket: memoryConfig.Bucket},
+	} {
+		r, err := p.ReaderFor(context.Background(), conf, bounds, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tables := readMemory(t, r); len(tables) != 1 || len(tables[0].Data) != 1 {
+			t.Errorf("expected a single row in bucket %s, got %v", conf.Bucket.IdOrName(), tables)
+		}
+	}
+}
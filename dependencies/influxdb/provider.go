@@ -6,6 +6,7 @@ import (
 
 	"github.com/apache/arrow/go/arrow/memory"
 	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/ast"
 	"github.com/influxdata/flux/codes"
 	"github.com/influxdata/flux/internal/errors"
 	"github.com/influxdata/flux/interpreter"
@@ -77,6 +78,35 @@ func (ps PredicateSet) Copy() PredicateSet {
 	return nps
 }
 
+// Transformation is a call to a transformation that is applied
+// to the tables of a read within the remote query, such as limit(n: 10).
+// The tables of the read are piped into the call.
+type Transformation struct {
+	Call *ast.CallExpression
+}
+
+// Copy produces a deep copy of the Transformation.
+func (t *Transformation) Copy() Transformation {
+	return Transformation{Call: t.Call.Copy().(*ast.CallExpression)}
+}
+
+// Transformations holds the transformations that are applied
+// to the tables of a read in order.
+type Transformations []Transformation
+
+// Copy produces a deep copy of the Transformations.
+func (ts Transformations) Copy() Transformations {
+	if ts == nil {
+		return nil
+	}
+
+	nts := make([]Transformation, len(ts))
+	for i := range ts {
+		nts[i] = ts[i].Copy()
+	}
+	return nts
+}
+
 // Provider is an interface for creating a Reader that will read
 // data from an influxdb instance.
 //
@@ -100,6 +130,18 @@ type Provider interface {
 	WriterFor(ctx context.Context, conf Config) (Writer, error)
 }
 
+// TransformedReaderProvider is an optional interface for a Provider
+// that can apply transformations within the read.
+//
+// The planner pushes group, window, aggregate, limit and schema mutation
+// transformations into the remote read when the provider implements this
+// interface, so implementations must support each of them.
+type TransformedReaderProvider interface {
+	// TransformedReaderFor will construct a Reader like ReaderFor that
+	// applies the transformations to the tables before they are returned.
+	TransformedReaderFor(ctx context.Context, conf Config, bounds flux.Bounds, predicateSet PredicateSet, transformations Transformations) (Reader, error)
+}
+
 // Reader reads tables from an influxdb instance.
 type Reader interface {
 	// Read will produce flux.Table values using the memory.Allocator
@@ -0,0 +1,150 @@
+package materialized
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+type key int
+
+const stateStoreKey key = iota
+
+// Dependency will inject the StateStore into the dependency chain.
+type Dependency struct {
+	Store StateStore
+}
+
+// Inject will inject the StateStore into the dependency chain.
+func (d Dependency) Inject(ctx context.Context) context.Context {
+	return context.WithValue(ctx, stateStoreKey, d.Store)
+}
+
+// GetStateStore will return the StateStore for the current context.
+// If no StateStore has been injected into the dependencies,
+// this will return a store that returns an error.
+func GetStateStore(ctx context.Context) StateStore {
+	s := ctx.Value(stateStoreKey)
+	if s == nil {
+		return ErrorStateStore{}
+	}
+	return s.(StateStore)
+}
+
+// StateStore persists the state of materialized views between queries.
+//
+// The state of a view is opaque to the store. It is saved
+// when a query that updates the view succeeds and loaded
+// by the next query that reads or updates the view.
+type StateStore interface {
+	// Load returns the state of the view.
+	// It returns nil if the view has no state.
+	Load(ctx context.Context, view string) ([]byte, error)
+
+	// Save replaces the state of the view.
+	Save(ctx context.Context, view string, state []byte) error
+}
+
+// ErrorStateStore is a StateStore that returns an error
+// with the code codes.Unimplemented for every view.
+type ErrorStateStore struct{}
+
+func (ErrorStateStore) Load(ctx context.Context, view string) ([]byte, error) {
+	return nil, errors.New(codes.Unimplemented, "StateStore.Load called on an error dependency")
+}
+
+func (ErrorStateStore) Save(ctx context.Context, view string, state []byte) error {
+	return errors.New(codes.Unimplemented, "StateStore.Save called on an error dependency")
+}
+
+// MemoryStateStore is a StateStore that keeps the state of each
+// view in memory. The state is lost when the process exits.
+//
+// The zero value is an empty store that is ready to use.
+type MemoryStateStore struct {
+	mu    sync.RWMutex
+	views map[string][]byte
+}
+
+func (s *MemoryStateStore) Load(ctx context.Context, view string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	state, ok := s.views[view]
+	if !ok {
+		return nil, nil
+	}
+	return append([]byte(nil), state...), nil
+}
+
+func (s *MemoryStateStore) Save(ctx context.Context, view string, state []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.views == nil {
+		s.views = make(map[string][]byte)
+	}
+	s.views[view] = append([]byte(nil), state...)
+	return nil
+}
+
+// DirStateStore is a StateStore that saves the state
+// of each view to a file in a directory.
+type DirStateStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewDirStateStore returns a DirStateStore that saves the state of the
+// views in the directory. The directory is created if it does not exist.
+func NewDirStateStore(dir string) (*DirStateStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrapf(err, codes.Invalid, "cannot create the materialized view state directory %q", dir)
+	}
+	return &DirStateStore{dir: dir}, nil
+}
+
+// path returns the file that holds the state of the view.
+// The name is escaped so any view name maps to a file in the directory.
+func (s *DirStateStore) path(view string) string {
+	return filepath.Join(s.dir, url.PathEscape(view)+".state")
+}
+
+func (s *DirStateStore) Load(ctx context.Context, view string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, err := ioutil.ReadFile(s.path(view))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, codes.Internal, "cannot read the state of view %q", view)
+	}
+	return state, nil
+}
+
+// Save writes the state to a temporary file and renames it,
+// so the state of a view is never partially written.
+func (s *DirStateStore) Save(ctx context.Context, view string, state []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := ioutil.TempFile(s.dir, ".tmp-")
+	if err != nil {
+		return errors.Wrapf(err, codes.Internal, "cannot save the state of view %q", view)
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+	if _, err := f.Write(state); err != nil {
+		_ = f.Close()
+		return errors.Wrapf(err, codes.Internal, "cannot save the state of view %q", view)
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrapf(err, codes.Internal, "cannot save the state of view %q", view)
+	}
+	if err := os.Rename(f.Name(), s.path(view)); err != nil {
+		return errors.Wrapf(err, codes.Internal, "cannot save the state of view %q", view)
+	}
+	return nil
+}
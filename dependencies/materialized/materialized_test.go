@@ -0,0 +1,77 @@
+package materialized_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/dependencies/materialized"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+func TestStateStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "materialized-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	dirStore, err := materialized.NewDirStateStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		store materialized.StateStore
+	}{
+		{name: "memory", store: &materialized.MemoryStateStore{}},
+		{name: "dir", store: dirStore},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			// A view name is escaped so it cannot name another file.
+			const view = "../views/cpu"
+			if state, err := tc.store.Load(ctx, view); err != nil {
+				t.Fatal(err)
+			} else if state != nil {
+				t.Fatalf("expected no state for a new view, got %q", state)
+			}
+
+			for _, want := range []string{"first", "second"} {
+				if err := tc.store.Save(ctx, view, []byte(want)); err != nil {
+					t.Fatal(err)
+				}
+				state, err := tc.store.Load(ctx, view)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := string(state); want != got {
+					t.Errorf("unexpected state -want/+got:\n\t- %q\n\t+ %q", want, got)
+				}
+			}
+
+			if state, err := tc.store.Load(ctx, "other"); err != nil {
+				t.Fatal(err)
+			} else if state != nil {
+				t.Errorf("expected no state for another view, got %q", state)
+			}
+		})
+	}
+}
+
+func TestGetStateStore(t *testing.T) {
+	ctx := context.Background()
+	if _, err := materialized.GetStateStore(ctx).Load(ctx, "view"); errors.Code(err) != codes.Unimplemented {
+		t.Errorf("expected an unimplemented error without a store, got %v", err)
+	}
+
+	store := &materialized.MemoryStateStore{}
+	ctx = materialized.Dependency{Store: store}.Inject(ctx)
+	if got := materialized.GetStateStore(ctx); got != store {
+		t.Errorf("unexpected store %v", got)
+	}
+}
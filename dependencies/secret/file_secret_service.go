@@ -0,0 +1,154 @@
+package secret
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// FileSecretService reads secrets from the filesystem.
+//
+// When Path is a directory, every regular file in the directory is
+// a secret whose key is the file name and whose value is the file content
+// without a trailing newline. This is the layout used when secrets are
+// mounted into a Kubernetes pod.
+//
+// Otherwise Path is a file that maps keys to values. The format is chosen
+// by the file extension: .json for a JSON object, .yml or .yaml for a
+// YAML mapping and anything else is read as a .env file with one
+// KEY=VALUE pair per line.
+//
+// Changes to the files are picked up on the next lookup.
+type FileSecretService struct {
+	Path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	secrets map[string]string
+}
+
+// NewFileSecretService creates a FileSecretService reading from path.
+// It returns an error if the path cannot be read.
+func NewFileSecretService(path string) (*FileSecretService, error) {
+	s := &FileSecretService{Path: path}
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSecretService) LoadSecret(ctx context.Context, k string) (string, error) {
+	fi, err := os.Stat(s.Path)
+	if err != nil {
+		return "", errors.Wrap(err, codes.Unavailable, "could not read secrets")
+	}
+	if fi.IsDir() {
+		return s.loadFromDir(k)
+	}
+
+	secrets, err := s.load(fi)
+	if err != nil {
+		return "", err
+	}
+	v, ok := secrets[k]
+	if !ok {
+		return "", errors.Newf(codes.NotFound, "secret key %q not found", k)
+	}
+	return v, nil
+}
+
+func (s *FileSecretService) loadFromDir(k string) (string, error) {
+	// Keys must name a file directly within the directory.
+	// Hidden files are skipped since Kubernetes uses them
+	// for the versioned data behind the mounted secrets.
+	if k == "" || strings.HasPrefix(k, ".") || strings.ContainsAny(k, `/\`) {
+		return "", errors.Newf(codes.NotFound, "secret key %q not found", k)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(s.Path, k))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", errors.Newf(codes.NotFound, "secret key %q not found", k)
+		}
+		return "", errors.Wrapf(err, codes.Unavailable, "could not read secret key %q", k)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// load returns the secrets in the file, parsing it
+// again when it has been modified since the last load.
+func (s *FileSecretService) load(fi os.FileInfo) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.secrets != nil && fi.ModTime().Equal(s.modTime) {
+		return s.secrets, nil
+	}
+	data, err := ioutil.ReadFile(s.Path)
+	if err != nil {
+		return nil, errors.Wrap(err, codes.Unavailable, "could not read secrets")
+	}
+
+	var secrets map[string]string
+	switch strings.ToLower(filepath.Ext(s.Path)) {
+	case ".json":
+		err = json.Unmarshal(data, &secrets)
+	case ".yml", ".yaml":
+		err = yaml.Unmarshal(data, &secrets)
+	default:
+		secrets, err = parseDotEnv(data)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, codes.Invalid, "could not parse secrets file %q", s.Path)
+	}
+	if secrets == nil {
+		secrets = make(map[string]string)
+	}
+	s.secrets, s.modTime = secrets, fi.ModTime()
+	return secrets, nil
+}
+
+// parseDotEnv parses the KEY=VALUE lines of a .env file.
+// Empty lines and lines starting with # are ignored,
+// a leading "export " is allowed and values may be quoted.
+func parseDotEnv(data []byte) (map[string]string, error) {
+	secrets := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		eq := strings.Index(line, "=")
+		if eq <= 0 {
+			return nil, errors.Newf(codes.Invalid, "line %d: expected KEY=VALUE", n)
+		}
+		k, v := strings.TrimSpace(line[:eq]), strings.TrimSpace(line[eq+1:])
+		if len(v) >= 2 {
+			switch {
+			case v[0] == '"' && v[len(v)-1] == '"':
+				uv, err := strconv.Unquote(v)
+				if err != nil {
+					return nil, errors.Newf(codes.Invalid, "line %d: invalid quoted value", n)
+				}
+				v = uv
+			case v[0] == '\'' && v[len(v)-1] == '\'':
+				v = v[1 : len(v)-1]
+			}
+		}
+		secrets[k] = v
+	}
+	return secrets, scanner.Err()
+}
@@ -0,0 +1,83 @@
+package secret_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/dependencies/secret"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+func TestFileSecretService(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flux-secret-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	mounted := filepath.Join(dir, "mounted")
+	if err := os.Mkdir(mounted, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"secrets.json":       `{"token": "json-token"}`,
+		"secrets.yaml":       "token: yaml-token\n",
+		"secrets.env":        "# comment\nexport token=\"env\\ttoken\"\nother='single'\n",
+		"mounted/token":      "mounted-token\n",
+		"mounted/..data":     "hidden",
+		"mounted/..2021_xyz": "hidden",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		path string
+		key  string
+		want string
+		code codes.Code
+	}{
+		{path: "secrets.json", key: "token", want: "json-token"},
+		{path: "secrets.yaml", key: "token", want: "yaml-token"},
+		{path: "secrets.env", key: "token", want: "env\ttoken"},
+		{path: "secrets.env", key: "other", want: "single"},
+		{path: "mounted", key: "token", want: "mounted-token"},
+		{path: "secrets.json", key: "missing", code: codes.NotFound},
+		{path: "mounted", key: "missing", code: codes.NotFound},
+		{path: "mounted", key: "..data", code: codes.NotFound},
+		{path: "mounted", key: "../secrets.env", code: codes.NotFound},
+	} {
+		tc := tc
+		t.Run(tc.path+"/"+tc.key, func(t *testing.T) {
+			ss, err := secret.NewFileSecretService(filepath.Join(dir, tc.path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ss.LoadSecret(context.Background(), tc.key)
+			if tc.code != codes.Inherit {
+				if want, got := tc.code, errors.Code(err); want != got {
+					t.Fatalf("unexpected error code -want/+got:\n\t- %v\n\t+ %v", want, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("unexpected secret -want/+got:\n\t- %q\n\t+ %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestFileSecretService_MissingPath(t *testing.T) {
+	if _, err := secret.NewFileSecretService("/this/path/does/not/exist"); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,215 @@
+package secret
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/flux/codes"
+	fluxhttp "github.com/influxdata/flux/dependencies/http"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+// DefaultVaultMount is the mount path of the KV secrets engine
+// used when VaultSecretService.Mount is empty.
+const DefaultVaultMount = "secret"
+
+// VaultSecretService reads secrets from an HTTP service
+// that is compatible with the HashiCorp Vault KV version 2 API.
+//
+// A key of the form "path#field" reads the field of the secret stored at path
+// relative to Prefix. The path cannot contain "." or ".." elements,
+// so a key cannot name a secret outside of Prefix.
+// Any other key reads the field with that name from the secret at Path.
+//
+// Secrets are cached for TTL after they have been read.
+// A TTL of zero disables the cache.
+type VaultSecretService struct {
+	// Address is the base URL of the service, such as https://vault:8200.
+	Address string
+	// Token authenticates the requests.
+	Token string
+	// Namespace is the optional Vault Enterprise namespace.
+	Namespace string
+	// Mount is the path of the KV secrets engine.
+	Mount string
+	// Prefix is the path that the paths named by keys are relative to.
+	// An empty prefix allows keys to name any secret of the mount.
+	Prefix string
+	// Path is the secret read for keys that do not name a path.
+	Path string
+	// TTL is how long a secret is cached.
+	TTL time.Duration
+	// Client sends the requests. The http.DefaultClient is used when it is nil.
+	Client fluxhttp.Client
+
+	mu    sync.Mutex
+	cache map[string]vaultCacheEntry
+}
+
+type vaultCacheEntry struct {
+	data    map[string]interface{}
+	expires time.Time
+}
+
+// vaultResponse is the body of a KV version 2 read.
+type vaultResponse struct {
+	Data struct {
+		Data map[string]interface{} `json:"data"`
+	} `json:"data"`
+	Errors []string `json:"errors"`
+}
+
+func (s *VaultSecretService) LoadSecret(ctx context.Context, k string) (string, error) {
+	path, field := s.Path, k
+	if i := strings.LastIndex(k, "#"); i >= 0 {
+		path, field = joinVaultPath(s.Prefix, k[:i]), k[i+1:]
+	}
+	path = strings.Trim(path, "/")
+	if path == "" || field == "" {
+		return "", errors.Newf(codes.NotFound, "secret key %q not found", k)
+	}
+	if !validVaultPath(path) {
+		return "", errors.Newf(codes.Invalid, "invalid path in secret key %q", k)
+	}
+
+	data, err := s.read(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	v, ok := data[field]
+	if !ok || v == nil {
+		return "", errors.Newf(codes.NotFound, "secret key %q not found", k)
+	}
+	if str, ok := v.(string); ok {
+		return str, nil
+	}
+	return fmt.Sprint(v), nil
+}
+
+// joinVaultPath joins the path named by a key to the prefix.
+func joinVaultPath(prefix, path string) string {
+	prefix, path = strings.Trim(prefix, "/"), strings.Trim(path, "/")
+	if prefix == "" || path == "" {
+		return path
+	}
+	return prefix + "/" + path
+}
+
+// validVaultPath reports whether every element of the path
+// names a secret or a directory of secrets. Empty, "." and ".."
+// elements would let a key resolve outside of the prefix.
+func validVaultPath(path string) bool {
+	for _, elem := range strings.Split(path, "/") {
+		if elem == "" || elem == "." || elem == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// escapeVaultPath escapes each element of the path.
+func escapeVaultPath(path string) string {
+	elems := strings.Split(path, "/")
+	for i, elem := range elems {
+		elems[i] = url.PathEscape(elem)
+	}
+	return strings.Join(elems, "/")
+}
+
+// read returns the data of the secret at path from the cache
+// or from the service when it is not cached.
+func (s *VaultSecretService) read(ctx context.Context, path string) (map[string]interface{}, error) {
+	s.mu.Lock()
+	if e, ok := s.cache[path]; ok && time.Now().Before(e.expires) {
+		s.mu.Unlock()
+		return e.data, nil
+	}
+	s.mu.Unlock()
+
+	data, err := s.fetch(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.TTL > 0 {
+		s.mu.Lock()
+		if s.cache == nil {
+			s.cache = make(map[string]vaultCacheEntry)
+		}
+		s.cache[path] = vaultCacheEntry{data: data, expires: time.Now().Add(s.TTL)}
+		s.mu.Unlock()
+	}
+	return data, nil
+}
+
+func (s *VaultSecretService) fetch(ctx context.Context, path string) (map[string]interface{}, error) {
+	mount := s.Mount
+	if mount == "" {
+		mount = DefaultVaultMount
+	}
+	u, err := url.Parse(strings.TrimRight(s.Address, "/"))
+	if err != nil {
+		return nil, errors.Wrap(err, codes.Invalid, "invalid secret service address")
+	}
+	mount = strings.Trim(mount, "/")
+	// The raw path keeps characters such as "?" and "%"
+	// within their elements of the path.
+	u.RawPath = u.EscapedPath() + "/v1/" + escapeVaultPath(mount) + "/data/" + escapeVaultPath(path)
+	u.Path += "/v1/" + mount + "/data/" + path
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.Token != "" {
+		req.Header.Set("X-Vault-Token", s.Token)
+	}
+	if s.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", s.Namespace)
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, codes.Unavailable, "could not reach secret service")
+	}
+	defer func() {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+
+	var body vaultResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil && err != io.EOF {
+		if resp.StatusCode == http.StatusOK {
+			return nil, errors.Wrap(err, codes.Internal, "invalid response from secret service")
+		}
+		// An error response from a proxy may not be JSON,
+		// so the status is reported without the errors of the body.
+		body = vaultResponse{}
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return body.Data.Data, nil
+	case http.StatusNotFound:
+		return nil, errors.Newf(codes.NotFound, "secret %q not found", path)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, errors.Newf(codes.PermissionDenied, "permission denied reading secret %q", path)
+	default:
+		msg := strings.Join(body.Errors, "; ")
+		if msg == "" {
+			msg = resp.Status
+		}
+		return nil, errors.Newf(codes.Unavailable, "secret service error reading %q: %s", path, msg)
+	}
+}
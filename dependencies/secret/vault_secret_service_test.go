@@ -0,0 +1,120 @@
+package secret_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/dependencies/secret"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+func newVaultServer(t *testing.T, requests *int64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(requests, 1)
+		if r.Header.Get("X-Vault-Token") != "vault-token" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/flux":
+			_, _ = w.Write([]byte(`{"data":{"data":{"token":"default-token","port":8086},"metadata":{"version":1}}}`))
+		case "/v1/kv/data/team/db":
+			_, _ = w.Write([]byte(`{"data":{"data":{"password":"db-password"},"metadata":{"version":3}}}`))
+		case "/v1/secret/data/apps/a?b":
+			_, _ = w.Write([]byte(`{"data":{"data":{"password":"escaped-password"},"metadata":{"version":1}}}`))
+		case "/v1/secret/data/flux/proxy":
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte(`<html>bad gateway</html>`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+}
+
+func TestVaultSecretService(t *testing.T) {
+	var requests int64
+	server := newVaultServer(t, &requests)
+	defer server.Close()
+
+	for _, tc := range []struct {
+		name   string
+		mount  string
+		prefix string
+		token  string
+		key    string
+		want   string
+		code   codes.Code
+	}{
+		{name: "default path", token: "vault-token", key: "token", want: "default-token"},
+		{name: "number", token: "vault-token", key: "port", want: "8086"},
+		{name: "explicit path", mount: "kv", token: "vault-token", key: "team/db#password", want: "db-password"},
+		{name: "missing field", token: "vault-token", key: "missing", code: codes.NotFound},
+		{name: "missing secret", token: "vault-token", key: "other#token", code: codes.NotFound},
+		{name: "bad token", token: "wrong", key: "token", code: codes.PermissionDenied},
+		{name: "prefix", mount: "kv", prefix: "/team/", token: "vault-token", key: "db#password", want: "db-password"},
+		{name: "escaped path", prefix: "apps", token: "vault-token", key: "a?b#password", want: "escaped-password"},
+		{name: "dot dot", prefix: "apps", token: "vault-token", key: "../flux#token", code: codes.Invalid},
+		{name: "dot", prefix: "apps", token: "vault-token", key: "./a?b#password", code: codes.Invalid},
+		{name: "empty element", token: "vault-token", key: "team//db#password", code: codes.Invalid},
+		{name: "error without json", token: "vault-token", key: "flux/proxy#token", code: codes.Unavailable},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ss := &secret.VaultSecretService{
+				Address: server.URL,
+				Token:   tc.token,
+				Mount:   tc.mount,
+				Prefix:  tc.prefix,
+				Path:    "flux",
+			}
+			got, err := ss.LoadSecret(context.Background(), tc.key)
+			if tc.code != codes.Inherit {
+				if want, got := tc.code, errors.Code(err); want != got {
+					t.Fatalf("unexpected error code -want/+got:\n\t- %v\n\t+ %v", want, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("unexpected secret -want/+got:\n\t- %q\n\t+ %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestVaultSecretService_TTL(t *testing.T) {
+	for _, tc := range []struct {
+		ttl  time.Duration
+		want int64
+	}{
+		{ttl: 0, want: 3},
+		{ttl: time.Hour, want: 1},
+	} {
+		var requests int64
+		server := newVaultServer(t, &requests)
+		ss := &secret.VaultSecretService{
+			Address: server.URL,
+			Token:   "vault-token",
+			Path:    "flux",
+			TTL:     tc.ttl,
+		}
+		for _, key := range []string{"token", "port", "token"} {
+			if _, err := ss.LoadSecret(context.Background(), key); err != nil {
+				t.Fatal(err)
+			}
+		}
+		server.Close()
+		if got := atomic.LoadInt64(&requests); got != tc.want {
+			t.Errorf("unexpected number of requests with ttl %v -want/+got:\n\t- %d\n\t+ %d", tc.ttl, tc.want, got)
+		}
+	}
+}
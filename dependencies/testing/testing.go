@@ -2,7 +2,13 @@ package testing
 
 import (
 	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/influxdata/flux/codes"
 	"github.com/influxdata/flux/internal/errors"
 )
@@ -19,10 +25,59 @@ func Inject(ctx context.Context) context.Context {
 
 // FrameworkConfig is the testing framework configuration.
 // This can be used to inject the testing framework as a dependency.
-type FrameworkConfig struct{}
+type FrameworkConfig struct {
+	// Name is the name of the test case. It is the default
+	// name of the plan snapshot of the test case.
+	Name string
+
+	// Snapshots stores the plan snapshots. It is required
+	// by tests that expect a plan snapshot.
+	Snapshots SnapshotStore
+
+	// UpdateSnapshots will save the physical plan as the snapshot
+	// instead of comparing it with the stored snapshot.
+	UpdateSnapshots bool
+}
 
 func (f FrameworkConfig) Inject(ctx context.Context) context.Context {
-	return context.WithValue(ctx, testingKey, &testingFramework{})
+	return context.WithValue(ctx, testingKey, &testingFramework{config: f})
+}
+
+// SnapshotStore loads and saves the snapshots of physical plans.
+type SnapshotStore interface {
+	// Load returns the snapshot with the name.
+	// It returns an error with codes.NotFound if the snapshot does not exist.
+	Load(name string) (string, error)
+
+	// Save stores the snapshot with the name.
+	Save(name, snapshot string) error
+}
+
+// SnapshotDir is a SnapshotStore that stores each snapshot
+// in a file of the directory named after the snapshot
+// with the .plan extension.
+type SnapshotDir string
+
+func (d SnapshotDir) path(name string) string {
+	return filepath.Join(string(d), name+".plan")
+}
+
+func (d SnapshotDir) Load(name string) (string, error) {
+	data, err := ioutil.ReadFile(d.path(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", errors.Newf(codes.NotFound, "plan snapshot %q does not exist", name)
+		}
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (d SnapshotDir) Save(name, snapshot string) error {
+	if err := os.MkdirAll(string(d), 0777); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(d.path(name), []byte(snapshot), 0666)
 }
 
 // getTestingFramework will retrieve the testing framework from
@@ -48,6 +103,17 @@ func MarkInvokedPlannerRule(ctx context.Context, name string) {
 	}
 }
 
+// MarkPhysicalPlan will record the physical plan of the query
+// in the testing dependencies. The plan is formatted with the verb v.
+// A query may have multiple physical plans, which are recorded in order.
+//
+// This method is a no-op if testing dependencies are not present.
+func MarkPhysicalPlan(ctx context.Context, p fmt.Formatter) {
+	if tf, err := getTestingFramework(ctx); err == nil {
+		tf.got.plans = append(tf.got.plans, fmt.Sprintf("%v", p))
+	}
+}
+
 // Check will check that all testing expectations have been met.
 // This is a no-op if testing dependencies are not present.
 func Check(ctx context.Context) error {
@@ -74,17 +140,71 @@ func ExpectPlannerRule(ctx context.Context, name string, n int) error {
 	return nil
 }
 
+// ExpectPlanSnapshot will mark that the physical plan is expected
+// to match the stored snapshot with the name. If the name is empty,
+// the name of the test case is used.
+//
+// This returns an error if testing dependencies have not been configured
+// or if the testing framework has no snapshot store.
+func ExpectPlanSnapshot(ctx context.Context, name string) error {
+	tf, err := getTestingFramework(ctx)
+	if err != nil {
+		return err
+	}
+	if tf.config.Snapshots == nil {
+		return errors.New(codes.FailedPrecondition, "plan snapshots are not configured for this test; run it with a snapshot store")
+	}
+
+	if name == "" {
+		name = tf.config.Name
+	}
+	if name == "" {
+		return errors.New(codes.Invalid, "plan snapshot requires a name outside of a test case")
+	}
+	tf.want.snapshot = name
+	return nil
+}
+
 type testingFramework struct {
-	want results
-	got  results
+	config FrameworkConfig
+	want   results
+	got    results
 }
 
 func (tf *testingFramework) Check() error {
-	return tf.want.Check(tf.got)
+	if err := tf.want.Check(tf.got); err != nil {
+		return err
+	}
+	return tf.checkSnapshot()
+}
+
+// checkSnapshot compares the physical plans with the expected snapshot
+// or saves them as the snapshot if snapshots are updated.
+func (tf *testingFramework) checkSnapshot() error {
+	name, store := tf.want.snapshot, tf.config.Snapshots
+	if name == "" {
+		return nil
+	}
+
+	got := strings.Join(tf.got.plans, "\n")
+	if tf.config.UpdateSnapshots {
+		return store.Save(name, got)
+	}
+
+	want, err := store.Load(name)
+	if err != nil {
+		return err
+	}
+	if want != got {
+		return errors.Newf(codes.Invalid, "physical plan does not match snapshot %q -want/+got:\n%s", name, cmp.Diff(want, got))
+	}
+	return nil
 }
 
 type results struct {
 	plannerRules map[string]int
+	plans        []string
+	snapshot     string
 }
 
 func (want results) Check(got results) error {
@@ -2,7 +2,13 @@ package testing
 
 import (
 	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
 )
 
 func MustExpectPlannerRule(ctx context.Context, name string, n int) {
@@ -103,3 +109,125 @@ func TestNoTestingFramework_ExpectPlannerRule(t *testing.T) {
 		t.Errorf("unexpected error: %s", err)
 	}
 }
+
+type planString string
+
+func (p planString) Format(fs fmt.State, c rune) {
+	_, _ = fmt.Fprint(fs, string(p))
+}
+
+type snapshotMap map[string]string
+
+func (m snapshotMap) Load(name string) (string, error) {
+	s, ok := m[name]
+	if !ok {
+		return "", errors.Newf(codes.NotFound, "plan snapshot %q does not exist", name)
+	}
+	return s, nil
+}
+
+func (m snapshotMap) Save(name, snapshot string) error {
+	m[name] = snapshot
+	return nil
+}
+
+func TestExpectPlanSnapshot(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		config   FrameworkConfig
+		snapshot string
+		plans    []string
+		want     snapshotMap
+		wantErr  string
+	}{
+		{
+			name:   "match",
+			config: FrameworkConfig{Name: "a", Snapshots: snapshotMap{"a": "p1"}},
+			plans:  []string{"p1"},
+			want:   snapshotMap{"a": "p1"},
+		},
+		{
+			name:   "multiple plans",
+			config: FrameworkConfig{Name: "a", Snapshots: snapshotMap{"a": "p1\np2"}},
+			plans:  []string{"p1", "p2"},
+			want:   snapshotMap{"a": "p1\np2"},
+		},
+		{
+			name:     "named snapshot",
+			config:   FrameworkConfig{Name: "a", Snapshots: snapshotMap{"b": "p1"}},
+			snapshot: "b",
+			plans:    []string{"p1"},
+			want:     snapshotMap{"b": "p1"},
+		},
+		{
+			name:    "mismatch",
+			config:  FrameworkConfig{Name: "a", Snapshots: snapshotMap{"a": "p1"}},
+			plans:   []string{"p2"},
+			want:    snapshotMap{"a": "p1"},
+			wantErr: "physical plan does not match snapshot \"a\"",
+		},
+		{
+			name:    "missing",
+			config:  FrameworkConfig{Name: "a", Snapshots: snapshotMap{}},
+			plans:   []string{"p1"},
+			want:    snapshotMap{},
+			wantErr: "plan snapshot \"a\" does not exist",
+		},
+		{
+			name:   "update",
+			config: FrameworkConfig{Name: "a", Snapshots: snapshotMap{"a": "p1"}, UpdateSnapshots: true},
+			plans:  []string{"p2"},
+			want:   snapshotMap{"a": "p2"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := tt.config.Inject(context.Background())
+			if err := ExpectPlanSnapshot(ctx, tt.snapshot); err != nil {
+				t.Fatal(err)
+			}
+			for _, p := range tt.plans {
+				MarkPhysicalPlan(ctx, planString(p))
+			}
+
+			got := Check(ctx)
+			if got != nil {
+				if gotErr := got.Error(); tt.wantErr == "" || !strings.HasPrefix(gotErr, tt.wantErr) {
+					t.Errorf("unexpected error -want/+got:\n\t- %s\n\t+ %s", tt.wantErr, gotErr)
+				}
+			} else if tt.wantErr != "" {
+				t.Error("expected error")
+			}
+			if tt.want != nil {
+				if got := tt.config.Snapshots.(snapshotMap); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+					t.Errorf("unexpected snapshots -want/+got:\n\t- %v\n\t+ %v", tt.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestExpectPlanSnapshot_NoSnapshotStore(t *testing.T) {
+	// A test that expects a plan snapshot must fail
+	// instead of passing without comparing the plan.
+	ctx := FrameworkConfig{Name: "a"}.Inject(context.Background())
+	if err := ExpectPlanSnapshot(ctx, ""); err == nil {
+		t.Fatal("expected error")
+	} else if want, got := codes.FailedPrecondition, errors.Code(err); want != got {
+		t.Errorf("unexpected error code -want/+got:\n\t- %v\n\t+ %v", want, got)
+	}
+}
+
+func TestSnapshotDir(t *testing.T) {
+	dir := SnapshotDir(filepath.Join(t.TempDir(), "testdata"))
+	if _, err := dir.Load("a"); errors.Code(err) != codes.NotFound {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+	if err := dir.Save("a", "p1\n"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := dir.Load("a"); err != nil {
+		t.Fatal(err)
+	} else if got != "p1\n" {
+		t.Fatalf("unexpected snapshot -want/+got:\n\t- %q\n\t+ %q", "p1\n", got)
+	}
+}
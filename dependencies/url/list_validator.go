@@ -0,0 +1,279 @@
+package url
+
+import (
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+// ListConfig describes the URLs accepted by a ListValidator.
+// Empty lists do not restrict anything.
+type ListConfig struct {
+	// AllowedSchemes lists the URL schemes that may be used, such as "https".
+	AllowedSchemes []string
+	// AllowedHosts lists the host names that may be used.
+	// A pattern of the form "*.example.com" matches every subdomain of example.com.
+	AllowedHosts []string
+	// DeniedHosts lists the host names that may not be used.
+	// It accepts the same patterns as AllowedHosts.
+	DeniedHosts []string
+	// AllowedNetworks lists the CIDR blocks or IP addresses that may be connected to.
+	AllowedNetworks []string
+	// DeniedNetworks lists the CIDR blocks or IP addresses that may not be connected to.
+	DeniedNetworks []string
+	// AllowedPorts lists the ports that may be used,
+	// either as a single port such as "443" or a range such as "8000-8999".
+	AllowedPorts []string
+	// DenyPrivate denies connections to private IP ranges
+	// in the same way as the PrivateIPValidator.
+	DenyPrivate bool
+}
+
+// ListValidator validates URLs against lists of allowed and denied
+// schemes, hosts, networks and ports.
+//
+// A URL is valid when its scheme and port are allowed, its host is not denied
+// and, if any hosts or networks are allowed, its host is either an allowed
+// host or resolves only to addresses within the allowed networks.
+// A URL without a host, such as a sqlite file, is only checked against the schemes.
+//
+// Network rules are also applied to the addresses passed to ValidateIP
+// so they hold for the connections made after a host has been resolved.
+type ListValidator struct {
+	schemes      map[string]bool
+	allowedHosts []string
+	deniedHosts  []string
+	allowedNets  []*net.IPNet
+	deniedNets   []*net.IPNet
+	ports        []portRange
+	denyPrivate  bool
+}
+
+type portRange struct {
+	lo, hi int
+}
+
+// defaultPorts maps the schemes used by the Flux sources and sinks
+// to the port used when a URL does not specify one.
+var defaultPorts = map[string]int{
+	"http":  80,
+	"https": 443,
+	"ws":    80,
+	"wss":   443,
+	"tcp":   1883,
+	"mqtt":  1883,
+	"ssl":   8883,
+	"tls":   8883,
+	"mqtts": 8883,
+
+	// The schemes of the sql data source names.
+	"postgres":   5432,
+	"postgresql": 5432,
+	"vertica":    5433,
+	"sqlserver":  1433,
+	"hdb":        30015,
+	"s3":         443,
+	"bigquery":   443,
+}
+
+// NewListValidator creates a ListValidator from the config.
+func NewListValidator(c ListConfig) (*ListValidator, error) {
+	v := &ListValidator{
+		allowedHosts: normalizeHosts(c.AllowedHosts),
+		deniedHosts:  normalizeHosts(c.DeniedHosts),
+		denyPrivate:  c.DenyPrivate,
+	}
+	if len(c.AllowedSchemes) > 0 {
+		v.schemes = make(map[string]bool, len(c.AllowedSchemes))
+		for _, s := range c.AllowedSchemes {
+			v.schemes[strings.ToLower(s)] = true
+		}
+	}
+	var err error
+	if v.allowedNets, err = parseNetworks(c.AllowedNetworks); err != nil {
+		return nil, err
+	}
+	if v.deniedNets, err = parseNetworks(c.DeniedNetworks); err != nil {
+		return nil, err
+	}
+	for _, p := range c.AllowedPorts {
+		r, err := parsePortRange(p)
+		if err != nil {
+			return nil, err
+		}
+		v.ports = append(v.ports, r)
+	}
+	return v, nil
+}
+
+func normalizeHosts(hosts []string) []string {
+	normalized := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		normalized = append(normalized, strings.ToLower(strings.TrimSuffix(h, ".")))
+	}
+	return normalized
+}
+
+func parseNetworks(networks []string) ([]*net.IPNet, error) {
+	blocks := make([]*net.IPNet, 0, len(networks))
+	for _, n := range networks {
+		if !strings.Contains(n, "/") {
+			ip := net.ParseIP(n)
+			if ip == nil {
+				return nil, errors.Newf(codes.Invalid, "invalid network %q", n)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			blocks = append(blocks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, block, err := net.ParseCIDR(n)
+		if err != nil {
+			return nil, errors.Newf(codes.Invalid, "invalid network %q: %v", n, err)
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
+
+func parsePortRange(s string) (portRange, error) {
+	lo, hi := s, s
+	if i := strings.Index(s, "-"); i >= 0 {
+		lo, hi = s[:i], s[i+1:]
+	}
+	var r portRange
+	var err error
+	if r.lo, err = strconv.Atoi(strings.TrimSpace(lo)); err != nil {
+		return portRange{}, errors.Newf(codes.Invalid, "invalid port range %q", s)
+	}
+	if r.hi, err = strconv.Atoi(strings.TrimSpace(hi)); err != nil {
+		return portRange{}, errors.Newf(codes.Invalid, "invalid port range %q", s)
+	}
+	if r.lo < 0 || r.hi > 65535 || r.lo > r.hi {
+		return portRange{}, errors.Newf(codes.Invalid, "invalid port range %q", s)
+	}
+	return r, nil
+}
+
+func (v *ListValidator) Validate(u *url.URL) error {
+	scheme := strings.ToLower(u.Scheme)
+	if v.schemes != nil && !v.schemes[scheme] {
+		return errors.Newf(codes.Invalid, "url is not valid, scheme %q is not allowed", u.Scheme)
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "" {
+		return nil
+	}
+	if matchHost(v.deniedHosts, host) {
+		return errors.Newf(codes.Invalid, "url is not valid, host %q is denied", host)
+	}
+
+	if len(v.ports) > 0 {
+		port, ok := defaultPorts[scheme]
+		if p := u.Port(); p != "" {
+			n, err := strconv.Atoi(p)
+			if err != nil {
+				return errors.Newf(codes.Invalid, "url is not valid, invalid port %q", p)
+			}
+			port, ok = n, true
+		}
+		if !ok || !v.allowsPort(port) {
+			return errors.Newf(codes.Invalid, "url is not valid, port is not allowed for host %q", host)
+		}
+	}
+
+	hostAllowed := matchHost(v.allowedHosts, host)
+	restricted := len(v.allowedHosts) > 0 || len(v.allowedNets) > 0
+	if hostAllowed && !v.denyPrivate && len(v.deniedNets) == 0 {
+		return nil
+	}
+	if restricted && !hostAllowed && len(v.allowedNets) == 0 {
+		return errors.Newf(codes.Invalid, "url is not valid, host %q is not allowed", host)
+	}
+	if !restricted && !v.denyPrivate && len(v.deniedNets) == 0 {
+		return nil
+	}
+
+	ips, err := lookupIP(host)
+	if err != nil {
+		return err
+	}
+	for _, ip := range ips {
+		if err := v.checkDenied(ip); err != nil {
+			return err
+		}
+		if restricted && !hostAllowed && !containsIP(v.allowedNets, ip) {
+			return errors.Newf(codes.Invalid, "url is not valid, host %q is not allowed", host)
+		}
+	}
+	return nil
+}
+
+func (v *ListValidator) ValidateIP(ip net.IP) error {
+	if err := v.checkDenied(ip); err != nil {
+		return err
+	}
+	// When hosts are allowed by name the address alone cannot tell
+	// whether the connection is allowed, so it is left to Validate.
+	if len(v.allowedNets) > 0 && len(v.allowedHosts) == 0 && !containsIP(v.allowedNets, ip) {
+		return errors.New(codes.Invalid, "url is not valid, it connects to an IP that is not allowed")
+	}
+	return nil
+}
+
+func (v *ListValidator) checkDenied(ip net.IP) error {
+	if v.denyPrivate && isPrivateIP(ip) {
+		return errors.New(codes.Invalid, "url is not valid, it connects to a private IP")
+	}
+	if containsIP(v.deniedNets, ip) {
+		return errors.New(codes.Invalid, "url is not valid, it connects to a denied IP")
+	}
+	return nil
+}
+
+func (v *ListValidator) allowsPort(port int) bool {
+	for _, r := range v.ports {
+		if r.lo <= port && port <= r.hi {
+			return true
+		}
+	}
+	return false
+}
+
+// matchHost reports whether the host matches one of the patterns.
+func matchHost(patterns []string, host string) bool {
+	for _, p := range patterns {
+		if strings.HasPrefix(p, "*.") {
+			if strings.HasSuffix(host, p[1:]) {
+				return true
+			}
+		} else if p == host {
+			return true
+		}
+	}
+	return false
+}
+
+func containsIP(blocks []*net.IPNet, ip net.IP) bool {
+	for _, block := range blocks {
+		if block.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupIP resolves the host unless it already is an IP address.
+func lookupIP(host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	return net.LookupIP(host)
+}
@@ -0,0 +1,68 @@
+package execute
+
+import (
+	"context"
+	"sync"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+// commits holds the functions that commit the changes
+// staged by the transformations of an execution.
+type commits struct {
+	mu      sync.Mutex
+	fns     []func(ctx context.Context) error
+	started bool
+}
+
+// OnCommit registers fn to commit the changes that a transformation staged
+// while the query ran, such as state that must only be saved when the whole
+// query succeeds. It must be called with the context of the execution
+// when the transformation is created.
+//
+// The executor calls the registered functions in order once the results
+// downstream of the transformations that registered them have finished
+// without an error. Those results do not finish until the functions have
+// returned, so an error from a commit is reported as their error.
+// The functions are not called if the query fails before then.
+func OnCommit(ctx context.Context, fn func(ctx context.Context) error) error {
+	c, ok := ctx.Value(commitsKey).(*commits)
+	if !ok {
+		return errors.New(codes.Internal, "cannot register a commit outside of an execution")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.started {
+		return errors.New(codes.Internal, "cannot register a commit after the execution has started")
+	}
+	c.fns = append(c.fns, fn)
+	return nil
+}
+
+// len returns the number of registered functions.
+func (c *commits) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.fns)
+}
+
+// start prevents more functions from being registered
+// and reports whether any function has been registered.
+func (c *commits) start() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.started = true
+	return len(c.fns) > 0
+}
+
+// commit calls the registered functions in order
+// and stops at the first error.
+func (c *commits) commit(ctx context.Context) error {
+	for _, fn := range c.fns {
+		if err := fn(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -16,7 +16,10 @@ import (
 
 type key int
 
-const executionDependenciesKey key = iota
+const (
+	executionDependenciesKey key = iota
+	commitsKey
+)
 
 type ExecutionOptions struct {
 	OperatorProfiler *OperatorProfiler
@@ -0,0 +1,435 @@
+package proptest
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"runtime/debug"
+	"testing"
+	"time"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/execute/executetest"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/memory"
+	"github.com/influxdata/flux/plan"
+	"github.com/influxdata/flux/values"
+)
+
+// DefaultRuns is the default number of runs of Check.
+const DefaultRuns = 100
+
+// CreateFn creates the transformation under test.
+// The transformation must allocate its memory from the allocator of the administration.
+// The bounds of the stream context contain every generated time.
+type CreateFn func(id execute.DatasetID, a execute.Administration) (execute.Transformation, execute.Dataset)
+
+// Chunk is a copy of a column reader that was produced by a transformation.
+type Chunk struct {
+	Key  flux.GroupKey
+	Cols []flux.ColMeta
+	// Len is the length reported by the column reader.
+	Len int
+	// ColLens are the lengths of the arrays of each column.
+	ColLens []int
+	// Data is the list of rows. It is only read
+	// if every column has the length of the column reader.
+	Data [][]interface{}
+}
+
+// Output is a table that was produced by a transformation.
+type Output struct {
+	Key    flux.GroupKey
+	Cols   []flux.ColMeta
+	Chunks []Chunk
+}
+
+// Len returns the number of rows of the table.
+func (o *Output) Len() int {
+	n := 0
+	for _, c := range o.Chunks {
+		n += c.Len
+	}
+	return n
+}
+
+// Result is the result of processing generated tables with a transformation.
+type Result struct {
+	Schema Schema
+	Input  []*Table
+	Output []*Output
+	// Err is the error returned by the transformation, if any.
+	Err error
+	// Allocated is the number of bytes that were still allocated
+	// after the transformation finished and its output was read.
+	Allocated int64
+}
+
+// InputLen returns the number of rows of the input tables.
+func (r *Result) InputLen() int {
+	n := 0
+	for _, tbl := range r.Input {
+		n += len(tbl.Data)
+	}
+	return n
+}
+
+// OutputLen returns the number of rows of the output tables.
+func (r *Result) OutputLen() int {
+	n := 0
+	for _, o := range r.Output {
+		n += o.Len()
+	}
+	return n
+}
+
+// Invariant is a property of a transformation that should hold for any input.
+// It returns an error describing the violation if the property does not hold.
+type Invariant func(r *Result) error
+
+// DefaultInvariants are the invariants that every transformation should satisfy.
+var DefaultInvariants = []Invariant{
+	ColReaderLengths,
+	GroupKeysConsistent,
+	AllocatorReleased,
+}
+
+// NoError checks that the transformation did not return an error.
+func NoError(r *Result) error {
+	return r.Err
+}
+
+// RowsConserved checks that the output has as many rows as the input.
+func RowsConserved(r *Result) error {
+	if in, out := r.InputLen(), r.OutputLen(); in != out {
+		return errors.Newf(codes.Internal, "rows are not conserved: %d input rows, %d output rows", in, out)
+	}
+	return nil
+}
+
+// RowsNotAdded checks that the output has no more rows than the input.
+func RowsNotAdded(r *Result) error {
+	if in, out := r.InputLen(), r.OutputLen(); out > in {
+		return errors.Newf(codes.Internal, "rows were added: %d input rows, %d output rows", in, out)
+	}
+	return nil
+}
+
+// ColReaderLengths checks that every column of a column reader has the length
+// reported by the column reader.
+func ColReaderLengths(r *Result) error {
+	for _, o := range r.Output {
+		for i, c := range o.Chunks {
+			for j, n := range c.ColLens {
+				if n != c.Len {
+					return errors.Newf(codes.Internal, "column %q of chunk %d of table %v has length %d, column reader has length %d",
+						c.Cols[j].Label, i, o.Key, n, c.Len)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// GroupKeysConsistent checks that no two tables have the same group key,
+// that every column reader has the group key and columns of its table,
+// that the columns of the group key are columns of the table and that
+// every row has the values of the group key.
+func GroupKeysConsistent(r *Result) error {
+	for i, o := range r.Output {
+		for _, p := range r.Output[:i] {
+			if p.Key.Equal(o.Key) {
+				return errors.Newf(codes.Internal, "multiple tables with group key %v", o.Key)
+			}
+		}
+		for j, kc := range o.Key.Cols() {
+			idx := execute.ColIdx(kc.Label, o.Cols)
+			if idx < 0 {
+				return errors.Newf(codes.Internal, "group key column %q is missing from table %v", kc.Label, o.Key)
+			}
+			if o.Cols[idx].Type != kc.Type {
+				return errors.Newf(codes.Internal, "group key column %q of table %v has type %v, table column has type %v",
+					kc.Label, o.Key, kc.Type, o.Cols[idx].Type)
+			}
+			want := o.Key.Value(j)
+			for _, c := range o.Chunks {
+				for _, row := range c.Data {
+					if got := value(kc.Type, row[idx]); !got.Equal(want) && !(got.IsNull() && want.IsNull()) {
+						return errors.Newf(codes.Internal, "row of table %v has value %v in group key column %q", o.Key, got, kc.Label)
+					}
+				}
+			}
+		}
+		for k, c := range o.Chunks {
+			if !c.Key.Equal(o.Key) {
+				return errors.Newf(codes.Internal, "chunk %d of table %v has group key %v", k, o.Key, c.Key)
+			}
+			if !colsEqual(c.Cols, o.Cols) {
+				return errors.Newf(codes.Internal, "chunk %d of table %v has columns %v, table has columns %v", k, o.Key, c.Cols, o.Cols)
+			}
+		}
+	}
+	return nil
+}
+
+// AllocatorReleased checks that all memory was released after the
+// transformation finished and its output was read.
+func AllocatorReleased(r *Result) error {
+	if r.Allocated != 0 {
+		return errors.Newf(codes.Internal, "caught memory leak: %d bytes were not released", r.Allocated)
+	}
+	return nil
+}
+
+// Registered returns a function that creates the transformation
+// that is registered for the procedure spec.
+func Registered(spec plan.ProcedureSpec) CreateFn {
+	return func(id execute.DatasetID, a execute.Administration) (execute.Transformation, execute.Dataset) {
+		createFn, ok := execute.LookupTransformation(spec.Kind())
+		if !ok {
+			panic(fmt.Errorf("no transformation is registered for procedure kind %v", spec.Kind()))
+		}
+		tx, d, err := createFn(id, execute.DiscardingMode, spec.Copy(), a)
+		if err != nil {
+			panic(err)
+		}
+		return tx, d
+	}
+}
+
+// Run generates tables with the schema and processes them with the transformation.
+// The context of the transformation has the dependencies of executetest.
+// An error is returned if the tables cannot be generated or the transformation panics.
+func Run(r *rand.Rand, s Schema, create CreateFn) (res *Result, err error) {
+	alloc := &memory.Allocator{}
+	input, err := Generate(r, s, alloc)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if e := recover(); e != nil {
+			res, err = nil, errors.Newf(codes.Internal, "caught panic: %v\n%s", e, debug.Stack())
+		}
+	}()
+
+	bounds := s.Bounds()
+	a := &administration{
+		ctx:    executetest.NewTestExecuteDependencies().Inject(context.Background()),
+		alloc:  alloc,
+		bounds: &bounds,
+	}
+	store := &store{}
+	tx, d := create(executetest.RandomDatasetID(), a)
+	d.SetTriggerSpec(plan.DefaultTriggerSpec)
+	d.AddTransformation(store)
+
+	res = &Result{Schema: s, Input: input}
+	parentID := executetest.RandomDatasetID()
+	for _, tbl := range input {
+		if res.Err = tx.Process(parentID, tbl); res.Err != nil {
+			break
+		}
+	}
+	tx.Finish(parentID, res.Err)
+	if res.Err == nil {
+		res.Err = store.err
+	}
+	res.Output = store.tables
+	res.Allocated = alloc.Allocated()
+	return res, nil
+}
+
+// Config configures the runs of Check.
+type Config struct {
+	// Seed is the seed of the first run. Each run increments the seed.
+	// If it is zero, the current time is used.
+	Seed int64
+
+	// Runs is the number of runs. This defaults to 100.
+	Runs int
+
+	// Schema returns the schema of a run.
+	// If it is not set, a random schema without any
+	// additional columns is used.
+	Schema func(r *rand.Rand) Schema
+}
+
+// Check runs the transformation over tables generated from random
+// schemas and verifies the invariants for every run.
+// If no invariants are given, the DefaultInvariants are verified.
+// The seed of a failing run is reported so the run can be repeated.
+func Check(t *testing.T, c Config, create CreateFn, invariants ...Invariant) {
+	t.Helper()
+
+	if len(invariants) == 0 {
+		invariants = DefaultInvariants
+	}
+	seed := c.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	runs := c.Runs
+	if runs == 0 {
+		runs = DefaultRuns
+	}
+	schema := c.Schema
+	if schema == nil {
+		schema = func(r *rand.Rand) Schema {
+			return RandomSchema(r, nil)
+		}
+	}
+
+	for i := 0; i < runs; i++ {
+		r := rand.New(rand.NewSource(seed + int64(i)))
+		s := schema(r)
+		res, err := Run(r, s, create)
+		if err != nil {
+			t.Fatalf("seed %d: %s", seed+int64(i), err)
+		}
+		for _, inv := range invariants {
+			if err := inv(res); err != nil {
+				t.Fatalf("seed %d: %s\nschema: %+v", seed+int64(i), err, s)
+			}
+		}
+	}
+}
+
+// store copies the tables that are produced by a transformation.
+type store struct {
+	execute.ExecutionNode
+	tables []*Output
+	err    error
+}
+
+func (s *store) RetractTable(id execute.DatasetID, key flux.GroupKey) error {
+	for i, o := range s.tables {
+		if o.Key.Equal(key) {
+			s.tables = append(s.tables[:i], s.tables[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (s *store) Process(id execute.DatasetID, tbl flux.Table) error {
+	o := &Output{
+		Key:  tbl.Key(),
+		Cols: tbl.Cols(),
+	}
+	s.tables = append(s.tables, o)
+	return tbl.Do(func(cr flux.ColReader) error {
+		c := Chunk{
+			Key:     cr.Key(),
+			Cols:    cr.Cols(),
+			Len:     cr.Len(),
+			ColLens: make([]int, len(cr.Cols())),
+		}
+		valid := true
+		for j := range c.Cols {
+			c.ColLens[j] = colLen(cr, j)
+			if c.ColLens[j] != c.Len {
+				valid = false
+			}
+		}
+		if valid {
+			c.Data = make([][]interface{}, c.Len)
+			for i := range c.Data {
+				row := make([]interface{}, len(c.Cols))
+				for j := range c.Cols {
+					if v := execute.ValueForRow(cr, i, j); !v.IsNull() {
+						row[j] = values.Unwrap(v)
+					}
+				}
+				c.Data[i] = row
+			}
+		}
+		o.Chunks = append(o.Chunks, c)
+		return nil
+	})
+}
+
+func (s *store) UpdateWatermark(id execute.DatasetID, t execute.Time) error {
+	return nil
+}
+
+func (s *store) UpdateProcessingTime(id execute.DatasetID, t execute.Time) error {
+	return nil
+}
+
+func (s *store) Finish(id execute.DatasetID, err error) {
+	if err != nil {
+		s.err = err
+	}
+}
+
+type administration struct {
+	ctx    context.Context
+	alloc  *memory.Allocator
+	bounds *execute.Bounds
+}
+
+func (a *administration) Context() context.Context {
+	return a.ctx
+}
+
+func (a *administration) ResolveTime(qt flux.Time) execute.Time {
+	return execute.Now()
+}
+
+func (a *administration) StreamContext() execute.StreamContext {
+	return a
+}
+
+func (a *administration) Bounds() *execute.Bounds {
+	return a.bounds
+}
+
+func (a *administration) Allocator() *memory.Allocator {
+	return a.alloc
+}
+
+func (a *administration) Parents() []execute.DatasetID {
+	return nil
+}
+
+func colLen(cr flux.ColReader, j int) int {
+	switch typ := cr.Cols()[j].Type; typ {
+	case flux.TBool:
+		return cr.Bools(j).Len()
+	case flux.TInt:
+		return cr.Ints(j).Len()
+	case flux.TUInt:
+		return cr.UInts(j).Len()
+	case flux.TFloat:
+		return cr.Floats(j).Len()
+	case flux.TString:
+		return cr.Strings(j).Len()
+	case flux.TTime:
+		return cr.Times(j).Len()
+	default:
+		panic(fmt.Errorf("unexpected column type: %v", typ))
+	}
+}
+
+// value converts a value of a row back to a Flux value.
+func value(typ flux.ColType, v interface{}) values.Value {
+	if v == nil {
+		return values.NewNull(flux.SemanticType(typ))
+	}
+	return values.New(v)
+}
+
+func colsEqual(a, b []flux.ColMeta) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
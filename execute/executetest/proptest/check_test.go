@@ -0,0 +1,225 @@
+package proptest_test
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/execute/executetest"
+	"github.com/influxdata/flux/execute/executetest/proptest"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/stdlib/universe"
+	"github.com/influxdata/flux/values"
+)
+
+func TestCheck(t *testing.T) {
+	schema := func(r *rand.Rand) proptest.Schema {
+		return proptest.RandomSchema(r, []flux.ColMeta{
+			{Label: "_time", Type: flux.TTime},
+			{Label: "_value", Type: flux.TFloat},
+		})
+	}
+	t.Run("limit", func(t *testing.T) {
+		proptest.Check(t, proptest.Config{Seed: 1, Schema: schema},
+			proptest.Registered(&universe.LimitProcedureSpec{N: 3, Offset: 1}),
+			proptest.NoError,
+			proptest.RowsNotAdded,
+			proptest.ColReaderLengths,
+			proptest.GroupKeysConsistent,
+			proptest.AllocatorReleased,
+		)
+	})
+	t.Run("group", func(t *testing.T) {
+		proptest.Check(t, proptest.Config{Seed: 1, Schema: schema},
+			proptest.Registered(&universe.GroupProcedureSpec{GroupMode: flux.GroupModeBy, GroupKeys: []string{"c0"}}),
+			proptest.NoError,
+			proptest.RowsConserved,
+			proptest.ColReaderLengths,
+			proptest.GroupKeysConsistent,
+			proptest.AllocatorReleased,
+		)
+	})
+}
+
+func TestRun_Panic(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	_, err := proptest.Run(r, proptest.Schema{NumTables: 1}, func(id execute.DatasetID, a execute.Administration) (execute.Transformation, execute.Dataset) {
+		panic("boom")
+	})
+	if err == nil || !strings.Contains(err.Error(), "caught panic: boom") {
+		t.Fatalf("expected panic error, got %v", err)
+	}
+}
+
+func TestInvariants(t *testing.T) {
+	cols := []flux.ColMeta{
+		{Label: "t0", Type: flux.TString},
+		{Label: "_value", Type: flux.TFloat},
+	}
+	key := func(v values.Value) flux.GroupKey {
+		return execute.NewGroupKey(cols[:1], []values.Value{v})
+	}
+	a := key(values.NewString("a"))
+	null := key(values.NewNull(flux.SemanticType(flux.TString)))
+	input := []*proptest.Table{{
+		Table: &executetest.Table{
+			KeyCols: []string{"t0"},
+			ColMeta: cols,
+			Data: [][]interface{}{
+				{"a", 1.0},
+				{"a", 2.0},
+			},
+		},
+	}}
+
+	testCases := []struct {
+		name      string
+		invariant proptest.Invariant
+		result    proptest.Result
+		wantErr   string
+	}{
+		{
+			name:      "no error",
+			invariant: proptest.NoError,
+			result:    proptest.Result{Err: errors.New(codes.Invalid, "bad input")},
+			wantErr:   "bad input",
+		},
+		{
+			name:      "rows conserved",
+			invariant: proptest.RowsConserved,
+			result: proptest.Result{
+				Input: input,
+				Output: []*proptest.Output{{
+					Key:    a,
+					Cols:   cols,
+					Chunks: []proptest.Chunk{{Key: a, Cols: cols, Len: 2}},
+				}},
+			},
+		},
+		{
+			name:      "rows not conserved",
+			invariant: proptest.RowsConserved,
+			result: proptest.Result{
+				Input: input,
+				Output: []*proptest.Output{{
+					Key:    a,
+					Cols:   cols,
+					Chunks: []proptest.Chunk{{Key: a, Cols: cols, Len: 1}},
+				}},
+			},
+			wantErr: "rows are not conserved: 2 input rows, 1 output rows",
+		},
+		{
+			name:      "rows added",
+			invariant: proptest.RowsNotAdded,
+			result: proptest.Result{
+				Input: input,
+				Output: []*proptest.Output{{
+					Key:    a,
+					Cols:   cols,
+					Chunks: []proptest.Chunk{{Key: a, Cols: cols, Len: 2}, {Key: a, Cols: cols, Len: 1}},
+				}},
+			},
+			wantErr: "rows were added: 2 input rows, 3 output rows",
+		},
+		{
+			name:      "column reader lengths",
+			invariant: proptest.ColReaderLengths,
+			result: proptest.Result{
+				Output: []*proptest.Output{{
+					Key:    a,
+					Cols:   cols,
+					Chunks: []proptest.Chunk{{Key: a, Cols: cols, Len: 2, ColLens: []int{2, 1}}},
+				}},
+			},
+			wantErr: `column "_value" of chunk 0 of table {t0=a} has length 1, column reader has length 2`,
+		},
+		{
+			name:      "group key with null",
+			invariant: proptest.GroupKeysConsistent,
+			result: proptest.Result{
+				Output: []*proptest.Output{
+					{
+						Key:    null,
+						Cols:   cols,
+						Chunks: []proptest.Chunk{{Key: null, Cols: cols, Len: 1, Data: [][]interface{}{{nil, 1.0}}}},
+					},
+					{
+						Key:    a,
+						Cols:   cols,
+						Chunks: []proptest.Chunk{{Key: a, Cols: cols, Len: 1, Data: [][]interface{}{{"a", 1.0}}}},
+					},
+				},
+			},
+		},
+		{
+			name:      "duplicate group key",
+			invariant: proptest.GroupKeysConsistent,
+			result: proptest.Result{
+				Output: []*proptest.Output{
+					{Key: a, Cols: cols},
+					{Key: a, Cols: cols},
+				},
+			},
+			wantErr: "multiple tables with group key {t0=a}",
+		},
+		{
+			name:      "missing group key column",
+			invariant: proptest.GroupKeysConsistent,
+			result: proptest.Result{
+				Output: []*proptest.Output{{Key: a, Cols: cols[1:]}},
+			},
+			wantErr: `group key column "t0" is missing from table {t0=a}`,
+		},
+		{
+			name:      "row outside of group key",
+			invariant: proptest.GroupKeysConsistent,
+			result: proptest.Result{
+				Output: []*proptest.Output{{
+					Key:    null,
+					Cols:   cols,
+					Chunks: []proptest.Chunk{{Key: null, Cols: cols, Len: 1, Data: [][]interface{}{{"", 1.0}}}},
+				}},
+			},
+			wantErr: `row of table {t0=<nil>} has value  in group key column "t0"`,
+		},
+		{
+			name:      "chunk with other group key",
+			invariant: proptest.GroupKeysConsistent,
+			result: proptest.Result{
+				Output: []*proptest.Output{{
+					Key:    a,
+					Cols:   cols,
+					Chunks: []proptest.Chunk{{Key: null, Cols: cols}},
+				}},
+			},
+			wantErr: "chunk 0 of table {t0=a} has group key {t0=<nil>}",
+		},
+		{
+			name:      "memory leak",
+			invariant: proptest.AllocatorReleased,
+			result:    proptest.Result{Allocated: 64},
+			wantErr:   "caught memory leak: 64 bytes were not released",
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.invariant(&tc.result)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected error %q, got none", tc.wantErr)
+			} else if got := err.Error(); got != tc.wantErr {
+				t.Fatalf("unexpected error -want/+got:\n\t- %s\n\t+ %s", tc.wantErr, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,299 @@
+// Package proptest contains utilities for property-based testing of transformations.
+//
+// Random tables are generated from a Schema and processed by a transformation.
+// The output is then verified with invariants that should hold for any input,
+// such as the lengths of the column readers or the release of all allocated memory.
+package proptest
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/execute/executetest"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/memory"
+	"github.com/influxdata/flux/values"
+)
+
+const (
+	// DefaultPeriod is the default distance between the times of two rows.
+	DefaultPeriod = time.Second
+
+	// DefaultCardinality is the default number of distinct values
+	// of a column in the group key.
+	DefaultCardinality = 3
+)
+
+var columnTypes = []flux.ColType{
+	flux.TBool,
+	flux.TInt,
+	flux.TUInt,
+	flux.TFloat,
+	flux.TString,
+	flux.TTime,
+}
+
+// Schema describes the tables to be generated.
+type Schema struct {
+	// Cols are the columns of every table.
+	Cols []flux.ColMeta
+
+	// KeyCols are the labels of the columns that are part of the group key.
+	KeyCols []string
+
+	// NumTables is the maximum number of tables. Tables with
+	// the same group key are only generated once, so fewer tables
+	// may be generated.
+	NumTables int
+
+	// Cardinality is the number of distinct values of each column
+	// in the group key. This defaults to 3.
+	Cardinality int
+
+	// MaxRows is the maximum number of rows in a table.
+	// Each table has between zero and MaxRows rows.
+	MaxRows int
+
+	// MaxChunkSize is the maximum number of rows in a column reader.
+	// The rows of a table are split into chunks of random sizes
+	// up to this length. If it is zero, each table is read in one chunk.
+	MaxChunkSize int
+
+	// Nulls is the chance that a value outside of the group key is null.
+	// The _time column is never null.
+	Nulls float64
+
+	// KeyNulls is the chance that a value of the group key is null.
+	KeyNulls float64
+
+	// Start is the time of the first row. The _time column
+	// increases by Period for each row.
+	Start values.Time
+
+	// Period is the distance between the times of two rows.
+	// This defaults to one second.
+	Period time.Duration
+}
+
+// Bounds returns the bounds that contain every time that may be generated.
+func (s Schema) Bounds() execute.Bounds {
+	return execute.Bounds{
+		Start: s.Start,
+		Stop:  s.Start.Add(s.period().Mul(s.MaxRows + 1)),
+	}
+}
+
+func (s Schema) period() values.Duration {
+	if s.Period == 0 {
+		return values.ConvertDurationNsecs(DefaultPeriod)
+	}
+	return values.ConvertDurationNsecs(s.Period)
+}
+
+// RandomSchema returns a schema with the columns and up to three additional
+// columns of random types. A random subset of the additional columns is
+// part of the group key and the number of tables, rows, chunks and nulls are
+// chosen so that empty tables, single rows and null columns are likely.
+func RandomSchema(r *rand.Rand, cols []flux.ColMeta) Schema {
+	s := Schema{
+		Cols:         append([]flux.ColMeta(nil), cols...),
+		NumTables:    1 + r.Intn(5),
+		MaxRows:      []int{0, 1, 10, 100}[r.Intn(4)],
+		MaxChunkSize: []int{0, 1, 3, 64}[r.Intn(4)],
+		Nulls:        []float64{0, 0.1, 0.5, 1}[r.Intn(4)],
+		KeyNulls:     []float64{0, 0.2}[r.Intn(2)],
+	}
+	for i, n := 0, r.Intn(4); i < n; i++ {
+		col := flux.ColMeta{
+			Label: fmt.Sprintf("c%d", i),
+			Type:  columnTypes[r.Intn(len(columnTypes))],
+		}
+		s.Cols = append(s.Cols, col)
+		if r.Intn(2) == 0 {
+			s.KeyCols = append(s.KeyCols, col.Label)
+		}
+	}
+	return s
+}
+
+// Table is a generated table.
+// The rows are read in chunks with column readers that are
+// allocated from the allocator of the table.
+type Table struct {
+	*executetest.Table
+
+	// Chunks are the number of rows of each column reader.
+	Chunks []int
+}
+
+// Do calls f with a column reader for each chunk of the table.
+func (t *Table) Do(f func(flux.ColReader) error) error {
+	if t.IsDone {
+		return errors.New(codes.Internal, "table already read")
+	}
+	t.IsDone = true
+
+	offset := 0
+	for _, n := range t.Chunks {
+		chunk := &executetest.Table{
+			GroupKey: t.Key(),
+			ColMeta:  t.ColMeta,
+			Data:     t.Data[offset : offset+n],
+			Alloc:    t.Alloc,
+		}
+		if err := chunk.Do(f); err != nil {
+			return err
+		}
+		offset += n
+	}
+	return nil
+}
+
+// Generate returns random tables with the schema.
+// The column readers of the tables are allocated from alloc.
+func Generate(r *rand.Rand, s Schema, alloc *memory.Allocator) ([]*Table, error) {
+	keyIdx := make([]int, len(s.KeyCols))
+	for i, label := range s.KeyCols {
+		keyIdx[i] = execute.ColIdx(label, s.Cols)
+		if keyIdx[i] < 0 {
+			return nil, errors.Newf(codes.Invalid, "group key column %q is not part of the schema", label)
+		}
+	}
+	cardinality := s.Cardinality
+	if cardinality <= 0 {
+		cardinality = DefaultCardinality
+	}
+
+	var keys [][]interface{}
+	for i := 0; i < s.NumTables; i++ {
+		key := make([]interface{}, len(s.KeyCols))
+		for j, idx := range keyIdx {
+			if s.KeyNulls > 0 && s.KeyNulls > r.Float64() {
+				continue
+			}
+			key[j] = keyValue(s.Cols[idx].Type, r.Intn(cardinality))
+		}
+		if !containsKey(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+
+	tables := make([]*Table, 0, len(keys))
+	for _, key := range keys {
+		tbl := &Table{
+			Table: &executetest.Table{
+				KeyCols:   s.KeyCols,
+				KeyValues: key,
+				ColMeta:   s.Cols,
+				Alloc:     alloc,
+			},
+		}
+
+		n := 0
+		if s.MaxRows > 0 {
+			n = r.Intn(s.MaxRows + 1)
+		}
+		tbl.Data = make([][]interface{}, n)
+		for i := range tbl.Data {
+			row := make([]interface{}, len(s.Cols))
+			for j, c := range s.Cols {
+				if k := indexOf(keyIdx, j); k >= 0 {
+					row[j] = key[k]
+				} else if c.Label == execute.DefaultTimeColLabel && c.Type == flux.TTime {
+					row[j] = s.Start.Add(s.period().Mul(i))
+				} else if s.Nulls == 0 || s.Nulls <= r.Float64() {
+					row[j] = randomValue(r, c.Type, s)
+				}
+			}
+			tbl.Data[i] = row
+		}
+
+		// An empty table is read with one empty column reader.
+		if n == 0 {
+			tbl.Chunks = []int{0}
+		}
+		for left := n; left > 0; {
+			size := left
+			if s.MaxChunkSize > 0 && size > s.MaxChunkSize {
+				size = 1 + r.Intn(s.MaxChunkSize)
+			}
+			tbl.Chunks = append(tbl.Chunks, size)
+			left -= size
+		}
+		tables = append(tables, tbl)
+	}
+	return tables, nil
+}
+
+// keyValue returns the nth distinct value of the type.
+func keyValue(typ flux.ColType, n int) interface{} {
+	switch typ {
+	case flux.TBool:
+		return n%2 == 1
+	case flux.TInt:
+		return int64(n)
+	case flux.TUInt:
+		return uint64(n)
+	case flux.TFloat:
+		return float64(n)
+	case flux.TString:
+		return fmt.Sprintf("v%d", n)
+	case flux.TTime:
+		return values.Time(n)
+	default:
+		panic(fmt.Errorf("unexpected column type: %v", typ))
+	}
+}
+
+func randomValue(r *rand.Rand, typ flux.ColType, s Schema) interface{} {
+	switch typ {
+	case flux.TBool:
+		return r.Intn(2) != 0
+	case flux.TInt:
+		return int64(r.Intn(201) - 100)
+	case flux.TUInt:
+		return uint64(r.Intn(101))
+	case flux.TFloat:
+		return r.NormFloat64() * 50
+	case flux.TString:
+		b := make([]byte, r.Intn(8))
+		for i := range b {
+			b[i] = byte('a' + r.Intn(26))
+		}
+		return string(b)
+	case flux.TTime:
+		bounds := s.Bounds()
+		return bounds.Start + values.Time(r.Int63n(int64(bounds.Stop-bounds.Start)))
+	default:
+		panic(fmt.Errorf("unexpected column type: %v", typ))
+	}
+}
+
+func containsKey(keys [][]interface{}, key []interface{}) bool {
+	for _, k := range keys {
+		equal := true
+		for i := range k {
+			if k[i] != key[i] {
+				equal = false
+				break
+			}
+		}
+		if equal {
+			return true
+		}
+	}
+	return false
+}
+
+func indexOf(idx []int, j int) int {
+	for i, v := range idx {
+		if v == j {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,117 @@
+package proptest_test
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/execute/executetest"
+	"github.com/influxdata/flux/execute/executetest/proptest"
+	"github.com/influxdata/flux/execute/table"
+	"github.com/influxdata/flux/memory"
+	"github.com/influxdata/flux/values"
+)
+
+func TestGenerate_TableTest(t *testing.T) {
+	executetest.RunTableTests(t, executetest.TableTest{
+		NewFn: func(ctx context.Context, alloc *memory.Allocator) flux.TableIterator {
+			s := proptest.Schema{
+				Cols: []flux.ColMeta{
+					{Label: "_time", Type: flux.TTime},
+					{Label: "_value", Type: flux.TFloat},
+					{Label: "t0", Type: flux.TString},
+				},
+				KeyCols:      []string{"t0"},
+				NumTables:    5,
+				MaxRows:      50,
+				MaxChunkSize: 7,
+				Nulls:        0.2,
+			}
+			tables, err := proptest.Generate(rand.New(rand.NewSource(1)), s, alloc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ti := make(table.Iterator, len(tables))
+			for i, tbl := range tables {
+				ti[i] = tbl
+			}
+			return ti
+		},
+		IsDone: func(tbl flux.Table) bool {
+			// Empty tables hold no memory, so they are done without being read.
+			return tbl.Empty() || tbl.(*proptest.Table).IsDone
+		},
+	})
+}
+
+func TestGenerate(t *testing.T) {
+	period := values.ConvertDurationNsecs(proptest.DefaultPeriod)
+	for seed := int64(0); seed < 100; seed++ {
+		r := rand.New(rand.NewSource(seed))
+		s := proptest.RandomSchema(r, []flux.ColMeta{
+			{Label: "_time", Type: flux.TTime},
+			{Label: "_value", Type: flux.TFloat},
+		})
+		tables, err := proptest.Generate(r, s, executetest.UnlimitedAllocator)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tables) == 0 || len(tables) > s.NumTables {
+			t.Fatalf("seed %d: unexpected number of tables %d for at most %d tables", seed, len(tables), s.NumTables)
+		}
+
+		for i, tbl := range tables {
+			for _, prev := range tables[:i] {
+				if prev.Key().Equal(tbl.Key()) {
+					t.Fatalf("seed %d: multiple tables with group key %v", seed, tbl.Key())
+				}
+			}
+			if len(tbl.Data) > s.MaxRows {
+				t.Fatalf("seed %d: table has %d rows, want at most %d", seed, len(tbl.Data), s.MaxRows)
+			}
+
+			n := 0
+			for _, size := range tbl.Chunks {
+				if s.MaxChunkSize > 0 && size > s.MaxChunkSize {
+					t.Fatalf("seed %d: chunk has %d rows, want at most %d", seed, size, s.MaxChunkSize)
+				}
+				n += size
+			}
+			if n != len(tbl.Data) {
+				t.Fatalf("seed %d: chunks have %d rows, table has %d rows", seed, n, len(tbl.Data))
+			}
+
+			var rows int
+			if err := tbl.Do(func(cr flux.ColReader) error {
+				for j, c := range cr.Cols() {
+					for i := 0; i < cr.Len(); i++ {
+						v := execute.ValueForRow(cr, i, j)
+						if idx := execute.ColIdx(c.Label, tbl.Key().Cols()); idx >= 0 {
+							if want := tbl.Key().Value(idx); !v.Equal(want) && !(v.IsNull() && want.IsNull()) {
+								t.Fatalf("seed %d: row has value %v in group key column %q, want %v", seed, v, c.Label, want)
+							}
+						} else if c.Label == "_time" {
+							want := values.NewTime(s.Start.Add(period.Mul(rows + i)))
+							if !v.Equal(want) {
+								t.Fatalf("seed %d: row %d has time %v, want %v", seed, rows+i, v, want)
+							}
+						} else if s.Nulls == 0 && v.IsNull() {
+							t.Fatalf("seed %d: unexpected null in column %q", seed, c.Label)
+						} else if s.Nulls == 1 && !v.IsNull() {
+							t.Fatalf("seed %d: unexpected value %v in column %q", seed, v, c.Label)
+						}
+					}
+				}
+				rows += cr.Len()
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if rows != len(tbl.Data) {
+				t.Fatalf("seed %d: read %d rows, table has %d rows", seed, rows, len(tbl.Data))
+			}
+		}
+	}
+}
@@ -45,6 +45,11 @@ func (src *FromProcedureSpec) Copy() plan.ProcedureSpec {
 	return src
 }
 
+// Cacheable reports that the source always reads the same tables.
+func (src *FromProcedureSpec) Cacheable() bool {
+	return true
+}
+
 func (src *FromProcedureSpec) Cost(inStats []plan.Statistics) (plan.Cost, plan.Statistics) {
 	return plan.Cost{}, plan.Statistics{}
 }
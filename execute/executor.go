@@ -66,6 +66,10 @@ type executionState struct {
 
 	transports []AsyncTransport
 
+	// commits holds the changes staged by the transformations
+	// that are committed when the execution succeeds.
+	commits *commits
+
 	dispatcher *poolDispatcher
 	logger     *zap.Logger
 }
@@ -92,6 +96,8 @@ func (e *executor) createExecutionState(ctx context.Context, p *plan.Spec, a *me
 	}
 
 	ctx, cancel := context.WithCancel(ctx)
+	c := &commits{}
+	ctx = context.WithValue(ctx, commitsKey, c)
 	es := &executionState{
 		p:         p,
 		ctx:       ctx,
@@ -99,13 +105,15 @@ func (e *executor) createExecutionState(ctx context.Context, p *plan.Spec, a *me
 		alloc:     a,
 		resources: p.Resources,
 		results:   make(map[string]flux.Result),
+		commits:   c,
 		// TODO(nathanielc): Have the planner specify the dispatcher throughput
 		dispatcher: newPoolDispatcher(10, e.logger),
 		logger:     e.logger,
 	}
 	v := &createExecutionNodeVisitor{
-		es:    es,
-		nodes: make(map[plan.Node]Node),
+		es:         es,
+		nodes:      make(map[plan.Node]Node),
+		committing: make(map[plan.Node]bool),
 	}
 
 	if err := p.BottomUpWalk(v.Visit); err != nil {
@@ -125,6 +133,10 @@ func (e *executor) createExecutionState(ctx context.Context, p *plan.Spec, a *me
 type createExecutionNodeVisitor struct {
 	es    *executionState
 	nodes map[plan.Node]Node
+
+	// committing marks the nodes that registered a commit
+	// and the nodes downstream of them.
+	committing map[plan.Node]bool
 }
 
 func skipYields(pn plan.Node) plan.Node {
@@ -159,10 +171,20 @@ func (v *createExecutionNodeVisitor) Visit(node plan.Node) error {
 	kind := spec.Kind()
 	id := DatasetIDFromNodeID(node.ID())
 
+	for _, pred := range node.Predecessors() {
+		if v.committing[pred] {
+			v.committing[node] = true
+		}
+	}
+	ncommits := v.es.commits.len()
+
 	if yieldSpec, ok := spec.(plan.YieldProcedureSpec); ok {
 		r := newResult(yieldSpec.YieldName())
 		v.es.results[yieldSpec.YieldName()] = r
 		v.nodes[skipYields(node)].AddTransformation(r)
+		if v.committing[node] {
+			r.hold()
+		}
 		return nil
 	}
 
@@ -199,6 +221,9 @@ func (v *createExecutionNodeVisitor) Visit(node plan.Node) error {
 		if err != nil {
 			return err
 		}
+		if v.es.commits.len() > ncommits {
+			v.committing[node] = true
+		}
 
 		source.SetLabel(string(node.ID()))
 		v.es.sources = append(v.es.sources, source)
@@ -218,6 +243,9 @@ func (v *createExecutionNodeVisitor) Visit(node plan.Node) error {
 		if err != nil {
 			return err
 		}
+		if v.es.commits.len() > ncommits {
+			v.committing[node] = true
+		}
 
 		if ds, ok := ds.(DatasetContext); ok {
 			ds.WithContext(v.es.ctx)
@@ -241,6 +269,9 @@ func (v *createExecutionNodeVisitor) Visit(node plan.Node) error {
 			r := newResult(name)
 			v.es.results[name] = r
 			v.nodes[skipYields(node)].AddTransformation(r)
+			if v.committing[node] {
+				r.hold()
+			}
 		}
 	}
 
@@ -256,6 +287,14 @@ func (es *executionState) abort(err error) {
 
 func (es *executionState) do() {
 	var wg sync.WaitGroup
+	if es.commits.start() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			es.commit()
+		}()
+	}
+
 	for _, src := range es.sources {
 		wg.Add(1)
 		go func(src Source) {
@@ -326,6 +365,46 @@ func (es *executionState) do() {
 	}()
 }
 
+// commit waits for the held results to finish and commits the changes staged
+// by the transformations if no error occurred. The held results are then
+// released with the error of the commit, if any.
+//
+// Only the results downstream of the nodes that registered a commit are held,
+// so the other results finish on their own and can be read in any order.
+func (es *executionState) commit() {
+	var err error
+	for _, r := range es.results {
+		r := r.(*result)
+		if !r.held {
+			continue
+		}
+		select {
+		case <-r.finished:
+			if err == nil {
+				err = r.err
+			}
+		case <-es.ctx.Done():
+		}
+	}
+	if es.ctx.Err() != nil {
+		// The tables of an aborted execution may still be
+		// written, so its results are aborted instead of released.
+		es.abort(es.ctx.Err())
+		return
+	}
+
+	// A result that finished with an error reports its own error.
+	var commitErr error
+	if err == nil {
+		commitErr = es.commits.commit(es.ctx)
+	}
+	for _, r := range es.results {
+		if r := r.(*result); r.held {
+			r.release(commitErr)
+		}
+	}
+}
+
 // Need a unique stream context per execution context
 type executionContext struct {
 	es            *executionState
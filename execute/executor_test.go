@@ -2,16 +2,21 @@ package execute_test
 
 import (
 	"context"
+	"fmt"
 	"math"
+	"strings"
 	"testing"
 	"time"
 
+	arrowmem "github.com/apache/arrow/go/arrow/memory"
 	"github.com/google/go-cmp/cmp"
 	"github.com/influxdata/flux"
 	"github.com/influxdata/flux/codes"
 	"github.com/influxdata/flux/execute"
 	"github.com/influxdata/flux/execute/executetest"
+	"github.com/influxdata/flux/execute/table"
 	_ "github.com/influxdata/flux/fluxinit/static"
+	"github.com/influxdata/flux/internal/errors"
 	"github.com/influxdata/flux/interpreter"
 	"github.com/influxdata/flux/memory"
 	"github.com/influxdata/flux/plan"
@@ -26,6 +31,7 @@ func init() {
 	execute.RegisterSource(executetest.AllocatingFromTestKind, executetest.CreateAllocatingFromSource)
 	execute.RegisterTransformation(executetest.ToTestKind, executetest.CreateToTransformation)
 	plan.RegisterProcedureSpecWithSideEffect(executetest.ToTestKind, executetest.NewToProcedure, executetest.ToTestKind)
+	execute.RegisterTransformation(commitTestKind, createCommitTransformation)
 }
 
 func TestExecutor_Execute(t *testing.T) {
@@ -758,3 +764,228 @@ func TestExecutor_Execute(t *testing.T) {
 		})
 	}
 }
+
+// TestExecutor_CommitIndependentResults checks that a result that does not
+// depend on a commit can be read after a result that waits for it,
+// even when it has more tables than the result can buffer.
+func TestExecutor_CommitIndependentResults(t *testing.T) {
+	var committed bool
+	commit := func(ctx context.Context) error {
+		committed = true
+		return nil
+	}
+
+	input := []*executetest.Table{{
+		ColMeta: []flux.ColMeta{
+			{Label: "_time", Type: flux.TTime},
+			{Label: "_value", Type: flux.TFloat},
+		},
+		Data: [][]interface{}{
+			{execute.Time(0), 1.0},
+		},
+	}}
+	many := make([]*executetest.Table, 1500)
+	for i := range many {
+		many[i] = &executetest.Table{
+			KeyCols: []string{"t0"},
+			ColMeta: []flux.ColMeta{
+				{Label: "_time", Type: flux.TTime},
+				{Label: "t0", Type: flux.TString},
+				{Label: "_value", Type: flux.TFloat},
+			},
+			Data: [][]interface{}{
+				{execute.Time(0), fmt.Sprintf("t%d", i), 1.0},
+			},
+		}
+	}
+	spec := plantest.CreatePlanSpec(&plantest.PlanSpec{
+		Nodes: []plan.Node{
+			plan.CreatePhysicalNode("from-a", executetest.NewFromProcedureSpec(input)),
+			plan.CreatePhysicalNode("a", &commitProcedureSpec{commit: commit}),
+			plan.CreatePhysicalNode("yield-a", executetest.NewYieldProcedureSpec("a")),
+			plan.CreatePhysicalNode("from-b", executetest.NewFromProcedureSpec(many)),
+			plan.CreatePhysicalNode("yield-b", executetest.NewYieldProcedureSpec("b")),
+		},
+		Edges: [][2]int{
+			{0, 1},
+			{1, 2},
+			{3, 4},
+		},
+		Resources: flux.ResourceManagement{
+			ConcurrencyQuota: 1,
+			MemoryBytesQuota: math.MaxInt64,
+		},
+		Now: time.Now(),
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ctx = executetest.NewTestExecuteDependencies().Inject(ctx)
+	results, _, err := execute.NewExecutor(zaptest.NewLogger(t)).Execute(ctx, spec, executetest.UnlimitedAllocator)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]int{"a": 1, "b": len(many)}
+	got := make(map[string]int)
+	for _, name := range []string{"a", "b"} {
+		if err := results[name].Tables().Do(func(tbl flux.Table) error {
+			got[name]++
+			return tbl.Do(func(flux.ColReader) error { return nil })
+		}); err != nil {
+			t.Fatalf("unexpected error reading result %q: %s", name, err)
+		}
+	}
+	if !cmp.Equal(want, got) {
+		t.Errorf("unexpected tables -want/+got:\n%s", cmp.Diff(want, got))
+	}
+	if !committed {
+		t.Error("expected the changes to be committed")
+	}
+}
+
+const commitTestKind = "commit-test"
+
+// commitProcedureSpec passes its input through and registers
+// commit to be called when the execution succeeds. It fails
+// with err when it processes a table if err is set.
+type commitProcedureSpec struct {
+	plan.DefaultCost
+	commit func(ctx context.Context) error
+	err    error
+}
+
+func (s *commitProcedureSpec) Kind() plan.ProcedureKind {
+	return commitTestKind
+}
+
+func (s *commitProcedureSpec) Copy() plan.ProcedureSpec {
+	ns := *s
+	return &ns
+}
+
+type commitTransformation struct {
+	err error
+}
+
+func createCommitTransformation(id execute.DatasetID, mode execute.AccumulationMode, spec plan.ProcedureSpec, a execute.Administration) (execute.Transformation, execute.Dataset, error) {
+	s := spec.(*commitProcedureSpec)
+	if err := execute.OnCommit(a.Context(), s.commit); err != nil {
+		return nil, nil, err
+	}
+	return execute.NewNarrowTransformation(id, &commitTransformation{err: s.err}, a.Allocator())
+}
+
+func (t *commitTransformation) Process(chunk table.Chunk, d *execute.TransportDataset, mem arrowmem.Allocator) error {
+	if t.err != nil {
+		return t.err
+	}
+	chunk.Retain()
+	return d.Process(chunk)
+}
+
+func (t *commitTransformation) Close() error {
+	return nil
+}
+
+func TestExecutor_Commit(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		commitErr   error
+		processErr  error
+		wantCommits []string
+		wantErr     error
+	}{
+		{
+			name:        "success",
+			wantCommits: []string{"a", "b"},
+		},
+		{
+			name:        "commit error",
+			commitErr:   errors.New(codes.Internal, "commit failed"),
+			wantCommits: []string{"a"},
+			wantErr:     errors.New(codes.Internal, "commit failed"),
+		},
+		{
+			name:       "query error",
+			processErr: errors.New(codes.Invalid, "process failed"),
+			wantErr:    errors.New(codes.Invalid, "process failed"),
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var commits []string
+			commit := func(name string, err error) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					commits = append(commits, name)
+					return err
+				}
+			}
+
+			input := []*executetest.Table{{
+				ColMeta: []flux.ColMeta{
+					{Label: "_time", Type: flux.TTime},
+					{Label: "_value", Type: flux.TFloat},
+				},
+				Data: [][]interface{}{
+					{execute.Time(0), 1.0},
+				},
+			}}
+			// The second branch fails the query when it processes a table,
+			// so the changes of neither branch are committed.
+			spec := plantest.CreatePlanSpec(&plantest.PlanSpec{
+				Nodes: []plan.Node{
+					plan.CreatePhysicalNode("from-test", executetest.NewFromProcedureSpec(input)),
+					plan.CreatePhysicalNode("a", &commitProcedureSpec{commit: commit("a", tc.commitErr)}),
+					plan.CreatePhysicalNode("b", &commitProcedureSpec{commit: commit("b", nil), err: tc.processErr}),
+					plan.CreatePhysicalNode("yield-a", executetest.NewYieldProcedureSpec("a")),
+					plan.CreatePhysicalNode("yield-b", executetest.NewYieldProcedureSpec("b")),
+				},
+				Edges: [][2]int{
+					{0, 1},
+					{0, 2},
+					{1, 3},
+					{2, 4},
+				},
+				Resources: flux.ResourceManagement{
+					ConcurrencyQuota: 1,
+					MemoryBytesQuota: math.MaxInt64,
+				},
+				Now: time.Now(),
+			})
+
+			ctx := executetest.NewTestExecuteDependencies().Inject(context.Background())
+			results, _, err := execute.NewExecutor(zaptest.NewLogger(t)).Execute(ctx, spec, executetest.UnlimitedAllocator)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Reading the results waits for the commit.
+			var errs []error
+			for _, name := range []string{"a", "b"} {
+				if err := results[name].Tables().Do(func(tbl flux.Table) error {
+					return tbl.Do(func(flux.ColReader) error { return nil })
+				}); err != nil {
+					errs = append(errs, err)
+				}
+			}
+
+			if !cmp.Equal(tc.wantCommits, commits) {
+				t.Errorf("unexpected commits -want/+got:\n%s", cmp.Diff(tc.wantCommits, commits))
+			}
+			if tc.wantErr == nil {
+				if len(errs) > 0 {
+					t.Fatalf("unexpected errors: %v", errs)
+				}
+				return
+			}
+			if len(errs) == 0 {
+				t.Fatalf("expected error %q, got none", tc.wantErr)
+			}
+			for _, err := range errs {
+				if want, got := tc.wantErr.Error(), err.Error(); !strings.Contains(got, want) {
+					t.Errorf("unexpected error -want/+got:\n\t- %v\n\t+ %v", want, got)
+				}
+			}
+		})
+	}
+}
@@ -17,6 +17,13 @@ type result struct {
 
 	abortErr chan error
 	aborted  chan struct{}
+
+	// held is set when the tables are held until the execution
+	// commits its changes. Finish then records the error of the
+	// result and closes finished, and release finishes the tables.
+	held     bool
+	finished chan struct{}
+	err      error
 }
 
 type resultMessage struct {
@@ -85,6 +92,15 @@ func (s *result) UpdateProcessingTime(id DatasetID, t Time) error {
 }
 
 func (s *result) Finish(id DatasetID, err error) {
+	if s.held {
+		s.err = err
+		close(s.finished)
+		return
+	}
+	s.finish(err)
+}
+
+func (s *result) finish(err error) {
 	if err != nil {
 		select {
 		case s.tables <- resultMessage{
@@ -96,6 +112,22 @@ func (s *result) Finish(id DatasetID, err error) {
 	close(s.tables)
 }
 
+// hold holds the tables of the result until it is released.
+// It must be called before the execution starts.
+func (s *result) hold() {
+	s.held = true
+	s.finished = make(chan struct{})
+}
+
+// release finishes the tables of a held result once it has finished.
+// The error is reported instead of the error of the result if it is set.
+func (s *result) release(err error) {
+	if err == nil {
+		err = s.err
+	}
+	s.finish(err)
+}
+
 // Abort the result with the given error
 func (s *result) abort(err error) {
 	s.mu.Lock()
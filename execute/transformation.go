@@ -116,3 +116,10 @@ func ReplaceTransformation(k plan.ProcedureKind, c CreateTransformation) {
 	}
 	procedureToTransformation[k] = c
 }
+
+// LookupTransformation returns the function that creates the transformation
+// registered for the procedure kind.
+func LookupTransformation(k plan.ProcedureKind) (CreateTransformation, bool) {
+	c, ok := procedureToTransformation[k]
+	return c, ok
+}
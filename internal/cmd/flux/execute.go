@@ -2,18 +2,25 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"os"
 
 	"github.com/influxdata/flux"
-	"github.com/influxdata/flux/csv"
-	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/internal/encoding"
 	"github.com/influxdata/flux/lang"
 	"github.com/influxdata/flux/memory"
 	"github.com/influxdata/flux/runtime"
 )
 
 func executeE(ctx context.Context, script, format string) error {
+	// The cli format predates the shared encodings.
+	if format == "cli" {
+		format = encoding.Table
+	}
+	enc, err := encoding.NewMultiResultEncoder(format)
+	if err != nil {
+		return err
+	}
+
 	c := lang.FluxCompiler{
 		Query: script,
 	}
@@ -31,25 +38,6 @@ func executeE(ctx context.Context, script, format string) error {
 	results := flux.NewResultIteratorFromQuery(q)
 	defer results.Release()
 
-	if format == "cli" {
-		for results.More() {
-			res := results.Next()
-			fmt.Println("Result:", res.Name())
-			if err := res.Tables().Do(func(table flux.Table) error {
-				_, err := execute.NewFormatter(table, nil).WriteTo(os.Stdout)
-				return err
-			}); err != nil {
-				return err
-			}
-		}
-	} else if format == "csv" {
-		config := csv.DefaultEncoderConfig()
-		encoder := csv.NewMultiResultEncoder(config)
-		_, err := encoder.Encode(os.Stdout, results)
-		if err != nil {
-			return err
-		}
-	}
-	results.Release()
-	return results.Err()
+	_, err = enc.Encode(os.Stdout, results)
+	return err
 }
@@ -5,11 +5,13 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 
 	"github.com/influxdata/flux"
 	"github.com/influxdata/flux/codes"
 	"github.com/influxdata/flux/dependencies"
 	"github.com/influxdata/flux/fluxinit"
+	"github.com/influxdata/flux/internal/encoding"
 	"github.com/influxdata/flux/internal/errors"
 	"github.com/opentracing/opentracing-go"
 	"github.com/spf13/cobra"
@@ -101,7 +103,7 @@ func main() {
 	}
 	cmd.Flags().BoolVarP(&flags.ExecScript, "exec", "e", false, "Interpret file argument as a raw flux script")
 	cmd.Flags().StringVar(&flags.Trace, "trace", "", "Trace query execution")
-	cmd.Flags().StringVarP(&flags.Format, "format", "", "cli", "Output format one of: cli,csv. Defaults to cli")
+	cmd.Flags().StringVarP(&flags.Format, "format", "", "cli", "Output format one of: cli,"+strings.Join(encoding.Formats, ",")+". Defaults to cli")
 	cmd.Flag("trace").NoOptDefVal = "jaeger"
 	if err := cmd.Execute(); err != nil {
 		os.Exit(1)
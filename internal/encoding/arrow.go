@@ -0,0 +1,223 @@
+package encoding
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/ipc"
+	arrowmemory "github.com/apache/arrow/go/arrow/memory"
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/iocounter"
+)
+
+// Schema metadata keys written by the ArrowEncoder.
+const (
+	ArrowResultKey         = "flux.result"
+	ArrowGroupKeyKey       = "flux.group_key"
+	ArrowGroupKeyValuesKey = "flux.group_key_values"
+)
+
+// ArrowContentType is the media type of the Arrow IPC streaming format.
+const ArrowContentType = "application/vnd.apache.arrow.stream"
+
+// ArrowEncoder encodes results in the Arrow IPC streaming format.
+//
+// Each table is written as its own stream since the tables of a result
+// may have different schemas. The schema metadata of each stream
+// contains the result name, the comma separated group key columns
+// and the group key values as a JSON array of strings, so the
+// group key of an empty table is not lost.
+// Times are encoded as nanosecond timestamps in UTC.
+type ArrowEncoder struct {
+	mem arrowmemory.Allocator
+}
+
+// NewArrowEncoder creates a new ArrowEncoder.
+func NewArrowEncoder() *ArrowEncoder {
+	return &ArrowEncoder{mem: arrowmemory.NewGoAllocator()}
+}
+
+func (e *ArrowEncoder) Encode(w io.Writer, result flux.Result) (int64, error) {
+	wc := &iocounter.Writer{Writer: w}
+	err := result.Tables().Do(func(tbl flux.Table) error {
+		return e.encodeTable(wc, result.Name(), tbl)
+	})
+	return wc.Count(), err
+}
+
+func (e *ArrowEncoder) encodeTable(w io.Writer, name string, tbl flux.Table) error {
+	tw, err := e.NewTableWriter(w, name, tbl.Key(), tbl.Cols())
+	if err != nil {
+		tbl.Done()
+		return err
+	}
+	if err := tbl.Do(tw.Write); err != nil {
+		_ = tw.Close()
+		return err
+	}
+	return tw.Close()
+}
+
+// ArrowTableWriter writes the buffers of a table as a single stream.
+type ArrowTableWriter struct {
+	enc    *ArrowEncoder
+	schema *arrow.Schema
+	writer *ipc.Writer
+}
+
+// NewTableWriter creates an ArrowTableWriter for a table of the named result.
+// Close must be called after the last buffer of the table is written.
+func (e *ArrowEncoder) NewTableWriter(w io.Writer, name string, key flux.GroupKey, cols []flux.ColMeta) (*ArrowTableWriter, error) {
+	keyCols := key.Cols()
+	labels := make([]string, len(keyCols))
+	for i, c := range keyCols {
+		labels[i] = c.Label
+	}
+	keyValues, err := encodeGroupKeyValues(key)
+	if err != nil {
+		return nil, err
+	}
+	md := arrow.NewMetadata(
+		[]string{ArrowResultKey, ArrowGroupKeyKey, ArrowGroupKeyValuesKey},
+		[]string{name, strings.Join(labels, ","), keyValues},
+	)
+
+	fields := make([]arrow.Field, len(cols))
+	for j, col := range cols {
+		fields[j] = arrow.Field{
+			Name:     col.Label,
+			Type:     arrowType(col.Type),
+			Nullable: true,
+		}
+	}
+	schema := arrow.NewSchema(fields, &md)
+	return &ArrowTableWriter{
+		enc:    e,
+		schema: schema,
+		writer: ipc.NewWriter(w, ipc.WithSchema(schema), ipc.WithAllocator(e.mem)),
+	}, nil
+}
+
+// Write writes a buffer of the table.
+func (tw *ArrowTableWriter) Write(cr flux.ColReader) error {
+	rec := tw.enc.newRecord(tw.schema, cr)
+	defer rec.Release()
+	return tw.writer.Write(rec)
+}
+
+// Close ends the stream of the table.
+func (tw *ArrowTableWriter) Close() error {
+	return tw.writer.Close()
+}
+
+func (e *ArrowEncoder) newRecord(schema *arrow.Schema, cr flux.ColReader) array.Record {
+	n := cr.Len()
+	arrs := make([]array.Interface, len(cr.Cols()))
+	for j, col := range cr.Cols() {
+		b := array.NewBuilder(e.mem, arrowType(col.Type))
+		b.Reserve(n)
+		for i := 0; i < n; i++ {
+			appendArrowValue(b, cr, i, j)
+		}
+		arrs[j] = b.NewArray()
+		b.Release()
+	}
+	rec := array.NewRecord(schema, arrs, int64(n))
+	for _, arr := range arrs {
+		arr.Release()
+	}
+	return rec
+}
+
+// encodeGroupKeyValues formats the values of the group key
+// as a JSON array of strings, with null for a null value.
+func encodeGroupKeyValues(key flux.GroupKey) (string, error) {
+	vs := make([]*string, len(key.Cols()))
+	for j := range vs {
+		v := key.Value(j)
+		if v.IsNull() {
+			continue
+		}
+		var s string
+		switch key.Cols()[j].Type {
+		case flux.TString:
+			s = v.Str()
+		case flux.TInt:
+			s = strconv.FormatInt(v.Int(), 10)
+		case flux.TUInt:
+			s = strconv.FormatUint(v.UInt(), 10)
+		case flux.TFloat:
+			s = strconv.FormatFloat(v.Float(), 'g', -1, 64)
+		case flux.TBool:
+			s = strconv.FormatBool(v.Bool())
+		case flux.TTime:
+			s = strconv.FormatInt(int64(v.Time()), 10)
+		default:
+			execute.PanicUnknownType(key.Cols()[j].Type)
+		}
+		vs[j] = &s
+	}
+	data, err := json.Marshal(vs)
+	return string(data), err
+}
+
+func arrowType(typ flux.ColType) arrow.DataType {
+	switch typ {
+	case flux.TString:
+		return arrow.BinaryTypes.String
+	case flux.TInt:
+		return arrow.PrimitiveTypes.Int64
+	case flux.TUInt:
+		return arrow.PrimitiveTypes.Uint64
+	case flux.TFloat:
+		return arrow.PrimitiveTypes.Float64
+	case flux.TBool:
+		return arrow.FixedWidthTypes.Boolean
+	case flux.TTime:
+		return arrow.FixedWidthTypes.Timestamp_ns
+	default:
+		execute.PanicUnknownType(typ)
+		return nil
+	}
+}
+
+func appendArrowValue(b array.Builder, cr flux.ColReader, i, j int) {
+	switch b := b.(type) {
+	case *array.StringBuilder:
+		if vs := cr.Strings(j); vs.IsValid(i) {
+			b.Append(vs.Value(i))
+			return
+		}
+	case *array.Int64Builder:
+		if vs := cr.Ints(j); vs.IsValid(i) {
+			b.Append(vs.Value(i))
+			return
+		}
+	case *array.Uint64Builder:
+		if vs := cr.UInts(j); vs.IsValid(i) {
+			b.Append(vs.Value(i))
+			return
+		}
+	case *array.Float64Builder:
+		if vs := cr.Floats(j); vs.IsValid(i) {
+			b.Append(vs.Value(i))
+			return
+		}
+	case *array.BooleanBuilder:
+		if vs := cr.Bools(j); vs.IsValid(i) {
+			b.Append(vs.Value(i))
+			return
+		}
+	case *array.TimestampBuilder:
+		if vs := cr.Times(j); vs.IsValid(i) {
+			b.Append(arrow.Timestamp(vs.Value(i)))
+			return
+		}
+	}
+	b.AppendNull()
+}
@@ -0,0 +1,380 @@
+package encoding
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/ipc"
+	arrowmemory "github.com/apache/arrow/go/arrow/memory"
+	"github.com/influxdata/flux"
+	fluxarray "github.com/influxdata/flux/array"
+	fluxarrow "github.com/influxdata/flux/arrow"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/values"
+)
+
+// ArrowDecoder decodes the tables written by the ArrowEncoder.
+//
+// The tables are streamed. Each record of a stream is decoded
+// when the table is read, so only one record of the input
+// is held in memory at a time.
+type ArrowDecoder struct {
+	mem arrowmemory.Allocator
+}
+
+// NewArrowDecoder creates a new ArrowDecoder that allocates
+// the tables with the allocator.
+func NewArrowDecoder(mem arrowmemory.Allocator) *ArrowDecoder {
+	if mem == nil {
+		mem = arrowmemory.NewGoAllocator()
+	}
+	return &ArrowDecoder{mem: mem}
+}
+
+// Decode reads the tables from r and calls f with each table and the
+// name of its result. The next table is read when f returns, so a table
+// must be read within f. A table that is not read is discarded.
+func (d *ArrowDecoder) Decode(r io.Reader, f func(result string, tbl flux.Table) error) error {
+	br := bufio.NewReader(r)
+	for {
+		if _, err := br.Peek(1); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		rdr, err := ipc.NewReader(br, ipc.WithAllocator(d.mem))
+		if err != nil {
+			return errors.Wrap(err, codes.Invalid, "invalid arrow stream")
+		}
+		result, tbl, err := newArrowTable(rdr, d.mem)
+		if err != nil {
+			rdr.Release()
+			return err
+		}
+		err = f(result, tbl)
+		tbl.Done()
+		rdr.Release()
+		if err != nil {
+			return err
+		}
+		if err := tbl.err; err != nil {
+			return err
+		}
+	}
+}
+
+// arrowTable is a table that reads the records of an arrow stream.
+type arrowTable struct {
+	key  flux.GroupKey
+	cols []flux.ColMeta
+	rdr  *ipc.Reader
+	mem  arrowmemory.Allocator
+
+	// first holds the first record of the stream,
+	// which is read to know if the table is empty.
+	first *fluxarrow.TableBuffer
+	empty bool
+	used  int32
+	err   error
+}
+
+func newArrowTable(rdr *ipc.Reader, mem arrowmemory.Allocator) (string, *arrowTable, error) {
+	schema := rdr.Schema()
+	md := schema.Metadata()
+	lookup := func(key string) (string, bool) {
+		if i := md.FindKey(key); i >= 0 {
+			return md.Values()[i], true
+		}
+		return "", false
+	}
+
+	t := &arrowTable{
+		cols: make([]flux.ColMeta, len(schema.Fields())),
+		rdr:  rdr,
+		mem:  mem,
+	}
+	for j, field := range schema.Fields() {
+		typ, err := fluxType(field.Type)
+		if err != nil {
+			return "", nil, errors.Wrapf(err, codes.Inherit, "column %q", field.Name)
+		}
+		t.cols[j] = flux.ColMeta{Label: field.Name, Type: typ}
+	}
+
+	if rdr.Next() {
+		buf, err := t.newBuffer(rdr.Record())
+		if err != nil {
+			return "", nil, err
+		}
+		t.first = buf
+	} else if err := rdr.Err(); err != nil {
+		return "", nil, errors.Wrap(err, codes.Invalid, "invalid arrow stream")
+	}
+	t.empty = t.first == nil
+
+	var labels []string
+	if s, _ := lookup(ArrowGroupKeyKey); s != "" {
+		labels = strings.Split(s, ",")
+	}
+	keyValues, _ := lookup(ArrowGroupKeyValuesKey)
+	key, err := t.groupKey(labels, keyValues)
+	if err != nil {
+		t.Done()
+		return "", nil, err
+	}
+	t.key = key
+	if t.first != nil {
+		t.first.GroupKey = key
+	}
+
+	result, _ := lookup(ArrowResultKey)
+	return result, t, nil
+}
+
+// groupKey builds the group key from the encoded key values. If the
+// values are missing, they are read from the first row of the table.
+func (t *arrowTable) groupKey(labels []string, encoded string) (flux.GroupKey, error) {
+	var vs []*string
+	if encoded != "" {
+		if err := json.Unmarshal([]byte(encoded), &vs); err != nil {
+			return nil, errors.Wrap(err, codes.Invalid, "invalid group key values")
+		}
+		if len(vs) != len(labels) {
+			return nil, errors.Newf(codes.Invalid, "got %d group key values for %d group key columns", len(vs), len(labels))
+		}
+	}
+
+	cols := make([]flux.ColMeta, len(labels))
+	keyValues := make([]values.Value, len(labels))
+	for i, label := range labels {
+		j := execute.ColIdx(label, t.cols)
+		if j < 0 {
+			return nil, errors.Newf(codes.Invalid, "group key column %q is missing from the table", label)
+		}
+		cols[i] = t.cols[j]
+		switch {
+		case vs != nil:
+			v, err := parseGroupKeyValue(t.cols[j].Type, vs[i])
+			if err != nil {
+				return nil, errors.Wrapf(err, codes.Inherit, "group key column %q", label)
+			}
+			keyValues[i] = v
+		case t.first != nil && t.first.Len() > 0:
+			keyValues[i] = execute.ValueForRow(t.first, 0, j)
+		default:
+			keyValues[i] = values.NewNull(flux.SemanticType(t.cols[j].Type))
+		}
+	}
+	return execute.NewGroupKey(cols, keyValues), nil
+}
+
+func parseGroupKeyValue(typ flux.ColType, s *string) (values.Value, error) {
+	if s == nil {
+		return values.NewNull(flux.SemanticType(typ)), nil
+	}
+	var (
+		v   values.Value
+		err error
+	)
+	switch typ {
+	case flux.TString:
+		v = values.NewString(*s)
+	case flux.TInt:
+		var i int64
+		i, err = strconv.ParseInt(*s, 10, 64)
+		v = values.NewInt(i)
+	case flux.TUInt:
+		var u uint64
+		u, err = strconv.ParseUint(*s, 10, 64)
+		v = values.NewUInt(u)
+	case flux.TFloat:
+		var f float64
+		f, err = strconv.ParseFloat(*s, 64)
+		v = values.NewFloat(f)
+	case flux.TBool:
+		var b bool
+		b, err = strconv.ParseBool(*s)
+		v = values.NewBool(b)
+	case flux.TTime:
+		var i int64
+		i, err = strconv.ParseInt(*s, 10, 64)
+		v = values.NewTime(values.Time(i))
+	default:
+		execute.PanicUnknownType(typ)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, codes.Invalid, "invalid group key value %q", *s)
+	}
+	return v, nil
+}
+
+func (t *arrowTable) Key() flux.GroupKey   { return t.key }
+func (t *arrowTable) Cols() []flux.ColMeta { return t.cols }
+func (t *arrowTable) Empty() bool          { return t.empty }
+
+func (t *arrowTable) Do(f func(flux.ColReader) error) error {
+	if !atomic.CompareAndSwapInt32(&t.used, 0, 1) {
+		return errors.New(codes.Internal, "table already read")
+	}
+	defer t.Done()
+
+	if t.first != nil {
+		buf := t.first
+		t.first = nil
+		err := f(buf)
+		buf.Release()
+		if err != nil {
+			return err
+		}
+	}
+	for t.rdr.Next() {
+		buf, err := t.newBuffer(t.rdr.Record())
+		if err != nil {
+			return err
+		}
+		err = f(buf)
+		buf.Release()
+		if err != nil {
+			return err
+		}
+	}
+	if err := t.rdr.Err(); err != nil {
+		return errors.Wrap(err, codes.Invalid, "invalid arrow stream")
+	}
+	return nil
+}
+
+// Done discards the records of the stream that were not read,
+// so the decoder can read the next stream.
+func (t *arrowTable) Done() {
+	atomic.StoreInt32(&t.used, 1)
+	if t.first != nil {
+		t.first.Release()
+		t.first = nil
+	}
+	for t.rdr.Next() {
+	}
+	if err := t.rdr.Err(); err != nil && t.err == nil {
+		t.err = errors.Wrap(err, codes.Invalid, "invalid arrow stream")
+	}
+}
+
+// newBuffer copies the columns of the record into the arrays used by flux.
+func (t *arrowTable) newBuffer(rec array.Record) (*fluxarrow.TableBuffer, error) {
+	if int(rec.NumCols()) != len(t.cols) {
+		return nil, errors.Newf(codes.Invalid, "got a record with %d columns for a table with %d columns", rec.NumCols(), len(t.cols))
+	}
+	vs := make([]fluxarray.Interface, len(t.cols))
+	for j, col := range rec.Columns() {
+		vs[j] = copyArrowColumn(t.cols[j].Type, col, t.mem)
+	}
+	return &fluxarrow.TableBuffer{
+		GroupKey: t.key,
+		Columns:  t.cols,
+		Values:   vs,
+	}, nil
+}
+
+func copyArrowColumn(typ flux.ColType, col array.Interface, mem arrowmemory.Allocator) fluxarray.Interface {
+	n := col.Len()
+	switch typ {
+	case flux.TString:
+		arr := col.(*array.String)
+		b := fluxarray.NewStringBuilder(mem)
+		b.Reserve(n)
+		for i := 0; i < n; i++ {
+			if arr.IsNull(i) {
+				b.AppendNull()
+				continue
+			}
+			b.Append(arr.Value(i))
+		}
+		return b.NewArray()
+	case flux.TInt:
+		arr := col.(*array.Int64)
+		b := fluxarray.NewIntBuilder(mem)
+		b.AppendValues(arr.Int64Values(), validity(col))
+		return b.NewArray()
+	case flux.TUInt:
+		arr := col.(*array.Uint64)
+		b := fluxarray.NewUintBuilder(mem)
+		b.AppendValues(arr.Uint64Values(), validity(col))
+		return b.NewArray()
+	case flux.TFloat:
+		arr := col.(*array.Float64)
+		b := fluxarray.NewFloatBuilder(mem)
+		b.AppendValues(arr.Float64Values(), validity(col))
+		return b.NewArray()
+	case flux.TBool:
+		arr := col.(*array.Boolean)
+		b := fluxarray.NewBooleanBuilder(mem)
+		b.Reserve(n)
+		for i := 0; i < n; i++ {
+			if arr.IsNull(i) {
+				b.AppendNull()
+				continue
+			}
+			b.Append(arr.Value(i))
+		}
+		return b.NewArray()
+	case flux.TTime:
+		arr := col.(*array.Timestamp)
+		b := fluxarray.NewIntBuilder(mem)
+		b.Reserve(n)
+		for i := 0; i < n; i++ {
+			if arr.IsNull(i) {
+				b.AppendNull()
+				continue
+			}
+			b.Append(int64(arr.Value(i)))
+		}
+		return b.NewArray()
+	default:
+		execute.PanicUnknownType(typ)
+		return nil
+	}
+}
+
+// validity returns the validity of each value,
+// or nil if the array has no nulls.
+func validity(col array.Interface) []bool {
+	if col.NullN() == 0 {
+		return nil
+	}
+	valid := make([]bool, col.Len())
+	for i := range valid {
+		valid[i] = col.IsValid(i)
+	}
+	return valid
+}
+
+func fluxType(typ arrow.DataType) (flux.ColType, error) {
+	switch typ.ID() {
+	case arrow.STRING:
+		return flux.TString, nil
+	case arrow.INT64:
+		return flux.TInt, nil
+	case arrow.UINT64:
+		return flux.TUInt, nil
+	case arrow.FLOAT64:
+		return flux.TFloat, nil
+	case arrow.BOOL:
+		return flux.TBool, nil
+	case arrow.TIMESTAMP:
+		if ts := typ.(*arrow.TimestampType); ts.Unit != arrow.Nanosecond {
+			return flux.TInvalid, errors.Newf(codes.Invalid, "unsupported timestamp unit %s", ts.Unit)
+		}
+		return flux.TTime, nil
+	default:
+		return flux.TInvalid, errors.Newf(codes.Invalid, "unsupported arrow type %s", typ)
+	}
+}
@@ -0,0 +1,74 @@
+// Package encoding implements the result encodings
+// supported by the flux command line tools.
+package encoding
+
+import (
+	"io"
+	"strings"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/csv"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/iocounter"
+)
+
+// The names of the supported encodings.
+const (
+	Table = "table"
+	CSV   = "csv"
+	JSON  = "json"
+	Line  = "line"
+	Arrow = "arrow"
+)
+
+// Formats lists the names of the supported encodings.
+var Formats = []string{Table, CSV, JSON, Line, Arrow}
+
+// NewMultiResultEncoder returns an encoder for the named format.
+//
+// Unlike the encoders used to serve queries over HTTP,
+// the returned encoder does not encode query errors into the output.
+// Any error is returned to the caller so the command can fail.
+func NewMultiResultEncoder(format string) (flux.MultiResultEncoder, error) {
+	switch format {
+	case Table:
+		return &multiResultEncoder{encoder: NewTableEncoder()}, nil
+	case CSV:
+		return &multiResultEncoder{
+			encoder:   csv.NewResultEncoder(csv.DefaultEncoderConfig()),
+			delimiter: []byte("\r\n"),
+		}, nil
+	case JSON:
+		return &multiResultEncoder{encoder: NewJSONEncoder()}, nil
+	case Line:
+		return &multiResultEncoder{encoder: NewLineEncoder()}, nil
+	case Arrow:
+		return &multiResultEncoder{encoder: NewArrowEncoder()}, nil
+	default:
+		return nil, errors.Newf(codes.Invalid, "unknown format %q, expected one of: %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+type multiResultEncoder struct {
+	encoder   flux.ResultEncoder
+	delimiter []byte
+}
+
+func (e *multiResultEncoder) Encode(w io.Writer, results flux.ResultIterator) (int64, error) {
+	wc := &iocounter.Writer{Writer: w}
+	defer results.Release()
+
+	for results.More() {
+		if _, err := e.encoder.Encode(wc, results.Next()); err != nil {
+			return wc.Count(), err
+		}
+		if len(e.delimiter) > 0 {
+			if _, err := wc.Write(e.delimiter); err != nil {
+				return wc.Count(), err
+			}
+		}
+	}
+	results.Release()
+	return wc.Count(), results.Err()
+}
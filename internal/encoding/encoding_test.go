@@ -0,0 +1,211 @@
+package encoding_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/arrow/ipc"
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/execute/executetest"
+	"github.com/influxdata/flux/internal/encoding"
+	"github.com/influxdata/flux/memory"
+	"github.com/influxdata/flux/values"
+)
+
+func newResults() flux.ResultIterator {
+	return flux.NewSliceResultIterator([]flux.Result{&executetest.Result{
+		Nm: "_result",
+		Tbls: []*executetest.Table{{
+			KeyCols: []string{"_start", "_stop", "_measurement", "_field", "host"},
+			ColMeta: []flux.ColMeta{
+				{Label: "_start", Type: flux.TTime},
+				{Label: "_stop", Type: flux.TTime},
+				{Label: "_time", Type: flux.TTime},
+				{Label: "_measurement", Type: flux.TString},
+				{Label: "_field", Type: flux.TString},
+				{Label: "host", Type: flux.TString},
+				{Label: "_value", Type: flux.TFloat},
+			},
+			Data: [][]interface{}{
+				{
+					values.ConvertTime(time.Date(2018, 4, 17, 0, 0, 0, 0, time.UTC)),
+					values.ConvertTime(time.Date(2018, 4, 17, 0, 5, 0, 0, time.UTC)),
+					values.ConvertTime(time.Date(2018, 4, 17, 0, 0, 0, 0, time.UTC)),
+					"cpu",
+					"usage",
+					"A",
+					42.0,
+				},
+				{
+					values.ConvertTime(time.Date(2018, 4, 17, 0, 0, 0, 0, time.UTC)),
+					values.ConvertTime(time.Date(2018, 4, 17, 0, 5, 0, 0, time.UTC)),
+					values.ConvertTime(time.Date(2018, 4, 17, 0, 0, 1, 0, time.UTC)),
+					"cpu",
+					"usage",
+					"A",
+					nil,
+				},
+			},
+		}},
+	}})
+}
+
+func TestMultiResultEncoder(t *testing.T) {
+	testCases := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: encoding.JSON,
+			want: `{"result":"_result","table":0,"values":{"_start":"2018-04-17T00:00:00Z","_stop":"2018-04-17T00:05:00Z","_time":"2018-04-17T00:00:00Z","_measurement":"cpu","_field":"usage","host":"A","_value":42}}
+{"result":"_result","table":0,"values":{"_start":"2018-04-17T00:00:00Z","_stop":"2018-04-17T00:05:00Z","_time":"2018-04-17T00:00:01Z","_measurement":"cpu","_field":"usage","host":"A","_value":null}}
+`,
+		},
+		{
+			format: encoding.Line,
+			want: `cpu,host=A usage=42 1523923200000000000
+`,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.format, func(t *testing.T) {
+			enc, err := encoding.NewMultiResultEncoder(tc.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if _, err := enc.Encode(&buf, newResults()); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := buf.String(), tc.want; !cmp.Equal(want, got) {
+				t.Errorf("unexpected output -want/+got:\n%s", cmp.Diff(want, got))
+			}
+		})
+	}
+}
+
+func TestMultiResultEncoder_Arrow(t *testing.T) {
+	enc, err := encoding.NewMultiResultEncoder(encoding.Arrow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := enc.Encode(&buf, newResults()); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := ipc.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Release()
+
+	md := r.Schema().Metadata()
+	if got, want := md.Values()[md.FindKey(encoding.ArrowGroupKeyKey)], "_start,_stop,_measurement,_field,host"; got != want {
+		t.Errorf("unexpected group key -want/+got:\n\t- %s\n\t+ %s", want, got)
+	}
+
+	var rows, nulls int64
+	for r.Next() {
+		rec := r.Record()
+		rows += rec.NumRows()
+		nulls += int64(rec.Column(6).NullN())
+	}
+	if rows != 2 || nulls != 1 {
+		t.Errorf("unexpected record contents: rows=%d nulls=%d", rows, nulls)
+	}
+}
+
+func TestArrowDecoder(t *testing.T) {
+	cols := []flux.ColMeta{
+		{Label: "_start", Type: flux.TTime},
+		{Label: "_time", Type: flux.TTime},
+		{Label: "host", Type: flux.TString},
+		{Label: "_value", Type: flux.TInt},
+	}
+	tables := func() []*executetest.Table {
+		return []*executetest.Table{
+			{
+				KeyCols: []string{"_start", "host"},
+				ColMeta: cols,
+				Data: [][]interface{}{
+					{execute.Time(0), execute.Time(1), "A", int64(1)},
+					{execute.Time(0), execute.Time(2), "A", nil},
+				},
+			},
+			{
+				// The group key of an empty table is kept.
+				KeyCols:   []string{"_start", "host"},
+				KeyValues: []interface{}{execute.Time(10), "B"},
+				ColMeta:   cols,
+			},
+			{
+				KeyCols: []string{"_start", "host"},
+				ColMeta: cols,
+				Data: [][]interface{}{
+					{execute.Time(0), execute.Time(3), nil, int64(3)},
+				},
+			},
+		}
+	}
+	results := flux.NewSliceResultIterator([]flux.Result{
+		&executetest.Result{Nm: "_result", Tbls: tables()},
+		&executetest.Result{Nm: "other", Tbls: []*executetest.Table{{
+			ColMeta: []flux.ColMeta{{Label: "_value", Type: flux.TFloat}},
+			Data:    [][]interface{}{{1.5}},
+		}}},
+	})
+
+	enc, err := encoding.NewMultiResultEncoder(encoding.Arrow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := enc.Encode(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+
+	mem := &memory.Allocator{}
+	var (
+		names []string
+		got   []*executetest.Table
+	)
+	if err := encoding.NewArrowDecoder(mem).Decode(&buf, func(result string, tbl flux.Table) error {
+		names = append(names, result)
+		if result != "_result" {
+			// A table that is not read is discarded.
+			return nil
+		}
+		tb, err := executetest.ConvertTable(tbl)
+		if err != nil {
+			return err
+		}
+		got = append(got, tb)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"_result", "_result", "_result", "other"}; !cmp.Equal(want, names) {
+		t.Errorf("unexpected results -want/+got:\n%s", cmp.Diff(want, names))
+	}
+
+	want := tables()
+	executetest.NormalizeTables(want)
+	executetest.NormalizeTables(got)
+	if !cmp.Equal(want, got) {
+		t.Errorf("unexpected tables -want/+got:\n%s", cmp.Diff(want, got))
+	}
+	if n := mem.Allocated(); n != 0 {
+		t.Errorf("caught memory leak: %d bytes were not released", n)
+	}
+}
+
+func TestMultiResultEncoder_UnknownFormat(t *testing.T) {
+	if _, err := encoding.NewMultiResultEncoder("xml"); err == nil {
+		t.Fatal("expected error for unknown format")
+	}
+}
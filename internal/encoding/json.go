@@ -0,0 +1,132 @@
+package encoding
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"math"
+	"time"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/iocounter"
+	"github.com/influxdata/flux/values"
+)
+
+// JSONEncoder encodes results as newline delimited JSON.
+// Every row is written as a single object that contains
+// the result name, the table index within the result and
+// the row values. The values are nested in a "values" object
+// with one property per column, so columns named "result"
+// or "table" do not collide with the other properties.
+//
+// Times are encoded as RFC3339 strings and null values as JSON null.
+// Float values that cannot be represented in JSON are encoded
+// as the strings "NaN", "+Inf" and "-Inf".
+type JSONEncoder struct{}
+
+// NewJSONEncoder creates a new JSONEncoder.
+func NewJSONEncoder() *JSONEncoder {
+	return &JSONEncoder{}
+}
+
+func (e *JSONEncoder) Encode(w io.Writer, result flux.Result) (int64, error) {
+	wc := &iocounter.Writer{Writer: w}
+	bw := bufio.NewWriter(wc)
+
+	name, err := json.Marshal(result.Name())
+	if err != nil {
+		return 0, err
+	}
+
+	tableID := 0
+	if err := result.Tables().Do(func(tbl flux.Table) error {
+		cols := tbl.Cols()
+		labels := make([][]byte, len(cols))
+		for j, col := range cols {
+			label, err := json.Marshal(col.Label)
+			if err != nil {
+				return err
+			}
+			labels[j] = label
+		}
+		prefix, err := json.Marshal(tableID)
+		if err != nil {
+			return err
+		}
+		tableID++
+
+		return tbl.Do(func(cr flux.ColReader) error {
+			for i, n := 0, cr.Len(); i < n; i++ {
+				_, _ = bw.WriteString(`{"result":`)
+				_, _ = bw.Write(name)
+				_, _ = bw.WriteString(`,"table":`)
+				_, _ = bw.Write(prefix)
+				_, _ = bw.WriteString(`,"values":{`)
+				for j := range cols {
+					if j > 0 {
+						_ = bw.WriteByte(',')
+					}
+					_, _ = bw.Write(labels[j])
+					_ = bw.WriteByte(':')
+					v, err := marshalJSONValue(cr, i, j)
+					if err != nil {
+						return err
+					}
+					_, _ = bw.Write(v)
+				}
+				if _, err := bw.WriteString("}}\n"); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}); err != nil {
+		_ = bw.Flush()
+		return wc.Count(), err
+	}
+	err = bw.Flush()
+	return wc.Count(), err
+}
+
+func marshalJSONValue(cr flux.ColReader, i, j int) ([]byte, error) {
+	switch typ := cr.Cols()[j].Type; typ {
+	case flux.TString:
+		if vs := cr.Strings(j); vs.IsValid(i) {
+			return json.Marshal(vs.Value(i))
+		}
+	case flux.TInt:
+		if vs := cr.Ints(j); vs.IsValid(i) {
+			return json.Marshal(vs.Value(i))
+		}
+	case flux.TUInt:
+		if vs := cr.UInts(j); vs.IsValid(i) {
+			return json.Marshal(vs.Value(i))
+		}
+	case flux.TFloat:
+		if vs := cr.Floats(j); vs.IsValid(i) {
+			switch f := vs.Value(i); {
+			case math.IsNaN(f):
+				return []byte(`"NaN"`), nil
+			case math.IsInf(f, 1):
+				return []byte(`"+Inf"`), nil
+			case math.IsInf(f, -1):
+				return []byte(`"-Inf"`), nil
+			default:
+				return json.Marshal(f)
+			}
+		}
+	case flux.TBool:
+		if vs := cr.Bools(j); vs.IsValid(i) {
+			return json.Marshal(vs.Value(i))
+		}
+	case flux.TTime:
+		if vs := cr.Times(j); vs.IsValid(i) {
+			t := values.Time(vs.Value(i)).Time()
+			return json.Marshal(t.Format(time.RFC3339Nano))
+		}
+	default:
+		execute.PanicUnknownType(typ)
+	}
+	return []byte("null"), nil
+}
@@ -0,0 +1,159 @@
+package encoding
+
+import (
+	"io"
+	"sort"
+	"time"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/iocounter"
+	"github.com/influxdata/flux/values"
+	protocol "github.com/influxdata/line-protocol"
+)
+
+// LineEncoder encodes results as InfluxDB line protocol.
+//
+// The measurement is read from the _measurement column and the timestamp
+// from the _time column. The remaining string columns in the group key
+// are written as tags. When a table has both a _field and a _value column,
+// each row produces the field named by _field. Every other column that is
+// not part of the group key is written as a field.
+// Null values are omitted and rows without any field are skipped.
+type LineEncoder struct{}
+
+// NewLineEncoder creates a new LineEncoder.
+func NewLineEncoder() *LineEncoder {
+	return &LineEncoder{}
+}
+
+func (e *LineEncoder) Encode(w io.Writer, result flux.Result) (int64, error) {
+	wc := &iocounter.Writer{Writer: w}
+	enc := protocol.NewEncoder(wc)
+	enc.FailOnFieldErr(true)
+	enc.SetFieldSortOrder(protocol.SortFields)
+
+	err := result.Tables().Do(func(tbl flux.Table) error {
+		return encodeLineTable(enc, tbl)
+	})
+	return wc.Count(), err
+}
+
+func encodeLineTable(enc *protocol.Encoder, tbl flux.Table) error {
+	cols := tbl.Cols()
+	measurementIdx := execute.ColIdx("_measurement", cols)
+	if measurementIdx < 0 || cols[measurementIdx].Type != flux.TString {
+		return errors.New(codes.Invalid, "line protocol output requires a _measurement column of type string")
+	}
+	timeIdx := execute.ColIdx(execute.DefaultTimeColLabel, cols)
+	if timeIdx < 0 || cols[timeIdx].Type != flux.TTime {
+		return errors.Newf(codes.Invalid, "line protocol output requires a %s column of type time", execute.DefaultTimeColLabel)
+	}
+	fieldIdx := execute.ColIdx("_field", cols)
+	valueIdx := execute.ColIdx(execute.DefaultValueColLabel, cols)
+	if fieldIdx < 0 || valueIdx < 0 || cols[fieldIdx].Type != flux.TString {
+		fieldIdx, valueIdx = -1, -1
+	}
+
+	// Tags are collected in sorted order as line protocol expects.
+	var tagCols, fieldCols []int
+	key := tbl.Key()
+	for j, col := range cols {
+		switch {
+		case j == measurementIdx, j == timeIdx, j == fieldIdx, j == valueIdx:
+		case col.Label == execute.DefaultStartColLabel, col.Label == execute.DefaultStopColLabel:
+		case key.HasCol(col.Label):
+			if col.Type == flux.TString {
+				tagCols = append(tagCols, j)
+			}
+		default:
+			fieldCols = append(fieldCols, j)
+		}
+	}
+	sort.Slice(tagCols, func(i, j int) bool {
+		return cols[tagCols[i]].Label < cols[tagCols[j]].Label
+	})
+
+	m := &lineMetric{}
+	return tbl.Do(func(cr flux.ColReader) error {
+		for i, n := 0, cr.Len(); i < n; i++ {
+			m.tags, m.fields = m.tags[:0], m.fields[:0]
+			if cr.Strings(measurementIdx).IsNull(i) || cr.Times(timeIdx).IsNull(i) {
+				continue
+			}
+			m.name = cr.Strings(measurementIdx).Value(i)
+			m.t = values.Time(cr.Times(timeIdx).Value(i)).Time()
+
+			if fieldIdx >= 0 && cr.Strings(fieldIdx).IsValid(i) {
+				if v := lineFieldValue(cr, i, valueIdx); v != nil {
+					m.fields = append(m.fields, &protocol.Field{Key: cr.Strings(fieldIdx).Value(i), Value: v})
+				}
+			}
+			for _, j := range tagCols {
+				if vs := cr.Strings(j); vs.IsValid(i) && vs.Value(i) != "" {
+					m.tags = append(m.tags, &protocol.Tag{Key: cols[j].Label, Value: vs.Value(i)})
+				}
+			}
+			for _, j := range fieldCols {
+				if v := lineFieldValue(cr, i, j); v != nil {
+					m.fields = append(m.fields, &protocol.Field{Key: cols[j].Label, Value: v})
+				}
+			}
+			if len(m.fields) == 0 {
+				continue
+			}
+			if _, err := enc.Encode(m); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// lineFieldValue returns the value of a column in a form
+// accepted by the line protocol encoder, or nil if it is null.
+func lineFieldValue(cr flux.ColReader, i, j int) interface{} {
+	switch typ := cr.Cols()[j].Type; typ {
+	case flux.TString:
+		if vs := cr.Strings(j); vs.IsValid(i) {
+			return vs.Value(i)
+		}
+	case flux.TInt:
+		if vs := cr.Ints(j); vs.IsValid(i) {
+			return vs.Value(i)
+		}
+	case flux.TUInt:
+		if vs := cr.UInts(j); vs.IsValid(i) {
+			return vs.Value(i)
+		}
+	case flux.TFloat:
+		if vs := cr.Floats(j); vs.IsValid(i) {
+			return vs.Value(i)
+		}
+	case flux.TBool:
+		if vs := cr.Bools(j); vs.IsValid(i) {
+			return vs.Value(i)
+		}
+	case flux.TTime:
+		if vs := cr.Times(j); vs.IsValid(i) {
+			return vs.Value(i)
+		}
+	default:
+		execute.PanicUnknownType(typ)
+	}
+	return nil
+}
+
+type lineMetric struct {
+	name   string
+	tags   []*protocol.Tag
+	fields []*protocol.Field
+	t      time.Time
+}
+
+func (m *lineMetric) Name() string                 { return m.name }
+func (m *lineMetric) TagList() []*protocol.Tag     { return m.tags }
+func (m *lineMetric) FieldList() []*protocol.Field { return m.fields }
+func (m *lineMetric) Time() time.Time              { return m.t }
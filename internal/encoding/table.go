@@ -0,0 +1,30 @@
+package encoding
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/iocounter"
+)
+
+// TableEncoder encodes results as human readable tables.
+type TableEncoder struct{}
+
+// NewTableEncoder creates a new TableEncoder.
+func NewTableEncoder() *TableEncoder {
+	return &TableEncoder{}
+}
+
+func (e *TableEncoder) Encode(w io.Writer, result flux.Result) (int64, error) {
+	wc := &iocounter.Writer{Writer: w}
+	if _, err := fmt.Fprintln(wc, "Result:", result.Name()); err != nil {
+		return wc.Count(), err
+	}
+	err := result.Tables().Do(func(tbl flux.Table) error {
+		_, err := execute.NewFormatter(tbl, nil).WriteTo(wc)
+		return err
+	})
+	return wc.Count(), err
+}
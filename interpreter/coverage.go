@@ -0,0 +1,199 @@
+package interpreter
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/influxdata/flux/ast"
+	"github.com/influxdata/flux/semantic"
+)
+
+// CoverageKind is the kind of source block that is covered.
+type CoverageKind int
+
+const (
+	// CoverageFunction counts the calls of a function.
+	CoverageFunction CoverageKind = iota
+	// CoverageConsequent counts how often the test of a conditional expression was true.
+	CoverageConsequent
+	// CoverageAlternate counts how often the test of a conditional expression was false.
+	CoverageAlternate
+	// CoverageOption counts the evaluations of an option statement.
+	CoverageOption
+)
+
+func (k CoverageKind) String() string {
+	switch k {
+	case CoverageFunction:
+		return "function"
+	case CoverageConsequent:
+		return "then"
+	case CoverageAlternate:
+		return "else"
+	case CoverageOption:
+		return "option"
+	default:
+		return fmt.Sprintf("CoverageKind(%d)", int(k))
+	}
+}
+
+// CoverageBlock is a source block and the number of times it was evaluated.
+// Both branches of a conditional expression have the location of the conditional expression.
+type CoverageBlock struct {
+	Kind  CoverageKind
+	File  string
+	Start ast.Position
+	End   ast.Position
+	// Name is the name a function is assigned to, if any.
+	Name  string
+	Count int64
+}
+
+type coverageKey struct {
+	kind       CoverageKind
+	file       string
+	start, end ast.Position
+}
+
+// Coverage records which functions, conditional branches and option statements
+// are evaluated by the interpreter, including the functions that are run
+// by the compiler, such as the functions passed to map() and filter().
+//
+// Blocks are identified by their source location, so the counts of
+// packages that are evaluated several times are added up.
+// Nodes without a source file are not recorded.
+type Coverage struct {
+	mu     sync.Mutex
+	counts map[coverageKey]int64
+	names  map[coverageKey]string
+}
+
+// NewCoverage creates an empty Coverage.
+func NewCoverage() *Coverage {
+	return &Coverage{
+		counts: make(map[coverageKey]int64),
+		names:  make(map[coverageKey]string),
+	}
+}
+
+// Inject adds the coverage to the context so the interpreter records to it.
+func (c *Coverage) Inject(ctx context.Context) context.Context {
+	return context.WithValue(ctx, coverageContextKey, c)
+}
+
+// GetCoverage returns the coverage of the context, or nil if there is none.
+func GetCoverage(ctx context.Context) *Coverage {
+	c, _ := ctx.Value(coverageContextKey).(*Coverage)
+	return c
+}
+
+// Add registers the blocks within the node, so blocks that are never
+// evaluated are part of the coverage.
+func (c *Coverage) Add(node semantic.Node) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	names := make(map[*semantic.FunctionExpression]string)
+	semantic.Walk(semantic.CreateVisitor(func(n semantic.Node) {
+		switch n := n.(type) {
+		case *semantic.NativeVariableAssignment:
+			if fn, ok := n.Init.(*semantic.FunctionExpression); ok {
+				names[fn] = n.Identifier.Name.Name()
+			}
+		case *semantic.FunctionExpression:
+			if k, ok := newCoverageKey(CoverageFunction, n.Loc); ok {
+				c.add(k)
+				if name, ok := names[n]; ok {
+					c.names[k] = name
+				}
+			}
+		case *semantic.ConditionalExpression:
+			for _, kind := range []CoverageKind{CoverageConsequent, CoverageAlternate} {
+				if k, ok := newCoverageKey(kind, n.Loc); ok {
+					c.add(k)
+				}
+			}
+		case *semantic.OptionStatement:
+			if k, ok := newCoverageKey(CoverageOption, n.Loc); ok {
+				c.add(k)
+			}
+		}
+	}), node)
+}
+
+// add registers a block without changing its count.
+func (c *Coverage) add(k coverageKey) {
+	if _, ok := c.counts[k]; !ok {
+		c.counts[k] = 0
+	}
+}
+
+func (c *Coverage) hit(kind CoverageKind, loc semantic.Loc) {
+	k, ok := newCoverageKey(kind, loc)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	c.counts[k]++
+	c.mu.Unlock()
+}
+
+// Blocks returns the blocks ordered by their location.
+func (c *Coverage) Blocks() []CoverageBlock {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	blocks := make([]CoverageBlock, 0, len(c.counts))
+	for k, n := range c.counts {
+		blocks = append(blocks, CoverageBlock{
+			Kind:  k.kind,
+			File:  k.file,
+			Start: k.start,
+			End:   k.end,
+			Name:  c.names[k],
+			Count: n,
+		})
+	}
+	sort.Slice(blocks, func(i, j int) bool {
+		a, b := blocks[i], blocks[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Start != b.Start {
+			return a.Start.Less(b.Start)
+		}
+		if a.End != b.End {
+			return b.End.Less(a.End)
+		}
+		return a.Kind < b.Kind
+	})
+	return blocks
+}
+
+func newCoverageKey(kind CoverageKind, loc semantic.Loc) (coverageKey, bool) {
+	if loc.File == "" {
+		return coverageKey{}, false
+	}
+	return coverageKey{
+		kind:  kind,
+		file:  loc.File,
+		start: loc.Start,
+		end:   loc.End,
+	}, true
+}
+
+// RecordCoverage records the evaluation of a block if the context has a coverage.
+func RecordCoverage(ctx context.Context, kind CoverageKind, loc semantic.Loc) {
+	GetCoverage(ctx).Record(kind, loc)
+}
+
+// Record records the evaluation of a block. It does nothing if the coverage is nil.
+// It is used by evaluators outside of the interpreter, such as the compiler,
+// so the functions they run are covered too.
+func (c *Coverage) Record(kind CoverageKind, loc semantic.Loc) {
+	if c != nil {
+		c.hit(kind, loc)
+	}
+}
@@ -0,0 +1,101 @@
+package interpreter_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux/ast"
+	"github.com/influxdata/flux/interpreter"
+	"github.com/influxdata/flux/semantic"
+	"github.com/influxdata/flux/values"
+)
+
+func loc(file string, startLine, startCol, endLine, endCol int) semantic.Loc {
+	return semantic.Loc{
+		File:  file,
+		Start: ast.Position{Line: startLine, Column: startCol},
+		End:   ast.Position{Line: endLine, Column: endCol},
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	// f = (x) => if x then 1 else 2
+	// g = () => 3
+	// option o = 1
+	fn := func(l semantic.Loc, params []string, body semantic.Expression) *semantic.FunctionExpression {
+		fp := &semantic.FunctionParameters{}
+		for _, p := range params {
+			fp.List = append(fp.List, &semantic.FunctionParameter{Key: &semantic.Identifier{Name: semantic.NewSymbol(p)}})
+		}
+		return &semantic.FunctionExpression{
+			Loc:        l,
+			Parameters: fp,
+			Block: &semantic.Block{
+				Body: []semantic.Statement{&semantic.ReturnStatement{Argument: body}},
+			},
+		}
+	}
+	pkg := &semantic.Package{
+		Package: "main",
+		Files: []*semantic.File{{
+			Body: []semantic.Statement{
+				&semantic.NativeVariableAssignment{
+					Identifier: &semantic.Identifier{Name: semantic.NewSymbol("f")},
+					Init: fn(loc("a.flux", 1, 5, 1, 31), []string{"x"}, &semantic.ConditionalExpression{
+						Loc:        loc("a.flux", 1, 12, 1, 31),
+						Test:       &semantic.IdentifierExpression{Name: semantic.NewSymbol("x")},
+						Consequent: &semantic.IntegerLiteral{Value: 1},
+						Alternate:  &semantic.IntegerLiteral{Value: 2},
+					}),
+				},
+				&semantic.NativeVariableAssignment{
+					Identifier: &semantic.Identifier{Name: semantic.NewSymbol("g")},
+					Init:       fn(loc("a.flux", 2, 5, 2, 12), nil, &semantic.IntegerLiteral{Value: 3}),
+				},
+				&semantic.OptionStatement{
+					Loc: loc("a.flux", 3, 1, 3, 13),
+					Assignment: &semantic.NativeVariableAssignment{
+						Identifier: &semantic.Identifier{Name: semantic.NewSymbol("o")},
+						Init:       &semantic.IntegerLiteral{Value: 1},
+					},
+				},
+				// Blocks without a source file are not recorded.
+				&semantic.NativeVariableAssignment{
+					Identifier: &semantic.Identifier{Name: semantic.NewSymbol("h")},
+					Init:       fn(semantic.Loc{}, nil, &semantic.IntegerLiteral{Value: 4}),
+				},
+			},
+		}},
+	}
+
+	coverage := interpreter.NewCoverage()
+	ctx := coverage.Inject(context.Background())
+	scope := values.NewScope()
+	itrp := interpreter.NewInterpreter(nil, nil)
+	if _, err := itrp.Eval(ctx, pkg, scope, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	f, ok := scope.Lookup("f")
+	if !ok {
+		t.Fatal("f is not defined")
+	}
+	for _, x := range []bool{true, true} {
+		args := values.NewObjectWithValues(map[string]values.Value{"x": values.NewBool(x)})
+		if _, err := f.Function().Call(ctx, args); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []interpreter.CoverageBlock{
+		{Kind: interpreter.CoverageFunction, File: "a.flux", Start: ast.Position{Line: 1, Column: 5}, End: ast.Position{Line: 1, Column: 31}, Name: "f", Count: 2},
+		{Kind: interpreter.CoverageConsequent, File: "a.flux", Start: ast.Position{Line: 1, Column: 12}, End: ast.Position{Line: 1, Column: 31}, Count: 2},
+		{Kind: interpreter.CoverageAlternate, File: "a.flux", Start: ast.Position{Line: 1, Column: 12}, End: ast.Position{Line: 1, Column: 31}, Count: 0},
+		{Kind: interpreter.CoverageFunction, File: "a.flux", Start: ast.Position{Line: 2, Column: 5}, End: ast.Position{Line: 2, Column: 12}, Name: "g", Count: 0},
+		{Kind: interpreter.CoverageOption, File: "a.flux", Start: ast.Position{Line: 3, Column: 1}, End: ast.Position{Line: 3, Column: 13}, Count: 1},
+	}
+	if got := coverage.Blocks(); !cmp.Equal(want, got) {
+		t.Errorf("unexpected coverage -want/+got:\n%s", cmp.Diff(want, got))
+	}
+}
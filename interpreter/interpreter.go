@@ -72,6 +72,9 @@ type SideEffect struct {
 // Eval evaluates the expressions composing a Flux package and returns any side effects that occurred during this evaluation.
 func (itrp *Interpreter) Eval(ctx context.Context, node semantic.Node, scope values.Scope, importer Importer) ([]SideEffect, error) {
 	itrp.sideEffects = itrp.sideEffects[:0]
+	if c := GetCoverage(ctx); c != nil {
+		c.Add(node)
+	}
 	if err := itrp.doRoot(ctx, node, scope, importer); err != nil {
 		return nil, err
 	}
@@ -138,7 +141,10 @@ func (itrp *Interpreter) doPackageClause(pkg *semantic.PackageClause) error {
 
 type key int
 
-const packagesKey key = iota
+const (
+	packagesKey key = iota
+	coverageContextKey
+)
 
 type Packages map[string]*Package
 
@@ -263,6 +269,7 @@ func (irtp *Interpreter) evaluateProfilerOption(ctx context.Context, pkg values.
 }
 
 func (itrp *Interpreter) doOptionStatement(ctx context.Context, s *semantic.OptionStatement, scope values.Scope) (values.Value, error) {
+	RecordCoverage(ctx, CoverageOption, s.Loc)
 	switch a := s.Assignment.(type) {
 	case *semantic.NativeVariableAssignment:
 		init, err := itrp.doExpression(ctx, a.Init, scope)
@@ -496,8 +503,10 @@ func (itrp *Interpreter) doExpression(ctx context.Context, expr semantic.Express
 			return nil, errors.New(codes.Invalid, "conditional test expression is not a boolean value")
 		}
 		if t.Bool() {
+			RecordCoverage(ctx, CoverageConsequent, e.Loc)
 			return itrp.doExpression(ctx, e.Consequent, scope)
 		}
+		RecordCoverage(ctx, CoverageAlternate, e.Loc)
 		return itrp.doExpression(ctx, e.Alternate, scope)
 	case *semantic.FunctionExpression:
 		// In the case of builtin functions this function value is shared across all query requests
@@ -899,6 +908,8 @@ func (f function) doCall(ctx context.Context, args Arguments) (values.Value, err
 		f.itrp = &Interpreter{}
 	}
 
+	RecordCoverage(ctx, CoverageFunction, f.e.Loc)
+
 	blockScope := f.scope.Nest(nil)
 	if f.e.Parameters != nil {
 	PARAMETERS:
@@ -0,0 +1,379 @@
+package lang
+
+import (
+	"bytes"
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/encoding"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/memory"
+	"github.com/influxdata/flux/plan"
+)
+
+const (
+	// DefaultResultCacheTTL is how long results are cached by default.
+	DefaultResultCacheTTL = time.Minute
+
+	// DefaultResultCacheMaxSize is the default size in bytes
+	// of the results held by a cache.
+	DefaultResultCacheMaxSize = 64 * 1024 * 1024
+)
+
+// ResultCacheConfig configures a ResultCache.
+type ResultCacheConfig struct {
+	// TTL is how long the results of a query are kept.
+	// The default is DefaultResultCacheTTL.
+	TTL time.Duration
+
+	// MaxSize is the size in bytes of the results that are kept.
+	// The results of a query that are larger are not cached and
+	// the least recently used results are removed to make room
+	// for new ones. The default is DefaultResultCacheMaxSize.
+	MaxSize int64
+}
+
+// ResultCache holds the results of queries in memory
+// so a program that runs the same plan again reads them
+// from the cache instead of executing the plan.
+//
+// Results are keyed by a canonical hash of the physical plan and the
+// scope of the program, and are stored as Arrow buffers. Only plans whose
+// sources are bounded, deterministic reads are cached, see plan.CacheableSource.
+// A plan whose functions call a function defined by the query, a function
+// that reads data such as from(), or a package that is not known to be pure
+// is not cached either.
+//
+// The key does not identify the caller or the dependencies of the query,
+// such as the credentials used to read data, the secrets or the HTTP client.
+// Programs that share a cache must set a scope with WithResultCacheScope that
+// identifies them, unless they read the same data with the same dependencies.
+//
+// Plans that write data with a function such as to() are never cached.
+// A program that writes data invalidates every cache when it starts and
+// when it is done, whether or not it uses a cache.
+type ResultCache struct {
+	ttl     time.Duration
+	maxSize int64
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	size    int64
+	// generation is incremented on each invalidation so results
+	// that were read before the invalidation are not stored.
+	generation uint64
+}
+
+// NewResultCache creates a new ResultCache.
+func NewResultCache(config ResultCacheConfig) *ResultCache {
+	if config.TTL <= 0 {
+		config.TTL = DefaultResultCacheTTL
+	}
+	if config.MaxSize <= 0 {
+		config.MaxSize = DefaultResultCacheMaxSize
+	}
+	return &ResultCache{
+		ttl:     config.TTL,
+		maxSize: config.MaxSize,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// dataWrites counts the programs that wrote data. The results that
+// were read before a write are stale, so each cache entry records
+// the count when its results were read.
+var dataWrites uint64
+
+// invalidateResultCaches invalidates the results of every cache.
+func invalidateResultCaches() {
+	atomic.AddUint64(&dataWrites, 1)
+}
+
+// cacheEntry holds the results of a plan.
+type cacheEntry struct {
+	key     string
+	results []cachedResult
+	size    int64
+	expires time.Time
+	// writes is the value of dataWrites when the results were read.
+	writes uint64
+}
+
+// cachedResult holds the tables of a result as a sequence of Arrow streams.
+type cachedResult struct {
+	name string
+	data []byte
+}
+
+// Invalidate removes all of the cached results.
+// It should be called by anything other than a program
+// that writes to the data read by queries.
+func (c *ResultCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+	c.size = 0
+	c.generation++
+}
+
+// Len returns the number of plans with cached results.
+// Results that are stale because data was written are removed.
+func (c *ResultCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	writes := atomic.LoadUint64(&dataWrites)
+	for _, elem := range c.entries {
+		if elem.Value.(*cacheEntry).writes != writes {
+			c.remove(elem)
+		}
+	}
+	return len(c.entries)
+}
+
+// get returns the results cached for the key.
+// The tables of the results are allocated with mem.
+func (c *ResultCache) get(key string, mem *memory.Allocator) (map[string]flux.Result, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if !time.Now().Before(entry.expires) || entry.writes != atomic.LoadUint64(&dataWrites) {
+		c.remove(elem)
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+
+	results := make(map[string]flux.Result, len(entry.results))
+	for _, res := range entry.results {
+		results[res.name] = &cacheResult{cachedResult: res, mem: mem}
+	}
+	return results, true
+}
+
+// put stores the results for the key unless the cache was invalidated
+// since the given generation or data was written since the given count.
+func (c *ResultCache) put(key string, generation, writes uint64, results []cachedResult) {
+	var size int64
+	for _, res := range results {
+		size += int64(len(res.data))
+	}
+	if size > c.maxSize {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation != c.generation || writes != atomic.LoadUint64(&dataWrites) {
+		return
+	}
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+	for c.size+size > c.maxSize {
+		c.remove(c.lru.Back())
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{
+		key:     key,
+		results: results,
+		size:    size,
+		expires: time.Now().Add(c.ttl),
+		writes:  writes,
+	})
+	c.size += size
+}
+
+func (c *ResultCache) remove(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*cacheEntry)
+	delete(c.entries, entry.key)
+	c.size -= entry.size
+}
+
+// record wraps the results of a plan so their tables are stored
+// in the cache once every result has been read.
+func (c *ResultCache) record(key string, results map[string]flux.Result) map[string]flux.Result {
+	c.mu.Lock()
+	generation := c.generation
+	c.mu.Unlock()
+	writes := atomic.LoadUint64(&dataWrites)
+
+	r := &resultRecorder{
+		cache:      c,
+		key:        key,
+		generation: generation,
+		writes:     writes,
+		results:    make([]cachedResult, 0, len(results)),
+		remaining:  len(results),
+	}
+	if r.remaining == 0 {
+		c.put(key, generation, writes, nil)
+		return results
+	}
+
+	recorded := make(map[string]flux.Result, len(results))
+	for name, res := range results {
+		recorded[name] = &recordingResult{Result: res, rec: r}
+	}
+	return recorded
+}
+
+// resultRecorder collects the encoded results of a plan.
+type resultRecorder struct {
+	cache      *ResultCache
+	key        string
+	generation uint64
+	writes     uint64
+
+	mu        sync.Mutex
+	results   []cachedResult
+	remaining int
+	size      int64
+}
+
+// tooLarge reports whether the results read so far
+// are larger than the cache can hold.
+func (r *resultRecorder) tooLarge(n int) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.size+int64(n) > r.cache.maxSize
+}
+
+// done adds a result that has been read.
+func (r *resultRecorder) done(res cachedResult) {
+	r.mu.Lock()
+	r.results = append(r.results, res)
+	r.size += int64(len(res.data))
+	r.remaining--
+	finished := r.remaining == 0
+	r.mu.Unlock()
+
+	if finished {
+		r.cache.put(r.key, r.generation, r.writes, r.results)
+	}
+}
+
+// recordingResult encodes the tables of a result as they are read.
+type recordingResult struct {
+	flux.Result
+	rec *resultRecorder
+}
+
+func (r *recordingResult) Tables() flux.TableIterator {
+	return r
+}
+
+func (r *recordingResult) Do(f func(flux.Table) error) error {
+	var (
+		buf      bytes.Buffer
+		complete = true
+	)
+	enc := encoding.NewArrowEncoder()
+	if err := r.Result.Tables().Do(func(tbl flux.Table) error {
+		if !complete {
+			return f(tbl)
+		}
+		tw, err := enc.NewTableWriter(&buf, r.Name(), tbl.Key(), tbl.Cols())
+		if err != nil {
+			return err
+		}
+		rt := &recordingTable{Table: tbl, w: tw, buf: &buf, rec: r.rec}
+		err = f(rt)
+		if !rt.read && rt.err == nil && tbl.Empty() {
+			// An empty table does not need to be read.
+			rt.err = tw.Close()
+			rt.read = true
+		}
+		if !rt.read || rt.err != nil {
+			// The results will not be cached,
+			// so the next tables are passed through.
+			complete = false
+			buf = bytes.Buffer{}
+		}
+		return err
+	}); err != nil {
+		return err
+	}
+	if complete {
+		r.rec.done(cachedResult{name: r.Name(), data: buf.Bytes()})
+	}
+	return nil
+}
+
+// errResultTooLarge is returned when the results of
+// a plan are larger than the cache can hold.
+var errResultTooLarge = errors.New(codes.ResourceExhausted, "results are too large to be cached")
+
+// recordingTable writes the buffers of a table as they are read.
+type recordingTable struct {
+	flux.Table
+	w    *encoding.ArrowTableWriter
+	buf  *bytes.Buffer
+	rec  *resultRecorder
+	read bool
+	err  error
+}
+
+func (t *recordingTable) Do(f func(flux.ColReader) error) error {
+	t.read = true
+	err := t.Table.Do(func(cr flux.ColReader) error {
+		if t.err == nil {
+			if t.rec.tooLarge(t.buf.Len()) {
+				t.err = errResultTooLarge
+			} else {
+				t.err = t.w.Write(cr)
+			}
+		}
+		return f(cr)
+	})
+	if err == nil && t.err == nil {
+		t.err = t.w.Close()
+	}
+	return err
+}
+
+// cacheResult reads a result from the cache.
+type cacheResult struct {
+	cachedResult
+	mem *memory.Allocator
+}
+
+func (r *cacheResult) Name() string {
+	return r.name
+}
+
+func (r *cacheResult) Tables() flux.TableIterator {
+	return r
+}
+
+func (r *cacheResult) Do(f func(flux.Table) error) error {
+	dec := encoding.NewArrowDecoder(r.mem)
+	return dec.Decode(bytes.NewReader(r.data), func(_ string, tbl flux.Table) error {
+		return f(tbl)
+	})
+}
+
+// writesData reports whether the plan has a side effect other than
+// returning results, such as writing data with to().
+func writesData(ps *plan.Spec) bool {
+	var writes bool
+	_ = ps.BottomUpWalk(func(node plan.Node) error {
+		spec := node.ProcedureSpec()
+		if _, ok := spec.(plan.YieldProcedureSpec); ok {
+			return nil
+		}
+		if plan.HasSideEffect(spec) {
+			writes = true
+		}
+		return nil
+	})
+	return writes
+}
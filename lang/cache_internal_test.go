@@ -0,0 +1,393 @@
+package lang
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/execute/executetest"
+	"github.com/influxdata/flux/interpreter"
+	"github.com/influxdata/flux/memory"
+	"github.com/influxdata/flux/plan"
+	"github.com/influxdata/flux/semantic"
+	"github.com/influxdata/flux/values"
+)
+
+func init() {
+	execute.RegisterSource(executetest.FromTestKind, executetest.CreateFromSource)
+	execute.RegisterTransformation(executetest.ToTestKind, executetest.CreateToTransformation)
+	plan.RegisterProcedureSpecWithSideEffect(executetest.ToTestKind, executetest.NewToProcedure, executetest.ToTestKind)
+}
+
+func cacheTestTables() []*executetest.Table {
+	return []*executetest.Table{
+		{
+			KeyCols: []string{"host"},
+			ColMeta: []flux.ColMeta{
+				{Label: "_time", Type: flux.TTime},
+				{Label: "host", Type: flux.TString},
+				{Label: "_value", Type: flux.TFloat},
+			},
+			Data: [][]interface{}{
+				{execute.Time(1), "a", 1.0},
+				{execute.Time(2), "a", nil},
+			},
+		},
+		{
+			KeyCols: []string{"host"},
+			ColMeta: []flux.ColMeta{
+				{Label: "_time", Type: flux.TTime},
+				{Label: "host", Type: flux.TString},
+				{Label: "_value", Type: flux.TFloat},
+			},
+			Data: [][]interface{}{
+				{execute.Time(1), "b", 3.0},
+			},
+		},
+	}
+}
+
+// newCacheTestPlan creates a plan where each node is
+// the successor of the previous one.
+func newCacheTestPlan(now time.Time, nodes ...plan.Node) *plan.Spec {
+	for i := 1; i < len(nodes); i++ {
+		nodes[i-1].AddSuccessors(nodes[i])
+		nodes[i].AddPredecessors(nodes[i-1])
+	}
+	ps := plan.NewPlanSpec()
+	ps.Roots[nodes[len(nodes)-1]] = struct{}{}
+	ps.Resources.ConcurrencyQuota = 1
+	ps.Now = now
+	return ps
+}
+
+// newCacheTestProgram creates a program that reads the test tables.
+// If write is set, the tables are written with a side effect.
+// The program does not use a cache if cache is nil.
+func newCacheTestProgram(cache *ResultCache, write bool) *Program {
+	nodes := []plan.Node{
+		plan.CreatePhysicalNode("from-test", executetest.NewFromProcedureSpec(cacheTestTables())),
+	}
+	if write {
+		nodes = append(nodes, plan.CreatePhysicalNode("to-test", &executetest.ToProcedureSpec{}))
+	}
+	nodes = append(nodes, plan.CreatePhysicalNode("yield", executetest.NewYieldProcedureSpec("_result")))
+	return &Program{
+		PlanSpec: newCacheTestPlan(time.Unix(0, 0), nodes...),
+		opts:     applyOptions(WithResultCache(cache)),
+	}
+}
+
+// runCacheTestProgram runs the program and returns its
+// results along with the result cache metadata.
+func runCacheTestProgram(t *testing.T, p *Program) (map[string][]*executetest.Table, []interface{}) {
+	t.Helper()
+	mem := &memory.Allocator{}
+	q, err := p.Start(context.Background(), mem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := make(map[string][]*executetest.Table)
+	for res := range q.Results() {
+		if err := res.Tables().Do(func(tbl flux.Table) error {
+			tb, err := executetest.ConvertTable(tbl)
+			if err != nil {
+				return err
+			}
+			results[res.Name()] = append(results[res.Name()], tb)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	q.Done()
+	if err := q.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n := mem.Allocated(); n != 0 {
+		t.Errorf("caught memory leak: %d bytes were not released", n)
+	}
+	for _, tables := range results {
+		executetest.NormalizeTables(tables)
+	}
+	return results, q.Statistics().Metadata["flux/result-cache"]
+}
+
+func TestResultCache(t *testing.T) {
+	want := map[string][]*executetest.Table{"_result": cacheTestTables()}
+	executetest.NormalizeTables(want["_result"])
+
+	cache := NewResultCache(ResultCacheConfig{})
+	for _, wantCache := range []string{"miss", "hit", "hit"} {
+		got, md := runCacheTestProgram(t, newCacheTestProgram(cache, false))
+		if !cmp.Equal(want, got) {
+			t.Errorf("unexpected results -want/+got:\n%s", cmp.Diff(want, got))
+		}
+		if want, got := []interface{}{wantCache}, md; !cmp.Equal(want, got) {
+			t.Errorf("unexpected result cache metadata -want/+got:\n\t- %v\n\t+ %v", want, got)
+		}
+	}
+	if want, got := 1, cache.Len(); want != got {
+		t.Errorf("unexpected number of cached plans -want/+got:\n\t- %v\n\t+ %v", want, got)
+	}
+
+	// A program that writes data is not cached
+	// and removes the cached results.
+	if _, md := runCacheTestProgram(t, newCacheTestProgram(cache, true)); md != nil {
+		t.Errorf("unexpected result cache metadata for a program that writes data: %v", md)
+	}
+	if want, got := 0, cache.Len(); want != got {
+		t.Errorf("unexpected number of cached plans -want/+got:\n\t- %v\n\t+ %v", want, got)
+	}
+	if _, md := runCacheTestProgram(t, newCacheTestProgram(cache, false)); !cmp.Equal([]interface{}{"miss"}, md) {
+		t.Errorf("expected a cache miss after the cache was invalidated, got %v", md)
+	}
+
+	// A program that writes data without a cache
+	// also invalidates the cached results.
+	runCacheTestProgram(t, newCacheTestProgram(nil, true))
+	if _, md := runCacheTestProgram(t, newCacheTestProgram(cache, false)); !cmp.Equal([]interface{}{"miss"}, md) {
+		t.Errorf("expected a cache miss after another program wrote data, got %v", md)
+	}
+}
+
+func TestResultCache_Limits(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config ResultCacheConfig
+	}{
+		{
+			name:   "ttl",
+			config: ResultCacheConfig{TTL: time.Nanosecond},
+		},
+		{
+			name:   "max size",
+			config: ResultCacheConfig{MaxSize: 16},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			cache := NewResultCache(tc.config)
+			for i := 0; i < 2; i++ {
+				_, md := runCacheTestProgram(t, newCacheTestProgram(cache, false))
+				if want, got := []interface{}{"miss"}, md; !cmp.Equal(want, got) {
+					t.Errorf("unexpected result cache metadata -want/+got:\n\t- %v\n\t+ %v", want, got)
+				}
+			}
+		})
+	}
+}
+
+// boundsProcedureSpec is a procedure spec for testing plan keys.
+type boundsProcedureSpec struct {
+	plan.DefaultCost
+	Bounds   flux.Bounds
+	Fn       func()
+	Resolved interpreter.ResolvedFunction
+	// Remote marks a source that is not cacheable.
+	Remote bool
+}
+
+func (s *boundsProcedureSpec) Kind() plan.ProcedureKind { return "bounds-test" }
+func (s *boundsProcedureSpec) Copy() plan.ProcedureSpec { ns := *s; return &ns }
+func (s *boundsProcedureSpec) Cacheable() bool          { return !s.Remote }
+
+func TestPlanKey(t *testing.T) {
+	absolute := flux.Bounds{
+		Start: flux.Time{Absolute: time.Unix(0, 0)},
+		Stop:  flux.Time{Absolute: time.Unix(60, 0)},
+	}
+	relative := flux.Bounds{
+		Start: flux.Time{IsRelative: true, Relative: -time.Minute},
+		Stop:  flux.Now,
+	}
+	scopedKey := func(spec *boundsProcedureSpec, now time.Time, scope string) (string, bool) {
+		spec.Bounds.Now = now
+		return planKey(newCacheTestPlan(now,
+			plan.CreatePhysicalNode("bounds", spec),
+			plan.CreatePhysicalNode("yield", executetest.NewYieldProcedureSpec("_result")),
+		), scope)
+	}
+	key := func(spec *boundsProcedureSpec, now time.Time) (string, bool) {
+		return scopedKey(spec, now, "")
+	}
+
+	for _, tc := range []struct {
+		name      string
+		p, q      *boundsProcedureSpec
+		nowP      time.Time
+		nowQ      time.Time
+		wantEqual bool
+	}{
+		{
+			name:      "absolute bounds",
+			p:         &boundsProcedureSpec{Bounds: absolute},
+			q:         &boundsProcedureSpec{Bounds: absolute},
+			nowP:      time.Unix(100, 0),
+			nowQ:      time.Unix(200, 0),
+			wantEqual: true,
+		},
+		{
+			name:      "relative bounds with the same now",
+			p:         &boundsProcedureSpec{Bounds: relative},
+			q:         &boundsProcedureSpec{Bounds: relative},
+			nowP:      time.Unix(100, 0),
+			nowQ:      time.Unix(100, 0),
+			wantEqual: true,
+		},
+		{
+			name: "relative bounds",
+			p:    &boundsProcedureSpec{Bounds: relative},
+			q:    &boundsProcedureSpec{Bounds: relative},
+			nowP: time.Unix(100, 0),
+			nowQ: time.Unix(200, 0),
+		},
+		{
+			name: "relative and absolute bounds",
+			p:    &boundsProcedureSpec{Bounds: relative},
+			q:    &boundsProcedureSpec{Bounds: absolute},
+			nowP: time.Unix(120, 0),
+			nowQ: time.Unix(120, 0),
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			p, ok := key(tc.p, tc.nowP)
+			if !ok {
+				t.Fatal("expected plan to have a key")
+			}
+			q, ok := key(tc.q, tc.nowQ)
+			if !ok {
+				t.Fatal("expected plan to have a key")
+			}
+			if got := p == q; tc.wantEqual != got {
+				t.Errorf("unexpected key equality -want/+got:\n\t- %v\n\t+ %v", tc.wantEqual, got)
+			}
+		})
+	}
+
+	// A function holds state that cannot be hashed.
+	if _, ok := key(&boundsProcedureSpec{Bounds: absolute, Fn: func() {}}, time.Unix(0, 0)); ok {
+		t.Error("expected a plan with a function to have no key")
+	}
+
+	// The results of a source that is not cacheable are not cached.
+	if _, ok := key(&boundsProcedureSpec{Bounds: absolute, Remote: true}, time.Unix(0, 0)); ok {
+		t.Error("expected a plan with a source that is not cacheable to have no key")
+	}
+
+	// Programs with different scopes do not share results.
+	p, _ := scopedKey(&boundsProcedureSpec{Bounds: absolute}, time.Unix(0, 0), "org-a")
+	q, _ := scopedKey(&boundsProcedureSpec{Bounds: absolute}, time.Unix(0, 0), "org-b")
+	if p == q {
+		t.Error("expected plans with different scopes to have different keys")
+	}
+}
+
+func TestPlanKey_Functions(t *testing.T) {
+	fnType := semantic.NewFunctionType(semantic.BasicInt, nil)
+	newFunction := func(sideEffect bool) values.Value {
+		return values.NewFunction("f", fnType, func(ctx context.Context, args values.Object) (values.Value, error) {
+			return values.NewInt(0), nil
+		}, sideEffect)
+	}
+	newPackage := func(path string) values.Value {
+		return interpreter.NewPackageWithValues("p", path, values.NewObjectWithValues(map[string]values.Value{
+			"f": newFunction(true),
+			"g": newFunction(false),
+		}))
+	}
+	// fn returns () => body
+	fn := func(body semantic.Expression) *semantic.FunctionExpression {
+		return &semantic.FunctionExpression{
+			Block: &semantic.Block{
+				Body: []semantic.Statement{&semantic.ReturnStatement{Argument: body}},
+			},
+		}
+	}
+	ident := func(name string) *semantic.IdentifierExpression {
+		return &semantic.IdentifierExpression{Name: semantic.NewSymbol(name)}
+	}
+	call := func(callee semantic.Expression) *semantic.CallExpression {
+		return &semantic.CallExpression{Callee: callee, Arguments: &semantic.ObjectExpression{}}
+	}
+	member := func(object, property string) *semantic.MemberExpression {
+		return &semantic.MemberExpression{Object: ident(object), Property: semantic.NewSymbol(property)}
+	}
+
+	// The outermost scope is the prelude, and the
+	// values defined by the query are nested in it.
+	prelude := values.NewScope()
+	prelude.Set("pure", newFunction(false))
+	prelude.Set("write", newFunction(true))
+	prelude.Set("from", newFunction(false))
+	prelude.Set("buckets", newFunction(false))
+	prelude.Set("today", newFunction(false))
+	scope := prelude.Nest(nil)
+	scope.Set("userFn", newFunction(false))
+	for _, path := range []string{
+		"strings",
+		"date",
+		"http",
+		"experimental/csv",
+		"experimental/influxdb",
+		"experimental/prometheus",
+		"experimental/bigtable",
+		"socket",
+		"sql",
+	} {
+		scope.Set(path[strings.LastIndex(path, "/")+1:], newPackage(path))
+	}
+
+	for _, tc := range []struct {
+		name    string
+		fn      *semantic.FunctionExpression
+		wantOK  bool
+		wantNow bool
+	}{
+		{name: "function", fn: fn(call(ident("pure"))), wantOK: true},
+		{name: "side effect", fn: fn(call(ident("write")))},
+		{name: "function defined by the query", fn: fn(call(ident("userFn")))},
+		{name: "from", fn: fn(call(ident("from")))},
+		{name: "buckets", fn: fn(call(ident("buckets")))},
+		{name: "today", fn: fn(call(ident("today"))), wantOK: true, wantNow: true},
+		{name: "pure package function", fn: fn(call(member("strings", "g"))), wantOK: true},
+		{name: "date package", fn: fn(call(member("date", "g"))), wantOK: true, wantNow: true},
+		{name: "http package", fn: fn(call(member("http", "g")))},
+		{name: "experimental/csv package", fn: fn(call(member("csv", "g")))},
+		{name: "experimental/influxdb package", fn: fn(call(member("influxdb", "g")))},
+		{name: "prometheus package", fn: fn(call(member("prometheus", "g")))},
+		{name: "bigtable package", fn: fn(call(member("bigtable", "g")))},
+		{name: "socket package", fn: fn(call(member("socket", "g")))},
+		{name: "sql package", fn: fn(call(member("sql", "g")))},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			key := func(now time.Time) (string, bool) {
+				spec := &boundsProcedureSpec{
+					Resolved: interpreter.ResolvedFunction{Fn: tc.fn, Scope: scope},
+				}
+				return planKey(newCacheTestPlan(now,
+					plan.CreatePhysicalNode("bounds", spec),
+					plan.CreatePhysicalNode("yield", executetest.NewYieldProcedureSpec("_result")),
+				), "")
+			}
+			p, ok := key(time.Unix(0, 0))
+			if tc.wantOK != ok {
+				t.Fatalf("unexpected key -want/+got:\n\t- %v\n\t+ %v", tc.wantOK, ok)
+			}
+			if !ok {
+				return
+			}
+			// A function that reads now has a different key for each value of now.
+			q, _ := key(time.Unix(1, 0))
+			if got := p != q; tc.wantNow != got {
+				t.Errorf("unexpected key for a different now -want/+got:\n\t- %v\n\t+ %v", tc.wantNow, got)
+			}
+		})
+	}
+}
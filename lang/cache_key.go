@@ -0,0 +1,374 @@
+package lang
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/interpreter"
+	"github.com/influxdata/flux/plan"
+	"github.com/influxdata/flux/semantic"
+	"github.com/influxdata/flux/values"
+)
+
+// errNotCacheable is returned when a plan contains a value
+// that cannot be hashed in a canonical way.
+var errNotCacheable = errors.New(codes.Unimplemented, "plan cannot be cached")
+
+var (
+	boundsType           = reflect.TypeOf(flux.Bounds{})
+	fluxTimeType         = reflect.TypeOf(flux.Time{})
+	timeType             = reflect.TypeOf(time.Time{})
+	monoType             = reflect.TypeOf(semantic.MonoType{})
+	polyType             = reflect.TypeOf(semantic.PolyType{})
+	resolvedFunctionType = reflect.TypeOf(interpreter.ResolvedFunction{})
+)
+
+// purePackages holds the packages whose functions compute their results
+// from their arguments alone. The functions of any other package may read
+// data from outside of the query, such as the response of a request or the
+// rows of a database, so a function that uses them may return different values.
+//
+// The value is true for the packages whose functions read the value of now,
+// such as the date functions that take a duration relative to now.
+var purePackages = map[string]bool{
+	"array":                false,
+	"date":                 true,
+	"dict":                 false,
+	"experimental/array":   false,
+	"experimental/bitwise": false,
+	"experimental/record":  false,
+	"json":                 false,
+	"math":                 false,
+	"regexp":               false,
+	"strings":              false,
+	"timezone":             false,
+	"types":                false,
+}
+
+// preludeReads holds the functions of the prelude that read data
+// from outside of the query when they are called.
+// The other functions of the prelude compute their results from their arguments.
+var preludeReads = map[string]bool{
+	"buckets":     true,
+	"cardinality": true,
+	"from":        true,
+}
+
+// preludeNow holds the functions of the prelude that read the value of now.
+var preludeNow = map[string]bool{
+	"today": true,
+}
+
+// planKey returns a canonical hash of the physical plan and the scope.
+//
+// Only plans whose sources implement plan.CacheableSource and report
+// that they are cacheable have a key. The hash covers the kind, procedure
+// spec and bounds of each node along with the shape of the plan. Bounds and relative times are hashed
+// as the absolute times they resolve to, so queries over a fixed time
+// range have the same key whatever the value of now. The value of now
+// itself is only hashed when a function reads it.
+//
+// It returns false if the plan cannot be hashed, for example
+// when a function captures a value defined by the query.
+func planKey(ps *plan.Spec, scope string) (string, bool) {
+	k := &keyHasher{
+		now:   ps.Now,
+		nodes: make(map[plan.Node][]byte),
+	}
+	roots := make([][]byte, 0, len(ps.Roots))
+	for root := range ps.Roots {
+		sum, err := k.node(root)
+		if err != nil {
+			return "", false
+		}
+		roots = append(roots, sum)
+	}
+	sort.Slice(roots, func(i, j int) bool {
+		return string(roots[i]) < string(roots[j])
+	})
+
+	h := sha256.New()
+	writeString(h, scope)
+	for _, sum := range roots {
+		_, _ = h.Write(sum)
+	}
+	if k.usesNow {
+		writeInt(h, k.now.UnixNano())
+	}
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+// keyHasher hashes the nodes of a plan.
+type keyHasher struct {
+	now     time.Time
+	usesNow bool
+	nodes   map[plan.Node][]byte
+
+	// visiting holds the pointers being hashed to detect cycles.
+	visiting map[uintptr]bool
+}
+
+// node hashes a node with its predecessors.
+func (k *keyHasher) node(n plan.Node) ([]byte, error) {
+	if sum, ok := k.nodes[n]; ok {
+		return sum, nil
+	}
+	preds := n.Predecessors()
+	if len(preds) == 0 {
+		if s, ok := n.ProcedureSpec().(plan.CacheableSource); !ok || !s.Cacheable() {
+			return nil, errNotCacheable
+		}
+	}
+
+	h := sha256.New()
+	writeString(h, string(n.Kind()))
+	if err := k.value(h, reflect.ValueOf(n.ProcedureSpec())); err != nil {
+		return nil, err
+	}
+	if ppn, ok := n.(*plan.PhysicalPlanNode); ok {
+		if err := k.value(h, reflect.ValueOf(ppn.TriggerSpec)); err != nil {
+			return nil, err
+		}
+	}
+	if b := n.Bounds(); b != nil {
+		writeInt(h, int64(b.Start))
+		writeInt(h, int64(b.Stop))
+	}
+	writeInt(h, int64(len(preds)))
+	for _, pred := range preds {
+		sum, err := k.node(pred)
+		if err != nil {
+			return nil, err
+		}
+		_, _ = h.Write(sum)
+	}
+	sum := h.Sum(nil)
+	k.nodes[n] = sum
+	return sum, nil
+}
+
+// value writes a value to the hash by walking it with reflection.
+func (k *keyHasher) value(h hash.Hash, v reflect.Value) error {
+	if !v.IsValid() {
+		writeString(h, "<nil>")
+		return nil
+	}
+
+	typ := v.Type()
+	writeString(h, typ.String())
+	switch typ {
+	case boundsType:
+		if v.CanInterface() {
+			b := v.Interface().(flux.Bounds)
+			writeInt(h, b.Start.Time(b.Now).UnixNano())
+			writeInt(h, b.Stop.Time(b.Now).UnixNano())
+			return nil
+		}
+	case fluxTimeType:
+		if v.CanInterface() {
+			writeInt(h, v.Interface().(flux.Time).Time(k.now).UnixNano())
+			return nil
+		}
+	case timeType:
+		if !v.CanInterface() {
+			return errNotCacheable
+		}
+		writeInt(h, v.Interface().(time.Time).UnixNano())
+		return nil
+	case monoType, polyType:
+		// A type that cannot be read is the type of the expression
+		// or value that holds it, which is hashed on its own.
+		if v.CanInterface() {
+			writeString(h, v.Interface().(interface{ String() string }).String())
+		}
+		return nil
+	case resolvedFunctionType:
+		if !v.CanInterface() {
+			return errNotCacheable
+		}
+		return k.function(h, v.Interface().(interpreter.ResolvedFunction))
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			writeInt(h, 1)
+		} else {
+			writeInt(h, 0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		writeInt(h, v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		writeInt(h, int64(v.Uint()))
+	case reflect.Float32, reflect.Float64:
+		writeInt(h, int64(math.Float64bits(v.Float())))
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		writeInt(h, int64(math.Float64bits(real(c))))
+		writeInt(h, int64(math.Float64bits(imag(c))))
+	case reflect.String:
+		writeString(h, v.String())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			writeString(h, "<nil>")
+			return nil
+		}
+		writeInt(h, int64(v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			if err := k.value(h, v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		// The entries are hashed on their own and
+		// sorted since the order of a map is random.
+		entries := make([][]byte, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			eh := sha256.New()
+			if err := k.value(eh, iter.Key()); err != nil {
+				return err
+			}
+			if err := k.value(eh, iter.Value()); err != nil {
+				return err
+			}
+			entries = append(entries, eh.Sum(nil))
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return string(entries[i]) < string(entries[j])
+		})
+		writeInt(h, int64(len(entries)))
+		for _, sum := range entries {
+			_, _ = h.Write(sum)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			writeString(h, typ.Field(i).Name)
+			if err := k.value(h, v.Field(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Ptr:
+		if v.IsNil() {
+			writeString(h, "<nil>")
+			return nil
+		}
+		p := v.Pointer()
+		if k.visiting[p] {
+			return errNotCacheable
+		}
+		if k.visiting == nil {
+			k.visiting = make(map[uintptr]bool)
+		}
+		k.visiting[p] = true
+		defer delete(k.visiting, p)
+		return k.value(h, v.Elem())
+	case reflect.Interface:
+		if v.IsNil() {
+			writeString(h, "<nil>")
+			return nil
+		}
+		return k.value(h, v.Elem())
+	default:
+		// Functions, channels and raw pointers hold state
+		// that is not part of the value.
+		if (v.Kind() == reflect.Func || v.Kind() == reflect.Chan) && v.IsNil() {
+			writeString(h, "<nil>")
+			return nil
+		}
+		return errNotCacheable
+	}
+	return nil
+}
+
+// function hashes a resolved function. The identifiers that were not
+// inlined when the function was resolved are read from its scope when
+// the function is called. The functions of the prelude and the pure
+// packages are the same for every query, but values defined by the query
+// are not, so a function that uses them cannot be hashed. Neither can a
+// function that uses a function with side effects or that reads data from
+// outside of the query, since calling it may return a different value.
+func (k *keyHasher) function(h hash.Hash, fn interpreter.ResolvedFunction) error {
+	if err := k.value(h, reflect.ValueOf(fn.Fn)); err != nil {
+		return err
+	}
+	if fn.Fn == nil || fn.Scope == nil {
+		return nil
+	}
+
+	var err error
+	semantic.Walk(semantic.CreateVisitor(func(node semantic.Node) {
+		if err != nil {
+			return
+		}
+		id, ok := node.(*semantic.IdentifierExpression)
+		if !ok {
+			return
+		}
+		name := id.Name.Name()
+		if name == interpreter.NowOption {
+			k.usesNow = true
+			return
+		}
+		v, ok := fn.Scope.Lookup(name)
+		if !ok {
+			// Parameters and variables defined by the function.
+			return
+		}
+		if opt, ok := v.(*values.Option); ok {
+			v = opt.Value
+		}
+		switch v := v.(type) {
+		case values.Package:
+			usesNow, ok := purePackages[v.Path()]
+			if !ok {
+				err = errNotCacheable
+			} else if usesNow {
+				k.usesNow = true
+			}
+		case interpreter.Resolver:
+			err = errNotCacheable
+		case values.Function:
+			// The functions defined by the query are not hashed.
+			if !inPrelude(fn.Scope, name) || v.HasSideEffect() || preludeReads[name] {
+				err = errNotCacheable
+			} else if preludeNow[name] {
+				k.usesNow = true
+			}
+		default:
+			err = errNotCacheable
+		}
+	}), fn.Fn)
+	return err
+}
+
+// inPrelude reports whether the name is defined by the prelude,
+// which is the outermost scope, and not by the query.
+func inPrelude(scope values.Scope, name string) bool {
+	for ; scope != nil; scope = scope.Pop() {
+		if _, ok := scope.LocalLookup(name); ok {
+			return scope.Pop() == nil
+		}
+	}
+	return false
+}
+
+func writeInt(h hash.Hash, n int64) {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(n))
+	_, _ = h.Write(buf[:])
+}
+
+func writeString(h hash.Hash, s string) {
+	writeInt(h, int64(len(s)))
+	_, _ = h.Write([]byte(s))
+}
@@ -53,6 +53,9 @@ type compileOptions struct {
 
 	extern flux.ASTHandle
 
+	cache      *ResultCache
+	cacheScope string
+
 	planOptions struct {
 		logical  []plan.LogicalOption
 		physical []plan.PhysicalOption
@@ -75,6 +78,25 @@ func WithExtern(extern flux.ASTHandle) CompileOption {
 	}
 }
 
+// WithResultCache reads the results of the program from the cache
+// when it holds the results of the same plan, and stores them otherwise.
+func WithResultCache(cache *ResultCache) CompileOption {
+	return func(o *compileOptions) {
+		o.cache = cache
+	}
+}
+
+// WithResultCacheScope sets the scope of the results of the program in the cache.
+// Programs only read the results cached by programs with the same scope.
+// The scope should identify the caller, such as its organization and
+// the credentials it reads data with, and any dependency that changes
+// what the program reads.
+func WithResultCacheScope(scope string) CompileOption {
+	return func(o *compileOptions) {
+		o.cacheScope = scope
+	}
+}
+
 func defaultOptions() *compileOptions {
 	o := new(compileOptions)
 	return o
@@ -302,12 +324,35 @@ func (p *Program) Start(ctx context.Context, alloc *memory.Allocator) (flux.Quer
 	q.stats.Metadata.Add("flux/query-plan",
 		fmt.Sprintf("%v", plan.Formatted(p.PlanSpec, plan.WithDetails())))
 
+	var cacheKey string
+	if writesData(p.PlanSpec) {
+		// The cached results may read the data written
+		// by the program, so they are invalidated before
+		// and after the program runs.
+		invalidateResultCaches()
+		q.onDone = invalidateResultCaches
+	} else if cache := p.resultCache(); cache != nil {
+		if key, ok := planKey(p.PlanSpec, p.opts.cacheScope); ok {
+			if resultMap, ok := cache.get(key, q.alloc); ok {
+				q.stats.Metadata.Add("flux/result-cache", "hit")
+				q.wg.Add(1)
+				go p.processResults(cctx, q, resultMap)
+				return q, nil
+			}
+			q.stats.Metadata.Add("flux/result-cache", "miss")
+			cacheKey = key
+		}
+	}
+
 	e := execute.NewExecutor(p.Logger)
 	resultMap, md, err := e.Execute(cctx, p.PlanSpec, q.alloc)
 	if err != nil {
 		s.Finish()
 		return nil, err
 	}
+	if cacheKey != "" {
+		resultMap = p.opts.cache.record(cacheKey, resultMap)
+	}
 
 	// There was no error so send the results downstream.
 	q.wg.Add(1)
@@ -320,6 +365,13 @@ func (p *Program) Start(ctx context.Context, alloc *memory.Allocator) (flux.Quer
 	return q, nil
 }
 
+func (p *Program) resultCache() *ResultCache {
+	if p.opts == nil {
+		return nil
+	}
+	return p.opts.cache
+}
+
 func (p *Program) processResults(ctx context.Context, q *query, resultMap map[string]flux.Result) {
 	defer q.wg.Done()
 	defer close(q.results)
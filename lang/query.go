@@ -20,6 +20,9 @@ type query struct {
 	cancel  func()
 	err     error
 	wg      sync.WaitGroup
+
+	// onDone is called when the query is done.
+	onDone func()
 }
 
 func (q *query) Results() <-chan flux.Result {
@@ -31,6 +34,10 @@ func (q *query) Done() {
 	q.wg.Wait()
 	q.stats.MaxAllocated = q.alloc.MaxAllocated()
 	q.stats.TotalAllocated = q.alloc.TotalAllocated()
+	if q.onDone != nil {
+		q.onDone()
+		q.onDone = nil
+	}
 	if q.span != nil {
 		q.span.Finish()
 		q.span = nil
@@ -63,8 +63,15 @@ func ParseFile(fset *token.FileSet, path string) (*ast.File, error) {
 // ParseSource parses the string as Flux source code.
 // The parsed package may contain errors, use ast.Check to check for errors.
 func ParseSource(source string) *ast.Package {
+	return ParseSourceFile("", source)
+}
+
+// ParseSourceFile parses the string as the Flux source code of the named file.
+// The name is recorded in the source locations of the parsed nodes.
+// The parsed package may contain errors, use ast.Check to check for errors.
+func ParseSourceFile(name, source string) *ast.Package {
 	src := []byte(source)
-	f := token.NewFile("", len(src))
+	f := token.NewFile(name, len(src))
 	file, err := parseFile(f, src)
 	if err != nil {
 		// Produce a default ast.File with the error
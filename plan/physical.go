@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math"
 
+	"github.com/influxdata/flux/dependencies/testing"
 	"github.com/influxdata/flux/internal/feature"
 	"github.com/influxdata/flux/interpreter"
 )
@@ -105,6 +106,8 @@ func (pp *physicalPlanner) Plan(ctx context.Context, spec *Spec) (*Spec, error)
 		}
 	}
 
+	// Record the plan so tests can compare it with a snapshot.
+	testing.MarkPhysicalPlan(ctx, Formatted(transformedSpec, WithDetails()))
 	return transformedSpec, nil
 }
 
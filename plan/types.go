@@ -157,6 +157,15 @@ type ProcedureSpec interface {
 	Copy() ProcedureSpec
 }
 
+// CacheableSource is an optional interface for the procedure spec of a source.
+// A source is cacheable when it reads the same bounded data each time it runs,
+// unless that data is written by a query. The results of a plan are only
+// cached when each of its sources is cacheable.
+type CacheableSource interface {
+	// Cacheable reports whether the source reads the same data each time it runs.
+	Cacheable() bool
+}
+
 // ProcedureKind denotes the kind of operation
 type ProcedureKind string
 
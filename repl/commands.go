@@ -0,0 +1,110 @@
+package repl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/c-bata/go-prompt"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+// commandPrefix marks an input line as a REPL command
+// instead of Flux source.
+const commandPrefix = ":"
+
+type replCommand struct {
+	name        string
+	args        string
+	description string
+}
+
+var replCommands = []replCommand{
+	{name: "help", description: "Print the available commands"},
+	{name: "load", args: "<file>", description: "Evaluate the Flux source in a file"},
+	{name: "save", args: "<file>", description: "Write every statement evaluated in this session to a file"},
+	{name: "reset", description: "Remove every value defined in this session from the scope"},
+}
+
+// command executes a REPL command such as ":load file.flux".
+func (r *REPL) command(t string) error {
+	name, arg := t[len(commandPrefix):], ""
+	if i := strings.IndexAny(name, " \t"); i >= 0 {
+		name, arg = name[:i], strings.TrimSpace(name[i:])
+	}
+
+	switch name {
+	case "help":
+		for _, c := range replCommands {
+			usage := commandPrefix + c.name
+			if c.args != "" {
+				usage += " " + c.args
+			}
+			fmt.Printf("%-20s %s\n", usage, c.description)
+		}
+		return nil
+	case "load":
+		if arg == "" {
+			return errors.New(codes.Invalid, "usage: :load <file>")
+		}
+		data, err := ioutil.ReadFile(arg)
+		if err != nil {
+			return err
+		}
+		fluxError, err := r.executeLine(string(data))
+		if fluxError != nil {
+			fluxError.Print()
+		}
+		return err
+	case "save":
+		if arg == "" {
+			return errors.New(codes.Invalid, "usage: :save <file>")
+		}
+		var data string
+		if len(r.session) > 0 {
+			data = strings.Join(r.session, "\n") + "\n"
+		}
+		if err := ioutil.WriteFile(arg, []byte(data), 0644); err != nil {
+			return err
+		}
+		fmt.Printf("Saved %d statement(s) to %s\n", len(r.session), arg)
+		return nil
+	case "reset":
+		r.reset()
+		fmt.Println("Scope reset")
+		return nil
+	default:
+		return errors.Newf(codes.Invalid, "unknown command %q, use :help to list the available commands", commandPrefix+name)
+	}
+}
+
+func (r *REPL) completeCommand(d prompt.Document) []prompt.Suggest {
+	text := d.TextBeforeCursor()
+	if i := strings.IndexAny(text, " \t"); i >= 0 {
+		// Complete file names for the commands that take a file.
+		name := text[len(commandPrefix):i]
+		if name != "load" && name != "save" {
+			return nil
+		}
+		word := d.GetWordBeforeCursor()
+		files, err := getFluxFiles("./" + word)
+		if err != nil {
+			return nil
+		}
+		s := make([]prompt.Suggest, 0, len(files))
+		for _, f := range files {
+			s = append(s, prompt.Suggest{Text: f})
+		}
+		return prompt.FilterHasPrefix(s, word, true)
+	}
+
+	s := make([]prompt.Suggest, 0, len(replCommands))
+	for _, c := range replCommands {
+		s = append(s, prompt.Suggest{
+			Text:        commandPrefix + c.name,
+			Description: c.description,
+		})
+	}
+	return prompt.FilterHasPrefix(s, text, true)
+}
@@ -0,0 +1,80 @@
+package repl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/c-bata/go-prompt"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+func TestCommand_Save(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repl-commands")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	path := filepath.Join(dir, "session.flux")
+	r := &REPL{session: []string{"x = 1", "y = x + 1"}}
+	if err := r.command(":save " + path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := "x = 1\ny = x + 1\n", string(data); want != got {
+		t.Errorf("unexpected saved session -want/+got:\n\t- %q\n\t+ %q", want, got)
+	}
+}
+
+func TestCommand_Errors(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		command string
+		want    error
+	}{
+		{
+			name:    "load without file",
+			command: ":load",
+			want:    errors.New(codes.Invalid, "usage: :load <file>"),
+		},
+		{
+			name:    "save without file",
+			command: ":save  ",
+			want:    errors.New(codes.Invalid, "usage: :save <file>"),
+		},
+		{
+			name:    "unknown",
+			command: ":quit now",
+			want:    errors.New(codes.Invalid, `unknown command ":quit", use :help to list the available commands`),
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			err := (&REPL{}).command(tc.command)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if want, got := tc.want.Error(), err.Error(); want != got {
+				t.Errorf("unexpected error -want/+got:\n\t- %q\n\t+ %q", want, got)
+			}
+			if want, got := codes.Invalid, errors.Code(err); want != got {
+				t.Errorf("unexpected error code -want/+got:\n\t- %v\n\t+ %v", want, got)
+			}
+		})
+	}
+}
+
+func TestCompleteCommand(t *testing.T) {
+	buf := prompt.NewBuffer()
+	buf.InsertText(":s", false, true)
+	got := (&REPL{}).completeCommand(*buf.Document())
+	if len(got) != 1 || got[0].Text != ":save" {
+		t.Errorf("unexpected suggestions for %q: %v", ":s", got)
+	}
+}
@@ -0,0 +1,72 @@
+package repl
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// maxHistory is the maximum number of lines kept in the history file.
+const maxHistory = 1000
+
+// history persists the lines entered into the REPL to a file.
+type history struct {
+	path string
+}
+
+func newHistory(path string) *history {
+	return &history{path: path}
+}
+
+// Load reads the most recent lines from the history file.
+// A missing history file is not an error.
+// When the file has grown beyond maxHistory lines,
+// it is rewritten with only the most recent lines.
+func (h *history) Load() ([]string, error) {
+	f, err := os.Open(h.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(lines) > maxHistory {
+		lines = lines[len(lines)-maxHistory:]
+		if err := h.write(lines); err != nil {
+			return nil, err
+		}
+	}
+	return lines, nil
+}
+
+// Append adds a line to the end of the history file.
+func (h *history) Append(line string) error {
+	f, err := os.OpenFile(h.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(line + "\n"); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (h *history) write(lines []string) error {
+	data := strings.Join(lines, "\n") + "\n"
+	return ioutil.WriteFile(h.path, []byte(data), 0600)
+}
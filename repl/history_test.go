@@ -0,0 +1,78 @@
+package repl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repl-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	h := newHistory(filepath.Join(dir, "history"))
+	if lines, err := h.Load(); err != nil {
+		t.Fatal(err)
+	} else if lines != nil {
+		t.Errorf("expected no lines from a missing history file, got %v", lines)
+	}
+
+	for _, line := range []string{"x = 1", "x + 1"} {
+		if err := h.Append(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lines, err := h.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"x = 1", "x + 1"}; !cmp.Equal(want, lines) {
+		t.Errorf("unexpected history -want/+got:\n%s", cmp.Diff(want, lines))
+	}
+}
+
+func TestHistory_Trim(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repl-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	path := filepath.Join(dir, "history")
+	var sb strings.Builder
+	for i := 0; i < maxHistory+10; i++ {
+		fmt.Fprintf(&sb, "x = %d\n\n", i)
+	}
+	if err := ioutil.WriteFile(path, []byte(sb.String()), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	h := newHistory(path)
+	lines, err := h.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := maxHistory, len(lines); want != got {
+		t.Fatalf("unexpected number of lines -want/+got:\n\t- %d\n\t+ %d", want, got)
+	}
+	if want, got := "x = 10", lines[0]; want != got {
+		t.Errorf("unexpected first line -want/+got:\n\t- %q\n\t+ %q", want, got)
+	}
+
+	// The file only keeps the most recent lines.
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := strings.Join(lines, "\n")+"\n", string(data); want != got {
+		t.Errorf("unexpected history file -want/+got:\n%s", cmp.Diff(want, got))
+	}
+}
@@ -0,0 +1,115 @@
+package repl
+
+import "strings"
+
+// continuationSuffixes are tokens that cannot end a complete statement.
+var continuationSuffixes = []string{"|>", "=>", "=", ",", "+", "-", "*", "%", "<", ">", "and", "or", "not"}
+
+// regexKeywords are the keywords that may be followed by a regular expression literal.
+var regexKeywords = []string{"return", "and", "or", "not", "if", "then", "else"}
+
+// isIncomplete reports whether the source ends in the middle of an expression,
+// for example with unbalanced brackets, an unterminated string literal
+// or a trailing pipe forward operator.
+// It is a lexical heuristic and does not parse the source.
+func isIncomplete(src string) bool {
+	var (
+		depth    int
+		inString bool
+		inRegex  bool
+		escaped  bool
+		last     strings.Builder
+	)
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		if inString || inRegex {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case inString && c == '"':
+				inString = false
+			case inRegex && c == '/':
+				inRegex = false
+			case inRegex && c == '\n':
+				// A regular expression cannot span lines,
+				// so the slash was a division.
+				inRegex = false
+				last.WriteByte(c)
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case '/':
+			if i+1 < len(src) && src[i+1] == '/' {
+				// Skip the comment up to the end of the line.
+				for i < len(src) && src[i] != '\n' {
+					i++
+				}
+				last.WriteByte('\n')
+				continue
+			}
+			if startsRegex(last.String()) {
+				// The brackets and quotes of a regular expression
+				// are skipped like those of a string.
+				inRegex = true
+				continue
+			}
+		}
+		last.WriteByte(c)
+	}
+	if inString || depth > 0 {
+		return true
+	}
+
+	code := strings.TrimSpace(last.String())
+	for _, suffix := range continuationSuffixes {
+		if !strings.HasSuffix(code, suffix) {
+			continue
+		}
+		// Keywords must not be the tail of a longer identifier.
+		if isLetter(suffix[0]) && len(code) > len(suffix) && isIdentChar(code[len(code)-len(suffix)-1]) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// startsRegex reports whether a slash that follows the code starts
+// a regular expression literal. A slash that follows an operand,
+// such as an identifier, a literal or a closing bracket, is a division.
+func startsRegex(code string) bool {
+	code = strings.TrimRight(code, " \t\r\n")
+	if code == "" {
+		return true
+	}
+	c := code[len(code)-1]
+	if c == ')' || c == ']' || c == '}' || c == '"' {
+		return false
+	}
+	if !isIdentChar(c) {
+		return true
+	}
+	for _, kw := range regexKeywords {
+		if strings.HasSuffix(code, kw) && (len(code) == len(kw) || !isIdentChar(code[len(code)-len(kw)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isIdentChar(c byte) bool {
+	return isLetter(c) || c >= '0' && c <= '9' || c == '_'
+}
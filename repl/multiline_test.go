@@ -0,0 +1,43 @@
+package repl
+
+import "testing"
+
+func TestIsIncomplete(t *testing.T) {
+	testCases := []struct {
+		name string
+		src  string
+		want bool
+	}{
+		{name: "empty", src: "", want: false},
+		{name: "complete expression", src: `from(bucket: "a") |> range(start: -1h)`, want: false},
+		{name: "open paren", src: `from(bucket: "a"`, want: true},
+		{name: "open brace", src: `f = (r) => {`, want: true},
+		{name: "open bracket", src: `a = [1, 2,`, want: true},
+		{name: "trailing pipe", src: `from(bucket: "a")` + "\n" + `    |>`, want: true},
+		{name: "trailing arrow", src: `f = (r) =>`, want: true},
+		{name: "trailing assignment", src: `x =`, want: true},
+		{name: "trailing keyword", src: `x = true and`, want: true},
+		{name: "identifier ending like keyword", src: `x = color`, want: false},
+		{name: "unterminated string", src: `s = "abc`, want: true},
+		{name: "escaped quote", src: `s = "a\"b`, want: true},
+		{name: "bracket in string", src: `s = "(["`, want: false},
+		{name: "bracket in comment", src: "x = 1 // (", want: false},
+		{name: "pipe in comment", src: "x = 1 // |>", want: false},
+		{name: "multiline complete", src: "f = (r) => {\n    return r\n}", want: false},
+		{name: "quote in regex", src: `filter(fn: (r) => r.host =~ /"/)`, want: false},
+		{name: "bracket in regex", src: `filter(fn: (r) => r.host =~ /[(]/)`, want: false},
+		{name: "escaped slash in regex", src: `filter(fn: (r) => r.path =~ /a\/(/)`, want: false},
+		{name: "regex after keyword", src: `f = (r) => r.a == "x" and /"/ =~ r.b`, want: false},
+		{name: "open paren after regex", src: `filter(fn: (r) => r.host =~ /a/`, want: true},
+		{name: "division", src: `x = (a + b) / (c`, want: true},
+		{name: "division by identifier", src: `x = a / b`, want: false},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isIncomplete(tc.src); got != tc.want {
+				t.Errorf("unexpected result for %q -want/+got:\n\t- %v\n\t+ %v", tc.src, tc.want, got)
+			}
+		})
+	}
+}
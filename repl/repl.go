@@ -35,36 +35,89 @@ type REPL struct {
 	analyzer *libflux.Analyzer
 	importer interpreter.Importer
 
+	history *history
+	// memoryLimit is the number of bytes a query may allocate.
+	// Zero means there is no limit.
+	memoryLimit int64
+	// pending holds the lines of an expression that
+	// has not been completed yet.
+	pending []string
+	// session holds every input that was evaluated successfully
+	// since the REPL was started or last reset.
+	session []string
+
 	cancelMu   sync.Mutex
 	cancelFunc context.CancelFunc
 }
 
-func New(ctx context.Context, deps flux.Dependencies) *REPL {
+// Option configures a REPL.
+type Option func(r *REPL)
+
+// WithHistoryFile persists the input history of the REPL
+// to the given file so it is available to later sessions.
+func WithHistoryFile(path string) Option {
+	return func(r *REPL) {
+		r.history = newHistory(path)
+	}
+}
+
+// WithAllocatorLimit limits the memory each query
+// may allocate to the given number of bytes.
+// A limit of zero means queries are not limited.
+func WithAllocatorLimit(limit int64) Option {
+	return func(r *REPL) {
+		r.memoryLimit = limit
+	}
+}
+
+func New(ctx context.Context, deps flux.Dependencies, opts ...Option) *REPL {
+	r := &REPL{
+		ctx:      ctx,
+		deps:     deps,
+		importer: runtime.StdLib(),
+	}
+	r.reset()
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// reset discards all of the values defined in the REPL
+// and restores the scope to the prelude.
+func (r *REPL) reset() {
 	scope := values.NewScope()
-	importer := runtime.StdLib()
 	for _, p := range runtime.PreludeList {
-		pkg, err := importer.ImportPackageObject(p)
+		pkg, err := r.importer.ImportPackageObject(p)
 		if err != nil {
 			panic(err)
 		}
 		pkg.Range(scope.Set)
 	}
-	return &REPL{
-		ctx:      ctx,
-		deps:     deps,
-		scope:    scope,
-		itrp:     interpreter.NewInterpreter(nil, &lang.ExecOptsConfig{}),
-		analyzer: libflux.NewAnalyzer(),
-		importer: importer,
-	}
+	r.scope = scope
+	r.itrp = interpreter.NewInterpreter(nil, &lang.ExecOptsConfig{})
+	r.analyzer = libflux.NewAnalyzer()
+	r.pending = nil
+	r.session = nil
 }
 
 func (r *REPL) Run() {
+	opts := []prompt.Option{
+		prompt.OptionPrefix("> "),
+		prompt.OptionLivePrefix(r.prefix),
+		prompt.OptionTitle("flux"),
+	}
+	if r.history != nil {
+		lines, err := r.history.Load()
+		if err != nil {
+			fmt.Println("Error: failed to load history:", err)
+		}
+		opts = append(opts, prompt.OptionHistory(lines))
+	}
 	p := prompt.New(
 		r.input,
 		r.completer,
-		prompt.OptionPrefix("> "),
-		prompt.OptionTitle("flux"),
+		opts...,
 	)
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT)
@@ -94,7 +147,19 @@ func (r *REPL) clearCancel() {
 	r.setCancel(nil)
 }
 
+// prefix returns the continuation prefix
+// while a multiline expression is being entered.
+func (r *REPL) prefix() (string, bool) {
+	if len(r.pending) > 0 {
+		return ". ", true
+	}
+	return "", false
+}
+
 func (r *REPL) completer(d prompt.Document) []prompt.Suggest {
+	if strings.HasPrefix(d.Text, commandPrefix) {
+		return r.completeCommand(d)
+	}
 	names := make([]string, 0, r.scope.Size())
 	r.scope.Range(func(k string, v values.Value) {
 		names = append(names, k)
@@ -131,7 +196,35 @@ func (r *REPL) Input(t string) (*libflux.FluxError, error) {
 }
 
 // input processes a line of input and prints the result.
+// Lines are buffered until they form a complete expression.
 func (r *REPL) input(t string) {
+	if r.history != nil && strings.TrimSpace(t) != "" {
+		if err := r.history.Append(t); err != nil {
+			fmt.Println("Error: failed to save history:", err)
+		}
+	}
+
+	if len(r.pending) == 0 && strings.HasPrefix(strings.TrimSpace(t), commandPrefix) {
+		if err := r.command(strings.TrimSpace(t)); err != nil {
+			fmt.Println("Error:", err)
+		}
+		return
+	}
+
+	// An empty line forces evaluation of an unfinished expression
+	// so that a misdetected continuation can always be escaped.
+	if len(r.pending) > 0 && strings.TrimSpace(t) == "" {
+		t = strings.Join(r.pending, "\n")
+		r.pending = nil
+	} else {
+		r.pending = append(r.pending, t)
+		src := strings.Join(r.pending, "\n")
+		if isIncomplete(src) {
+			return
+		}
+		t, r.pending = src, nil
+	}
+
 	if fluxError, err := r.executeLine(t); err != nil {
 		if fluxError != nil {
 			fluxError.Print()
@@ -168,6 +261,9 @@ func (r *REPL) evalWithFluxError(t string) ([]interpreter.SideEffect, *libflux.F
 	r.ctx = deps.Inject(r.ctx)
 
 	x, err := r.itrp.Eval(r.ctx, pkg, r.scope, r.importer)
+	if err == nil {
+		r.session = append(r.session, t)
+	}
 	return x, nil, err
 }
 
@@ -237,6 +333,10 @@ func (r *REPL) doQuery(ctx context.Context, spec *flux.Spec, deps flux.Dependenc
 		return err
 	}
 	alloc := &memory.Allocator{}
+	if r.memoryLimit > 0 {
+		limit := r.memoryLimit
+		alloc.Limit = &limit
+	}
 
 	qry, err := program.Start(deps.Inject(ctx), alloc)
 	if err != nil {
@@ -25,6 +25,11 @@ func ReplacePackageValue(pkgpath, name string, value values.Value) {
 	}
 }
 
+// LookupBuiltin returns the value registered for an identifier in a builtin package.
+func LookupBuiltin(pkgpath, name string) (values.Value, bool) {
+	return Default.lookupBuiltin(pkgpath, name)
+}
+
 // StdLib returns an importer for the Flux standard library.
 func StdLib() interpreter.Importer {
 	return Default.Stdlib()
@@ -23,6 +23,8 @@ var (
 type importer struct {
 	r    *runtime
 	pkgs map[string]*interpreter.Package
+	// coverage records the evaluation of the imported packages, if set.
+	coverage *interpreter.Coverage
 }
 
 func (imp *importer) Import(path string) (semantic.MonoType, error) {
@@ -78,8 +80,12 @@ func (imp *importer) ImportPackageObject(path string) (*interpreter.Package, err
 	// Run the interpreter on the package to construct the values
 	// created by the package. Pass in the previously initialized
 	// packages as importable packages as we evaluate these in order.
+	ctx := context.Background()
+	if imp.coverage != nil {
+		ctx = imp.coverage.Inject(ctx)
+	}
 	itrp := interpreter.NewInterpreter(nil, nil)
-	if _, err := itrp.Eval(context.Background(), semPkg, scope, imp); err != nil {
+	if _, err := itrp.Eval(ctx, semPkg, scope, imp); err != nil {
 		return nil, err
 	}
 	obj := newObjectFromScope(scope)
@@ -84,6 +84,11 @@ func (r *runtime) registerPackageValue(pkgpath, name string, value values.Value,
 	return nil
 }
 
+func (r *runtime) lookupBuiltin(pkgpath, name string) (values.Value, bool) {
+	v, ok := r.builtins[pkgpath][name]
+	return v, ok
+}
+
 func (r *runtime) Prelude() values.Scope {
 	if !r.finalized {
 		panic("builtins not finalized")
@@ -103,7 +108,7 @@ func (r *runtime) Eval(ctx context.Context, astPkg flux.ASTHandle, es interprete
 	}
 
 	// Construct the initial scope for this package.
-	importer := &importer{r: r}
+	importer := &importer{r: r, coverage: interpreter.GetCoverage(ctx)}
 	scope, err := r.newScopeFor("main", importer)
 	if err != nil {
 		return nil, nil, err
@@ -83,6 +83,11 @@ func (s *FromProcedureSpec) Copy() plan.ProcedureSpec {
 	return ns
 }
 
+// Cacheable reports that the source always produces the rows of the array.
+func (s *FromProcedureSpec) Cacheable() bool {
+	return true
+}
+
 func createFromSource(ps plan.ProcedureSpec, id execute.DatasetID, a execute.Administration) (execute.Source, error) {
 	spec := ps.(*FromProcedureSpec)
 	return &tableSource{
@@ -113,6 +113,12 @@ func (s *FromCSVProcedureSpec) Copy() plan.ProcedureSpec {
 	return ns
 }
 
+// Cacheable reports whether the source reads a CSV string.
+// A file can change between reads, so reading one is not cacheable.
+func (s *FromCSVProcedureSpec) Cacheable() bool {
+	return s.File == ""
+}
+
 func createFromCSVSource(prSpec plan.ProcedureSpec, dsid execute.DatasetID, a execute.Administration) (execute.Source, error) {
 	spec, ok := prSpec.(*FromCSVProcedureSpec)
 	if !ok {
@@ -0,0 +1,541 @@
+package experimental
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/compiler"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/interpreter"
+	"github.com/influxdata/flux/plan"
+	"github.com/influxdata/flux/runtime"
+	"github.com/influxdata/flux/semantic"
+	"github.com/influxdata/flux/values"
+)
+
+const AsofJoinKind = "experimental.asofJoin"
+
+// Directions in which asofJoin looks for the right row to join with a left row.
+const (
+	AsofBackward = "backward"
+	AsofForward  = "forward"
+	AsofNearest  = "nearest"
+)
+
+func init() {
+	signature := runtime.MustLookupBuiltinType("experimental", "asofJoin")
+	runtime.RegisterPackageValue("experimental", "asofJoin", flux.MustValue(flux.FunctionValue("asofJoin", createAsofJoinOpSpec, signature)))
+	flux.RegisterOpSpec(AsofJoinKind, newAsofJoinOp)
+	plan.RegisterProcedureSpec(AsofJoinKind, newAsofJoinProcedure, AsofJoinKind)
+	execute.RegisterTransformation(AsofJoinKind, createAsofJoinTransformation)
+}
+
+type AsofJoinOpSpec struct {
+	Left       flux.OperationID             `json:"left"`
+	Right      flux.OperationID             `json:"right"`
+	Fn         interpreter.ResolvedFunction `json:"fn"`
+	On         []string                     `json:"on"`
+	TimeColumn string                       `json:"timeColumn"`
+	Tolerance  flux.Duration                `json:"tolerance"`
+	Direction  string                       `json:"direction"`
+
+	l, r *flux.TableObject
+}
+
+func (s *AsofJoinOpSpec) IDer(ider flux.IDer) {
+	s.Left = ider.ID(s.l)
+	s.Right = ider.ID(s.r)
+}
+
+func createAsofJoinOpSpec(args flux.Arguments, p *flux.Administration) (flux.OperationSpec, error) {
+	spec := new(AsofJoinOpSpec)
+
+	l, ok := args.Get("left")
+	if !ok {
+		return nil, errors.New(codes.Invalid, "argument 'left' not present")
+	}
+	if spec.l, ok = l.(*flux.TableObject); !ok {
+		return nil, errors.New(codes.Invalid, "argument 'left' must be a table stream")
+	}
+	p.AddParent(spec.l)
+
+	r, ok := args.Get("right")
+	if !ok {
+		return nil, errors.New(codes.Invalid, "argument 'right' not present")
+	}
+	if spec.r, ok = r.(*flux.TableObject); !ok {
+		return nil, errors.New(codes.Invalid, "argument 'right' must be a table stream")
+	}
+	p.AddParent(spec.r)
+
+	f, err := args.GetRequiredFunction("fn")
+	if err != nil {
+		return nil, err
+	}
+	if spec.Fn, err = interpreter.ResolveFunction(f); err != nil {
+		return nil, err
+	}
+
+	if on, ok, err := args.GetArray("on", semantic.String); err != nil {
+		return nil, err
+	} else if ok {
+		if spec.On, err = interpreter.ToStringArray(on); err != nil {
+			return nil, err
+		}
+	}
+
+	if label, ok, err := args.GetString("timeColumn"); err != nil {
+		return nil, err
+	} else if ok {
+		spec.TimeColumn = label
+	} else {
+		spec.TimeColumn = execute.DefaultTimeColLabel
+	}
+
+	if tolerance, ok, err := args.GetDuration("tolerance"); err != nil {
+		return nil, err
+	} else if ok {
+		if tolerance.IsNegative() || !tolerance.NanoOnly() {
+			return nil, errors.New(codes.Invalid, "tolerance must be a non-negative duration without months")
+		}
+		spec.Tolerance = tolerance
+	}
+
+	if direction, ok, err := args.GetString("direction"); err != nil {
+		return nil, err
+	} else if ok {
+		switch direction {
+		case AsofBackward, AsofForward, AsofNearest:
+			spec.Direction = direction
+		default:
+			return nil, errors.Newf(codes.Invalid, "direction must be one of %q, %q or %q, got %q", AsofBackward, AsofForward, AsofNearest, direction)
+		}
+	} else {
+		spec.Direction = AsofBackward
+	}
+
+	return spec, nil
+}
+
+func newAsofJoinOp() flux.OperationSpec {
+	return new(AsofJoinOpSpec)
+}
+
+func (s *AsofJoinOpSpec) Kind() flux.OperationKind {
+	return AsofJoinKind
+}
+
+type AsofJoinProcedureSpec struct {
+	plan.DefaultCost
+
+	Fn         interpreter.ResolvedFunction `json:"fn"`
+	On         []string
+	TimeColumn string
+	Tolerance  flux.Duration
+	Direction  string
+}
+
+func newAsofJoinProcedure(qs flux.OperationSpec, pa plan.Administration) (plan.ProcedureSpec, error) {
+	spec, ok := qs.(*AsofJoinOpSpec)
+	if !ok {
+		return nil, errors.Newf(codes.Internal, "invalid spec type %T", qs)
+	}
+	return &AsofJoinProcedureSpec{
+		Fn:         spec.Fn,
+		On:         spec.On,
+		TimeColumn: spec.TimeColumn,
+		Tolerance:  spec.Tolerance,
+		Direction:  spec.Direction,
+	}, nil
+}
+
+func (s *AsofJoinProcedureSpec) Kind() plan.ProcedureKind {
+	return AsofJoinKind
+}
+
+func (s *AsofJoinProcedureSpec) Copy() plan.ProcedureSpec {
+	ns := *s
+	ns.Fn = s.Fn.Copy()
+	ns.On = append([]string(nil), s.On...)
+	return &ns
+}
+
+func createAsofJoinTransformation(id execute.DatasetID, mode execute.AccumulationMode, spec plan.ProcedureSpec, a execute.Administration) (execute.Transformation, execute.Dataset, error) {
+	s, ok := spec.(*AsofJoinProcedureSpec)
+	if !ok {
+		return nil, nil, errors.Newf(codes.Internal, "invalid spec type %T", spec)
+	}
+	parents := a.Parents()
+	if len(parents) != 2 {
+		return nil, nil, errors.Newf(codes.Internal, "asofJoin expects 2 parents, got %d", len(parents))
+	}
+
+	cache := execute.NewTableBuilderCache(a.Allocator())
+	d := execute.NewDataset(id, mode, cache)
+	t := NewAsofJoinTransformation(a.Context(), d, cache, s, parents[0], parents[1])
+	return t, d, nil
+}
+
+// asofTable is a buffered input table with the
+// indices of its time and on columns.
+type asofTable struct {
+	flux.BufferedTable
+	timeIdx int
+	onIdx   []int
+}
+
+// asofRow locates a row within a buffered table.
+type asofRow struct {
+	time   values.Time
+	table  *asofTable
+	buffer int
+	row    int
+}
+
+// asofSeries holds the right rows that share the values of the on columns.
+type asofSeries struct {
+	rows []asofRow
+	// next is the index of the first row that is later than
+	// the last left row joined with the series.
+	next int
+}
+
+// asofPair holds the left and right tables with the same group key
+// until both have been received.
+type asofPair struct {
+	left, right *asofTable
+}
+
+type asofJoinTransformation struct {
+	execute.ExecutionNode
+	mu    sync.Mutex
+	ctx   context.Context
+	d     execute.Dataset
+	cache execute.TableBuilderCache
+
+	fn         *rowJoinFn
+	on         []string
+	timeColumn string
+	tolerance  values.Time
+	direction  string
+
+	left, right execute.DatasetID
+	// pairs holds the tables that are waiting for the table
+	// with the same group key from the other input.
+	pairs               *execute.GroupLookup
+	leftDone, rightDone bool
+}
+
+// NewAsofJoinTransformation creates a transformation that joins every row of the left input
+// with the closest row in time of the right input.
+//
+// Left and right tables are paired by group key and each pair is joined as soon as
+// both of its tables have been received, by merging their rows sorted by time.
+// Only the tables waiting for the other input are held in memory,
+// and tables that have no pair once the other input is finished are dropped.
+func NewAsofJoinTransformation(ctx context.Context, d execute.Dataset, cache execute.TableBuilderCache, spec *AsofJoinProcedureSpec, left, right execute.DatasetID) *asofJoinTransformation {
+	return &asofJoinTransformation{
+		ctx:        ctx,
+		d:          d,
+		cache:      cache,
+		fn:         newRowJoinFn(spec.Fn.Fn, compiler.ToScope(spec.Fn.Scope)),
+		on:         spec.On,
+		timeColumn: spec.TimeColumn,
+		tolerance:  values.Time(spec.Tolerance.Duration()),
+		direction:  spec.Direction,
+		left:       left,
+		right:      right,
+		pairs:      execute.NewGroupLookup(),
+	}
+}
+
+func (t *asofJoinTransformation) RetractTable(id execute.DatasetID, key flux.GroupKey) error {
+	return errors.New(codes.Unimplemented)
+}
+
+func (t *asofJoinTransformation) Process(id execute.DatasetID, tbl flux.Table) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.validate(id, tbl.Cols()); err != nil {
+		return err
+	}
+
+	key := tbl.Key()
+	value, ok := t.pairs.Lookup(key)
+	if !ok && (id == t.left && t.rightDone || id == t.right && t.leftDone) {
+		// The other input is finished without a table
+		// with this group key so there is nothing to join.
+		tbl.Done()
+		return nil
+	}
+	buf, err := execute.CopyTable(tbl)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		value = new(asofPair)
+		t.pairs.Set(key, value)
+	}
+	p := value.(*asofPair)
+	side := &p.left
+	if id == t.right {
+		side = &p.right
+	}
+	if *side != nil {
+		(*side).Done()
+	}
+	*side = t.newTable(buf)
+	if p.left == nil || p.right == nil {
+		return nil
+	}
+
+	defer t.release(key)
+	return t.join(p)
+}
+
+// validate checks that the time and on columns exist in a table of the input.
+func (t *asofJoinTransformation) validate(id execute.DatasetID, cols []flux.ColMeta) error {
+	side := "left"
+	if id == t.right {
+		side = "right"
+	}
+	timeIdx := execute.ColIdx(t.timeColumn, cols)
+	if timeIdx < 0 {
+		return errors.Newf(codes.FailedPrecondition, "%s table is missing time column %q", side, t.timeColumn)
+	}
+	if typ := cols[timeIdx].Type; typ != flux.TTime {
+		return errors.Newf(codes.FailedPrecondition, "time column %q must be of type time, got %v", t.timeColumn, typ)
+	}
+	for _, label := range t.on {
+		if execute.ColIdx(label, cols) < 0 {
+			return errors.Newf(codes.FailedPrecondition, "%s table is missing column %q", side, label)
+		}
+	}
+	return nil
+}
+
+// newTable looks up the column indices of a buffered table once
+// so they are not looked up again for every row.
+func (t *asofJoinTransformation) newTable(buf flux.BufferedTable) *asofTable {
+	tbl := &asofTable{
+		BufferedTable: buf,
+		timeIdx:       execute.ColIdx(t.timeColumn, buf.Cols()),
+		onIdx:         make([]int, len(t.on)),
+	}
+	for j, label := range t.on {
+		tbl.onIdx[j] = execute.ColIdx(label, buf.Cols())
+	}
+	return tbl
+}
+
+// rows returns the rows of a buffered table that have a time, sorted by time.
+func (t *asofJoinTransformation) rows(tbl *asofTable) []asofRow {
+	var rows []asofRow
+	for i, n := 0, tbl.BufferN(); i < n; i++ {
+		ts := tbl.Buffer(i).Times(tbl.timeIdx)
+		for j, l := 0, ts.Len(); j < l; j++ {
+			if ts.IsValid(j) {
+				rows = append(rows, asofRow{time: values.Time(ts.Value(j)), table: tbl, buffer: i, row: j})
+			}
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].time < rows[j].time
+	})
+	return rows
+}
+
+// onKey returns the values of the on columns of a row as a group key.
+func (t *asofJoinTransformation) onKey(r asofRow) flux.GroupKey {
+	cr := r.table.Buffer(r.buffer)
+	cols := make([]flux.ColMeta, len(t.on))
+	vs := make([]values.Value, len(t.on))
+	for j, idx := range r.table.onIdx {
+		cols[j] = cr.Cols()[idx]
+		vs[j] = execute.ValueForRow(cr, r.row, idx)
+	}
+	return execute.NewGroupKey(cols, vs)
+}
+
+// match returns the right row to join with a left row at time ts.
+// The left rows must be matched in time order as the series
+// only moves forward through its rows.
+func (t *asofJoinTransformation) match(s *asofSeries, ts values.Time) (asofRow, bool) {
+	for s.next < len(s.rows) && s.rows[s.next].time <= ts {
+		s.next++
+	}
+
+	var (
+		before, after       asofRow
+		hasBefore, hasAfter bool
+	)
+	if s.next > 0 {
+		before, hasBefore = s.rows[s.next-1], true
+	}
+	if hasBefore && before.time == ts {
+		// A row at the same time is the earliest row at or after ts as well.
+		after, hasAfter = before, true
+	} else if s.next < len(s.rows) {
+		after, hasAfter = s.rows[s.next], true
+	}
+	if hasBefore && !t.within(ts-before.time) {
+		hasBefore = false
+	}
+	if hasAfter && !t.within(after.time-ts) {
+		hasAfter = false
+	}
+
+	switch t.direction {
+	case AsofForward:
+		return after, hasAfter
+	case AsofNearest:
+		if hasBefore && (!hasAfter || ts-before.time <= after.time-ts) {
+			return before, true
+		}
+		return after, hasAfter
+	default:
+		return before, hasBefore
+	}
+}
+
+func (t *asofJoinTransformation) within(d values.Time) bool {
+	return t.tolerance == 0 || d <= t.tolerance
+}
+
+// join merges the rows of a pair of tables sorted by time.
+// Left rows without a matching right row are dropped.
+func (t *asofJoinTransformation) join(p *asofPair) error {
+	series := execute.NewGroupLookup()
+	for _, r := range t.rows(p.right) {
+		key := t.onKey(r)
+		s, ok := series.Lookup(key)
+		if !ok {
+			s = new(asofSeries)
+			series.Set(key, s)
+		}
+		s.(*asofSeries).rows = append(s.(*asofSeries).rows, r)
+	}
+	if err := t.fn.Prepare(p.left.Cols(), p.right.Cols()); err != nil {
+		return err
+	}
+
+	key := p.left.Key()
+	var builder execute.TableBuilder
+	for _, l := range t.rows(p.left) {
+		s, ok := series.Lookup(t.onKey(l))
+		if !ok {
+			continue
+		}
+		r, ok := t.match(s.(*asofSeries), l.time)
+		if !ok {
+			continue
+		}
+		obj, err := t.fn.Eval(t.ctx, record(l), record(r))
+		if err != nil {
+			return err
+		}
+
+		if builder == nil {
+			var created bool
+			builder, created = t.cache.TableBuilder(key)
+			if created {
+				if err := buildSchema(builder, obj); err != nil {
+					return err
+				}
+			}
+		}
+		if !objContainsKey(obj, key) {
+			return errors.New(codes.Invalid, "argument 'fn' may not modify group key")
+		}
+		if err := appendRowToBuilder(builder, obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// record returns the values of a row by column label.
+func record(r asofRow) map[string]values.Value {
+	cr := r.table.Buffer(r.buffer)
+	rec := make(map[string]values.Value, len(cr.Cols()))
+	for j, col := range cr.Cols() {
+		rec[col.Label] = execute.ValueForRow(cr, r.row, j)
+	}
+	return rec
+}
+
+func (t *asofJoinTransformation) UpdateWatermark(id execute.DatasetID, mark execute.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.d.UpdateWatermark(mark)
+}
+
+func (t *asofJoinTransformation) UpdateProcessingTime(id execute.DatasetID, pt execute.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.d.UpdateProcessingTime(pt)
+}
+
+func (t *asofJoinTransformation) Finish(id execute.DatasetID, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if id == t.left {
+		t.leftDone = true
+	} else {
+		t.rightDone = true
+	}
+	if err != nil || t.leftDone && t.rightDone {
+		t.d.Finish(err)
+		t.clean()
+		return
+	}
+
+	// The tables of the other input that are still waiting
+	// for a table of the finished input will never be joined.
+	var keys []flux.GroupKey
+	_ = t.pairs.Range(func(key flux.GroupKey, value interface{}) error {
+		if p := value.(*asofPair); id == t.left && p.left == nil || id == t.right && p.right == nil {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	for _, key := range keys {
+		t.release(key)
+	}
+}
+
+// release removes the pair with the given key and releases its tables.
+func (t *asofJoinTransformation) release(key flux.GroupKey) {
+	value, ok := t.pairs.Delete(key)
+	if !ok {
+		return
+	}
+	p := value.(*asofPair)
+	if p.left != nil {
+		p.left.Done()
+	}
+	if p.right != nil {
+		p.right.Done()
+	}
+}
+
+// clean releases the buffered tables.
+func (t *asofJoinTransformation) clean() {
+	var keys []flux.GroupKey
+	_ = t.pairs.Range(func(key flux.GroupKey, value interface{}) error {
+		keys = append(keys, key)
+		return nil
+	})
+	for _, key := range keys {
+		t.release(key)
+	}
+}
@@ -0,0 +1,224 @@
+package experimental_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/execute/executetest"
+	"github.com/influxdata/flux/interpreter"
+	"github.com/influxdata/flux/plan"
+	"github.com/influxdata/flux/stdlib/experimental"
+	"github.com/influxdata/flux/values/valuestest"
+)
+
+func TestAsofJoin_Process(t *testing.T) {
+	sec := func(n int64) execute.Time {
+		return execute.Time(n * int64(time.Second))
+	}
+	fn := interpreter.ResolvedFunction{
+		Fn:    executetest.FunctionExpression(t, `(left, right) => ({left with rt: right._time, rv: right._value})`),
+		Scope: valuestest.Scope(),
+	}
+	left := func() []flux.Table {
+		return []flux.Table{
+			&executetest.Table{
+				KeyCols: []string{"_measurement"},
+				ColMeta: []flux.ColMeta{
+					{Label: "_measurement", Type: flux.TString},
+					{Label: "id", Type: flux.TString},
+					{Label: "_time", Type: flux.TTime},
+					{Label: "_value", Type: flux.TFloat},
+				},
+				Data: [][]interface{}{
+					{"m", "a", sec(10), 1.0},
+					{"m", "b", sec(12), 2.0},
+					{"m", "a", sec(20), 3.0},
+					{"m", "a", sec(5), 4.0},
+					{"m", "c", sec(12), 5.0},
+				},
+			},
+			// There is no right table with this group key.
+			&executetest.Table{
+				KeyCols: []string{"_measurement"},
+				ColMeta: []flux.ColMeta{
+					{Label: "_measurement", Type: flux.TString},
+					{Label: "id", Type: flux.TString},
+					{Label: "_time", Type: flux.TTime},
+					{Label: "_value", Type: flux.TFloat},
+				},
+				Data: [][]interface{}{
+					{"n", "a", sec(10), 6.0},
+				},
+			},
+		}
+	}
+	right := func() []flux.Table {
+		return []flux.Table{
+			&executetest.Table{
+				KeyCols: []string{"_measurement"},
+				ColMeta: []flux.ColMeta{
+					{Label: "_measurement", Type: flux.TString},
+					{Label: "id", Type: flux.TString},
+					{Label: "_time", Type: flux.TTime},
+					{Label: "_value", Type: flux.TInt},
+				},
+				Data: [][]interface{}{
+					{"m", "a", sec(19), int64(30)},
+					{"m", "a", sec(8), int64(10)},
+					{"m", "b", sec(12), int64(40)},
+					{"m", "a", sec(11), int64(20)},
+				},
+			},
+			// There is no left table with this group key.
+			&executetest.Table{
+				KeyCols: []string{"_measurement"},
+				ColMeta: []flux.ColMeta{
+					{Label: "_measurement", Type: flux.TString},
+					{Label: "id", Type: flux.TString},
+					{Label: "_time", Type: flux.TTime},
+					{Label: "_value", Type: flux.TInt},
+				},
+				Data: [][]interface{}{
+					{"o", "a", sec(10), int64(50)},
+				},
+			},
+		}
+	}
+	wantCols := []flux.ColMeta{
+		{Label: "_measurement", Type: flux.TString},
+		{Label: "_time", Type: flux.TTime},
+		{Label: "_value", Type: flux.TFloat},
+		{Label: "id", Type: flux.TString},
+		{Label: "rt", Type: flux.TTime},
+		{Label: "rv", Type: flux.TInt},
+	}
+	testCases := []struct {
+		name      string
+		spec      *experimental.AsofJoinProcedureSpec
+		rightLast bool
+		want      []*executetest.Table
+	}{
+		{
+			name: "backward",
+			spec: &experimental.AsofJoinProcedureSpec{
+				On:         []string{"id"},
+				TimeColumn: "_time",
+				Direction:  experimental.AsofBackward,
+			},
+			// The left rows without a right row before them
+			// or with another id are dropped.
+			want: []*executetest.Table{{
+				KeyCols: []string{"_measurement"},
+				ColMeta: wantCols,
+				Data: [][]interface{}{
+					{"m", sec(10), 1.0, "a", sec(8), int64(10)},
+					{"m", sec(12), 2.0, "b", sec(12), int64(40)},
+					{"m", sec(20), 3.0, "a", sec(19), int64(30)},
+				},
+			}},
+		},
+		{
+			name: "forward",
+			spec: &experimental.AsofJoinProcedureSpec{
+				On:         []string{"id"},
+				TimeColumn: "_time",
+				Direction:  experimental.AsofForward,
+			},
+			want: []*executetest.Table{{
+				KeyCols: []string{"_measurement"},
+				ColMeta: wantCols,
+				Data: [][]interface{}{
+					{"m", sec(5), 4.0, "a", sec(8), int64(10)},
+					{"m", sec(10), 1.0, "a", sec(11), int64(20)},
+					{"m", sec(12), 2.0, "b", sec(12), int64(40)},
+				},
+			}},
+		},
+		{
+			name: "nearest with tolerance",
+			spec: &experimental.AsofJoinProcedureSpec{
+				On:         []string{"id"},
+				TimeColumn: "_time",
+				Tolerance:  flux.ConvertDuration(2 * time.Second),
+				Direction:  experimental.AsofNearest,
+			},
+			want: []*executetest.Table{{
+				KeyCols: []string{"_measurement"},
+				ColMeta: wantCols,
+				Data: [][]interface{}{
+					{"m", sec(10), 1.0, "a", sec(11), int64(20)},
+					{"m", sec(12), 2.0, "b", sec(12), int64(40)},
+					{"m", sec(20), 3.0, "a", sec(19), int64(30)},
+				},
+			}},
+		},
+		{
+			name: "without on columns",
+			spec: &experimental.AsofJoinProcedureSpec{
+				TimeColumn: "_time",
+				Tolerance:  flux.ConvertDuration(time.Second),
+				Direction:  experimental.AsofBackward,
+			},
+			rightLast: true,
+			want: []*executetest.Table{{
+				KeyCols: []string{"_measurement"},
+				ColMeta: wantCols,
+				Data: [][]interface{}{
+					{"m", sec(12), 2.0, "b", sec(12), int64(40)},
+					{"m", sec(12), 5.0, "c", sec(12), int64(40)},
+					{"m", sec(20), 3.0, "a", sec(19), int64(30)},
+				},
+			}},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			tc.spec.Fn = fn
+			l := executetest.RandomDatasetID()
+			r := executetest.RandomDatasetID()
+			cache := execute.NewTableBuilderCache(executetest.UnlimitedAllocator)
+			cache.SetTriggerSpec(plan.DefaultTriggerSpec)
+			d := executetest.NewDataset(executetest.RandomDatasetID())
+			tr := experimental.NewAsofJoinTransformation(context.Background(), d, cache, tc.spec, l, r)
+
+			process := func(id execute.DatasetID, tables []flux.Table) {
+				for _, tbl := range tables {
+					if err := tr.Process(id, tbl); err != nil {
+						t.Fatal(err)
+					}
+				}
+				tr.Finish(id, nil)
+			}
+			// Tables are buffered until the table with the same group key
+			// arrives on the other input.
+			if tc.rightLast {
+				process(l, left())
+				process(r, right())
+			} else {
+				process(r, right())
+				process(l, left())
+			}
+			if d.FinishedErr != nil {
+				t.Fatal(d.FinishedErr)
+			}
+
+			got, err := executetest.TablesFromCache(cache)
+			if err != nil {
+				t.Fatal(err)
+			}
+			executetest.NormalizeTables(got)
+			executetest.NormalizeTables(tc.want)
+			sort.Sort(executetest.SortedTables(got))
+			sort.Sort(executetest.SortedTables(tc.want))
+			if !cmp.Equal(tc.want, got) {
+				t.Errorf("unexpected tables -want/+got\n%s", cmp.Diff(tc.want, got))
+			}
+		})
+	}
+}
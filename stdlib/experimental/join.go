@@ -401,7 +401,7 @@ func objContainsKey(obj values.Object, key flux.GroupKey) bool {
 }
 
 // buildSchema adds a schema defined by an object to an empty builder
-func buildSchema(builder *execute.ColListTableBuilder, obj values.Object) error {
+func buildSchema(builder execute.TableBuilder, obj values.Object) error {
 	schema := make([]flux.ColMeta, 0, obj.Len())
 	obj.Range(func(name string, v values.Value) {
 		schema = append(schema, flux.ColMeta{
@@ -420,7 +420,7 @@ func buildSchema(builder *execute.ColListTableBuilder, obj values.Object) error
 	return nil
 }
 
-func appendRowToBuilder(builder *execute.ColListTableBuilder, obj values.Object) error {
+func appendRowToBuilder(builder execute.TableBuilder, obj values.Object) error {
 	var err error
 	obj.Range(func(name string, v values.Value) {
 		idx := execute.ColIdx(name, builder.Cols())
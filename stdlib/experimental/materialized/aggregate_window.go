@@ -0,0 +1,610 @@
+package materialized
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/apache/arrow/go/arrow/memory"
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/array"
+	"github.com/influxdata/flux/arrow"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/execute/table"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/interval"
+	"github.com/influxdata/flux/plan"
+	"github.com/influxdata/flux/runtime"
+	"github.com/influxdata/flux/stdlib/universe"
+	"github.com/influxdata/flux/values"
+)
+
+const AggregateWindowKind = pkgpath + ".aggregateWindow"
+
+// The aggregates supported by aggregateWindow.
+const (
+	fnCount = universe.CountKind
+	fnSum   = universe.SumKind
+	fnMean  = universe.MeanKind
+	fnMin   = universe.MinKind
+	fnMax   = universe.MaxKind
+	fnFirst = universe.FirstKind
+	fnLast  = universe.LastKind
+)
+
+type AggregateWindowOpSpec struct {
+	View     string        `json:"view"`
+	Every    flux.Duration `json:"every"`
+	Offset   flux.Duration `json:"offset"`
+	Fn       string        `json:"fn"`
+	Column   string        `json:"column"`
+	Lateness flux.Duration `json:"lateness"`
+}
+
+func init() {
+	aggregateWindowSignature := runtime.MustLookupBuiltinType(pkgpath, "aggregateWindow")
+
+	runtime.RegisterPackageValue(pkgpath, "aggregateWindow", flux.MustValue(flux.FunctionValue(AggregateWindowKind, createAggregateWindowOpSpec, aggregateWindowSignature)))
+	flux.RegisterOpSpec(AggregateWindowKind, newAggregateWindowOp)
+	// Updating a view saves its state, so the
+	// procedure is kept even if its output is not used.
+	plan.RegisterProcedureSpecWithSideEffect(AggregateWindowKind, newAggregateWindowProcedure, AggregateWindowKind)
+	execute.RegisterTransformation(AggregateWindowKind, createAggregateWindowTransformation)
+}
+
+func createAggregateWindowOpSpec(args flux.Arguments, a *flux.Administration) (flux.OperationSpec, error) {
+	if err := a.AddParentFromArgs(args); err != nil {
+		return nil, err
+	}
+
+	spec := new(AggregateWindowOpSpec)
+	view, err := args.GetRequiredString("view")
+	if err != nil {
+		return nil, err
+	} else if view == "" {
+		return nil, errors.New(codes.Invalid, "view name must not be empty")
+	}
+	spec.View = view
+
+	every, err := args.GetRequiredDuration("every")
+	if err != nil {
+		return nil, err
+	}
+	spec.Every = every
+
+	if offset, ok, err := args.GetDuration("offset"); err != nil {
+		return nil, err
+	} else if ok {
+		spec.Offset = offset
+	}
+
+	if _, err := interval.NewWindow(spec.Every, spec.Every, spec.Offset); err != nil {
+		return nil, err
+	}
+
+	fn, err := args.GetRequiredFunction("fn")
+	if err != nil {
+		return nil, err
+	}
+	if spec.Fn, err = aggregateOf(fn); err != nil {
+		return nil, err
+	}
+
+	if col, ok, err := args.GetString("column"); err != nil {
+		return nil, err
+	} else if ok {
+		spec.Column = col
+	} else {
+		spec.Column = execute.DefaultValueColLabel
+	}
+	if spec.Column == execute.DefaultTimeColLabel {
+		return nil, errors.Newf(codes.Invalid, "cannot aggregate column %q", spec.Column)
+	}
+
+	if lateness, ok, err := args.GetDuration("lateness"); err != nil {
+		return nil, err
+	} else if ok {
+		if !lateness.IsPositive() {
+			return nil, errors.New(codes.Invalid, "lateness must be positive")
+		}
+		spec.Lateness = lateness
+	}
+	return spec, nil
+}
+
+// aggregateOf returns the aggregate that fn computes. The function
+// must be one of the supported aggregates of the universe package,
+// such as mean, and is identified by its value without calling it.
+func aggregateOf(fn values.Function) (string, error) {
+	for _, name := range []string{fnCount, fnSum, fnMean, fnMin, fnMax, fnFirst, fnLast} {
+		if v, ok := runtime.LookupBuiltin("universe", name); ok && v.Equal(fn) {
+			return name, nil
+		}
+	}
+	return "", errors.New(codes.Invalid, "unsupported aggregate function, fn must be one of count, sum, mean, min, max, first or last")
+}
+
+func newAggregateWindowOp() flux.OperationSpec {
+	return new(AggregateWindowOpSpec)
+}
+
+func (s *AggregateWindowOpSpec) Kind() flux.OperationKind {
+	return AggregateWindowKind
+}
+
+type AggregateWindowProcedureSpec struct {
+	plan.DefaultCost
+	View     string
+	Every    flux.Duration
+	Offset   flux.Duration
+	Fn       string
+	Column   string
+	Lateness flux.Duration
+}
+
+func newAggregateWindowProcedure(qs flux.OperationSpec, pa plan.Administration) (plan.ProcedureSpec, error) {
+	spec, ok := qs.(*AggregateWindowOpSpec)
+	if !ok {
+		return nil, errors.Newf(codes.Internal, "invalid spec type %T", qs)
+	}
+	return &AggregateWindowProcedureSpec{
+		View:     spec.View,
+		Every:    spec.Every,
+		Offset:   spec.Offset,
+		Fn:       spec.Fn,
+		Column:   spec.Column,
+		Lateness: spec.Lateness,
+	}, nil
+}
+
+func (s *AggregateWindowProcedureSpec) Kind() plan.ProcedureKind {
+	return AggregateWindowKind
+}
+
+func (s *AggregateWindowProcedureSpec) Copy() plan.ProcedureSpec {
+	ns := *s
+	return &ns
+}
+
+func createAggregateWindowTransformation(id execute.DatasetID, mode execute.AccumulationMode, spec plan.ProcedureSpec, a execute.Administration) (execute.Transformation, execute.Dataset, error) {
+	s, ok := spec.(*AggregateWindowProcedureSpec)
+	if !ok {
+		return nil, nil, errors.Newf(codes.Internal, "invalid spec type %T", spec)
+	}
+	t, err := NewAggregateWindowTransformation(a.Context(), s)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := execute.OnCommit(a.Context(), t.Commit); err != nil {
+		return nil, nil, err
+	}
+	return execute.NewAggregateTransformation(id, t, a.Allocator())
+}
+
+type aggregateWindowTransformation struct {
+	view     string
+	window   interval.Window
+	fn       string
+	column   string
+	lateness flux.Duration
+
+	state *viewState
+	// latest holds the latest time of the rows
+	// read for each series by its key.
+	latest map[string]values.Time
+}
+
+// NewAggregateWindowTransformation creates an aggregate transformation
+// that merges the rows of each table into the partial aggregates of the
+// materialized view and outputs the windows that were updated.
+//
+// The state of the view is loaded from the state store in the context.
+// The updated state is only saved by Commit, which must be called
+// once the query has succeeded.
+func NewAggregateWindowTransformation(ctx context.Context, spec *AggregateWindowProcedureSpec) (*aggregateWindowTransformation, error) {
+	window, err := interval.NewWindow(spec.Every, spec.Every, spec.Offset)
+	if err != nil {
+		return nil, err
+	}
+	config := viewConfig{
+		Every:  spec.Every.String(),
+		Offset: spec.Offset.String(),
+		Fn:     spec.Fn,
+		Column: spec.Column,
+	}
+
+	state, err := loadViewState(ctx, spec.View)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		state = &viewState{Config: config}
+	} else if state.Config != config {
+		return nil, errors.Newf(codes.FailedPrecondition,
+			"materialized view %q is defined with every: %s, offset: %s, fn: %q and column: %q; use a new view name to change its definition",
+			spec.View, state.Config.Every, state.Config.Offset, state.Config.Fn, state.Config.Column)
+	}
+	if state.Groups == nil {
+		state.Groups = make(map[string]*groupState)
+	}
+
+	return &aggregateWindowTransformation{
+		view:     spec.View,
+		window:   window,
+		fn:       spec.Fn,
+		column:   spec.Column,
+		lateness: spec.Lateness,
+		state:    state,
+		latest:   make(map[string]values.Time),
+	}, nil
+}
+
+// groupAggregate is the state of a table that is being aggregated.
+type groupAggregate struct {
+	key   string
+	state *groupState
+	typ   flux.ColType
+	// watermark is the watermark of the series when the query
+	// started. Rows at or before it have already been aggregated.
+	watermark *values.Time
+	// updated holds the stop time of the windows
+	// that were updated by their start time.
+	updated map[values.Time]values.Time
+}
+
+// newGroup validates the columns of the chunk and returns
+// the state of its series in the materialized view.
+func (t *aggregateWindowTransformation) newGroup(chunk table.Chunk) (*groupAggregate, error) {
+	key := chunk.Key()
+	timeIdx := chunk.Index(execute.DefaultTimeColLabel)
+	if timeIdx < 0 {
+		return nil, errors.Newf(codes.FailedPrecondition, "column %q does not exist", execute.DefaultTimeColLabel)
+	} else if typ := chunk.Col(timeIdx).Type; typ != flux.TTime {
+		return nil, errors.Newf(codes.FailedPrecondition, "column %q is of type %s, expected time", execute.DefaultTimeColLabel, typ)
+	} else if key.HasCol(execute.DefaultTimeColLabel) {
+		return nil, errors.Newf(codes.FailedPrecondition, "column %q cannot be part of the group key", execute.DefaultTimeColLabel)
+	}
+
+	idx := chunk.Index(t.column)
+	if idx < 0 {
+		return nil, errors.Newf(codes.FailedPrecondition, "column %q does not exist", t.column)
+	} else if key.HasCol(t.column) {
+		return nil, errors.New(codes.FailedPrecondition, "cannot aggregate columns that are part of the group key")
+	}
+	typ := chunk.Col(idx).Type
+	if t.fn != fnCount {
+		switch typ {
+		case flux.TInt, flux.TUInt, flux.TFloat:
+		default:
+			return nil, errors.Newf(codes.FailedPrecondition, "unsupported aggregate column type %v for %s", typ, t.fn)
+		}
+	}
+
+	seriesKey, err := seriesKey(key)
+	if err != nil {
+		return nil, err
+	}
+	gs, ok := t.state.Groups[seriesKey]
+	if !ok {
+		gs = &groupState{
+			Type:    typ.String(),
+			Windows: make(map[values.Time]*partial),
+		}
+		t.state.Groups[seriesKey] = gs
+	} else if gs.Type != typ.String() {
+		return nil, errors.Newf(codes.FailedPrecondition, "aggregate type conflict: %s != %s", typ, gs.Type)
+	}
+	if gs.Windows == nil {
+		gs.Windows = make(map[values.Time]*partial)
+	}
+	return &groupAggregate{
+		key:       seriesKey,
+		state:     gs,
+		typ:       typ,
+		watermark: gs.Watermark,
+		updated:   make(map[values.Time]values.Time),
+	}, nil
+}
+
+func (t *aggregateWindowTransformation) Aggregate(chunk table.Chunk, state interface{}, mem memory.Allocator) (interface{}, bool, error) {
+	g, _ := state.(*groupAggregate)
+	if g == nil {
+		var err error
+		if g, err = t.newGroup(chunk); err != nil {
+			return nil, false, err
+		}
+	}
+
+	idx := chunk.Index(t.column)
+	if typ := chunk.Col(idx).Type; typ != g.typ {
+		return nil, false, errors.Newf(codes.FailedPrecondition, "aggregate type conflict: %s != %s", typ, g.typ)
+	}
+	times := chunk.Ints(chunk.Index(execute.DefaultTimeColLabel))
+	vs := chunk.Values(idx)
+	for i, n := 0, chunk.Len(); i < n; i++ {
+		if times.IsNull(i) {
+			continue
+		}
+		ts := values.Time(times.Value(i))
+		if g.watermark != nil && ts <= *g.watermark {
+			continue
+		}
+		if latest, ok := t.latest[g.key]; !ok || ts > latest {
+			t.latest[g.key] = ts
+		}
+		if vs.IsNull(i) {
+			continue
+		}
+
+		bounds := t.window.GetLatestBounds(ts)
+		p, ok := g.state.Windows[bounds.Start()]
+		if !ok {
+			p = &partial{}
+			g.state.Windows[bounds.Start()] = p
+		}
+		switch arr := vs.(type) {
+		case *array.Int:
+			t.addInt(p, arr.Value(i), ts)
+		case *array.Uint:
+			t.addUInt(p, arr.Value(i), ts)
+		case *array.Float:
+			t.addFloat(p, arr.Value(i), ts)
+		default:
+			// Only count supports other types.
+			p.Count++
+		}
+		g.updated[bounds.Start()] = bounds.Stop()
+	}
+	return g, true, nil
+}
+
+// selects reports whether the value at ts replaces the
+// value of the partial for the first and last aggregates.
+func (t *aggregateWindowTransformation) selects(p *partial, ts values.Time) bool {
+	if p.Count == 0 {
+		return true
+	}
+	if t.fn == fnFirst {
+		return ts < p.Time
+	}
+	return ts >= p.Time
+}
+
+func (t *aggregateWindowTransformation) addInt(p *partial, v int64, ts values.Time) {
+	switch t.fn {
+	case fnSum:
+		p.Int += v
+	case fnMean:
+		p.Float += float64(v)
+	case fnMin:
+		if p.Count == 0 || v < p.Int {
+			p.Int = v
+		}
+	case fnMax:
+		if p.Count == 0 || v > p.Int {
+			p.Int = v
+		}
+	case fnFirst, fnLast:
+		if t.selects(p, ts) {
+			p.Int, p.Time = v, ts
+		}
+	}
+	p.Count++
+}
+
+func (t *aggregateWindowTransformation) addUInt(p *partial, v uint64, ts values.Time) {
+	switch t.fn {
+	case fnSum:
+		p.UInt += v
+	case fnMean:
+		p.Float += float64(v)
+	case fnMin:
+		if p.Count == 0 || v < p.UInt {
+			p.UInt = v
+		}
+	case fnMax:
+		if p.Count == 0 || v > p.UInt {
+			p.UInt = v
+		}
+	case fnFirst, fnLast:
+		if t.selects(p, ts) {
+			p.UInt, p.Time = v, ts
+		}
+	}
+	p.Count++
+}
+
+func (t *aggregateWindowTransformation) addFloat(p *partial, v float64, ts values.Time) {
+	switch t.fn {
+	case fnSum, fnMean:
+		p.Float += v
+	case fnMin:
+		if p.Count == 0 || v < p.Float {
+			p.Float = v
+		}
+	case fnMax:
+		if p.Count == 0 || v > p.Float {
+			p.Float = v
+		}
+	case fnFirst, fnLast:
+		if t.selects(p, ts) {
+			p.Float, p.Time = v, ts
+		}
+	}
+	p.Count++
+}
+
+// outputType returns the type of the aggregate of a column.
+func (t *aggregateWindowTransformation) outputType(typ flux.ColType) flux.ColType {
+	switch t.fn {
+	case fnCount:
+		return flux.TInt
+	case fnMean:
+		return flux.TFloat
+	default:
+		return typ
+	}
+}
+
+func (t *aggregateWindowTransformation) Compute(key flux.GroupKey, state interface{}, d *execute.TransportDataset, mem memory.Allocator) error {
+	g := state.(*groupAggregate)
+	if len(g.updated) == 0 {
+		return nil
+	}
+	starts := make([]values.Time, 0, len(g.updated))
+	for start := range g.updated {
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(i, j int) bool {
+		return starts[i] < starts[j]
+	})
+
+	n := len(starts)
+	outType := t.outputType(g.typ)
+	buffer := arrow.TableBuffer{
+		GroupKey: key,
+		Columns:  make([]flux.ColMeta, 0, len(key.Cols())+2),
+	}
+	buffer.Columns = append(buffer.Columns, key.Cols()...)
+	buffer.Columns = append(buffer.Columns,
+		flux.ColMeta{Label: execute.DefaultTimeColLabel, Type: flux.TTime},
+		flux.ColMeta{Label: t.column, Type: outType},
+	)
+	buffer.Values = make([]array.Interface, len(key.Cols()), len(buffer.Columns))
+	for j := range key.Cols() {
+		buffer.Values[j] = arrow.Repeat(key.Cols()[j].Type, key.Value(j), n, mem)
+	}
+
+	tb := array.NewIntBuilder(mem)
+	tb.Reserve(n)
+	for _, start := range starts {
+		tb.Append(int64(g.updated[start]))
+	}
+	buffer.Values = append(buffer.Values, tb.NewArray())
+
+	var arr array.Interface
+	switch outType {
+	case flux.TInt:
+		b := array.NewIntBuilder(mem)
+		b.Reserve(n)
+		for _, start := range starts {
+			p := g.state.Windows[start]
+			if t.fn == fnCount {
+				b.Append(p.Count)
+			} else {
+				b.Append(p.Int)
+			}
+		}
+		arr = b.NewArray()
+	case flux.TUInt:
+		b := array.NewUintBuilder(mem)
+		b.Reserve(n)
+		for _, start := range starts {
+			b.Append(g.state.Windows[start].UInt)
+		}
+		arr = b.NewArray()
+	case flux.TFloat:
+		b := array.NewFloatBuilder(mem)
+		b.Reserve(n)
+		for _, start := range starts {
+			p := g.state.Windows[start]
+			if t.fn == fnMean {
+				b.Append(p.Float / float64(p.Count))
+			} else {
+				b.Append(p.Float)
+			}
+		}
+		arr = b.NewArray()
+	default:
+		return errors.Newf(codes.Internal, "unsupported aggregate type %v", outType)
+	}
+	buffer.Values = append(buffer.Values, arr)
+
+	if err := buffer.Validate(); err != nil {
+		buffer.Release()
+		return err
+	}
+	return d.Process(table.ChunkFromBuffer(buffer))
+}
+
+// Commit saves the state of the view once the query has succeeded.
+//
+// The watermark of each series is advanced to the latest time read for
+// it. The windows that end at or before the watermark of their series
+// cannot receive more rows, so their partial aggregates are removed.
+// If the view has a lateness, the series whose watermark is further
+// behind the latest watermark of the view are removed.
+func (t *aggregateWindowTransformation) Commit(ctx context.Context) error {
+	for key, ts := range t.latest {
+		ts := ts
+		if gs := t.state.Groups[key]; gs.Watermark == nil || ts > *gs.Watermark {
+			gs.Watermark = &ts
+		}
+	}
+
+	var cutoff *values.Time
+	if latest := t.state.latest(); latest != nil && !t.lateness.IsZero() {
+		c := latest.Add(t.lateness.Mul(-1))
+		cutoff = &c
+	}
+	for k, gs := range t.state.Groups {
+		if gs.Watermark == nil || (cutoff != nil && *gs.Watermark < *cutoff) {
+			delete(t.state.Groups, k)
+			continue
+		}
+		for start := range gs.Windows {
+			if t.window.GetLatestBounds(start).Stop() <= *gs.Watermark {
+				delete(gs.Windows, start)
+			}
+		}
+	}
+	return saveViewState(ctx, t.view, t.state)
+}
+
+func (t *aggregateWindowTransformation) Close() error {
+	return nil
+}
+
+// seriesKey returns a string that identifies the series of a group key.
+// The _start and _stop columns are left out since they are set by the
+// range of each query.
+func seriesKey(key flux.GroupKey) (string, error) {
+	type column struct {
+		Label string      `json:"label"`
+		Type  string      `json:"type"`
+		Value interface{} `json:"value"`
+	}
+	cols := make([]column, 0, len(key.Cols()))
+	for j, c := range key.Cols() {
+		if c.Label == execute.DefaultStartColLabel || c.Label == execute.DefaultStopColLabel {
+			continue
+		}
+		col := column{Label: c.Label, Type: c.Type.String()}
+		if v := key.Value(j); !v.IsNull() {
+			switch c.Type {
+			case flux.TString:
+				col.Value = v.Str()
+			case flux.TInt:
+				col.Value = v.Int()
+			case flux.TUInt:
+				col.Value = v.UInt()
+			case flux.TFloat:
+				col.Value = v.Float()
+			case flux.TBool:
+				col.Value = v.Bool()
+			case flux.TTime:
+				col.Value = int64(v.Time())
+			}
+		}
+		cols = append(cols, col)
+	}
+	sort.Slice(cols, func(i, j int) bool {
+		return cols[i].Label < cols[j].Label
+	})
+	data, err := json.Marshal(cols)
+	if err != nil {
+		return "", errors.Wrap(err, codes.Invalid, "cannot encode the group key")
+	}
+	return string(data), nil
+}
@@ -0,0 +1,64 @@
+package materialized
+
+import (
+	"context"
+	"testing"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/runtime"
+	"github.com/influxdata/flux/stdlib/universe"
+	"github.com/influxdata/flux/values"
+)
+
+func TestAggregateOf(t *testing.T) {
+	builtin := func(name string) values.Function {
+		v, ok := runtime.LookupBuiltin("universe", name)
+		if !ok {
+			t.Fatalf("missing builtin %q", name)
+		}
+		return v.Function()
+	}
+	for _, tc := range []struct {
+		name    string
+		fn      values.Function
+		want    string
+		wantErr bool
+	}{
+		{name: "count", fn: builtin("count"), want: "count"},
+		{name: "mean", fn: builtin("mean"), want: "mean"},
+		{name: "last", fn: builtin("last"), want: "last"},
+		{name: "unsupported aggregate", fn: builtin("spread"), wantErr: true},
+		{
+			// A function with the same name and kind is not the universe builtin.
+			name:    "other function named mean",
+			fn:      flux.MustValue(flux.FunctionValue("mean", universe.CreateMeanOpSpec, runtime.MustLookupBuiltinType("universe", "mean"))).Function(),
+			wantErr: true,
+		},
+		{
+			name: "not an aggregate",
+			fn: values.NewFunction("f", runtime.MustLookupBuiltinType("universe", "mean"), func(ctx context.Context, args values.Object) (values.Value, error) {
+				t.Error("unexpected call to fn")
+				return values.NewInt(1), nil
+			}, false),
+			wantErr: true,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := aggregateOf(tc.fn)
+			if tc.wantErr {
+				if want, got := codes.Invalid, errors.Code(err); want != got {
+					t.Fatalf("unexpected error code -want/+got:\n\t- %v\n\t+ %v (%v)", want, got, err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if tc.want != got {
+				t.Errorf("unexpected aggregate -want/+got:\n\t- %v\n\t+ %v", tc.want, got)
+			}
+		})
+	}
+}
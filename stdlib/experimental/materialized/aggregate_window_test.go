@@ -0,0 +1,349 @@
+package materialized_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	materializeddeps "github.com/influxdata/flux/dependencies/materialized"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/execute/executetest"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/memory"
+	"github.com/influxdata/flux/querytest"
+	"github.com/influxdata/flux/stdlib/experimental/materialized"
+	"github.com/influxdata/flux/values"
+)
+
+func TestAggregateWindowOperation_Marshaling(t *testing.T) {
+	data := []byte(`{
+		"id":"aggregateWindow",
+		"kind":"experimental/materialized.aggregateWindow",
+		"spec":{
+			"view":"cpu",
+			"every":"5m",
+			"offset":"1m",
+			"fn":"mean",
+			"column":"_value",
+			"lateness":"1h"
+		}
+	}`)
+	op := &flux.Operation{
+		ID: "aggregateWindow",
+		Spec: &materialized.AggregateWindowOpSpec{
+			View:     "cpu",
+			Every:    flux.ConvertDuration(5 * time.Minute),
+			Offset:   flux.ConvertDuration(time.Minute),
+			Fn:       "mean",
+			Column:   "_value",
+			Lateness: flux.ConvertDuration(time.Hour),
+		},
+	}
+	querytest.OperationMarshalingTestHelper(t, data, op)
+}
+
+// newContext returns a context with a state store for the views.
+func newContext(store materializeddeps.StateStore) context.Context {
+	ctx := materializeddeps.Dependency{Store: store}.Inject(context.Background())
+	now := time.Unix(0, 100)
+	return execute.NewExecutionDependencies(nil, &now, nil).Inject(ctx)
+}
+
+// processAggregateWindow processes the data and commits
+// the state of the view if no error is expected.
+func processAggregateWindow(t *testing.T, ctx context.Context, spec *materialized.AggregateWindowProcedureSpec, data []flux.Table, want []*executetest.Table, wantErr error) {
+	t.Helper()
+	tr, err := materialized.NewAggregateWindowTransformation(ctx, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	executetest.ProcessTestHelper2(t, data, want, wantErr, func(id execute.DatasetID, alloc *memory.Allocator) (execute.Transformation, execute.Dataset) {
+		tx, d, err := execute.NewAggregateTransformation(id, tr, alloc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tx, d
+	})
+	if wantErr == nil {
+		if err := tr.Commit(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// watermark returns the watermark of the view, or -1 if it has none.
+func watermark(t *testing.T, ctx context.Context, view string) values.Time {
+	t.Helper()
+	v, err := materialized.Watermark(ctx, values.NewObjectWithValues(map[string]values.Value{
+		"view":   values.NewString(view),
+		"orTime": values.NewTime(-1),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v.Time()
+}
+
+func hostTable(start, stop int64, host string, rows ...[]interface{}) *executetest.Table {
+	data := make([][]interface{}, len(rows))
+	for i, row := range rows {
+		data[i] = append([]interface{}{execute.Time(start), execute.Time(stop), host}, row...)
+	}
+	return &executetest.Table{
+		KeyCols: []string{"_start", "_stop", "host"},
+		ColMeta: []flux.ColMeta{
+			{Label: "_start", Type: flux.TTime},
+			{Label: "_stop", Type: flux.TTime},
+			{Label: "host", Type: flux.TString},
+			{Label: "_time", Type: flux.TTime},
+			{Label: "_value", Type: flux.TFloat},
+		},
+		Data: data,
+	}
+}
+
+func TestAggregateWindow_Incremental(t *testing.T) {
+	ctx := newContext(&materializeddeps.MemoryStateStore{})
+	spec := &materialized.AggregateWindowProcedureSpec{
+		View:   "cpu",
+		Every:  flux.ConvertDuration(10),
+		Fn:     "sum",
+		Column: "_value",
+	}
+
+	if want, got := values.Time(-1), watermark(t, ctx, "cpu"); want != got {
+		t.Errorf("unexpected watermark of a new view -want/+got:\n\t- %v\n\t+ %v", want, got)
+	}
+
+	processAggregateWindow(t, ctx, spec,
+		[]flux.Table{
+			hostTable(0, 30, "a",
+				[]interface{}{execute.Time(1), 1.0},
+				[]interface{}{execute.Time(5), 2.0},
+				[]interface{}{execute.Time(12), 3.0},
+			),
+			hostTable(0, 30, "b",
+				[]interface{}{execute.Time(3), 10.0},
+			),
+		},
+		[]*executetest.Table{
+			hostTable(0, 30, "a",
+				[]interface{}{execute.Time(10), 3.0},
+				[]interface{}{execute.Time(20), 3.0},
+			),
+			hostTable(0, 30, "b",
+				[]interface{}{execute.Time(10), 10.0},
+			),
+		},
+		nil,
+	)
+	// The watermark of the view is the earliest watermark of its series.
+	if want, got := values.Time(3), watermark(t, ctx, "cpu"); want != got {
+		t.Errorf("unexpected watermark -want/+got:\n\t- %v\n\t+ %v", want, got)
+	}
+
+	// The rows at or before the watermark of their series were aggregated
+	// by the first run. Series b lags behind series a, so its late row
+	// still updates its open window.
+	processAggregateWindow(t, ctx, spec,
+		[]flux.Table{
+			hostTable(3, 40, "a",
+				[]interface{}{execute.Time(5), 100.0},
+				[]interface{}{execute.Time(12), 100.0},
+				[]interface{}{execute.Time(15), 4.0},
+				[]interface{}{execute.Time(25), 5.0},
+			),
+			hostTable(3, 40, "b",
+				[]interface{}{execute.Time(3), 100.0},
+				[]interface{}{execute.Time(8), 20.0},
+			),
+		},
+		[]*executetest.Table{
+			hostTable(3, 40, "a",
+				[]interface{}{execute.Time(20), 7.0},
+				[]interface{}{execute.Time(30), 5.0},
+			),
+			hostTable(3, 40, "b",
+				[]interface{}{execute.Time(10), 30.0},
+			),
+		},
+		nil,
+	)
+	if want, got := values.Time(8), watermark(t, ctx, "cpu"); want != got {
+		t.Errorf("unexpected watermark -want/+got:\n\t- %v\n\t+ %v", want, got)
+	}
+
+	// A row that arrives after the watermark of its series has passed its time
+	// is ignored. Series b falls more than the lateness behind series a,
+	// so it is removed and no longer holds back the watermark of the view.
+	late := *spec
+	late.Lateness = flux.ConvertDuration(10)
+	processAggregateWindow(t, ctx, &late,
+		[]flux.Table{
+			hostTable(8, 50, "a",
+				[]interface{}{execute.Time(19), 100.0},
+				[]interface{}{execute.Time(26), 6.0},
+			),
+		},
+		[]*executetest.Table{
+			hostTable(8, 50, "a",
+				[]interface{}{execute.Time(30), 11.0},
+			),
+		},
+		nil,
+	)
+	if want, got := values.Time(26), watermark(t, ctx, "cpu"); want != got {
+		t.Errorf("unexpected watermark -want/+got:\n\t- %v\n\t+ %v", want, got)
+	}
+}
+
+func TestAggregateWindow_Aggregates(t *testing.T) {
+	input := func(typ flux.ColType, vs ...interface{}) []flux.Table {
+		tbl := &executetest.Table{
+			KeyCols: []string{"host"},
+			ColMeta: []flux.ColMeta{
+				{Label: "host", Type: flux.TString},
+				{Label: "_time", Type: flux.TTime},
+				{Label: "_value", Type: typ},
+			},
+		}
+		for i, v := range vs {
+			tbl.Data = append(tbl.Data, []interface{}{"a", execute.Time(i + 1), v})
+		}
+		return []flux.Table{tbl}
+	}
+	output := func(typ flux.ColType, v interface{}) []*executetest.Table {
+		return []*executetest.Table{{
+			KeyCols: []string{"host"},
+			ColMeta: []flux.ColMeta{
+				{Label: "host", Type: flux.TString},
+				{Label: "_time", Type: flux.TTime},
+				{Label: "_value", Type: typ},
+			},
+			Data: [][]interface{}{
+				{"a", execute.Time(10), v},
+			},
+		}}
+	}
+
+	floats := func() []flux.Table {
+		return input(flux.TFloat, 4.0, nil, 2.0, 6.0)
+	}
+	for _, tc := range []struct {
+		name string
+		fn   string
+		data []flux.Table
+		want []*executetest.Table
+	}{
+		{name: "count", fn: "count", data: floats(), want: output(flux.TInt, int64(3))},
+		{name: "sum", fn: "sum", data: floats(), want: output(flux.TFloat, 12.0)},
+		{name: "mean", fn: "mean", data: floats(), want: output(flux.TFloat, 4.0)},
+		{name: "min", fn: "min", data: floats(), want: output(flux.TFloat, 2.0)},
+		{name: "max", fn: "max", data: floats(), want: output(flux.TFloat, 6.0)},
+		{name: "first", fn: "first", data: floats(), want: output(flux.TFloat, 4.0)},
+		{name: "last", fn: "last", data: floats(), want: output(flux.TFloat, 6.0)},
+		{name: "sum int", fn: "sum", data: input(flux.TInt, int64(1), int64(-3)), want: output(flux.TInt, int64(-2))},
+		{name: "mean int", fn: "mean", data: input(flux.TInt, int64(1), int64(2)), want: output(flux.TFloat, 1.5)},
+		{name: "max uint", fn: "max", data: input(flux.TUInt, uint64(3), uint64(7)), want: output(flux.TUInt, uint64(7))},
+		{name: "count string", fn: "count", data: input(flux.TString, "x", "y"), want: output(flux.TInt, int64(2))},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := newContext(&materializeddeps.MemoryStateStore{})
+			spec := &materialized.AggregateWindowProcedureSpec{
+				View:   "view",
+				Every:  flux.ConvertDuration(10),
+				Fn:     tc.fn,
+				Column: "_value",
+			}
+			processAggregateWindow(t, ctx, spec, tc.data, tc.want, nil)
+		})
+	}
+}
+
+func TestAggregateWindow_Errors(t *testing.T) {
+	store := &materializeddeps.MemoryStateStore{}
+	ctx := newContext(store)
+	spec := &materialized.AggregateWindowProcedureSpec{
+		View:   "cpu",
+		Every:  flux.ConvertDuration(10),
+		Fn:     "sum",
+		Column: "_value",
+	}
+
+	// The state is only saved when the query succeeds and it is committed.
+	tr, err := materialized.NewAggregateWindowTransformation(ctx, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	executetest.ProcessTestHelper2(t,
+		[]flux.Table{hostTable(0, 30, "a", []interface{}{execute.Time(1), 1.0})},
+		[]*executetest.Table{hostTable(0, 30, "a", []interface{}{execute.Time(10), 1.0})},
+		nil,
+		func(id execute.DatasetID, alloc *memory.Allocator) (execute.Transformation, execute.Dataset) {
+			tx, d, err := execute.NewAggregateTransformation(id, tr, alloc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return tx, d
+		},
+	)
+	if state, err := store.Load(ctx, "cpu"); err != nil {
+		t.Fatal(err)
+	} else if state != nil {
+		t.Errorf("expected no state before the commit, got %s", state)
+	}
+
+	// Only count supports columns that are not numeric.
+	strings := &executetest.Table{
+		KeyCols: []string{"_start", "_stop", "host"},
+		ColMeta: []flux.ColMeta{
+			{Label: "_start", Type: flux.TTime},
+			{Label: "_stop", Type: flux.TTime},
+			{Label: "host", Type: flux.TString},
+			{Label: "_time", Type: flux.TTime},
+			{Label: "_value", Type: flux.TString},
+		},
+		Data: [][]interface{}{
+			{execute.Time(0), execute.Time(30), "b", execute.Time(1), "x"},
+		},
+	}
+	processAggregateWindow(t, ctx, spec,
+		[]flux.Table{
+			hostTable(0, 30, "a", []interface{}{execute.Time(1), 1.0}),
+			strings,
+		},
+		nil,
+		errors.New(codes.FailedPrecondition, "unsupported aggregate column type string for sum"),
+	)
+
+	// A view cannot change its definition.
+	processAggregateWindow(t, ctx, spec,
+		[]flux.Table{hostTable(0, 30, "a", []interface{}{execute.Time(1), 1.0})},
+		[]*executetest.Table{hostTable(0, 30, "a", []interface{}{execute.Time(10), 1.0})},
+		nil,
+	)
+	mean := *spec
+	mean.Fn = "mean"
+	_, err = materialized.NewAggregateWindowTransformation(ctx, &mean)
+	if want, got := codes.FailedPrecondition, errors.Code(err); want != got {
+		t.Errorf("unexpected error code for a changed definition -want/+got:\n\t- %v\n\t+ %v (%v)", want, got, err)
+	}
+}
+
+func TestWatermark(t *testing.T) {
+	ctx := newContext(&materializeddeps.MemoryStateStore{})
+	got, err := materialized.Watermark(ctx, values.NewObjectWithValues(map[string]values.Value{
+		"view":   values.NewString("cpu"),
+		"orTime": values.NewDuration(flux.ConvertDuration(-10)),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := values.NewTime(90); !cmp.Equal(want, got) {
+		t.Errorf("unexpected watermark -want/+got:\n%s", cmp.Diff(want, got))
+	}
+}
@@ -0,0 +1,148 @@
+package materialized
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/dependencies/materialized"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/interpreter"
+	"github.com/influxdata/flux/runtime"
+	"github.com/influxdata/flux/values"
+)
+
+const pkgpath = "experimental/materialized"
+
+func init() {
+	runtime.RegisterPackageValue(pkgpath, "watermark", values.NewFunction(
+		"watermark",
+		runtime.MustLookupBuiltinType(pkgpath, "watermark"),
+		Watermark,
+		false,
+	))
+}
+
+// Watermark returns the earliest watermark of the series of a materialized view,
+// or the value of the orTime parameter if the view has not aggregated any data.
+// A query that starts at the watermark reads every row that a series of the
+// view has not aggregated.
+func Watermark(ctx context.Context, args values.Object) (values.Value, error) {
+	return interpreter.DoFunctionCallContext(func(ctx context.Context, args interpreter.Arguments) (values.Value, error) {
+		view, err := args.GetRequiredString("view")
+		if err != nil {
+			return nil, err
+		}
+		orTime, err := args.GetRequired("orTime")
+		if err != nil {
+			return nil, err
+		} else if !values.IsTimeable(orTime) {
+			return nil, errors.Newf(codes.Invalid, "keyword argument \"orTime\" should be a time or duration, but got %v", orTime.Type().Nature())
+		}
+
+		state, err := loadViewState(ctx, view)
+		if err != nil {
+			return nil, err
+		}
+		if state != nil {
+			if wm := state.earliest(); wm != nil {
+				return values.NewTime(*wm), nil
+			}
+		}
+		t, err := execute.GetExecutionDependencies(ctx).ResolveTimeable(orTime)
+		if err != nil {
+			return nil, err
+		}
+		return values.NewTime(t), nil
+	}, ctx, args)
+}
+
+// viewState is the state of a materialized view
+// that is saved in the state store.
+type viewState struct {
+	Config viewConfig `json:"config"`
+	// Groups holds the state of each series by its key.
+	Groups map[string]*groupState `json:"groups,omitempty"`
+}
+
+// earliest returns the earliest watermark of the series of the view.
+func (s *viewState) earliest() *values.Time {
+	var wm *values.Time
+	for _, gs := range s.Groups {
+		if gs.Watermark != nil && (wm == nil || *gs.Watermark < *wm) {
+			wm = gs.Watermark
+		}
+	}
+	return wm
+}
+
+// latest returns the latest watermark of the series of the view.
+func (s *viewState) latest() *values.Time {
+	var wm *values.Time
+	for _, gs := range s.Groups {
+		if gs.Watermark != nil && (wm == nil || *gs.Watermark > *wm) {
+			wm = gs.Watermark
+		}
+	}
+	return wm
+}
+
+// viewConfig holds the parameters that define the aggregate of a view.
+type viewConfig struct {
+	Every  string `json:"every"`
+	Offset string `json:"offset"`
+	Fn     string `json:"fn"`
+	Column string `json:"column"`
+}
+
+// groupState holds the partial aggregates of a series.
+type groupState struct {
+	// Type is the type of the aggregated column.
+	Type string `json:"type"`
+	// Watermark is the latest time aggregated for the series.
+	Watermark *values.Time `json:"watermark,omitempty"`
+	// Windows holds the partial aggregates
+	// of the open windows by their start time.
+	Windows map[values.Time]*partial `json:"windows"`
+}
+
+// partial is the partial aggregate of a window.
+type partial struct {
+	// Count is the number of values aggregated in the window.
+	Count int64 `json:"count"`
+
+	// The aggregated value is held by the field
+	// that matches the type of the aggregate.
+	Int   int64   `json:"int,omitempty"`
+	UInt  uint64  `json:"uint,omitempty"`
+	Float float64 `json:"float,omitempty"`
+
+	// Time is the time of the value selected by first and last.
+	Time values.Time `json:"time,omitempty"`
+}
+
+// loadViewState reads the state of the view from the state store.
+// It returns nil if the view has no state.
+func loadViewState(ctx context.Context, view string) (*viewState, error) {
+	data, err := materialized.GetStateStore(ctx).Load(ctx, view)
+	if err != nil {
+		return nil, err
+	} else if data == nil {
+		return nil, nil
+	}
+	var state viewState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, errors.Wrapf(err, codes.Internal, "invalid state for materialized view %q", view)
+	}
+	return &state, nil
+}
+
+// saveViewState writes the state of the view to the state store.
+func saveViewState(ctx context.Context, view string, state *viewState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return errors.Wrapf(err, codes.Internal, "cannot encode the state of materialized view %q", view)
+	}
+	return materialized.GetStateStore(ctx).Save(ctx, view, data)
+}
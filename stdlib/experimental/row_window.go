@@ -0,0 +1,514 @@
+package experimental
+
+import (
+	"context"
+	"sort"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/compiler"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/interpreter"
+	"github.com/influxdata/flux/memory"
+	"github.com/influxdata/flux/plan"
+	"github.com/influxdata/flux/runtime"
+	"github.com/influxdata/flux/stdlib/universe"
+	"github.com/influxdata/flux/values"
+)
+
+const (
+	CountWindowKind = "experimental-countWindow"
+	EventWindowKind = "experimental-eventWindow"
+)
+
+// CountWindowOpSpec windows every N rows of a table.
+type CountWindowOpSpec struct {
+	N           int64  `json:"n"`
+	Step        int64  `json:"step"`
+	TimeColumn  string `json:"timeColumn"`
+	StartColumn string `json:"startColumn"`
+	StopColumn  string `json:"stopColumn"`
+}
+
+// EventWindowOpSpec opens a window on the rows that match Start
+// and closes it on the rows that match Stop.
+type EventWindowOpSpec struct {
+	Start       interpreter.ResolvedFunction `json:"start"`
+	Stop        interpreter.ResolvedFunction `json:"stop"`
+	TimeColumn  string                       `json:"timeColumn"`
+	StartColumn string                       `json:"startColumn"`
+	StopColumn  string                       `json:"stopColumn"`
+}
+
+func init() {
+	countWindowSignature := runtime.MustLookupBuiltinType("experimental", "countWindow")
+	runtime.RegisterPackageValue("experimental", "countWindow", flux.MustValue(flux.FunctionValue("countWindow", createCountWindowOpSpec, countWindowSignature)))
+	flux.RegisterOpSpec(CountWindowKind, newCountWindowOp)
+	plan.RegisterProcedureSpec(CountWindowKind, newCountWindowProcedure, CountWindowKind)
+	execute.RegisterTransformation(CountWindowKind, createCountWindowTransformation)
+
+	eventWindowSignature := runtime.MustLookupBuiltinType("experimental", "eventWindow")
+	runtime.RegisterPackageValue("experimental", "eventWindow", flux.MustValue(flux.FunctionValue("eventWindow", createEventWindowOpSpec, eventWindowSignature)))
+	flux.RegisterOpSpec(EventWindowKind, newEventWindowOp)
+	plan.RegisterProcedureSpec(EventWindowKind, newEventWindowProcedure, EventWindowKind)
+	execute.RegisterTransformation(EventWindowKind, createEventWindowTransformation)
+}
+
+// getWindowColumns reads the time, start and stop column arguments
+// shared by the row based windows.
+func getWindowColumns(args flux.Arguments) (timeCol, startCol, stopCol string, err error) {
+	timeCol, startCol, stopCol = execute.DefaultTimeColLabel, execute.DefaultStartColLabel, execute.DefaultStopColLabel
+	for _, c := range []struct {
+		name string
+		dst  *string
+	}{
+		{name: "timeColumn", dst: &timeCol},
+		{name: "startColumn", dst: &startCol},
+		{name: "stopColumn", dst: &stopCol},
+	} {
+		if label, ok, err := args.GetString(c.name); err != nil {
+			return "", "", "", err
+		} else if ok {
+			*c.dst = label
+		}
+	}
+	return timeCol, startCol, stopCol, nil
+}
+
+func createCountWindowOpSpec(args flux.Arguments, a *flux.Administration) (flux.OperationSpec, error) {
+	if err := a.AddParentFromArgs(args); err != nil {
+		return nil, err
+	}
+
+	spec := new(CountWindowOpSpec)
+
+	n, err := args.GetRequiredInt("n")
+	if err != nil {
+		return nil, err
+	}
+	if n <= 0 {
+		return nil, errors.Newf(codes.Invalid, "n must be greater than zero, got %d", n)
+	}
+	spec.N = n
+
+	if step, ok, err := args.GetInt("step"); err != nil {
+		return nil, err
+	} else if ok {
+		if step <= 0 {
+			return nil, errors.Newf(codes.Invalid, "step must be greater than zero, got %d", step)
+		}
+		spec.Step = step
+	} else {
+		spec.Step = n
+	}
+
+	if spec.TimeColumn, spec.StartColumn, spec.StopColumn, err = getWindowColumns(args); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+func newCountWindowOp() flux.OperationSpec {
+	return new(CountWindowOpSpec)
+}
+
+func (s *CountWindowOpSpec) Kind() flux.OperationKind {
+	return CountWindowKind
+}
+
+func createEventWindowOpSpec(args flux.Arguments, a *flux.Administration) (flux.OperationSpec, error) {
+	if err := a.AddParentFromArgs(args); err != nil {
+		return nil, err
+	}
+
+	spec := new(EventWindowOpSpec)
+
+	for _, fn := range []struct {
+		name string
+		dst  *interpreter.ResolvedFunction
+	}{
+		{name: "start", dst: &spec.Start},
+		{name: "stop", dst: &spec.Stop},
+	} {
+		f, err := args.GetRequiredFunction(fn.name)
+		if err != nil {
+			return nil, err
+		}
+		if *fn.dst, err = interpreter.ResolveFunction(f); err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	if spec.TimeColumn, spec.StartColumn, spec.StopColumn, err = getWindowColumns(args); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+func newEventWindowOp() flux.OperationSpec {
+	return new(EventWindowOpSpec)
+}
+
+func (s *EventWindowOpSpec) Kind() flux.OperationKind {
+	return EventWindowKind
+}
+
+type CountWindowProcedureSpec struct {
+	plan.DefaultCost
+	N           int64
+	Step        int64
+	TimeColumn  string
+	StartColumn string
+	StopColumn  string
+}
+
+func newCountWindowProcedure(qs flux.OperationSpec, pa plan.Administration) (plan.ProcedureSpec, error) {
+	spec, ok := qs.(*CountWindowOpSpec)
+	if !ok {
+		return nil, errors.Newf(codes.Internal, "invalid spec type %T", qs)
+	}
+	return &CountWindowProcedureSpec{
+		N:           spec.N,
+		Step:        spec.Step,
+		TimeColumn:  spec.TimeColumn,
+		StartColumn: spec.StartColumn,
+		StopColumn:  spec.StopColumn,
+	}, nil
+}
+
+func (s *CountWindowProcedureSpec) Kind() plan.ProcedureKind {
+	return CountWindowKind
+}
+
+func (s *CountWindowProcedureSpec) Copy() plan.ProcedureSpec {
+	ns := *s
+	return &ns
+}
+
+type EventWindowProcedureSpec struct {
+	plan.DefaultCost
+	Start       interpreter.ResolvedFunction
+	Stop        interpreter.ResolvedFunction
+	TimeColumn  string
+	StartColumn string
+	StopColumn  string
+}
+
+func newEventWindowProcedure(qs flux.OperationSpec, pa plan.Administration) (plan.ProcedureSpec, error) {
+	spec, ok := qs.(*EventWindowOpSpec)
+	if !ok {
+		return nil, errors.Newf(codes.Internal, "invalid spec type %T", qs)
+	}
+	return &EventWindowProcedureSpec{
+		Start:       spec.Start,
+		Stop:        spec.Stop,
+		TimeColumn:  spec.TimeColumn,
+		StartColumn: spec.StartColumn,
+		StopColumn:  spec.StopColumn,
+	}, nil
+}
+
+func (s *EventWindowProcedureSpec) Kind() plan.ProcedureKind {
+	return EventWindowKind
+}
+
+func (s *EventWindowProcedureSpec) Copy() plan.ProcedureSpec {
+	ns := *s
+	ns.Start = s.Start.Copy()
+	ns.Stop = s.Stop.Copy()
+	return &ns
+}
+
+func createCountWindowTransformation(id execute.DatasetID, mode execute.AccumulationMode, spec plan.ProcedureSpec, a execute.Administration) (execute.Transformation, execute.Dataset, error) {
+	s, ok := spec.(*CountWindowProcedureSpec)
+	if !ok {
+		return nil, nil, errors.Newf(codes.Internal, "invalid spec type %T", spec)
+	}
+	t, d := NewCountWindowTransformation(id, s, a.Allocator())
+	return t, d, nil
+}
+
+func createEventWindowTransformation(id execute.DatasetID, mode execute.AccumulationMode, spec plan.ProcedureSpec, a execute.Administration) (execute.Transformation, execute.Dataset, error) {
+	s, ok := spec.(*EventWindowProcedureSpec)
+	if !ok {
+		return nil, nil, errors.Newf(codes.Internal, "invalid spec type %T", spec)
+	}
+	t, d := NewEventWindowTransformation(a.Context(), id, s, a.Allocator())
+	return t, d, nil
+}
+
+// rowWindowRow locates a row within a buffered table.
+type rowWindowRow struct {
+	time   values.Time
+	buffer int
+	row    int
+}
+
+// rowWindow is a window made of a run of rows sorted by time.
+type rowWindow struct {
+	rows        []rowWindowRow
+	start, stop values.Time
+}
+
+// rowWindowFunc splits the rows of a table, sorted by time, into windows.
+type rowWindowFunc func(tbl flux.BufferedTable, rows []rowWindowRow) ([]rowWindow, error)
+
+// rowWindowTransformation windows tables based on their rows
+// instead of fixed time boundaries.
+// Each window is output as its own table with the start and stop
+// columns added to the group key.
+// Windows of rows with the same time can have the same bounds and
+// therefore the same group key, so their rows are output in a single table.
+type rowWindowTransformation struct {
+	execute.ExecutionNode
+	d     *execute.PassthroughDataset
+	alloc *memory.Allocator
+
+	windows                    rowWindowFunc
+	timeCol, startCol, stopCol string
+}
+
+// NewCountWindowTransformation creates a transformation that windows
+// every N rows ordered by time.
+// A window starts every Step rows so windows overlap when Step is less than N.
+// The start of a window is the time of its first row and
+// the stop is the time of its last row plus one nanosecond.
+func NewCountWindowTransformation(id execute.DatasetID, spec *CountWindowProcedureSpec, alloc *memory.Allocator) (execute.Transformation, execute.Dataset) {
+	n, step := int(spec.N), int(spec.Step)
+	windows := func(tbl flux.BufferedTable, rows []rowWindowRow) ([]rowWindow, error) {
+		var ws []rowWindow
+		for start := 0; start < len(rows); start += step {
+			end := start + n
+			if end > len(rows) {
+				end = len(rows)
+			}
+			ws = append(ws, rowWindow{
+				rows:  rows[start:end],
+				start: rows[start].time,
+				stop:  rows[end-1].time + 1,
+			})
+			if end == len(rows) {
+				break
+			}
+		}
+		return ws, nil
+	}
+	t := &rowWindowTransformation{
+		d:        execute.NewPassthroughDataset(id),
+		alloc:    alloc,
+		windows:  windows,
+		timeCol:  spec.TimeColumn,
+		startCol: spec.StartColumn,
+		stopCol:  spec.StopColumn,
+	}
+	return t, t.d
+}
+
+// NewEventWindowTransformation creates a transformation that opens a window
+// on the first row that matches the start predicate and closes it on the next
+// row that matches the stop predicate.
+// The start of a window is the time of its first row.
+// The stop is the time of the row that closed the window, which is not part of the window.
+// A row that closes a window can open the next one.
+// A window that is never closed stops one nanosecond after the last row.
+func NewEventWindowTransformation(ctx context.Context, id execute.DatasetID, spec *EventWindowProcedureSpec, alloc *memory.Allocator) (execute.Transformation, execute.Dataset) {
+	startFn := execute.NewRowPredicateFn(spec.Start.Fn, compiler.ToScope(spec.Start.Scope))
+	stopFn := execute.NewRowPredicateFn(spec.Stop.Fn, compiler.ToScope(spec.Stop.Scope))
+	windows := func(tbl flux.BufferedTable, rows []rowWindowRow) ([]rowWindow, error) {
+		start, err := startFn.Prepare(tbl.Cols())
+		if err != nil {
+			return nil, err
+		}
+		stop, err := stopFn.Prepare(tbl.Cols())
+		if err != nil {
+			return nil, err
+		}
+
+		var ws []rowWindow
+		open := -1
+		for i, r := range rows {
+			cr := tbl.Buffer(r.buffer)
+			if open >= 0 {
+				closed, err := stop.EvalRow(ctx, r.row, cr)
+				if err != nil {
+					return nil, err
+				}
+				if !closed {
+					continue
+				}
+				ws = append(ws, rowWindow{
+					rows:  rows[open:i],
+					start: rows[open].time,
+					stop:  r.time,
+				})
+				open = -1
+			}
+			opened, err := start.EvalRow(ctx, r.row, cr)
+			if err != nil {
+				return nil, err
+			}
+			if opened {
+				open = i
+			}
+		}
+		if open >= 0 {
+			ws = append(ws, rowWindow{
+				rows:  rows[open:],
+				start: rows[open].time,
+				stop:  rows[len(rows)-1].time + 1,
+			})
+		}
+		return ws, nil
+	}
+	t := &rowWindowTransformation{
+		d:        execute.NewPassthroughDataset(id),
+		alloc:    alloc,
+		windows:  windows,
+		timeCol:  spec.TimeColumn,
+		startCol: spec.StartColumn,
+		stopCol:  spec.StopColumn,
+	}
+	return t, t.d
+}
+
+func (t *rowWindowTransformation) RetractTable(id execute.DatasetID, key flux.GroupKey) error {
+	return t.d.RetractTable(key)
+}
+
+func (t *rowWindowTransformation) Process(id execute.DatasetID, tbl flux.Table) error {
+	timeIdx := execute.ColIdx(t.timeCol, tbl.Cols())
+	if timeIdx < 0 {
+		return errors.Newf(codes.FailedPrecondition, "no time column: %s", t.timeCol)
+	}
+	if colType := tbl.Cols()[timeIdx].Type; colType != flux.TTime {
+		return errors.Newf(codes.FailedPrecondition, "time column is not a time value: %s", colType)
+	}
+
+	buf, err := execute.CopyTable(tbl)
+	if err != nil {
+		return err
+	}
+	defer buf.Done()
+
+	// Rows with a null time do not belong to any window.
+	var rows []rowWindowRow
+	for i, n := 0, buf.BufferN(); i < n; i++ {
+		ts := buf.Buffer(i).Times(timeIdx)
+		for j, l := 0, ts.Len(); j < l; j++ {
+			if ts.IsValid(j) {
+				rows = append(rows, rowWindowRow{time: values.Time(ts.Value(j)), buffer: i, row: j})
+			}
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].time < rows[j].time
+	})
+
+	windows, err := t.windows(buf, rows)
+	if err != nil {
+		return err
+	}
+	windows = mergeWindows(windows)
+
+	keyCols, keyValues := universe.WindowGroupKeyTemplate(tbl.Key(), t.startCol, t.stopCol)
+	cols := universe.WindowSchema(tbl.Cols(), t.startCol, t.stopCol)
+	for _, window := range windows {
+		key := universe.NewWindowGroupKey(keyCols, keyValues, t.startCol, t.stopCol, execute.Bounds{
+			Start: window.start,
+			Stop:  window.stop,
+		})
+		out, err := t.buildWindow(buf, window, cols, key)
+		if err != nil {
+			return err
+		}
+		if err := t.d.Process(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeWindows merges the windows that have the same bounds into the first
+// of them, so every window has a unique group key. A row that belongs to more
+// than one of the merged windows is only kept once. The rows remain sorted by time.
+func mergeWindows(windows []rowWindow) []rowWindow {
+	type bounds struct {
+		start, stop values.Time
+	}
+	type rowKey struct {
+		buffer, row int
+	}
+	merged := make([]rowWindow, 0, len(windows))
+	index := make(map[bounds]int, len(windows))
+	for _, w := range windows {
+		b := bounds{start: w.start, stop: w.stop}
+		i, ok := index[b]
+		if !ok {
+			index[b] = len(merged)
+			merged = append(merged, w)
+			continue
+		}
+
+		seen := make(map[rowKey]bool, len(merged[i].rows))
+		rows := make([]rowWindowRow, 0, len(merged[i].rows)+len(w.rows))
+		for _, r := range merged[i].rows {
+			seen[rowKey{buffer: r.buffer, row: r.row}] = true
+			rows = append(rows, r)
+		}
+		for _, r := range w.rows {
+			if !seen[rowKey{buffer: r.buffer, row: r.row}] {
+				rows = append(rows, r)
+			}
+		}
+		sort.SliceStable(rows, func(i, j int) bool {
+			return rows[i].time < rows[j].time
+		})
+		merged[i].rows = rows
+	}
+	return merged
+}
+
+// buildWindow builds the table of a single window.
+func (t *rowWindowTransformation) buildWindow(buf flux.BufferedTable, window rowWindow, cols []flux.ColMeta, key flux.GroupKey) (flux.Table, error) {
+	builder := execute.NewColListTableBuilder(key, t.alloc)
+	for _, c := range cols {
+		if _, err := builder.AddCol(c); err != nil {
+			return nil, err
+		}
+	}
+
+	// The columns keep the order of the input columns so the
+	// index of every column other than start and stop is the same.
+	for _, r := range window.rows {
+		cr := buf.Buffer(r.buffer)
+		for j, c := range cols {
+			var err error
+			switch c.Label {
+			case t.startCol:
+				err = builder.AppendTime(j, window.start)
+			case t.stopCol:
+				err = builder.AppendTime(j, window.stop)
+			default:
+				err = builder.AppendValue(j, execute.ValueForRow(cr, r.row, j))
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return builder.Table()
+}
+
+func (t *rowWindowTransformation) UpdateWatermark(id execute.DatasetID, mark execute.Time) error {
+	return t.d.UpdateWatermark(mark)
+}
+
+func (t *rowWindowTransformation) UpdateProcessingTime(id execute.DatasetID, pt execute.Time) error {
+	return t.d.UpdateProcessingTime(pt)
+}
+
+func (t *rowWindowTransformation) Finish(id execute.DatasetID, err error) {
+	t.d.Finish(err)
+}
@@ -0,0 +1,281 @@
+package experimental_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/execute/executetest"
+	"github.com/influxdata/flux/interpreter"
+	"github.com/influxdata/flux/memory"
+	"github.com/influxdata/flux/runtime"
+	"github.com/influxdata/flux/stdlib/experimental"
+)
+
+func TestCountWindow_Process(t *testing.T) {
+	data := func() []flux.Table {
+		return []flux.Table{&executetest.Table{
+			KeyCols: []string{"t0"},
+			ColMeta: []flux.ColMeta{
+				{Label: "t0", Type: flux.TString},
+				{Label: "_time", Type: flux.TTime},
+				{Label: "_value", Type: flux.TFloat},
+			},
+			Data: [][]interface{}{
+				{"a", execute.Time(5), 5.0},
+				{"a", execute.Time(1), 1.0},
+				{"a", execute.Time(2), 2.0},
+				{"a", nil, 0.0},
+				{"a", execute.Time(4), 4.0},
+				{"a", execute.Time(3), 3.0},
+			},
+		}}
+	}
+	window := func(start, stop execute.Time, rows ...[]interface{}) *executetest.Table {
+		tbl := &executetest.Table{
+			KeyCols: []string{"t0", "_start", "_stop"},
+			ColMeta: []flux.ColMeta{
+				{Label: "t0", Type: flux.TString},
+				{Label: "_time", Type: flux.TTime},
+				{Label: "_value", Type: flux.TFloat},
+				{Label: "_start", Type: flux.TTime},
+				{Label: "_stop", Type: flux.TTime},
+			},
+		}
+		for _, row := range rows {
+			tbl.Data = append(tbl.Data, append(row, start, stop))
+		}
+		return tbl
+	}
+
+	testCases := []struct {
+		name string
+		spec *experimental.CountWindowProcedureSpec
+		want []*executetest.Table
+	}{
+		{
+			name: "tumbling",
+			spec: &experimental.CountWindowProcedureSpec{N: 2, Step: 2},
+			want: []*executetest.Table{
+				window(1, 3,
+					[]interface{}{"a", execute.Time(1), 1.0},
+					[]interface{}{"a", execute.Time(2), 2.0},
+				),
+				window(3, 5,
+					[]interface{}{"a", execute.Time(3), 3.0},
+					[]interface{}{"a", execute.Time(4), 4.0},
+				),
+				window(5, 6,
+					[]interface{}{"a", execute.Time(5), 5.0},
+				),
+			},
+		},
+		{
+			name: "sliding",
+			spec: &experimental.CountWindowProcedureSpec{N: 3, Step: 1},
+			want: []*executetest.Table{
+				window(1, 4,
+					[]interface{}{"a", execute.Time(1), 1.0},
+					[]interface{}{"a", execute.Time(2), 2.0},
+					[]interface{}{"a", execute.Time(3), 3.0},
+				),
+				window(2, 5,
+					[]interface{}{"a", execute.Time(2), 2.0},
+					[]interface{}{"a", execute.Time(3), 3.0},
+					[]interface{}{"a", execute.Time(4), 4.0},
+				),
+				window(3, 6,
+					[]interface{}{"a", execute.Time(3), 3.0},
+					[]interface{}{"a", execute.Time(4), 4.0},
+					[]interface{}{"a", execute.Time(5), 5.0},
+				),
+			},
+		},
+		{
+			name: "hopping",
+			spec: &experimental.CountWindowProcedureSpec{N: 1, Step: 2},
+			want: []*executetest.Table{
+				window(1, 2, []interface{}{"a", execute.Time(1), 1.0}),
+				window(3, 4, []interface{}{"a", execute.Time(3), 3.0}),
+				window(5, 6, []interface{}{"a", execute.Time(5), 5.0}),
+			},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			tc.spec.TimeColumn = execute.DefaultTimeColLabel
+			tc.spec.StartColumn = execute.DefaultStartColLabel
+			tc.spec.StopColumn = execute.DefaultStopColLabel
+			executetest.ProcessTestHelper2(
+				t,
+				data(),
+				tc.want,
+				nil,
+				func(id execute.DatasetID, alloc *memory.Allocator) (execute.Transformation, execute.Dataset) {
+					return experimental.NewCountWindowTransformation(id, tc.spec, alloc)
+				},
+			)
+		})
+	}
+}
+
+func TestCountWindow_DuplicateTimes(t *testing.T) {
+	cols := []flux.ColMeta{
+		{Label: "_time", Type: flux.TTime},
+		{Label: "_value", Type: flux.TFloat},
+		{Label: "_start", Type: flux.TTime},
+		{Label: "_stop", Type: flux.TTime},
+	}
+	for _, tc := range []struct {
+		name    string
+		n, step int64
+		data    [][]interface{}
+		want    []*executetest.Table
+	}{
+		{
+			// Every window has the same bounds,
+			// so they are output as a single table.
+			name: "same bounds",
+			n:    2,
+			step: 2,
+			data: [][]interface{}{
+				{execute.Time(1), 1.0},
+				{execute.Time(1), 2.0},
+				{execute.Time(1), 3.0},
+				{execute.Time(1), 4.0},
+				{execute.Time(1), 5.0},
+			},
+			want: []*executetest.Table{{
+				KeyCols: []string{"_start", "_stop"},
+				ColMeta: cols,
+				Data: [][]interface{}{
+					{execute.Time(1), 1.0, execute.Time(1), execute.Time(2)},
+					{execute.Time(1), 2.0, execute.Time(1), execute.Time(2)},
+					{execute.Time(1), 3.0, execute.Time(1), execute.Time(2)},
+					{execute.Time(1), 4.0, execute.Time(1), execute.Time(2)},
+					{execute.Time(1), 5.0, execute.Time(1), execute.Time(2)},
+				},
+			}},
+		},
+		{
+			// The overlapping windows with the same bounds
+			// keep each of their rows once.
+			name: "overlapping",
+			n:    2,
+			step: 1,
+			data: [][]interface{}{
+				{execute.Time(1), 1.0},
+				{execute.Time(2), 2.0},
+				{execute.Time(2), 3.0},
+				{execute.Time(2), 4.0},
+			},
+			want: []*executetest.Table{
+				{
+					KeyCols: []string{"_start", "_stop"},
+					ColMeta: cols,
+					Data: [][]interface{}{
+						{execute.Time(1), 1.0, execute.Time(1), execute.Time(3)},
+						{execute.Time(2), 2.0, execute.Time(1), execute.Time(3)},
+					},
+				},
+				{
+					KeyCols: []string{"_start", "_stop"},
+					ColMeta: cols,
+					Data: [][]interface{}{
+						{execute.Time(2), 2.0, execute.Time(2), execute.Time(3)},
+						{execute.Time(2), 3.0, execute.Time(2), execute.Time(3)},
+						{execute.Time(2), 4.0, execute.Time(2), execute.Time(3)},
+					},
+				},
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			spec := &experimental.CountWindowProcedureSpec{
+				N:           tc.n,
+				Step:        tc.step,
+				TimeColumn:  execute.DefaultTimeColLabel,
+				StartColumn: execute.DefaultStartColLabel,
+				StopColumn:  execute.DefaultStopColLabel,
+			}
+			data := []flux.Table{&executetest.Table{
+				ColMeta: cols[:2],
+				Data:    tc.data,
+			}}
+			executetest.ProcessTestHelper2(
+				t,
+				data,
+				tc.want,
+				nil,
+				func(id execute.DatasetID, alloc *memory.Allocator) (execute.Transformation, execute.Dataset) {
+					return experimental.NewCountWindowTransformation(id, spec, alloc)
+				},
+			)
+		})
+	}
+}
+
+func TestEventWindow_Process(t *testing.T) {
+	spec := &experimental.EventWindowProcedureSpec{
+		Start: interpreter.ResolvedFunction{
+			Fn:    executetest.FunctionExpression(t, `(r) => r.state == "running"`),
+			Scope: runtime.Prelude(),
+		},
+		Stop: interpreter.ResolvedFunction{
+			Fn:    executetest.FunctionExpression(t, `(r) => r.state != "running"`),
+			Scope: runtime.Prelude(),
+		},
+		TimeColumn:  execute.DefaultTimeColLabel,
+		StartColumn: execute.DefaultStartColLabel,
+		StopColumn:  execute.DefaultStopColLabel,
+	}
+	data := []flux.Table{&executetest.Table{
+		ColMeta: []flux.ColMeta{
+			{Label: "_time", Type: flux.TTime},
+			{Label: "state", Type: flux.TString},
+		},
+		Data: [][]interface{}{
+			{execute.Time(1), "stopped"},
+			{execute.Time(2), "running"},
+			{execute.Time(3), "running"},
+			{execute.Time(4), "stopped"},
+			{execute.Time(5), "stopped"},
+			{execute.Time(6), "running"},
+		},
+	}}
+	cols := []flux.ColMeta{
+		{Label: "_time", Type: flux.TTime},
+		{Label: "state", Type: flux.TString},
+		{Label: "_start", Type: flux.TTime},
+		{Label: "_stop", Type: flux.TTime},
+	}
+	want := []*executetest.Table{
+		{
+			KeyCols: []string{"_start", "_stop"},
+			ColMeta: cols,
+			Data: [][]interface{}{
+				{execute.Time(2), "running", execute.Time(2), execute.Time(4)},
+				{execute.Time(3), "running", execute.Time(2), execute.Time(4)},
+			},
+		},
+		{
+			KeyCols: []string{"_start", "_stop"},
+			ColMeta: cols,
+			Data: [][]interface{}{
+				{execute.Time(6), "running", execute.Time(6), execute.Time(7)},
+			},
+		},
+	}
+	executetest.ProcessTestHelper2(
+		t,
+		data,
+		want,
+		nil,
+		func(id execute.DatasetID, alloc *memory.Allocator) (execute.Transformation, execute.Dataset) {
+			return experimental.NewEventWindowTransformation(context.Background(), id, spec, alloc)
+		},
+	)
+}
@@ -0,0 +1,245 @@
+package experimental
+
+import (
+	"sort"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/plan"
+	"github.com/influxdata/flux/runtime"
+	"github.com/influxdata/flux/stdlib/universe"
+	"github.com/influxdata/flux/values"
+)
+
+const SessionWindowKind = "experimental-sessionWindow"
+
+type SessionWindowOpSpec struct {
+	Gap         flux.Duration `json:"gap"`
+	TimeColumn  string        `json:"timeColumn"`
+	StartColumn string        `json:"startColumn"`
+	StopColumn  string        `json:"stopColumn"`
+}
+
+func init() {
+	sessionWindowSignature := runtime.MustLookupBuiltinType("experimental", "sessionWindow")
+	runtime.RegisterPackageValue("experimental", "sessionWindow", flux.MustValue(flux.FunctionValue("sessionWindow", createSessionWindowOpSpec, sessionWindowSignature)))
+	flux.RegisterOpSpec(SessionWindowKind, newSessionWindowOp)
+	plan.RegisterProcedureSpec(SessionWindowKind, newSessionWindowProcedure, SessionWindowKind)
+	execute.RegisterTransformation(SessionWindowKind, createSessionWindowTransformation)
+}
+
+func createSessionWindowOpSpec(args flux.Arguments, a *flux.Administration) (flux.OperationSpec, error) {
+	if err := a.AddParentFromArgs(args); err != nil {
+		return nil, err
+	}
+
+	spec := new(SessionWindowOpSpec)
+
+	gap, err := args.GetRequiredDuration("gap")
+	if err != nil {
+		return nil, err
+	}
+	if !gap.IsPositive() || !gap.NanoOnly() {
+		return nil, errors.New(codes.Invalid, "gap must be a positive duration without months")
+	}
+	spec.Gap = gap
+
+	if label, ok, err := args.GetString("timeColumn"); err != nil {
+		return nil, err
+	} else if ok {
+		spec.TimeColumn = label
+	} else {
+		spec.TimeColumn = execute.DefaultTimeColLabel
+	}
+
+	if label, ok, err := args.GetString("startColumn"); err != nil {
+		return nil, err
+	} else if ok {
+		spec.StartColumn = label
+	} else {
+		spec.StartColumn = execute.DefaultStartColLabel
+	}
+
+	if label, ok, err := args.GetString("stopColumn"); err != nil {
+		return nil, err
+	} else if ok {
+		spec.StopColumn = label
+	} else {
+		spec.StopColumn = execute.DefaultStopColLabel
+	}
+
+	return spec, nil
+}
+
+func newSessionWindowOp() flux.OperationSpec {
+	return new(SessionWindowOpSpec)
+}
+
+func (s *SessionWindowOpSpec) Kind() flux.OperationKind {
+	return SessionWindowKind
+}
+
+type SessionWindowProcedureSpec struct {
+	plan.DefaultCost
+	Gap         flux.Duration
+	TimeColumn  string
+	StartColumn string
+	StopColumn  string
+}
+
+func newSessionWindowProcedure(qs flux.OperationSpec, pa plan.Administration) (plan.ProcedureSpec, error) {
+	spec, ok := qs.(*SessionWindowOpSpec)
+	if !ok {
+		return nil, errors.Newf(codes.Internal, "invalid spec type %T", qs)
+	}
+
+	return &SessionWindowProcedureSpec{
+		Gap:         spec.Gap,
+		TimeColumn:  spec.TimeColumn,
+		StartColumn: spec.StartColumn,
+		StopColumn:  spec.StopColumn,
+	}, nil
+}
+
+func (s *SessionWindowProcedureSpec) Kind() plan.ProcedureKind {
+	return SessionWindowKind
+}
+
+func (s *SessionWindowProcedureSpec) Copy() plan.ProcedureSpec {
+	ns := *s
+	return &ns
+}
+
+func createSessionWindowTransformation(id execute.DatasetID, mode execute.AccumulationMode, spec plan.ProcedureSpec, a execute.Administration) (execute.Transformation, execute.Dataset, error) {
+	s, ok := spec.(*SessionWindowProcedureSpec)
+	if !ok {
+		return nil, nil, errors.Newf(codes.Internal, "invalid spec type %T", spec)
+	}
+	cache := execute.NewTableBuilderCache(a.Allocator())
+	d := execute.NewDataset(id, mode, cache)
+	t := NewSessionWindowTransformation(d, cache, s)
+	return t, d, nil
+}
+
+type sessionWindowTransformation struct {
+	execute.ExecutionNode
+	d     execute.Dataset
+	cache execute.TableBuilderCache
+
+	gap         values.Time
+	timeColumn  string
+	startColumn string
+	stopColumn  string
+}
+
+// NewSessionWindowTransformation creates a transformation that splits
+// each table into sessions of rows whose consecutive times are at most gap apart.
+func NewSessionWindowTransformation(d execute.Dataset, cache execute.TableBuilderCache, spec *SessionWindowProcedureSpec) *sessionWindowTransformation {
+	return &sessionWindowTransformation{
+		d:           d,
+		cache:       cache,
+		gap:         values.Time(spec.Gap.Duration()),
+		timeColumn:  spec.TimeColumn,
+		startColumn: spec.StartColumn,
+		stopColumn:  spec.StopColumn,
+	}
+}
+
+func (t *sessionWindowTransformation) RetractTable(id execute.DatasetID, key flux.GroupKey) error {
+	return t.d.RetractTable(key)
+}
+
+func (t *sessionWindowTransformation) Process(id execute.DatasetID, tbl flux.Table) error {
+	timeIdx := execute.ColIdx(t.timeColumn, tbl.Cols())
+	if timeIdx < 0 {
+		return errors.Newf(codes.FailedPrecondition, "missing time column %q", t.timeColumn)
+	}
+	if typ := tbl.Cols()[timeIdx].Type; typ != flux.TTime {
+		return errors.Newf(codes.FailedPrecondition, "time column %q must be of type time, got %v", t.timeColumn, typ)
+	}
+
+	buf, err := execute.CopyTable(tbl)
+	if err != nil {
+		return err
+	}
+	defer buf.Done()
+
+	// Rows without a time cannot belong to a session and are dropped.
+	var rows []rowWindowRow
+	for i, n := 0, buf.BufferN(); i < n; i++ {
+		ts := buf.Buffer(i).Times(timeIdx)
+		for j, l := 0, ts.Len(); j < l; j++ {
+			if ts.IsValid(j) {
+				rows = append(rows, rowWindowRow{time: values.Time(ts.Value(j)), buffer: i, row: j})
+			}
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].time < rows[j].time
+	})
+
+	// Sessions have the schema and group key of the windows of window().
+	cols := universe.WindowSchema(tbl.Cols(), t.startColumn, t.stopColumn)
+	keyCols, keyValues := universe.WindowGroupKeyTemplate(tbl.Key(), t.startColumn, t.stopColumn)
+	for start := 0; start < len(rows); {
+		end := start + 1
+		for end < len(rows) && rows[end].time-rows[end-1].time <= t.gap {
+			end++
+		}
+		bounds := execute.Bounds{Start: rows[start].time, Stop: rows[end-1].time + t.gap}
+		key := universe.NewWindowGroupKey(keyCols, keyValues, t.startColumn, t.stopColumn, bounds)
+		if err := t.appendSession(key, buf, rows[start:end], bounds, cols); err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
+
+func (t *sessionWindowTransformation) appendSession(key flux.GroupKey, buf flux.BufferedTable, rows []rowWindowRow, bounds execute.Bounds, cols []flux.ColMeta) error {
+	// Tables that only differed by their start and stop columns
+	// may produce the same session and are merged.
+	builder, created := t.cache.TableBuilder(key)
+	if created {
+		for _, c := range cols {
+			if _, err := builder.AddCol(c); err != nil {
+				return err
+			}
+		}
+	}
+
+	// The columns keep the order of the input columns so the
+	// index of every column other than start and stop is the same.
+	for _, r := range rows {
+		cr := buf.Buffer(r.buffer)
+		for j, c := range cols {
+			var err error
+			switch c.Label {
+			case t.startColumn:
+				err = builder.AppendTime(j, bounds.Start)
+			case t.stopColumn:
+				err = builder.AppendTime(j, bounds.Stop)
+			default:
+				err = builder.AppendValue(j, execute.ValueForRow(cr, r.row, j))
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (t *sessionWindowTransformation) UpdateWatermark(id execute.DatasetID, mark execute.Time) error {
+	return t.d.UpdateWatermark(mark)
+}
+
+func (t *sessionWindowTransformation) UpdateProcessingTime(id execute.DatasetID, pt execute.Time) error {
+	return t.d.UpdateProcessingTime(pt)
+}
+
+func (t *sessionWindowTransformation) Finish(id execute.DatasetID, err error) {
+	t.d.Finish(err)
+}
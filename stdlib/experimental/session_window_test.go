@@ -0,0 +1,163 @@
+package experimental_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/execute/executetest"
+	"github.com/influxdata/flux/stdlib/experimental"
+)
+
+func TestSessionWindow_Process(t *testing.T) {
+	spec := &experimental.SessionWindowProcedureSpec{
+		Gap:         flux.ConvertDuration(10 * time.Second),
+		TimeColumn:  "_time",
+		StartColumn: "_start",
+		StopColumn:  "_stop",
+	}
+	sec := func(n int64) execute.Time {
+		return execute.Time(n * int64(time.Second))
+	}
+	testCases := []struct {
+		name string
+		data []flux.Table
+		want []*executetest.Table
+	}{
+		{
+			name: "sessions",
+			data: []flux.Table{&executetest.Table{
+				KeyCols: []string{"id"},
+				ColMeta: []flux.ColMeta{
+					{Label: "id", Type: flux.TString},
+					{Label: "_time", Type: flux.TTime},
+					{Label: "_value", Type: flux.TFloat},
+				},
+				Data: [][]interface{}{
+					{"a", sec(1), 1.0},
+					{"a", sec(5), 2.0},
+					{"a", sec(15), 3.0},
+					{"a", sec(26), 4.0},
+					{"a", sec(30), 5.0},
+				},
+			}},
+			want: []*executetest.Table{
+				{
+					KeyCols: []string{"id", "_start", "_stop"},
+					ColMeta: []flux.ColMeta{
+						{Label: "id", Type: flux.TString},
+						{Label: "_time", Type: flux.TTime},
+						{Label: "_value", Type: flux.TFloat},
+						{Label: "_start", Type: flux.TTime},
+						{Label: "_stop", Type: flux.TTime},
+					},
+					Data: [][]interface{}{
+						{"a", sec(1), 1.0, sec(1), sec(25)},
+						{"a", sec(5), 2.0, sec(1), sec(25)},
+						{"a", sec(15), 3.0, sec(1), sec(25)},
+					},
+				},
+				{
+					KeyCols: []string{"id", "_start", "_stop"},
+					ColMeta: []flux.ColMeta{
+						{Label: "id", Type: flux.TString},
+						{Label: "_time", Type: flux.TTime},
+						{Label: "_value", Type: flux.TFloat},
+						{Label: "_start", Type: flux.TTime},
+						{Label: "_stop", Type: flux.TTime},
+					},
+					Data: [][]interface{}{
+						{"a", sec(26), 4.0, sec(26), sec(40)},
+						{"a", sec(30), 5.0, sec(26), sec(40)},
+					},
+				},
+			},
+		},
+		{
+			name: "unsorted with nulls",
+			data: []flux.Table{&executetest.Table{
+				ColMeta: []flux.ColMeta{
+					{Label: "_time", Type: flux.TTime},
+					{Label: "_value", Type: flux.TInt},
+				},
+				Data: [][]interface{}{
+					{sec(40), int64(3)},
+					{nil, int64(0)},
+					{sec(1), int64(1)},
+					{sec(2), int64(2)},
+				},
+			}},
+			want: []*executetest.Table{
+				{
+					KeyCols: []string{"_start", "_stop"},
+					ColMeta: []flux.ColMeta{
+						{Label: "_time", Type: flux.TTime},
+						{Label: "_value", Type: flux.TInt},
+						{Label: "_start", Type: flux.TTime},
+						{Label: "_stop", Type: flux.TTime},
+					},
+					Data: [][]interface{}{
+						{sec(1), int64(1), sec(1), sec(12)},
+						{sec(2), int64(2), sec(1), sec(12)},
+					},
+				},
+				{
+					KeyCols: []string{"_start", "_stop"},
+					ColMeta: []flux.ColMeta{
+						{Label: "_time", Type: flux.TTime},
+						{Label: "_value", Type: flux.TInt},
+						{Label: "_start", Type: flux.TTime},
+						{Label: "_stop", Type: flux.TTime},
+					},
+					Data: [][]interface{}{
+						{sec(40), int64(3), sec(40), sec(50)},
+					},
+				},
+			},
+		},
+		{
+			name: "replaces existing bounds",
+			data: []flux.Table{&executetest.Table{
+				KeyCols: []string{"_start", "_stop"},
+				ColMeta: []flux.ColMeta{
+					{Label: "_start", Type: flux.TTime},
+					{Label: "_stop", Type: flux.TTime},
+					{Label: "_time", Type: flux.TTime},
+					{Label: "_value", Type: flux.TInt},
+				},
+				Data: [][]interface{}{
+					{sec(0), sec(100), sec(3), int64(1)},
+					{sec(0), sec(100), sec(8), int64(2)},
+				},
+			}},
+			want: []*executetest.Table{{
+				KeyCols: []string{"_start", "_stop"},
+				ColMeta: []flux.ColMeta{
+					{Label: "_start", Type: flux.TTime},
+					{Label: "_stop", Type: flux.TTime},
+					{Label: "_time", Type: flux.TTime},
+					{Label: "_value", Type: flux.TInt},
+				},
+				Data: [][]interface{}{
+					{sec(3), sec(18), sec(3), int64(1)},
+					{sec(3), sec(18), sec(8), int64(2)},
+				},
+			}},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			executetest.ProcessTestHelper(
+				t,
+				tc.data,
+				tc.want,
+				nil,
+				func(d execute.Dataset, c execute.TableBuilderCache) execute.Transformation {
+					return experimental.NewSessionWindowTransformation(d, c, spec)
+				},
+			)
+		})
+	}
+}
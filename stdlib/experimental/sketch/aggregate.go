@@ -0,0 +1,250 @@
+package sketch
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/array"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/execute/table"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/plan"
+	"github.com/influxdata/flux/values"
+)
+
+const pkgpath = "experimental/sketch"
+
+// aggregator summarizes the values of a column.
+type aggregator interface {
+	// add adds the non-null values of the array to the aggregate.
+	add(arr array.Interface) error
+	// value returns the aggregate of all values that have been added.
+	value() values.Value
+}
+
+// sketch summarizes values and can be merged with sketches of the same kind.
+type sketch interface {
+	// merge adds the values summarized by another sketch of the same kind to the sketch.
+	merge(o sketch) error
+	// encode encodes the sketch as a base64 string.
+	encode() string
+}
+
+// aggregateSpec is implemented by the procedure specs of the sketch aggregates.
+type aggregateSpec interface {
+	plan.ProcedureSpec
+	// aggregateColumn returns the label of the column that is aggregated.
+	aggregateColumn() string
+	// newAggregator returns an aggregator for a column of the given type
+	// and the type of its aggregate.
+	newAggregator(typ flux.ColType) (aggregator, flux.ColType, error)
+}
+
+// getColumn reads the column argument of the sketch functions.
+func getColumn(args flux.Arguments) (string, error) {
+	if label, ok, err := args.GetString("column"); err != nil {
+		return "", err
+	} else if ok {
+		return label, nil
+	}
+	return execute.DefaultValueColLabel, nil
+}
+
+func createAggregateTransformation(id execute.DatasetID, mode execute.AccumulationMode, spec plan.ProcedureSpec, a execute.Administration) (execute.Transformation, execute.Dataset, error) {
+	s, ok := spec.(aggregateSpec)
+	if !ok {
+		return nil, nil, errors.Newf(codes.Internal, "invalid spec type %T", spec)
+	}
+	cache := execute.NewTableBuilderCache(a.Allocator())
+	d := execute.NewDataset(id, mode, cache)
+	t := newAggregateTransformation(d, cache, s)
+	return t, d, nil
+}
+
+type aggregateTransformation struct {
+	execute.ExecutionNode
+	d     execute.Dataset
+	cache execute.TableBuilderCache
+	spec  aggregateSpec
+}
+
+// newAggregateTransformation creates a transformation that aggregates each table
+// into a single row with the group key columns and the aggregated column.
+func newAggregateTransformation(d execute.Dataset, cache execute.TableBuilderCache, spec aggregateSpec) *aggregateTransformation {
+	return &aggregateTransformation{
+		d:     d,
+		cache: cache,
+		spec:  spec,
+	}
+}
+
+func (t *aggregateTransformation) RetractTable(id execute.DatasetID, key flux.GroupKey) error {
+	return t.d.RetractTable(key)
+}
+
+func (t *aggregateTransformation) Process(id execute.DatasetID, tbl flux.Table) error {
+	label := t.spec.aggregateColumn()
+	idx := execute.ColIdx(label, tbl.Cols())
+	if idx < 0 {
+		return errors.Newf(codes.FailedPrecondition, "column %q does not exist", label)
+	}
+	if tbl.Key().HasCol(label) {
+		return errors.New(codes.FailedPrecondition, "cannot aggregate columns that are part of the group key")
+	}
+	agg, typ, err := t.spec.newAggregator(tbl.Cols()[idx].Type)
+	if err != nil {
+		return err
+	}
+
+	if err := tbl.Do(func(cr flux.ColReader) error {
+		return agg.add(table.Values(cr, idx))
+	}); err != nil {
+		return err
+	}
+
+	builder, created := t.cache.TableBuilder(tbl.Key())
+	if !created {
+		return errors.Newf(codes.FailedPrecondition, "aggregate found duplicate table with key: %v", tbl.Key())
+	}
+	if err := execute.AddTableKeyCols(tbl.Key(), builder); err != nil {
+		return err
+	}
+	j, err := builder.AddCol(flux.ColMeta{Label: label, Type: typ})
+	if err != nil {
+		return err
+	}
+	if err := execute.AppendKeyValues(tbl.Key(), builder); err != nil {
+		return err
+	}
+	return builder.AppendValue(j, agg.value())
+}
+
+func (t *aggregateTransformation) UpdateWatermark(id execute.DatasetID, mark execute.Time) error {
+	return t.d.UpdateWatermark(mark)
+}
+
+func (t *aggregateTransformation) UpdateProcessingTime(id execute.DatasetID, pt execute.Time) error {
+	return t.d.UpdateProcessingTime(pt)
+}
+
+func (t *aggregateTransformation) Finish(id execute.DatasetID, err error) {
+	t.d.Finish(err)
+}
+
+// mergeAggregator merges encoded sketches of the same kind.
+// The aggregate is null if there are no sketches.
+type mergeAggregator struct {
+	decode func(s string) (sketch, error)
+	s      sketch
+}
+
+func (a *mergeAggregator) add(arr array.Interface) error {
+	sketches := arr.(*array.String)
+	for i, l := 0, sketches.Len(); i < l; i++ {
+		if sketches.IsNull(i) {
+			continue
+		}
+		s, err := a.decode(sketches.Value(i))
+		if err != nil {
+			return err
+		}
+		if a.s == nil {
+			a.s = s
+			continue
+		}
+		if err := a.s.merge(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *mergeAggregator) value() values.Value {
+	if a.s == nil {
+		return values.NewNull(flux.SemanticType(flux.TString))
+	}
+	return values.NewString(a.s.encode())
+}
+
+// hashValues calls fn with the 64 bit hash of every non-null value of the array.
+// Equal values of the same type have the same hash.
+func hashValues(arr array.Interface, fn func(x uint64)) error {
+	var buf [8]byte
+	switch a := arr.(type) {
+	case *array.Int:
+		for i, l := 0, a.Len(); i < l; i++ {
+			if a.IsValid(i) {
+				binary.LittleEndian.PutUint64(buf[:], uint64(a.Value(i)))
+				fn(xxhash.Sum64(buf[:]))
+			}
+		}
+	case *array.Uint:
+		for i, l := 0, a.Len(); i < l; i++ {
+			if a.IsValid(i) {
+				binary.LittleEndian.PutUint64(buf[:], a.Value(i))
+				fn(xxhash.Sum64(buf[:]))
+			}
+		}
+	case *array.Float:
+		for i, l := 0, a.Len(); i < l; i++ {
+			if a.IsValid(i) {
+				v := a.Value(i)
+				if v == 0 {
+					// Negative zero is equal to zero.
+					v = 0
+				}
+				binary.LittleEndian.PutUint64(buf[:], math.Float64bits(v))
+				fn(xxhash.Sum64(buf[:]))
+			}
+		}
+	case *array.Boolean:
+		for i, l := 0, a.Len(); i < l; i++ {
+			if a.IsValid(i) {
+				buf[0] = 0
+				if a.Value(i) {
+					buf[0] = 1
+				}
+				fn(xxhash.Sum64(buf[:1]))
+			}
+		}
+	case *array.String:
+		for i, l := 0, a.Len(); i < l; i++ {
+			if a.IsValid(i) {
+				fn(xxhash.Sum64String(a.Value(i)))
+			}
+		}
+	default:
+		return errors.Newf(codes.FailedPrecondition, "unsupported column type %v", arr.DataType())
+	}
+	return nil
+}
+
+// floatValues calls fn with every non-null value of a numeric array converted to a float.
+func floatValues(arr array.Interface, fn func(x float64)) error {
+	switch a := arr.(type) {
+	case *array.Int:
+		for i, l := 0, a.Len(); i < l; i++ {
+			if a.IsValid(i) {
+				fn(float64(a.Value(i)))
+			}
+		}
+	case *array.Uint:
+		for i, l := 0, a.Len(); i < l; i++ {
+			if a.IsValid(i) {
+				fn(float64(a.Value(i)))
+			}
+		}
+	case *array.Float:
+		for i, l := 0, a.Len(); i < l; i++ {
+			if a.IsValid(i) {
+				fn(a.Value(i))
+			}
+		}
+	default:
+		return errors.Newf(codes.FailedPrecondition, "unsupported column type %v", arr.DataType())
+	}
+	return nil
+}
@@ -0,0 +1,342 @@
+package sketch
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"math"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+const (
+	ddDefaultRelativeAccuracy = 0.01
+	ddDefaultMaxBuckets       = 2048
+	ddMaxMaxBuckets           = 1 << 16
+
+	// ddVersion is the version of the encoding of a sketch.
+	ddVersion = 1
+)
+
+// ddMagic is the prefix of every encoded DDSketch.
+var ddMagic = [2]byte{'D', 'D'}
+
+// ddSketch estimates quantiles with a DDSketch.
+//
+// Values are counted in buckets whose bounds grow exponentially by gamma,
+// so every quantile is estimated within the relative accuracy of the sketch.
+// Positive and negative values are counted in separate stores by their absolute value.
+type ddSketch struct {
+	relativeAccuracy float64
+	maxBuckets       int
+	logGamma         float64
+
+	positive ddStore
+	negative ddStore
+	zeros    uint64
+}
+
+func newDDSketch(relativeAccuracy float64, maxBuckets int) (*ddSketch, error) {
+	if !(relativeAccuracy > 0 && relativeAccuracy < 1) {
+		return nil, errors.Newf(codes.Invalid, "relative accuracy must be between 0 and 1, got %v", relativeAccuracy)
+	}
+	if maxBuckets < 1 || maxBuckets > ddMaxMaxBuckets {
+		return nil, errors.Newf(codes.Invalid, "max buckets must be between 1 and %d, got %d", ddMaxMaxBuckets, maxBuckets)
+	}
+	gamma := (1 + relativeAccuracy) / (1 - relativeAccuracy)
+	return &ddSketch{
+		relativeAccuracy: relativeAccuracy,
+		maxBuckets:       maxBuckets,
+		logGamma:         math.Log(gamma),
+	}, nil
+}
+
+// add adds a value to the sketch. NaN and infinite values are ignored.
+func (s *ddSketch) add(x float64) {
+	switch {
+	case math.IsNaN(x) || math.IsInf(x, 0):
+	case x > 0:
+		s.positive.add(s.index(x), 1, s.maxBuckets)
+	case x < 0:
+		s.negative.add(s.index(-x), 1, s.maxBuckets)
+	default:
+		s.zeros++
+	}
+}
+
+// index returns the index of the bucket of a positive value.
+func (s *ddSketch) index(x float64) int {
+	return int(math.Ceil(math.Log(x) / s.logGamma))
+}
+
+// indexRange returns the indices of the buckets of the smallest
+// and the largest positive values that can be added to the sketch.
+// They are floats since a sketch with a small relative accuracy
+// may have indices that do not fit in an int.
+func (s *ddSketch) indexRange() (float64, float64) {
+	return math.Ceil(math.Log(math.SmallestNonzeroFloat64) / s.logGamma),
+		math.Ceil(math.Log(math.MaxFloat64) / s.logGamma)
+}
+
+// value returns the value that represents the bucket with the given index.
+func (s *ddSketch) value(idx int) float64 {
+	return 2 * math.Exp(float64(idx)*s.logGamma) / (1 + math.Exp(s.logGamma))
+}
+
+func (s *ddSketch) count() uint64 {
+	return s.negative.count + s.zeros + s.positive.count
+}
+
+// merge adds the values counted by another DDSketch to the sketch.
+func (s *ddSketch) merge(o sketch) error {
+	d := o.(*ddSketch)
+	if s.relativeAccuracy != d.relativeAccuracy {
+		return errors.Newf(codes.FailedPrecondition, "cannot merge DDSketches with relative accuracy %v and %v", s.relativeAccuracy, d.relativeAccuracy)
+	}
+	for i, c := range d.positive.bins {
+		s.positive.add(d.positive.offset+i, c, s.maxBuckets)
+	}
+	for i, c := range d.negative.bins {
+		s.negative.add(d.negative.offset+i, c, s.maxBuckets)
+	}
+	s.zeros += d.zeros
+	return nil
+}
+
+// quantile returns the estimated value at the quantile q.
+// It returns false if the sketch is empty.
+func (s *ddSketch) quantile(q float64) (float64, bool) {
+	n := s.count()
+	if n == 0 {
+		return 0, false
+	}
+	rank := q * float64(n-1)
+
+	var seen uint64
+	for i := len(s.negative.bins) - 1; i >= 0; i-- {
+		seen += s.negative.bins[i]
+		if float64(seen) > rank {
+			return -s.value(s.negative.offset + i), true
+		}
+	}
+	seen += s.zeros
+	if float64(seen) > rank {
+		return 0, true
+	}
+	for i, c := range s.positive.bins {
+		seen += c
+		if float64(seen) > rank {
+			return s.value(s.positive.offset + i), true
+		}
+	}
+	return s.value(s.positive.offset + len(s.positive.bins) - 1), true
+}
+
+// encode encodes the sketch as a base64 string.
+//
+// The encoding starts with the magic bytes and the version followed by
+// the relative accuracy, the maximum number of buckets, the number of zeros
+// and the positive and negative stores.
+func (s *ddSketch) encode() string {
+	buf := []byte{ddMagic[0], ddMagic[1], ddVersion}
+	var tmp [binary.MaxVarintLen64]byte
+	binary.LittleEndian.PutUint64(tmp[:], math.Float64bits(s.relativeAccuracy))
+	buf = append(buf, tmp[:8]...)
+	buf = append(buf, tmp[:binary.PutUvarint(tmp[:], uint64(s.maxBuckets))]...)
+	buf = append(buf, tmp[:binary.PutUvarint(tmp[:], s.zeros)]...)
+	buf = s.positive.encode(buf)
+	buf = s.negative.encode(buf)
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+// decodeDDSketch decodes a sketch encoded with encode.
+func decodeDDSketch(str string) (*ddSketch, error) {
+	buf, err := base64.StdEncoding.DecodeString(str)
+	if err != nil {
+		return nil, errors.Wrap(err, codes.Invalid, "invalid DDSketch")
+	}
+	if len(buf) < 11 || buf[0] != ddMagic[0] || buf[1] != ddMagic[1] {
+		return nil, errors.New(codes.Invalid, "invalid DDSketch")
+	}
+	if buf[2] != ddVersion {
+		return nil, errors.Newf(codes.Invalid, "unsupported DDSketch version %d", buf[2])
+	}
+	r := &byteReader{buf: buf[3:]}
+	relativeAccuracy := math.Float64frombits(r.uint64())
+	maxBuckets := r.uvarint()
+	zeros := r.uvarint()
+	if r.err != nil {
+		return nil, errors.Wrap(r.err, codes.Invalid, "invalid DDSketch")
+	}
+	if maxBuckets > ddMaxMaxBuckets {
+		return nil, errors.Newf(codes.Invalid, "invalid DDSketch: max buckets %d exceeds %d", maxBuckets, ddMaxMaxBuckets)
+	}
+	s, err := newDDSketch(relativeAccuracy, int(maxBuckets))
+	if err != nil {
+		return nil, errors.Wrap(err, codes.Invalid, "invalid DDSketch")
+	}
+	s.zeros = zeros
+	if err := s.positive.decode(r, s); err != nil {
+		return nil, errors.Wrap(err, codes.Invalid, "invalid DDSketch")
+	}
+	if err := s.negative.decode(r, s); err != nil {
+		return nil, errors.Wrap(err, codes.Invalid, "invalid DDSketch")
+	}
+	if len(r.buf) > 0 {
+		return nil, errors.New(codes.Invalid, "invalid DDSketch: unexpected trailing bytes")
+	}
+	return s, nil
+}
+
+// ddStore counts values in a contiguous range of buckets.
+// When the range exceeds the maximum number of buckets,
+// the lowest buckets are collapsed into the lowest remaining bucket,
+// so the values closest to zero lose their accuracy first.
+type ddStore struct {
+	// offset is the index of the first bucket.
+	offset int
+	bins   []uint64
+	count  uint64
+}
+
+func (s *ddStore) add(idx int, n uint64, maxBuckets int) {
+	s.count += n
+	if len(s.bins) == 0 {
+		s.offset, s.bins = idx, []uint64{n}
+		return
+	}
+
+	hi := s.offset + len(s.bins) - 1
+	if idx > hi {
+		// The buckets below the new range are collapsed
+		// before growing so at most maxBuckets are allocated.
+		if lo := idx - maxBuckets + 1; lo > s.offset {
+			s.collapse(lo)
+		}
+		hi = s.offset + len(s.bins) - 1
+		s.bins = append(s.bins, make([]uint64, idx-hi)...)
+		hi = idx
+	}
+	if idx < s.offset {
+		lo := idx
+		if hi-lo+1 > maxBuckets {
+			lo = hi - maxBuckets + 1
+		}
+		if lo < s.offset {
+			s.bins = append(make([]uint64, s.offset-lo), s.bins...)
+			s.offset = lo
+		}
+		if idx < s.offset {
+			idx = s.offset
+		}
+	}
+	s.bins[idx-s.offset] += n
+
+	if k := len(s.bins) - maxBuckets; k > 0 {
+		s.collapse(s.offset + k)
+	}
+}
+
+// collapse adds the counts of the buckets below lo to the bucket lo,
+// which becomes the first bucket of the store.
+func (s *ddStore) collapse(lo int) {
+	k := lo - s.offset
+	if k >= len(s.bins) {
+		var sum uint64
+		for _, c := range s.bins {
+			sum += c
+		}
+		s.offset, s.bins = lo, append(s.bins[:0], sum)
+		return
+	}
+	var sum uint64
+	for _, c := range s.bins[:k+1] {
+		sum += c
+	}
+	s.bins = s.bins[k:]
+	s.bins[0] = sum
+	s.offset = lo
+}
+
+func (s *ddStore) encode(buf []byte) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	buf = append(buf, tmp[:binary.PutVarint(tmp[:], int64(s.offset))]...)
+	buf = append(buf, tmp[:binary.PutUvarint(tmp[:], uint64(len(s.bins)))]...)
+	for _, c := range s.bins {
+		buf = append(buf, tmp[:binary.PutUvarint(tmp[:], c)]...)
+	}
+	return buf
+}
+
+// decode decodes a store encoded with encode.
+// The buckets must be within the range of indices
+// of the finite values of the sketch.
+func (s *ddStore) decode(r *byteReader, sketch *ddSketch) error {
+	offset := r.varint()
+	n := r.uvarint()
+	if r.err != nil {
+		return r.err
+	}
+	if n > uint64(sketch.maxBuckets) {
+		return errors.Newf(codes.Invalid, "%d buckets exceed the maximum of %d", n, sketch.maxBuckets)
+	}
+	if n > 0 {
+		minIdx, maxIdx := sketch.indexRange()
+		if float64(offset) < minIdx || float64(offset)+float64(n-1) > maxIdx {
+			return errors.Newf(codes.Invalid, "buckets %d to %d are out of range", offset, offset+int64(n-1))
+		}
+	}
+	s.offset = int(offset)
+	s.bins = make([]uint64, n)
+	for i := range s.bins {
+		s.bins[i] = r.uvarint()
+		s.count += s.bins[i]
+	}
+	return r.err
+}
+
+// byteReader reads the values of an encoded sketch.
+// The first error is kept and all later reads return zero.
+type byteReader struct {
+	buf []byte
+	err error
+}
+
+func (r *byteReader) uint64() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.buf) < 8 {
+		r.err = errors.New(codes.Invalid, "unexpected end of sketch")
+		return 0
+	}
+	v := binary.LittleEndian.Uint64(r.buf)
+	r.buf = r.buf[8:]
+	return v
+}
+
+func (r *byteReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.buf)
+	if n <= 0 {
+		r.err = errors.New(codes.Invalid, "unexpected end of sketch")
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return v
+}
+
+func (r *byteReader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.buf)
+	if n <= 0 {
+		r.err = errors.New(codes.Invalid, "unexpected end of sketch")
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return v
+}
@@ -0,0 +1,299 @@
+package sketch
+
+import (
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/array"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/internal/function"
+	"github.com/influxdata/flux/interpreter"
+	"github.com/influxdata/flux/plan"
+	"github.com/influxdata/flux/runtime"
+	"github.com/influxdata/flux/values"
+)
+
+const (
+	HLLKind            = pkgpath + ".hll"
+	MergeHLLKind       = pkgpath + ".mergeHLL"
+	ApproxDistinctKind = pkgpath + ".approxDistinct"
+)
+
+type HLLOpSpec struct {
+	Column    string `json:"column"`
+	Precision int64  `json:"precision"`
+}
+
+type MergeHLLOpSpec struct {
+	Column string `json:"column"`
+}
+
+type ApproxDistinctOpSpec struct {
+	Column    string `json:"column"`
+	Precision int64  `json:"precision"`
+}
+
+func init() {
+	hllSignature := runtime.MustLookupBuiltinType(pkgpath, "hll")
+	mergeHLLSignature := runtime.MustLookupBuiltinType(pkgpath, "mergeHLL")
+	approxDistinctSignature := runtime.MustLookupBuiltinType(pkgpath, "approxDistinct")
+
+	runtime.RegisterPackageValue(pkgpath, "hll", flux.MustValue(flux.FunctionValue(HLLKind, createHLLOpSpec, hllSignature)))
+	runtime.RegisterPackageValue(pkgpath, "mergeHLL", flux.MustValue(flux.FunctionValue(MergeHLLKind, createMergeHLLOpSpec, mergeHLLSignature)))
+	runtime.RegisterPackageValue(pkgpath, "approxDistinct", flux.MustValue(flux.FunctionValue(ApproxDistinctKind, createApproxDistinctOpSpec, approxDistinctSignature)))
+	flux.RegisterOpSpec(HLLKind, newHLLOp)
+	flux.RegisterOpSpec(MergeHLLKind, newMergeHLLOp)
+	flux.RegisterOpSpec(ApproxDistinctKind, newApproxDistinctOp)
+	plan.RegisterProcedureSpec(HLLKind, newHLLProcedure, HLLKind)
+	plan.RegisterProcedureSpec(MergeHLLKind, newMergeHLLProcedure, MergeHLLKind)
+	plan.RegisterProcedureSpec(ApproxDistinctKind, newApproxDistinctProcedure, ApproxDistinctKind)
+	execute.RegisterTransformation(HLLKind, createAggregateTransformation)
+	execute.RegisterTransformation(MergeHLLKind, createAggregateTransformation)
+	execute.RegisterTransformation(ApproxDistinctKind, createAggregateTransformation)
+
+	function.ForPackage(pkgpath).Register("estimateHLL", estimateHLL)
+}
+
+// getColumnAndPrecision reads the column and precision arguments
+// of the functions that build a HyperLogLog sketch.
+func getColumnAndPrecision(args flux.Arguments) (string, int64, error) {
+	column, err := getColumn(args)
+	if err != nil {
+		return "", 0, err
+	}
+
+	precision := int64(hllDefaultPrecision)
+	if p, ok, err := args.GetInt("precision"); err != nil {
+		return "", 0, err
+	} else if ok {
+		if p < hllMinPrecision || p > hllMaxPrecision {
+			return "", 0, errors.Newf(codes.Invalid, "precision must be between %d and %d, got %d", hllMinPrecision, hllMaxPrecision, p)
+		}
+		precision = p
+	}
+	return column, precision, nil
+}
+
+func createHLLOpSpec(args flux.Arguments, a *flux.Administration) (flux.OperationSpec, error) {
+	if err := a.AddParentFromArgs(args); err != nil {
+		return nil, err
+	}
+	column, precision, err := getColumnAndPrecision(args)
+	if err != nil {
+		return nil, err
+	}
+	return &HLLOpSpec{Column: column, Precision: precision}, nil
+}
+
+func createMergeHLLOpSpec(args flux.Arguments, a *flux.Administration) (flux.OperationSpec, error) {
+	if err := a.AddParentFromArgs(args); err != nil {
+		return nil, err
+	}
+	column, err := getColumn(args)
+	if err != nil {
+		return nil, err
+	}
+	return &MergeHLLOpSpec{Column: column}, nil
+}
+
+func createApproxDistinctOpSpec(args flux.Arguments, a *flux.Administration) (flux.OperationSpec, error) {
+	if err := a.AddParentFromArgs(args); err != nil {
+		return nil, err
+	}
+	column, precision, err := getColumnAndPrecision(args)
+	if err != nil {
+		return nil, err
+	}
+	return &ApproxDistinctOpSpec{Column: column, Precision: precision}, nil
+}
+
+func newHLLOp() flux.OperationSpec {
+	return new(HLLOpSpec)
+}
+
+func newMergeHLLOp() flux.OperationSpec {
+	return new(MergeHLLOpSpec)
+}
+
+func newApproxDistinctOp() flux.OperationSpec {
+	return new(ApproxDistinctOpSpec)
+}
+
+func (s *HLLOpSpec) Kind() flux.OperationKind {
+	return HLLKind
+}
+
+func (s *MergeHLLOpSpec) Kind() flux.OperationKind {
+	return MergeHLLKind
+}
+
+func (s *ApproxDistinctOpSpec) Kind() flux.OperationKind {
+	return ApproxDistinctKind
+}
+
+type HLLProcedureSpec struct {
+	plan.DefaultCost
+	Column    string
+	Precision int64
+}
+
+type MergeHLLProcedureSpec struct {
+	plan.DefaultCost
+	Column string
+}
+
+type ApproxDistinctProcedureSpec struct {
+	plan.DefaultCost
+	Column    string
+	Precision int64
+}
+
+func newHLLProcedure(qs flux.OperationSpec, pa plan.Administration) (plan.ProcedureSpec, error) {
+	spec, ok := qs.(*HLLOpSpec)
+	if !ok {
+		return nil, errors.Newf(codes.Internal, "invalid spec type %T", qs)
+	}
+	return &HLLProcedureSpec{
+		Column:    spec.Column,
+		Precision: spec.Precision,
+	}, nil
+}
+
+func newMergeHLLProcedure(qs flux.OperationSpec, pa plan.Administration) (plan.ProcedureSpec, error) {
+	spec, ok := qs.(*MergeHLLOpSpec)
+	if !ok {
+		return nil, errors.Newf(codes.Internal, "invalid spec type %T", qs)
+	}
+	return &MergeHLLProcedureSpec{
+		Column: spec.Column,
+	}, nil
+}
+
+func newApproxDistinctProcedure(qs flux.OperationSpec, pa plan.Administration) (plan.ProcedureSpec, error) {
+	spec, ok := qs.(*ApproxDistinctOpSpec)
+	if !ok {
+		return nil, errors.Newf(codes.Internal, "invalid spec type %T", qs)
+	}
+	return &ApproxDistinctProcedureSpec{
+		Column:    spec.Column,
+		Precision: spec.Precision,
+	}, nil
+}
+
+func (s *HLLProcedureSpec) Kind() plan.ProcedureKind {
+	return HLLKind
+}
+
+func (s *HLLProcedureSpec) Copy() plan.ProcedureSpec {
+	ns := *s
+	return &ns
+}
+
+func (s *HLLProcedureSpec) aggregateColumn() string {
+	return s.Column
+}
+
+func (s *HLLProcedureSpec) newAggregator(typ flux.ColType) (aggregator, flux.ColType, error) {
+	h, err := newHyperLogLog(s.Precision)
+	if err != nil {
+		return nil, flux.TInvalid, err
+	}
+	return &hllAggregator{h: h}, flux.TString, nil
+}
+
+func (s *MergeHLLProcedureSpec) Kind() plan.ProcedureKind {
+	return MergeHLLKind
+}
+
+func (s *MergeHLLProcedureSpec) Copy() plan.ProcedureSpec {
+	ns := *s
+	return &ns
+}
+
+func (s *MergeHLLProcedureSpec) aggregateColumn() string {
+	return s.Column
+}
+
+func (s *MergeHLLProcedureSpec) newAggregator(typ flux.ColType) (aggregator, flux.ColType, error) {
+	if typ != flux.TString {
+		return nil, flux.TInvalid, errors.Newf(codes.FailedPrecondition, "HyperLogLog sketches must be strings, got %v", typ)
+	}
+	return &mergeAggregator{decode: decodeHLLSketch}, flux.TString, nil
+}
+
+func (s *ApproxDistinctProcedureSpec) Kind() plan.ProcedureKind {
+	return ApproxDistinctKind
+}
+
+func (s *ApproxDistinctProcedureSpec) Copy() plan.ProcedureSpec {
+	ns := *s
+	return &ns
+}
+
+func (s *ApproxDistinctProcedureSpec) aggregateColumn() string {
+	return s.Column
+}
+
+func (s *ApproxDistinctProcedureSpec) newAggregator(typ flux.ColType) (aggregator, flux.ColType, error) {
+	h, err := newHyperLogLog(s.Precision)
+	if err != nil {
+		return nil, flux.TInvalid, err
+	}
+	return &hllAggregator{h: h, estimate: true}, flux.TInt, nil
+}
+
+// NewHLLTransformation creates a transformation that summarizes the distinct values
+// of a column of each table with a HyperLogLog sketch.
+func NewHLLTransformation(d execute.Dataset, cache execute.TableBuilderCache, spec *HLLProcedureSpec) execute.Transformation {
+	return newAggregateTransformation(d, cache, spec)
+}
+
+// NewMergeHLLTransformation creates a transformation that merges
+// the HyperLogLog sketches of a column of each table.
+func NewMergeHLLTransformation(d execute.Dataset, cache execute.TableBuilderCache, spec *MergeHLLProcedureSpec) execute.Transformation {
+	return newAggregateTransformation(d, cache, spec)
+}
+
+// NewApproxDistinctTransformation creates a transformation that estimates
+// the number of distinct values of a column of each table.
+func NewApproxDistinctTransformation(d execute.Dataset, cache execute.TableBuilderCache, spec *ApproxDistinctProcedureSpec) execute.Transformation {
+	return newAggregateTransformation(d, cache, spec)
+}
+
+// hllAggregator adds the hashes of values to a HyperLogLog sketch.
+// It returns the encoded sketch or, if estimate is set, the estimated number of distinct values.
+type hllAggregator struct {
+	h        *hyperLogLog
+	estimate bool
+}
+
+func (a *hllAggregator) add(arr array.Interface) error {
+	return hashValues(arr, a.h.addHash)
+}
+
+func (a *hllAggregator) value() values.Value {
+	if a.estimate {
+		return values.NewInt(a.h.estimate())
+	}
+	return values.NewString(a.h.encode())
+}
+
+func estimateHLL(args interpreter.Arguments) (values.Value, error) {
+	sketch, err := args.GetRequiredString("sketch")
+	if err != nil {
+		return nil, err
+	}
+	h, err := decodeHyperLogLog(sketch)
+	if err != nil {
+		return nil, err
+	}
+	return values.NewInt(h.estimate()), nil
+}
+
+func decodeHLLSketch(s string) (sketch, error) {
+	h, err := decodeHyperLogLog(s)
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
@@ -0,0 +1,190 @@
+package sketch_test
+
+import (
+	"testing"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/execute/executetest"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/querytest"
+	"github.com/influxdata/flux/stdlib/experimental/sketch"
+)
+
+func TestHLLOperation_Marshaling(t *testing.T) {
+	data := []byte(`{
+		"id":"hll",
+		"kind":"experimental/sketch.hll",
+		"spec":{
+			"column":"user",
+			"precision":12
+		}
+	}`)
+	op := &flux.Operation{
+		ID: "hll",
+		Spec: &sketch.HLLOpSpec{
+			Column:    "user",
+			Precision: 12,
+		},
+	}
+	querytest.OperationMarshalingTestHelper(t, data, op)
+}
+
+func hllInput() []flux.Table {
+	return []flux.Table{
+		&executetest.Table{
+			KeyCols: []string{"host"},
+			ColMeta: []flux.ColMeta{
+				{Label: "_time", Type: flux.TTime},
+				{Label: "host", Type: flux.TString},
+				{Label: "_value", Type: flux.TString},
+			},
+			Data: [][]interface{}{
+				{execute.Time(1), "a", "alice"},
+				{execute.Time(2), "a", "bob"},
+				{execute.Time(3), "a", "alice"},
+				{execute.Time(4), "a", nil},
+				{execute.Time(5), "a", "carol"},
+			},
+		},
+		&executetest.Table{
+			KeyCols: []string{"host"},
+			ColMeta: []flux.ColMeta{
+				{Label: "_time", Type: flux.TTime},
+				{Label: "host", Type: flux.TString},
+				{Label: "_value", Type: flux.TString},
+			},
+			Data: [][]interface{}{
+				{execute.Time(1), "b", "dave"},
+			},
+		},
+	}
+}
+
+func hllOutput(label string, typ flux.ColType, a, b interface{}) []*executetest.Table {
+	return []*executetest.Table{
+		{
+			KeyCols: []string{"host"},
+			ColMeta: []flux.ColMeta{
+				{Label: "host", Type: flux.TString},
+				{Label: label, Type: typ},
+			},
+			Data: [][]interface{}{
+				{"a", a},
+			},
+		},
+		{
+			KeyCols: []string{"host"},
+			ColMeta: []flux.ColMeta{
+				{Label: "host", Type: flux.TString},
+				{Label: label, Type: typ},
+			},
+			Data: [][]interface{}{
+				{"b", b},
+			},
+		},
+	}
+}
+
+func TestHLL_Process(t *testing.T) {
+	executetest.ProcessTestHelper(
+		t,
+		hllInput(),
+		hllOutput("_value", flux.TString, "SEwBBAEHAwIDAwQ=", "SEwBBAECAQ=="),
+		nil,
+		func(d execute.Dataset, c execute.TableBuilderCache) execute.Transformation {
+			return sketch.NewHLLTransformation(d, c, &sketch.HLLProcedureSpec{
+				Column:    "_value",
+				Precision: 4,
+			})
+		},
+	)
+}
+
+func TestApproxDistinct_Process(t *testing.T) {
+	executetest.ProcessTestHelper(
+		t,
+		hllInput(),
+		hllOutput("_value", flux.TInt, int64(3), int64(1)),
+		nil,
+		func(d execute.Dataset, c execute.TableBuilderCache) execute.Transformation {
+			return sketch.NewApproxDistinctTransformation(d, c, &sketch.ApproxDistinctProcedureSpec{
+				Column:    "_value",
+				Precision: 14,
+			})
+		},
+	)
+}
+
+func TestMergeHLL_Process(t *testing.T) {
+	sketches := func(vs ...interface{}) []flux.Table {
+		tbl := &executetest.Table{
+			KeyCols: []string{"host"},
+			ColMeta: []flux.ColMeta{
+				{Label: "_time", Type: flux.TTime},
+				{Label: "host", Type: flux.TString},
+				{Label: "_value", Type: flux.TString},
+			},
+		}
+		for i, v := range vs {
+			tbl.Data = append(tbl.Data, []interface{}{execute.Time(i), "a", v})
+		}
+		return []flux.Table{tbl}
+	}
+	merged := func(v interface{}) []*executetest.Table {
+		return []*executetest.Table{{
+			KeyCols: []string{"host"},
+			ColMeta: []flux.ColMeta{
+				{Label: "host", Type: flux.TString},
+				{Label: "_value", Type: flux.TString},
+			},
+			Data: [][]interface{}{
+				{"a", v},
+			},
+		}}
+	}
+	testCases := []struct {
+		name    string
+		data    []flux.Table
+		want    []*executetest.Table
+		wantErr error
+	}{
+		{
+			name: "merge",
+			data: sketches("SEwBBAEHAwIDAwQ=", nil, "SEwBBAECAQ=="),
+			want: merged("SEwBBAECAQUDAgMDBA=="),
+		},
+		{
+			name: "no sketches",
+			data: sketches(nil),
+			want: merged(nil),
+		},
+		{
+			name:    "invalid sketch",
+			data:    sketches("SEwBBAEHAwIDAwQ=", "aGVsbG8gd29ybGQ="),
+			wantErr: errors.New(codes.Invalid, "invalid HyperLogLog sketch"),
+		},
+		{
+			name:    "different precision",
+			data:    sketches("SEwBBAEHAwIDAwQ=", "SEwBBQEAAQ=="),
+			wantErr: errors.New(codes.FailedPrecondition, "cannot merge HyperLogLog sketches with precision 4 and 5"),
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			executetest.ProcessTestHelper(
+				t,
+				tc.data,
+				tc.want,
+				tc.wantErr,
+				func(d execute.Dataset, c execute.TableBuilderCache) execute.Transformation {
+					return sketch.NewMergeHLLTransformation(d, c, &sketch.MergeHLLProcedureSpec{
+						Column: "_value",
+					})
+				},
+			)
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package sketch
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"math"
+	"math/bits"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+const (
+	hllMinPrecision     = 4
+	hllMaxPrecision     = 18
+	hllDefaultPrecision = 14
+
+	// hllVersion is the version of the encoding of a sketch.
+	hllVersion = 1
+
+	hllDense  = 0
+	hllSparse = 1
+)
+
+// hllMagic is the prefix of every encoded HyperLogLog sketch.
+var hllMagic = [2]byte{'H', 'L'}
+
+// hyperLogLog estimates the number of distinct values with a HyperLogLog sketch.
+//
+// The first p bits of the 64 bit hash of a value select one of 2^p registers
+// and the register keeps the largest position of the first set bit
+// in the remaining bits of the hashes it has seen.
+type hyperLogLog struct {
+	p         uint8
+	registers []uint8
+}
+
+func newHyperLogLog(precision int64) (*hyperLogLog, error) {
+	if precision < hllMinPrecision || precision > hllMaxPrecision {
+		return nil, errors.Newf(codes.Invalid, "precision must be between %d and %d, got %d", hllMinPrecision, hllMaxPrecision, precision)
+	}
+	return &hyperLogLog{
+		p:         uint8(precision),
+		registers: make([]uint8, 1<<precision),
+	}, nil
+}
+
+// addHash adds the 64 bit hash of a value to the sketch.
+func (h *hyperLogLog) addHash(x uint64) {
+	idx := x >> (64 - h.p)
+	// The sentinel bit bounds the rank by 64-p+1 when the remaining bits are all zero.
+	w := x<<h.p | 1<<(h.p-1)
+	rank := uint8(bits.LeadingZeros64(w)) + 1
+	if rank > h.registers[idx] {
+		h.registers[idx] = rank
+	}
+}
+
+// merge adds the values summarized by another HyperLogLog sketch to the sketch.
+func (h *hyperLogLog) merge(s sketch) error {
+	o := s.(*hyperLogLog)
+	if h.p != o.p {
+		return errors.Newf(codes.FailedPrecondition, "cannot merge HyperLogLog sketches with precision %d and %d", h.p, o.p)
+	}
+	for i, r := range o.registers {
+		if r > h.registers[i] {
+			h.registers[i] = r
+		}
+	}
+	return nil
+}
+
+// estimate returns the estimated number of distinct values.
+func (h *hyperLogLog) estimate() int64 {
+	m := float64(len(h.registers))
+	var (
+		sum   float64
+		zeros int
+	)
+	for _, r := range h.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	var alpha float64
+	switch len(h.registers) {
+	case 16:
+		alpha = 0.673
+	case 32:
+		alpha = 0.697
+	case 64:
+		alpha = 0.709
+	default:
+		alpha = 0.7213 / (1 + 1.079/m)
+	}
+	e := alpha * m * m / sum
+	if e <= 2.5*m && zeros > 0 {
+		// Linear counting is more accurate for small cardinalities.
+		e = m * math.Log(m/float64(zeros))
+	}
+	return int64(math.Round(e))
+}
+
+// encode encodes the sketch as a base64 string.
+//
+// The encoding starts with the magic bytes, the version, the precision and the format.
+// Sketches with few non-zero registers use the sparse format,
+// which stores the gap to the previous non-zero register and the register of each of them,
+// all others store every register.
+func (h *hyperLogLog) encode() string {
+	header := []byte{hllMagic[0], hllMagic[1], hllVersion, h.p}
+
+	var (
+		sparse []byte
+		gap    [binary.MaxVarintLen64]byte
+	)
+	last := 0
+	for i, r := range h.registers {
+		if r == 0 {
+			continue
+		}
+		n := binary.PutUvarint(gap[:], uint64(i-last))
+		sparse = append(append(sparse, gap[:n]...), r)
+		last = i
+		if len(sparse) >= len(h.registers) {
+			break
+		}
+	}
+
+	var buf []byte
+	if len(sparse) < len(h.registers) {
+		buf = append(append(header, hllSparse), sparse...)
+	} else {
+		buf = append(append(header, hllDense), h.registers...)
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+// decodeHyperLogLog decodes a sketch encoded with encode.
+func decodeHyperLogLog(s string) (*hyperLogLog, error) {
+	buf, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.Wrap(err, codes.Invalid, "invalid HyperLogLog sketch")
+	}
+	if len(buf) < 5 || buf[0] != hllMagic[0] || buf[1] != hllMagic[1] {
+		return nil, errors.New(codes.Invalid, "invalid HyperLogLog sketch")
+	}
+	if buf[2] != hllVersion {
+		return nil, errors.Newf(codes.Invalid, "unsupported HyperLogLog sketch version %d", buf[2])
+	}
+	h, err := newHyperLogLog(int64(buf[3]))
+	if err != nil {
+		return nil, errors.Wrap(err, codes.Invalid, "invalid HyperLogLog sketch")
+	}
+	maxRank := 64 - h.p + 1
+
+	data := buf[5:]
+	switch buf[4] {
+	case hllDense:
+		if len(data) != len(h.registers) {
+			return nil, errors.Newf(codes.Invalid, "invalid HyperLogLog sketch: expected %d registers, got %d", len(h.registers), len(data))
+		}
+		copy(h.registers, data)
+	case hllSparse:
+		idx := uint64(0)
+		for len(data) > 0 {
+			gap, n := binary.Uvarint(data)
+			if n <= 0 || n >= len(data) {
+				return nil, errors.New(codes.Invalid, "invalid HyperLogLog sketch: truncated register")
+			}
+			idx += gap
+			if idx >= uint64(len(h.registers)) {
+				return nil, errors.Newf(codes.Invalid, "invalid HyperLogLog sketch: register %d out of range", idx)
+			}
+			h.registers[idx] = data[n]
+			data = data[n+1:]
+		}
+	default:
+		return nil, errors.Newf(codes.Invalid, "invalid HyperLogLog sketch: unknown format %d", buf[4])
+	}
+	for _, r := range h.registers {
+		if r > maxRank {
+			return nil, errors.Newf(codes.Invalid, "invalid HyperLogLog sketch: register value %d exceeds %d", r, maxRank)
+		}
+	}
+	return h, nil
+}
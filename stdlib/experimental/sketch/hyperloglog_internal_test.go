@@ -0,0 +1,97 @@
+package sketch
+
+import (
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+func TestHyperLogLog_Estimate(t *testing.T) {
+	for _, precision := range []int64{4, 10, 14, 18} {
+		for _, n := range []int{0, 1, 100, 10000, 200000} {
+			h, err := newHyperLogLog(precision)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < n; i++ {
+				// Every value is added twice to check that duplicates are not counted.
+				h.addHash(xxhash.Sum64String(strconv.Itoa(i)))
+				h.addHash(xxhash.Sum64String(strconv.Itoa(i)))
+			}
+
+			// Allow four times the relative standard error.
+			stdErr := 1.04 / math.Sqrt(float64(int(1)<<precision))
+			got := h.estimate()
+			if diff := math.Abs(float64(got) - float64(n)); diff > 4*stdErr*float64(n)+0.5 {
+				t.Errorf("precision %d: unexpected estimate of %d distinct values: %d", precision, n, got)
+			}
+		}
+	}
+}
+
+func TestHyperLogLog_Merge(t *testing.T) {
+	a, _ := newHyperLogLog(12)
+	b, _ := newHyperLogLog(12)
+	all, _ := newHyperLogLog(12)
+	for i := 0; i < 5000; i++ {
+		x := xxhash.Sum64String(strconv.Itoa(i))
+		if i < 3000 {
+			a.addHash(x)
+		}
+		if i >= 2000 {
+			b.addHash(x)
+		}
+		all.addHash(x)
+	}
+	if err := a.merge(b); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := a.estimate(), all.estimate(); got != want {
+		t.Errorf("unexpected estimate of merged sketch: got %d, want %d", got, want)
+	}
+
+	c, _ := newHyperLogLog(10)
+	if err := a.merge(c); err == nil {
+		t.Error("expected error merging sketches with different precision")
+	}
+}
+
+func TestHyperLogLog_Encode(t *testing.T) {
+	for _, n := range []int{0, 10, 100000} {
+		h, _ := newHyperLogLog(14)
+		for i := 0; i < n; i++ {
+			h.addHash(xxhash.Sum64String(strconv.Itoa(i)))
+		}
+		got, err := decodeHyperLogLog(h.encode())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.p != h.p {
+			t.Fatalf("unexpected precision: got %d, want %d", got.p, h.p)
+		}
+		for i := range h.registers {
+			if got.registers[i] != h.registers[i] {
+				t.Fatalf("%d values: unexpected register %d: got %d, want %d", n, i, got.registers[i], h.registers[i])
+			}
+		}
+	}
+}
+
+func TestHyperLogLog_DecodeInvalid(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"not base64!",
+		// Valid base64 but no sketch.
+		"aGVsbG8gd29ybGQ=",
+		// Precision 30.
+		"SEwBHgE=",
+		// Sparse register out of range.
+		"SEwBBAEgAQ==",
+	} {
+		if _, err := decodeHyperLogLog(s); err == nil {
+			t.Errorf("expected error decoding %q", s)
+		}
+	}
+}
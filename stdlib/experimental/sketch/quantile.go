@@ -0,0 +1,453 @@
+package sketch
+
+import (
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/array"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/internal/function"
+	"github.com/influxdata/flux/interpreter"
+	"github.com/influxdata/flux/plan"
+	"github.com/influxdata/flux/runtime"
+	"github.com/influxdata/flux/values"
+)
+
+const (
+	TDigestKind       = pkgpath + ".tdigest"
+	MergeTDigestKind  = pkgpath + ".mergeTDigest"
+	DDSketchKind      = pkgpath + ".ddsketch"
+	MergeDDSketchKind = pkgpath + ".mergeDDSketch"
+)
+
+type TDigestOpSpec struct {
+	Column      string  `json:"column"`
+	Compression float64 `json:"compression"`
+}
+
+type MergeTDigestOpSpec struct {
+	Column string `json:"column"`
+}
+
+type DDSketchOpSpec struct {
+	Column           string  `json:"column"`
+	RelativeAccuracy float64 `json:"relativeAccuracy"`
+	MaxBuckets       int64   `json:"maxBuckets"`
+}
+
+type MergeDDSketchOpSpec struct {
+	Column string `json:"column"`
+}
+
+func init() {
+	tdigestSignature := runtime.MustLookupBuiltinType(pkgpath, "tdigest")
+	mergeTDigestSignature := runtime.MustLookupBuiltinType(pkgpath, "mergeTDigest")
+	ddsketchSignature := runtime.MustLookupBuiltinType(pkgpath, "ddsketch")
+	mergeDDSketchSignature := runtime.MustLookupBuiltinType(pkgpath, "mergeDDSketch")
+
+	runtime.RegisterPackageValue(pkgpath, "tdigest", flux.MustValue(flux.FunctionValue(TDigestKind, createTDigestOpSpec, tdigestSignature)))
+	runtime.RegisterPackageValue(pkgpath, "mergeTDigest", flux.MustValue(flux.FunctionValue(MergeTDigestKind, createMergeTDigestOpSpec, mergeTDigestSignature)))
+	runtime.RegisterPackageValue(pkgpath, "ddsketch", flux.MustValue(flux.FunctionValue(DDSketchKind, createDDSketchOpSpec, ddsketchSignature)))
+	runtime.RegisterPackageValue(pkgpath, "mergeDDSketch", flux.MustValue(flux.FunctionValue(MergeDDSketchKind, createMergeDDSketchOpSpec, mergeDDSketchSignature)))
+	flux.RegisterOpSpec(TDigestKind, newTDigestOp)
+	flux.RegisterOpSpec(MergeTDigestKind, newMergeTDigestOp)
+	flux.RegisterOpSpec(DDSketchKind, newDDSketchOp)
+	flux.RegisterOpSpec(MergeDDSketchKind, newMergeDDSketchOp)
+	plan.RegisterProcedureSpec(TDigestKind, newTDigestProcedure, TDigestKind)
+	plan.RegisterProcedureSpec(MergeTDigestKind, newMergeTDigestProcedure, MergeTDigestKind)
+	plan.RegisterProcedureSpec(DDSketchKind, newDDSketchProcedure, DDSketchKind)
+	plan.RegisterProcedureSpec(MergeDDSketchKind, newMergeDDSketchProcedure, MergeDDSketchKind)
+	execute.RegisterTransformation(TDigestKind, createAggregateTransformation)
+	execute.RegisterTransformation(MergeTDigestKind, createAggregateTransformation)
+	execute.RegisterTransformation(DDSketchKind, createAggregateTransformation)
+	execute.RegisterTransformation(MergeDDSketchKind, createAggregateTransformation)
+
+	b := function.ForPackage(pkgpath)
+	b.Register("quantileTDigest", quantileTDigest)
+	b.Register("quantileDDSketch", quantileDDSketch)
+}
+
+func createTDigestOpSpec(args flux.Arguments, a *flux.Administration) (flux.OperationSpec, error) {
+	if err := a.AddParentFromArgs(args); err != nil {
+		return nil, err
+	}
+	column, err := getColumn(args)
+	if err != nil {
+		return nil, err
+	}
+	spec := &TDigestOpSpec{Column: column, Compression: tdDefaultCompression}
+	if c, ok, err := args.GetFloat("compression"); err != nil {
+		return nil, err
+	} else if ok {
+		if !(c > 0) || c > tdMaxCompression {
+			return nil, errors.Newf(codes.Invalid, "compression must be greater than 0 and at most %v, got %v", tdMaxCompression, c)
+		}
+		spec.Compression = c
+	}
+	return spec, nil
+}
+
+func createMergeTDigestOpSpec(args flux.Arguments, a *flux.Administration) (flux.OperationSpec, error) {
+	if err := a.AddParentFromArgs(args); err != nil {
+		return nil, err
+	}
+	column, err := getColumn(args)
+	if err != nil {
+		return nil, err
+	}
+	return &MergeTDigestOpSpec{Column: column}, nil
+}
+
+func createDDSketchOpSpec(args flux.Arguments, a *flux.Administration) (flux.OperationSpec, error) {
+	if err := a.AddParentFromArgs(args); err != nil {
+		return nil, err
+	}
+	column, err := getColumn(args)
+	if err != nil {
+		return nil, err
+	}
+	spec := &DDSketchOpSpec{
+		Column:           column,
+		RelativeAccuracy: ddDefaultRelativeAccuracy,
+		MaxBuckets:       ddDefaultMaxBuckets,
+	}
+	if r, ok, err := args.GetFloat("relativeAccuracy"); err != nil {
+		return nil, err
+	} else if ok {
+		if r <= 0 || r >= 1 {
+			return nil, errors.Newf(codes.Invalid, "relative accuracy must be between 0 and 1, got %v", r)
+		}
+		spec.RelativeAccuracy = r
+	}
+	if n, ok, err := args.GetInt("maxBuckets"); err != nil {
+		return nil, err
+	} else if ok {
+		if n < 1 || n > ddMaxMaxBuckets {
+			return nil, errors.Newf(codes.Invalid, "max buckets must be between 1 and %d, got %d", ddMaxMaxBuckets, n)
+		}
+		spec.MaxBuckets = n
+	}
+	return spec, nil
+}
+
+func createMergeDDSketchOpSpec(args flux.Arguments, a *flux.Administration) (flux.OperationSpec, error) {
+	if err := a.AddParentFromArgs(args); err != nil {
+		return nil, err
+	}
+	column, err := getColumn(args)
+	if err != nil {
+		return nil, err
+	}
+	return &MergeDDSketchOpSpec{Column: column}, nil
+}
+
+func newTDigestOp() flux.OperationSpec {
+	return new(TDigestOpSpec)
+}
+
+func newMergeTDigestOp() flux.OperationSpec {
+	return new(MergeTDigestOpSpec)
+}
+
+func newDDSketchOp() flux.OperationSpec {
+	return new(DDSketchOpSpec)
+}
+
+func newMergeDDSketchOp() flux.OperationSpec {
+	return new(MergeDDSketchOpSpec)
+}
+
+func (s *TDigestOpSpec) Kind() flux.OperationKind {
+	return TDigestKind
+}
+
+func (s *MergeTDigestOpSpec) Kind() flux.OperationKind {
+	return MergeTDigestKind
+}
+
+func (s *DDSketchOpSpec) Kind() flux.OperationKind {
+	return DDSketchKind
+}
+
+func (s *MergeDDSketchOpSpec) Kind() flux.OperationKind {
+	return MergeDDSketchKind
+}
+
+type TDigestProcedureSpec struct {
+	plan.DefaultCost
+	Column      string
+	Compression float64
+}
+
+type MergeTDigestProcedureSpec struct {
+	plan.DefaultCost
+	Column string
+}
+
+type DDSketchProcedureSpec struct {
+	plan.DefaultCost
+	Column           string
+	RelativeAccuracy float64
+	MaxBuckets       int64
+}
+
+type MergeDDSketchProcedureSpec struct {
+	plan.DefaultCost
+	Column string
+}
+
+func newTDigestProcedure(qs flux.OperationSpec, pa plan.Administration) (plan.ProcedureSpec, error) {
+	spec, ok := qs.(*TDigestOpSpec)
+	if !ok {
+		return nil, errors.Newf(codes.Internal, "invalid spec type %T", qs)
+	}
+	return &TDigestProcedureSpec{
+		Column:      spec.Column,
+		Compression: spec.Compression,
+	}, nil
+}
+
+func newMergeTDigestProcedure(qs flux.OperationSpec, pa plan.Administration) (plan.ProcedureSpec, error) {
+	spec, ok := qs.(*MergeTDigestOpSpec)
+	if !ok {
+		return nil, errors.Newf(codes.Internal, "invalid spec type %T", qs)
+	}
+	return &MergeTDigestProcedureSpec{
+		Column: spec.Column,
+	}, nil
+}
+
+func newDDSketchProcedure(qs flux.OperationSpec, pa plan.Administration) (plan.ProcedureSpec, error) {
+	spec, ok := qs.(*DDSketchOpSpec)
+	if !ok {
+		return nil, errors.Newf(codes.Internal, "invalid spec type %T", qs)
+	}
+	return &DDSketchProcedureSpec{
+		Column:           spec.Column,
+		RelativeAccuracy: spec.RelativeAccuracy,
+		MaxBuckets:       spec.MaxBuckets,
+	}, nil
+}
+
+func newMergeDDSketchProcedure(qs flux.OperationSpec, pa plan.Administration) (plan.ProcedureSpec, error) {
+	spec, ok := qs.(*MergeDDSketchOpSpec)
+	if !ok {
+		return nil, errors.Newf(codes.Internal, "invalid spec type %T", qs)
+	}
+	return &MergeDDSketchProcedureSpec{
+		Column: spec.Column,
+	}, nil
+}
+
+func (s *TDigestProcedureSpec) Kind() plan.ProcedureKind {
+	return TDigestKind
+}
+
+func (s *TDigestProcedureSpec) Copy() plan.ProcedureSpec {
+	ns := *s
+	return &ns
+}
+
+func (s *TDigestProcedureSpec) aggregateColumn() string {
+	return s.Column
+}
+
+func (s *TDigestProcedureSpec) newAggregator(typ flux.ColType) (aggregator, flux.ColType, error) {
+	if err := checkNumeric(typ); err != nil {
+		return nil, flux.TInvalid, err
+	}
+	t, err := newTDigest(s.Compression)
+	if err != nil {
+		return nil, flux.TInvalid, err
+	}
+	return &tdigestAggregator{t: t}, flux.TString, nil
+}
+
+func (s *MergeTDigestProcedureSpec) Kind() plan.ProcedureKind {
+	return MergeTDigestKind
+}
+
+func (s *MergeTDigestProcedureSpec) Copy() plan.ProcedureSpec {
+	ns := *s
+	return &ns
+}
+
+func (s *MergeTDigestProcedureSpec) aggregateColumn() string {
+	return s.Column
+}
+
+func (s *MergeTDigestProcedureSpec) newAggregator(typ flux.ColType) (aggregator, flux.ColType, error) {
+	if typ != flux.TString {
+		return nil, flux.TInvalid, errors.Newf(codes.FailedPrecondition, "t-digests must be strings, got %v", typ)
+	}
+	return &mergeAggregator{decode: decodeTDigestSketch}, flux.TString, nil
+}
+
+func (s *DDSketchProcedureSpec) Kind() plan.ProcedureKind {
+	return DDSketchKind
+}
+
+func (s *DDSketchProcedureSpec) Copy() plan.ProcedureSpec {
+	ns := *s
+	return &ns
+}
+
+func (s *DDSketchProcedureSpec) aggregateColumn() string {
+	return s.Column
+}
+
+func (s *DDSketchProcedureSpec) newAggregator(typ flux.ColType) (aggregator, flux.ColType, error) {
+	if err := checkNumeric(typ); err != nil {
+		return nil, flux.TInvalid, err
+	}
+	d, err := newDDSketch(s.RelativeAccuracy, int(s.MaxBuckets))
+	if err != nil {
+		return nil, flux.TInvalid, err
+	}
+	return &ddsketchAggregator{s: d}, flux.TString, nil
+}
+
+func (s *MergeDDSketchProcedureSpec) Kind() plan.ProcedureKind {
+	return MergeDDSketchKind
+}
+
+func (s *MergeDDSketchProcedureSpec) Copy() plan.ProcedureSpec {
+	ns := *s
+	return &ns
+}
+
+func (s *MergeDDSketchProcedureSpec) aggregateColumn() string {
+	return s.Column
+}
+
+func (s *MergeDDSketchProcedureSpec) newAggregator(typ flux.ColType) (aggregator, flux.ColType, error) {
+	if typ != flux.TString {
+		return nil, flux.TInvalid, errors.Newf(codes.FailedPrecondition, "DDSketches must be strings, got %v", typ)
+	}
+	return &mergeAggregator{decode: decodeDDSketchSketch}, flux.TString, nil
+}
+
+// NewTDigestTransformation creates a transformation that summarizes
+// a column of each table with a t-digest.
+func NewTDigestTransformation(d execute.Dataset, cache execute.TableBuilderCache, spec *TDigestProcedureSpec) execute.Transformation {
+	return newAggregateTransformation(d, cache, spec)
+}
+
+// NewMergeTDigestTransformation creates a transformation that merges
+// the t-digests of a column of each table.
+func NewMergeTDigestTransformation(d execute.Dataset, cache execute.TableBuilderCache, spec *MergeTDigestProcedureSpec) execute.Transformation {
+	return newAggregateTransformation(d, cache, spec)
+}
+
+// NewDDSketchTransformation creates a transformation that summarizes
+// a column of each table with a DDSketch.
+func NewDDSketchTransformation(d execute.Dataset, cache execute.TableBuilderCache, spec *DDSketchProcedureSpec) execute.Transformation {
+	return newAggregateTransformation(d, cache, spec)
+}
+
+// NewMergeDDSketchTransformation creates a transformation that merges
+// the DDSketches of a column of each table.
+func NewMergeDDSketchTransformation(d execute.Dataset, cache execute.TableBuilderCache, spec *MergeDDSketchProcedureSpec) execute.Transformation {
+	return newAggregateTransformation(d, cache, spec)
+}
+
+func checkNumeric(typ flux.ColType) error {
+	switch typ {
+	case flux.TInt, flux.TUInt, flux.TFloat:
+		return nil
+	default:
+		return errors.Newf(codes.FailedPrecondition, "quantile sketches require a numeric column, got %v", typ)
+	}
+}
+
+// tdigestAggregator adds values to a t-digest and returns the encoded t-digest.
+type tdigestAggregator struct {
+	t *tDigest
+}
+
+func (a *tdigestAggregator) add(arr array.Interface) error {
+	return floatValues(arr, func(x float64) {
+		a.t.Add(x, 1)
+	})
+}
+
+func (a *tdigestAggregator) value() values.Value {
+	return values.NewString(a.t.encode())
+}
+
+// ddsketchAggregator adds values to a DDSketch and returns the encoded DDSketch.
+type ddsketchAggregator struct {
+	s *ddSketch
+}
+
+func (a *ddsketchAggregator) add(arr array.Interface) error {
+	return floatValues(arr, a.s.add)
+}
+
+func (a *ddsketchAggregator) value() values.Value {
+	return values.NewString(a.s.encode())
+}
+
+func decodeTDigestSketch(s string) (sketch, error) {
+	t, err := decodeTDigest(s)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func decodeDDSketchSketch(s string) (sketch, error) {
+	d, err := decodeDDSketch(s)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// getSketchAndQuantile reads the arguments of the functions
+// that extract a quantile from a sketch.
+func getSketchAndQuantile(args interpreter.Arguments) (string, float64, error) {
+	s, err := args.GetRequiredString("sketch")
+	if err != nil {
+		return "", 0, err
+	}
+	q, err := args.GetRequiredFloat("q")
+	if err != nil {
+		return "", 0, err
+	}
+	if q < 0 || q > 1 {
+		return "", 0, errors.Newf(codes.Invalid, "quantile must be between 0 and 1, got %v", q)
+	}
+	return s, q, nil
+}
+
+func quantileTDigest(args interpreter.Arguments) (values.Value, error) {
+	s, q, err := getSketchAndQuantile(args)
+	if err != nil {
+		return nil, err
+	}
+	t, err := decodeTDigest(s)
+	if err != nil {
+		return nil, err
+	}
+	if t.Count() == 0 {
+		return values.NewNull(flux.SemanticType(flux.TFloat)), nil
+	}
+	return values.NewFloat(t.Quantile(q)), nil
+}
+
+func quantileDDSketch(args interpreter.Arguments) (values.Value, error) {
+	s, q, err := getSketchAndQuantile(args)
+	if err != nil {
+		return nil, err
+	}
+	d, err := decodeDDSketch(s)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := d.quantile(q)
+	if !ok {
+		return values.NewNull(flux.SemanticType(flux.TFloat)), nil
+	}
+	return values.NewFloat(v), nil
+}
@@ -0,0 +1,208 @@
+package sketch
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/interpreter"
+	"github.com/influxdata/flux/values"
+)
+
+// quantileSample returns n sorted, normally distributed values
+// that include positive and negative numbers.
+func quantileSample(n int) []float64 {
+	r := rand.New(rand.NewSource(1))
+	xs := make([]float64, n)
+	for i := range xs {
+		xs[i] = r.NormFloat64()*100 + 50
+	}
+	sort.Float64s(xs)
+	return xs
+}
+
+func exactQuantile(xs []float64, q float64) float64 {
+	return xs[int(q*float64(len(xs)-1))]
+}
+
+func TestDDSketch_Quantile(t *testing.T) {
+	xs := quantileSample(10000)
+	a, _ := newDDSketch(0.01, 2048)
+	b, _ := newDDSketch(0.01, 2048)
+	for i, x := range xs {
+		// Split the values between two sketches to check merging.
+		if i%3 == 0 {
+			a.add(x)
+		} else {
+			b.add(x)
+		}
+	}
+	if err := a.merge(b); err != nil {
+		t.Fatal(err)
+	}
+	s, err := decodeDDSketch(a.encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := s.count(), uint64(len(xs)); got != want {
+		t.Fatalf("unexpected count: got %d, want %d", got, want)
+	}
+	for _, q := range []float64{0, 0.01, 0.25, 0.5, 0.75, 0.99, 1} {
+		want := exactQuantile(xs, q)
+		got, ok := s.quantile(q)
+		if !ok {
+			t.Fatalf("expected quantile %v", q)
+		}
+		if math.Abs(got-want) > 0.01*math.Abs(want)+1e-9 {
+			t.Errorf("unexpected quantile %v: got %v, want %v", q, got, want)
+		}
+	}
+
+	c, _ := newDDSketch(0.02, 2048)
+	if err := a.merge(c); err == nil {
+		t.Error("expected error merging sketches with different relative accuracy")
+	}
+}
+
+func TestDDSketch_MaxBuckets(t *testing.T) {
+	s, _ := newDDSketch(0.01, 100)
+	for x := 1.0; x < 1e9; x *= 1.001 {
+		s.add(x)
+	}
+	if got := len(s.positive.bins); got > 100 {
+		t.Fatalf("sketch has %d buckets, want at most 100", got)
+	}
+	// The highest quantiles are unaffected by collapsing the lowest buckets.
+	got, _ := s.quantile(1)
+	if math.Abs(got-1e9) > 0.01*1e9 {
+		t.Errorf("unexpected maximum: %v", got)
+	}
+}
+
+func TestDDSketch_MaxBucketsGrowth(t *testing.T) {
+	s, _ := newDDSketch(0.01, 100)
+	s.add(1)
+	s.add(1e300)
+	if got := len(s.positive.bins); got > 100 {
+		t.Fatalf("sketch has %d buckets, want at most 100", got)
+	}
+	if want, got := uint64(2), s.positive.count; want != got {
+		t.Fatalf("unexpected count -want/+got:\n\t- %d\n\t+ %d", want, got)
+	}
+	// The smaller value is collapsed into the lowest remaining bucket.
+	if want, got := uint64(1), s.positive.bins[0]; want != got {
+		t.Errorf("unexpected count of the lowest bucket -want/+got:\n\t- %d\n\t+ %d", want, got)
+	}
+}
+
+func TestTDigest_Quantile(t *testing.T) {
+	xs := quantileSample(10000)
+	a, _ := newTDigest(1000)
+	b, _ := newTDigest(1000)
+	for i, x := range xs {
+		if i%3 == 0 {
+			a.Add(x, 1)
+		} else {
+			b.Add(x, 1)
+		}
+	}
+	if err := a.merge(b); err != nil {
+		t.Fatal(err)
+	}
+	td, err := decodeTDigest(a.encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := td.Count(), float64(len(xs)); got != want {
+		t.Fatalf("unexpected count: got %v, want %v", got, want)
+	}
+	for _, q := range []float64{0.01, 0.25, 0.5, 0.75, 0.99} {
+		want := exactQuantile(xs, q)
+		if got := td.Quantile(q); math.Abs(got-want) > 1 {
+			t.Errorf("unexpected quantile %v: got %v, want %v", q, got, want)
+		}
+	}
+}
+
+func TestTDigest_InvalidCompression(t *testing.T) {
+	for _, c := range []float64{0, -1, math.NaN(), math.Inf(1), tdMaxCompression + 1, 1e18} {
+		if _, err := newTDigest(c); errors.Code(err) != codes.Invalid {
+			t.Errorf("unexpected error for compression %v -want/+got:\n\t- %v\n\t+ %v", c, codes.Invalid, errors.Code(err))
+		}
+	}
+	if _, err := newTDigest(tdMaxCompression); err != nil {
+		t.Errorf("unexpected error for the maximum compression: %s", err)
+	}
+}
+
+func TestQuantileSketch_DecodeInvalid(t *testing.T) {
+	d, _ := newDDSketch(0.01, 2048)
+	d.add(1)
+	td, _ := newTDigest(100)
+	td.Add(1, 1)
+
+	// A store whose buckets are beyond the largest float.
+	outOfRange, _ := newDDSketch(0.01, 2048)
+	outOfRange.positive = ddStore{offset: math.MaxInt32, bins: []uint64{1}, count: 1}
+
+	for _, s := range []string{"", "not base64!", "aGVsbG8gd29ybGQ=", td.encode(), d.encode()[:12], outOfRange.encode()} {
+		if _, err := decodeDDSketch(s); err == nil {
+			t.Errorf("expected error decoding %q as DDSketch", s)
+		}
+	}
+	// A t-digest whose compression is too large to allocate.
+	huge, _ := newTDigest(100)
+	huge.Compression = 1e18
+
+	for _, s := range []string{"", "not base64!", "aGVsbG8gd29ybGQ=", d.encode(), td.encode()[:12], huge.encode()} {
+		if _, err := decodeTDigest(s); err == nil {
+			t.Errorf("expected error decoding %q as t-digest", s)
+		}
+	}
+}
+
+func TestQuantileFunctions(t *testing.T) {
+	d, _ := newDDSketch(0.01, 2048)
+	td, _ := newTDigest(100)
+	for _, x := range []float64{-10, 0, 10, 20, 30} {
+		d.add(x)
+		td.Add(x, 1)
+	}
+	emptyD, _ := newDDSketch(0.01, 2048)
+	emptyTD, _ := newTDigest(100)
+
+	call := func(fn func(interpreter.Arguments) (values.Value, error), sketch string, q float64) (values.Value, error) {
+		return fn(interpreter.NewArguments(values.NewObjectWithValues(map[string]values.Value{
+			"sketch": values.NewString(sketch),
+			"q":      values.NewFloat(q),
+		})))
+	}
+
+	if v, err := call(quantileDDSketch, d.encode(), 0.5); err != nil {
+		t.Fatal(err)
+	} else if got := v.Float(); math.Abs(got-10) > 0.1 {
+		t.Errorf("unexpected DDSketch median: %v", got)
+	}
+	if v, err := call(quantileDDSketch, d.encode(), 0); err != nil {
+		t.Fatal(err)
+	} else if got := v.Float(); math.Abs(got+10) > 0.1 {
+		t.Errorf("unexpected DDSketch minimum: %v", got)
+	}
+	if v, err := call(quantileTDigest, td.encode(), 0.5); err != nil {
+		t.Fatal(err)
+	} else if got := v.Float(); got != 10 {
+		t.Errorf("unexpected t-digest median: %v", got)
+	}
+	if v, err := call(quantileDDSketch, emptyD.encode(), 0.5); err != nil || !v.IsNull() {
+		t.Errorf("expected null quantile of empty DDSketch, got %v, %v", v, err)
+	}
+	if v, err := call(quantileTDigest, emptyTD.encode(), 0.5); err != nil || !v.IsNull() {
+		t.Errorf("expected null quantile of empty t-digest, got %v, %v", v, err)
+	}
+	if _, err := call(quantileTDigest, td.encode(), 1.5); err == nil {
+		t.Error("expected error for quantile outside of [0, 1]")
+	}
+}
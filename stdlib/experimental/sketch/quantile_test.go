@@ -0,0 +1,132 @@
+package sketch_test
+
+import (
+	"testing"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/execute/executetest"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/querytest"
+	"github.com/influxdata/flux/stdlib/experimental/sketch"
+)
+
+func TestDDSketchOperation_Marshaling(t *testing.T) {
+	data := []byte(`{
+		"id":"ddsketch",
+		"kind":"experimental/sketch.ddsketch",
+		"spec":{
+			"column":"latency",
+			"relativeAccuracy":0.02,
+			"maxBuckets":512
+		}
+	}`)
+	op := &flux.Operation{
+		ID: "ddsketch",
+		Spec: &sketch.DDSketchOpSpec{
+			Column:           "latency",
+			RelativeAccuracy: 0.02,
+			MaxBuckets:       512,
+		},
+	}
+	querytest.OperationMarshalingTestHelper(t, data, op)
+}
+
+func TestQuantileSketch_Process(t *testing.T) {
+	input := func(typ flux.ColType, vs ...interface{}) []flux.Table {
+		tbl := &executetest.Table{
+			KeyCols: []string{"host"},
+			ColMeta: []flux.ColMeta{
+				{Label: "_time", Type: flux.TTime},
+				{Label: "host", Type: flux.TString},
+				{Label: "_value", Type: typ},
+			},
+		}
+		for i, v := range vs {
+			tbl.Data = append(tbl.Data, []interface{}{execute.Time(i), "a", v})
+		}
+		return []flux.Table{tbl}
+	}
+	output := func(v interface{}) []*executetest.Table {
+		return []*executetest.Table{{
+			KeyCols: []string{"host"},
+			ColMeta: []flux.ColMeta{
+				{Label: "host", Type: flux.TString},
+				{Label: "_value", Type: flux.TString},
+			},
+			Data: [][]interface{}{
+				{"a", v},
+			},
+		}}
+	}
+	testCases := []struct {
+		name    string
+		data    []flux.Table
+		create  func(d execute.Dataset, c execute.TableBuilderCache) execute.Transformation
+		want    []*executetest.Table
+		wantErr error
+	}{
+		{
+			name: "ddsketch",
+			data: input(flux.TInt, int64(-1), nil, int64(0), int64(1)),
+			create: func(d execute.Dataset, c execute.TableBuilderCache) execute.Transformation {
+				return sketch.NewDDSketchTransformation(d, c, &sketch.DDSketchProcedureSpec{
+					Column:           "_value",
+					RelativeAccuracy: 0.5,
+					MaxBuckets:       16,
+				})
+			},
+			// Relative accuracy 0.5, 16 buckets, one zero and one value in bucket 0 of each store.
+			want: output("REQBAAAAAAAA4D8QAQABAQABAQ=="),
+		},
+		{
+			name: "tdigest",
+			data: input(flux.TFloat, 1.5, 2.5),
+			create: func(d execute.Dataset, c execute.TableBuilderCache) execute.Transformation {
+				return sketch.NewTDigestTransformation(d, c, &sketch.TDigestProcedureSpec{
+					Column:      "_value",
+					Compression: 100,
+				})
+			},
+			want: output("VEQBAAAAAAAAWUACAAAAAAAA+D8AAAAAAADwPwAAAAAAAARAAAAAAAAA8D8="),
+		},
+		{
+			name: "merge ddsketch",
+			data: input(flux.TString, "REQBAAAAAAAA4D8QAQABAQABAQ==", nil, "REQBAAAAAAAA4D8QAQABAQABAQ=="),
+			create: func(d execute.Dataset, c execute.TableBuilderCache) execute.Transformation {
+				return sketch.NewMergeDDSketchTransformation(d, c, &sketch.MergeDDSketchProcedureSpec{
+					Column: "_value",
+				})
+			},
+			want: output("REQBAAAAAAAA4D8QAgABAgABAg=="),
+		},
+		{
+			name: "string values",
+			data: input(flux.TString, "a"),
+			create: func(d execute.Dataset, c execute.TableBuilderCache) execute.Transformation {
+				return sketch.NewTDigestTransformation(d, c, &sketch.TDigestProcedureSpec{
+					Column:      "_value",
+					Compression: 100,
+				})
+			},
+			wantErr: errors.New(codes.FailedPrecondition, "quantile sketches require a numeric column, got string"),
+		},
+		{
+			name: "merge different sketches",
+			data: input(flux.TString, "REQBAAAAAAAA4D8QAQABAQABAQ=="),
+			create: func(d execute.Dataset, c execute.TableBuilderCache) execute.Transformation {
+				return sketch.NewMergeTDigestTransformation(d, c, &sketch.MergeTDigestProcedureSpec{
+					Column: "_value",
+				})
+			},
+			wantErr: errors.New(codes.Invalid, "invalid t-digest"),
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			executetest.ProcessTestHelper(t, tc.data, tc.want, tc.wantErr, tc.create)
+		})
+	}
+}
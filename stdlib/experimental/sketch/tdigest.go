@@ -0,0 +1,105 @@
+package sketch
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"math"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/tdigest"
+)
+
+const (
+	// tdDefaultCompression matches the default compression of quantile().
+	tdDefaultCompression = 1000.0
+	// tdMaxCompression bounds the memory of a sketch,
+	// which grows with its compression.
+	tdMaxCompression = 10000.0
+
+	// tdVersion is the version of the encoding of a sketch.
+	tdVersion = 1
+)
+
+// tdMagic is the prefix of every encoded t-digest.
+var tdMagic = [2]byte{'T', 'D'}
+
+// tDigest estimates quantiles with a t-digest.
+type tDigest struct {
+	*tdigest.TDigest
+}
+
+func newTDigest(compression float64) (*tDigest, error) {
+	if !(compression > 0) || compression > tdMaxCompression {
+		return nil, errors.Newf(codes.Invalid, "compression must be greater than 0 and at most %v, got %v", tdMaxCompression, compression)
+	}
+	return &tDigest{TDigest: tdigest.NewWithCompression(compression)}, nil
+}
+
+// merge adds the values summarized by another t-digest to the sketch.
+func (t *tDigest) merge(o sketch) error {
+	t.Merge(o.(*tDigest).TDigest)
+	return nil
+}
+
+// encode encodes the sketch as a base64 string.
+//
+// The encoding starts with the magic bytes and the version followed by
+// the compression and the mean and weight of every centroid.
+func (t *tDigest) encode() string {
+	centroids := t.Centroids(nil)
+	buf := make([]byte, 3, 3+8+binary.MaxVarintLen64+16*len(centroids))
+	copy(buf, []byte{tdMagic[0], tdMagic[1], tdVersion})
+
+	var tmp [binary.MaxVarintLen64]byte
+	binary.LittleEndian.PutUint64(tmp[:], math.Float64bits(t.Compression))
+	buf = append(buf, tmp[:8]...)
+	buf = append(buf, tmp[:binary.PutUvarint(tmp[:], uint64(len(centroids)))]...)
+	for _, c := range centroids {
+		binary.LittleEndian.PutUint64(tmp[:], math.Float64bits(c.Mean))
+		buf = append(buf, tmp[:8]...)
+		binary.LittleEndian.PutUint64(tmp[:], math.Float64bits(c.Weight))
+		buf = append(buf, tmp[:8]...)
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+// decodeTDigest decodes a sketch encoded with encode.
+func decodeTDigest(str string) (*tDigest, error) {
+	buf, err := base64.StdEncoding.DecodeString(str)
+	if err != nil {
+		return nil, errors.Wrap(err, codes.Invalid, "invalid t-digest")
+	}
+	if len(buf) < 3 || buf[0] != tdMagic[0] || buf[1] != tdMagic[1] {
+		return nil, errors.New(codes.Invalid, "invalid t-digest")
+	}
+	if buf[2] != tdVersion {
+		return nil, errors.Newf(codes.Invalid, "unsupported t-digest version %d", buf[2])
+	}
+	r := &byteReader{buf: buf[3:]}
+	compression := math.Float64frombits(r.uint64())
+	n := r.uvarint()
+	if r.err != nil {
+		return nil, errors.Wrap(r.err, codes.Invalid, "invalid t-digest")
+	}
+	if n != uint64(len(r.buf)/16) || len(r.buf)%16 != 0 {
+		return nil, errors.Newf(codes.Invalid, "invalid t-digest: expected %d centroids", n)
+	}
+	t, err := newTDigest(compression)
+	if err != nil {
+		return nil, errors.Wrap(err, codes.Invalid, "invalid t-digest")
+	}
+	centroids := make(tdigest.CentroidList, n)
+	for i := range centroids {
+		c := tdigest.Centroid{
+			Mean:   math.Float64frombits(r.uint64()),
+			Weight: math.Float64frombits(r.uint64()),
+		}
+		if math.IsNaN(c.Mean) || math.IsInf(c.Mean, 0) || !(c.Weight > 0) || math.IsInf(c.Weight, 0) {
+			return nil, errors.New(codes.Invalid, "invalid t-digest: invalid centroid")
+		}
+		centroids[i] = c
+	}
+	t.AddCentroidList(centroids)
+	return t, nil
+}
@@ -0,0 +1,369 @@
+package sketch
+
+import (
+	"container/heap"
+	"sort"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/array"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/execute/table"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/plan"
+	"github.com/influxdata/flux/runtime"
+	"github.com/influxdata/flux/values"
+)
+
+const (
+	TopKKind = pkgpath + ".topK"
+
+	topKCountColumn = "count"
+	topKErrorColumn = "error"
+
+	// topKCapacityFactor is the number of counters per requested value
+	// when the capacity is not set.
+	topKCapacityFactor = 10
+	// topKMaxK bounds k so the default capacity does not overflow.
+	topKMaxK = 1 << 20
+)
+
+type TopKOpSpec struct {
+	Column   string `json:"column"`
+	K        int64  `json:"k"`
+	Capacity int64  `json:"capacity"`
+}
+
+func init() {
+	topKSignature := runtime.MustLookupBuiltinType(pkgpath, "topK")
+
+	runtime.RegisterPackageValue(pkgpath, "topK", flux.MustValue(flux.FunctionValue(TopKKind, createTopKOpSpec, topKSignature)))
+	flux.RegisterOpSpec(TopKKind, newTopKOp)
+	plan.RegisterProcedureSpec(TopKKind, newTopKProcedure, TopKKind)
+	execute.RegisterTransformation(TopKKind, createTopKTransformation)
+}
+
+func createTopKOpSpec(args flux.Arguments, a *flux.Administration) (flux.OperationSpec, error) {
+	if err := a.AddParentFromArgs(args); err != nil {
+		return nil, err
+	}
+	column, err := getColumn(args)
+	if err != nil {
+		return nil, err
+	}
+	if column == topKCountColumn || column == topKErrorColumn {
+		return nil, errors.Newf(codes.Invalid, "cannot compute the top values of column %q", column)
+	}
+
+	k, err := args.GetRequiredInt("k")
+	if err != nil {
+		return nil, err
+	}
+	if k < 1 || k > topKMaxK {
+		return nil, errors.Newf(codes.Invalid, "k must be between 1 and %d, got %d", topKMaxK, k)
+	}
+
+	capacity := k * topKCapacityFactor
+	if c, ok, err := args.GetInt("capacity"); err != nil {
+		return nil, err
+	} else if ok {
+		if c < k {
+			return nil, errors.Newf(codes.Invalid, "capacity must be at least k (%d), got %d", k, c)
+		}
+		capacity = c
+	}
+	return &TopKOpSpec{Column: column, K: k, Capacity: capacity}, nil
+}
+
+func newTopKOp() flux.OperationSpec {
+	return new(TopKOpSpec)
+}
+
+func (s *TopKOpSpec) Kind() flux.OperationKind {
+	return TopKKind
+}
+
+type TopKProcedureSpec struct {
+	plan.DefaultCost
+	Column   string
+	K        int64
+	Capacity int64
+}
+
+func newTopKProcedure(qs flux.OperationSpec, pa plan.Administration) (plan.ProcedureSpec, error) {
+	spec, ok := qs.(*TopKOpSpec)
+	if !ok {
+		return nil, errors.Newf(codes.Internal, "invalid spec type %T", qs)
+	}
+	return &TopKProcedureSpec{
+		Column:   spec.Column,
+		K:        spec.K,
+		Capacity: spec.Capacity,
+	}, nil
+}
+
+func (s *TopKProcedureSpec) Kind() plan.ProcedureKind {
+	return TopKKind
+}
+
+func (s *TopKProcedureSpec) Copy() plan.ProcedureSpec {
+	ns := *s
+	return &ns
+}
+
+func createTopKTransformation(id execute.DatasetID, mode execute.AccumulationMode, spec plan.ProcedureSpec, a execute.Administration) (execute.Transformation, execute.Dataset, error) {
+	s, ok := spec.(*TopKProcedureSpec)
+	if !ok {
+		return nil, nil, errors.Newf(codes.Internal, "invalid spec type %T", spec)
+	}
+	cache := execute.NewTableBuilderCache(a.Allocator())
+	d := execute.NewDataset(id, mode, cache)
+	t := NewTopKTransformation(d, cache, s)
+	return t, d, nil
+}
+
+type topKTransformation struct {
+	execute.ExecutionNode
+	d     execute.Dataset
+	cache execute.TableBuilderCache
+
+	column   string
+	k        int
+	capacity int
+}
+
+// NewTopKTransformation creates a transformation that estimates
+// the most frequent values of a column of each table with the Space-Saving algorithm.
+//
+// Each output table has a row for each of the k most frequent values with the group key columns,
+// the value, its estimated count and the maximum overestimation of the count,
+// ordered by the estimated count.
+func NewTopKTransformation(d execute.Dataset, cache execute.TableBuilderCache, spec *TopKProcedureSpec) *topKTransformation {
+	return &topKTransformation{
+		d:        d,
+		cache:    cache,
+		column:   spec.Column,
+		k:        int(spec.K),
+		capacity: int(spec.Capacity),
+	}
+}
+
+func (t *topKTransformation) RetractTable(id execute.DatasetID, key flux.GroupKey) error {
+	return t.d.RetractTable(key)
+}
+
+func (t *topKTransformation) Process(id execute.DatasetID, tbl flux.Table) error {
+	idx := execute.ColIdx(t.column, tbl.Cols())
+	if idx < 0 {
+		return errors.Newf(codes.FailedPrecondition, "column %q does not exist", t.column)
+	}
+	if tbl.Key().HasCol(t.column) {
+		return errors.New(codes.FailedPrecondition, "cannot aggregate columns that are part of the group key")
+	}
+	for _, label := range []string{topKCountColumn, topKErrorColumn} {
+		if tbl.Key().HasCol(label) {
+			return errors.Newf(codes.FailedPrecondition, "group key column %q conflicts with an output column", label)
+		}
+	}
+	typ := tbl.Cols()[idx].Type
+
+	s := newSpaceSaving(t.capacity)
+	if err := tbl.Do(func(cr flux.ColReader) error {
+		return eachValue(table.Values(cr, idx), s.add)
+	}); err != nil {
+		return err
+	}
+
+	builder, created := t.cache.TableBuilder(tbl.Key())
+	if !created {
+		return errors.Newf(codes.FailedPrecondition, "aggregate found duplicate table with key: %v", tbl.Key())
+	}
+	if err := execute.AddTableKeyCols(tbl.Key(), builder); err != nil {
+		return err
+	}
+	valueIdx, err := builder.AddCol(flux.ColMeta{Label: t.column, Type: typ})
+	if err != nil {
+		return err
+	}
+	countIdx, err := builder.AddCol(flux.ColMeta{Label: topKCountColumn, Type: flux.TInt})
+	if err != nil {
+		return err
+	}
+	errorIdx, err := builder.AddCol(flux.ColMeta{Label: topKErrorColumn, Type: flux.TInt})
+	if err != nil {
+		return err
+	}
+
+	for _, c := range s.top(t.k) {
+		if err := execute.AppendKeyValues(tbl.Key(), builder); err != nil {
+			return err
+		}
+		v := values.New(c.value)
+		if typ == flux.TTime {
+			v = values.NewTime(values.Time(c.value.(int64)))
+		}
+		if err := builder.AppendValue(valueIdx, v); err != nil {
+			return err
+		}
+		if err := builder.AppendInt(countIdx, c.count); err != nil {
+			return err
+		}
+		if err := builder.AppendInt(errorIdx, c.error); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *topKTransformation) UpdateWatermark(id execute.DatasetID, mark execute.Time) error {
+	return t.d.UpdateWatermark(mark)
+}
+
+func (t *topKTransformation) UpdateProcessingTime(id execute.DatasetID, pt execute.Time) error {
+	return t.d.UpdateProcessingTime(pt)
+}
+
+func (t *topKTransformation) Finish(id execute.DatasetID, err error) {
+	t.d.Finish(err)
+}
+
+// eachValue calls fn with every non-null value of the array.
+func eachValue(arr array.Interface, fn func(v interface{})) error {
+	switch a := arr.(type) {
+	case *array.Int:
+		for i, l := 0, a.Len(); i < l; i++ {
+			if a.IsValid(i) {
+				fn(a.Value(i))
+			}
+		}
+	case *array.Uint:
+		for i, l := 0, a.Len(); i < l; i++ {
+			if a.IsValid(i) {
+				fn(a.Value(i))
+			}
+		}
+	case *array.Float:
+		for i, l := 0, a.Len(); i < l; i++ {
+			if a.IsValid(i) {
+				fn(a.Value(i))
+			}
+		}
+	case *array.Boolean:
+		for i, l := 0, a.Len(); i < l; i++ {
+			if a.IsValid(i) {
+				fn(a.Value(i))
+			}
+		}
+	case *array.String:
+		for i, l := 0, a.Len(); i < l; i++ {
+			if a.IsValid(i) {
+				fn(a.Value(i))
+			}
+		}
+	default:
+		return errors.Newf(codes.FailedPrecondition, "unsupported column type %v", arr.DataType())
+	}
+	return nil
+}
+
+// spaceSaving counts the most frequent values with a fixed number of counters.
+//
+// When all counters are taken, the counter with the lowest count is reassigned
+// to a new value and keeps its count as the error of the new value,
+// so the estimated count of a value is never lower than its true count
+// and exceeds it by at most its error.
+// Every value that occurs more than n/capacity times out of n values has a counter.
+type spaceSaving struct {
+	capacity int
+	counters map[interface{}]*counter
+	heap     counterHeap
+	seq      int
+}
+
+type counter struct {
+	value interface{}
+	count int64
+	error int64
+	// seq orders counters with the same count by the time they were assigned.
+	seq int
+	// index is the position of the counter in the heap.
+	index int
+}
+
+// newSpaceSaving creates a summary with the given number of counters.
+// The counters are allocated as values are added since the capacity
+// is derived from a user argument and may exceed the number of values.
+func newSpaceSaving(capacity int) *spaceSaving {
+	return &spaceSaving{
+		capacity: capacity,
+		counters: make(map[interface{}]*counter),
+	}
+}
+
+func (s *spaceSaving) add(v interface{}) {
+	if c, ok := s.counters[v]; ok {
+		c.count++
+		heap.Fix(&s.heap, c.index)
+		return
+	}
+
+	s.seq++
+	if len(s.heap) < s.capacity {
+		c := &counter{value: v, count: 1, seq: s.seq}
+		s.counters[v] = c
+		heap.Push(&s.heap, c)
+		return
+	}
+
+	c := s.heap[0]
+	delete(s.counters, c.value)
+	c.value, c.error, c.seq = v, c.count, s.seq
+	c.count++
+	s.counters[v] = c
+	heap.Fix(&s.heap, 0)
+}
+
+// top returns the k counters with the highest counts.
+func (s *spaceSaving) top(k int) []*counter {
+	counters := append([]*counter(nil), s.heap...)
+	sort.Slice(counters, func(i, j int) bool {
+		if counters[i].count != counters[j].count {
+			return counters[i].count > counters[j].count
+		}
+		return counters[i].seq < counters[j].seq
+	})
+	if len(counters) > k {
+		counters = counters[:k]
+	}
+	return counters
+}
+
+// counterHeap is a min-heap of counters ordered by count.
+type counterHeap []*counter
+
+func (h counterHeap) Len() int { return len(h) }
+func (h counterHeap) Less(i, j int) bool {
+	if h[i].count != h[j].count {
+		return h[i].count < h[j].count
+	}
+	// Replace the counter that was assigned first.
+	return h[i].seq < h[j].seq
+}
+func (h counterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *counterHeap) Push(x interface{}) {
+	c := x.(*counter)
+	c.index = len(*h)
+	*h = append(*h, c)
+}
+
+func (h *counterHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
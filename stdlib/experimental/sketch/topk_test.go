@@ -0,0 +1,156 @@
+package sketch_test
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/execute/executetest"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/querytest"
+	"github.com/influxdata/flux/runtime"
+	"github.com/influxdata/flux/stdlib/experimental/sketch"
+	"github.com/influxdata/flux/values"
+)
+
+func TestTopKOperation_Marshaling(t *testing.T) {
+	data := []byte(`{
+		"id":"topK",
+		"kind":"experimental/sketch.topK",
+		"spec":{
+			"column":"user",
+			"k":5,
+			"capacity":50
+		}
+	}`)
+	op := &flux.Operation{
+		ID: "topK",
+		Spec: &sketch.TopKOpSpec{
+			Column:   "user",
+			K:        5,
+			Capacity: 50,
+		},
+	}
+	querytest.OperationMarshalingTestHelper(t, data, op)
+}
+
+func TestTopK_Process(t *testing.T) {
+	input := func(keyCols []string) []flux.Table {
+		return []flux.Table{&executetest.Table{
+			KeyCols: keyCols,
+			ColMeta: []flux.ColMeta{
+				{Label: "_time", Type: flux.TTime},
+				{Label: "host", Type: flux.TString},
+				{Label: "_value", Type: flux.TString},
+			},
+			Data: [][]interface{}{
+				{execute.Time(1), "h", "a"},
+				{execute.Time(2), "h", "a"},
+				{execute.Time(3), "h", "b"},
+				{execute.Time(4), "h", nil},
+				{execute.Time(5), "h", "a"},
+				{execute.Time(6), "h", "c"},
+				{execute.Time(7), "h", "a"},
+				{execute.Time(8), "h", "b"},
+			},
+		}}
+	}
+	output := func(data ...[]interface{}) []*executetest.Table {
+		return []*executetest.Table{{
+			KeyCols: []string{"host"},
+			ColMeta: []flux.ColMeta{
+				{Label: "host", Type: flux.TString},
+				{Label: "_value", Type: flux.TString},
+				{Label: "count", Type: flux.TInt},
+				{Label: "error", Type: flux.TInt},
+			},
+			Data: data,
+		}}
+	}
+	testCases := []struct {
+		name    string
+		spec    *sketch.TopKProcedureSpec
+		keyCols []string
+		want    []*executetest.Table
+		wantErr error
+	}{
+		{
+			name: "exact",
+			spec: &sketch.TopKProcedureSpec{
+				Column:   "_value",
+				K:        2,
+				Capacity: 20,
+			},
+			keyCols: []string{"host"},
+			want: output(
+				[]interface{}{"h", "a", int64(4), int64(0)},
+				[]interface{}{"h", "b", int64(2), int64(0)},
+			),
+		},
+		{
+			name: "approximate",
+			spec: &sketch.TopKProcedureSpec{
+				Column:   "_value",
+				K:        2,
+				Capacity: 2,
+			},
+			keyCols: []string{"host"},
+			// The counter of b is reassigned to c and back to b,
+			// so b is overcounted by the count of c.
+			want: output(
+				[]interface{}{"h", "a", int64(4), int64(0)},
+				[]interface{}{"h", "b", int64(3), int64(2)},
+			),
+		},
+		{
+			name: "group key column",
+			spec: &sketch.TopKProcedureSpec{
+				Column:   "_value",
+				K:        2,
+				Capacity: 20,
+			},
+			keyCols: []string{"_value"},
+			wantErr: errors.New(codes.FailedPrecondition, "cannot aggregate columns that are part of the group key"),
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			executetest.ProcessTestHelper(
+				t,
+				input(tc.keyCols),
+				tc.want,
+				tc.wantErr,
+				func(d execute.Dataset, c execute.TableBuilderCache) execute.Transformation {
+					return sketch.NewTopKTransformation(d, c, tc.spec)
+				},
+			)
+		})
+	}
+}
+
+func TestTopK_InvalidK(t *testing.T) {
+	topK, ok := runtime.LookupBuiltin("experimental/sketch", "topK")
+	if !ok {
+		t.Fatal("missing builtin topK")
+	}
+	call := func(k int64) error {
+		args := values.NewObjectWithValues(map[string]values.Value{
+			flux.TablesParameter: &flux.TableObject{},
+			"k":                  values.NewInt(k),
+		})
+		_, err := topK.Function().Call(context.Background(), args)
+		return err
+	}
+	if err := call(1 << 20); err != nil {
+		t.Fatalf("unexpected error for the maximum k: %s", err)
+	}
+	for _, k := range []int64{0, -1, 1<<20 + 1, math.MaxInt64} {
+		if want, got := codes.Invalid, errors.Code(call(k)); want != got {
+			t.Errorf("unexpected error code for k %d -want/+got:\n\t- %v\n\t+ %v", k, want, got)
+		}
+	}
+}
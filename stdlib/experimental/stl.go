@@ -0,0 +1,319 @@
+package experimental
+
+import (
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/internal/errors"
+	fluxmemory "github.com/influxdata/flux/memory"
+	"github.com/influxdata/flux/plan"
+	"github.com/influxdata/flux/runtime"
+	"github.com/influxdata/flux/stdlib/universe/holt_winters"
+	"github.com/influxdata/flux/values"
+)
+
+const STLKind = "experimental-stl"
+
+const (
+	stlDefaultSeasonalWindow = 7
+
+	stlTrendColumn        = "trend"
+	stlSeasonalColumn     = "seasonal"
+	stlResidualColumn     = "residual"
+	stlAnomalyScoreColumn = "anomalyScore"
+)
+
+type STLOpSpec struct {
+	Column         string        `json:"column"`
+	TimeColumn     string        `json:"timeColumn"`
+	Seasonality    int64         `json:"seasonality"`
+	Interval       flux.Duration `json:"interval"`
+	SeasonalWindow int64         `json:"seasonalWindow"`
+	TrendWindow    int64         `json:"trendWindow"`
+	Robust         bool          `json:"robust"`
+}
+
+func init() {
+	stlSignature := runtime.MustLookupBuiltinType("experimental", "stl")
+	runtime.RegisterPackageValue("experimental", "stl", flux.MustValue(flux.FunctionValue("stl", createSTLOpSpec, stlSignature)))
+	flux.RegisterOpSpec(STLKind, newSTLOp)
+	plan.RegisterProcedureSpec(STLKind, newSTLProcedure, STLKind)
+	execute.RegisterTransformation(STLKind, createSTLTransformation)
+}
+
+func createSTLOpSpec(args flux.Arguments, a *flux.Administration) (flux.OperationSpec, error) {
+	if err := a.AddParentFromArgs(args); err != nil {
+		return nil, err
+	}
+	spec := new(STLOpSpec)
+	if s, err := args.GetRequiredInt("seasonality"); err != nil {
+		return nil, err
+	} else if s < 2 {
+		return nil, errors.Newf(codes.Invalid, "seasonality must be at least 2, got %d", s)
+	} else {
+		spec.Seasonality = s
+	}
+	if i, err := args.GetRequiredDuration("interval"); err != nil {
+		return nil, err
+	} else if !i.IsPositive() {
+		return nil, errors.Newf(codes.Invalid, "interval must be positive, got %v", i)
+	} else {
+		spec.Interval = i
+	}
+	if col, ok, err := args.GetString("column"); err != nil {
+		return nil, err
+	} else if ok {
+		spec.Column = col
+	} else {
+		spec.Column = execute.DefaultValueColLabel
+	}
+	if col, ok, err := args.GetString("timeColumn"); err != nil {
+		return nil, err
+	} else if ok {
+		spec.TimeColumn = col
+	} else {
+		spec.TimeColumn = execute.DefaultTimeColLabel
+	}
+	if w, ok, err := args.GetInt("seasonalWindow"); err != nil {
+		return nil, err
+	} else if ok {
+		if w < 3 || w%2 == 0 {
+			return nil, errors.Newf(codes.Invalid, "seasonalWindow must be an odd number of at least 3, got %d", w)
+		}
+		spec.SeasonalWindow = w
+	} else {
+		spec.SeasonalWindow = stlDefaultSeasonalWindow
+	}
+	if w, ok, err := args.GetInt("trendWindow"); err != nil {
+		return nil, err
+	} else if ok {
+		if w < 3 || w%2 == 0 {
+			return nil, errors.Newf(codes.Invalid, "trendWindow must be an odd number of at least 3, got %d", w)
+		}
+		spec.TrendWindow = w
+	}
+	if r, ok, err := args.GetBool("robust"); err != nil {
+		return nil, err
+	} else if ok {
+		spec.Robust = r
+	} else {
+		spec.Robust = true
+	}
+	return spec, nil
+}
+
+func newSTLOp() flux.OperationSpec {
+	return new(STLOpSpec)
+}
+
+func (s *STLOpSpec) Kind() flux.OperationKind {
+	return STLKind
+}
+
+type STLProcedureSpec struct {
+	plan.DefaultCost
+	Column         string
+	TimeColumn     string
+	Seasonality    int64
+	Interval       flux.Duration
+	SeasonalWindow int64
+	TrendWindow    int64
+	Robust         bool
+}
+
+func newSTLProcedure(qs flux.OperationSpec, pa plan.Administration) (plan.ProcedureSpec, error) {
+	spec, ok := qs.(*STLOpSpec)
+	if !ok {
+		return nil, errors.Newf(codes.Internal, "invalid spec type %T", qs)
+	}
+	return &STLProcedureSpec{
+		Column:         spec.Column,
+		TimeColumn:     spec.TimeColumn,
+		Seasonality:    spec.Seasonality,
+		Interval:       spec.Interval,
+		SeasonalWindow: spec.SeasonalWindow,
+		TrendWindow:    spec.TrendWindow,
+		Robust:         spec.Robust,
+	}, nil
+}
+
+func (s *STLProcedureSpec) Kind() plan.ProcedureKind {
+	return STLKind
+}
+
+func (s *STLProcedureSpec) Copy() plan.ProcedureSpec {
+	ns := new(STLProcedureSpec)
+	*ns = *s
+	return ns
+}
+
+// TriggerSpec implements plan.TriggerAwareProcedureSpec
+func (s *STLProcedureSpec) TriggerSpec() plan.TriggerSpec {
+	return plan.NarrowTransformationTriggerSpec{}
+}
+
+func createSTLTransformation(id execute.DatasetID, mode execute.AccumulationMode, spec plan.ProcedureSpec, a execute.Administration) (execute.Transformation, execute.Dataset, error) {
+	s, ok := spec.(*STLProcedureSpec)
+	if !ok {
+		return nil, nil, errors.Newf(codes.Internal, "invalid spec type %T", spec)
+	}
+	cache := execute.NewTableBuilderCache(a.Allocator())
+	d := execute.NewDataset(id, mode, cache)
+	t := NewSTLTransformation(d, cache, a.Allocator(), s)
+	return t, d, nil
+}
+
+type stlTransformation struct {
+	execute.ExecutionNode
+	d     execute.Dataset
+	cache execute.TableBuilderCache
+	alloc *fluxmemory.Allocator
+
+	column         string
+	timeColumn     string
+	seasonality    int64
+	interval       values.Duration
+	seasonalWindow int64
+	trendWindow    int64
+	robust         bool
+}
+
+// NewSTLTransformation creates a transformation that decomposes the values of each table
+// into trend, seasonal and residual components and scores every value by its residual.
+//
+// Like holtWinters, the values are divided into evenly spaced time buckets of the given interval
+// and each output row is the first value of a bucket.
+func NewSTLTransformation(d execute.Dataset, cache execute.TableBuilderCache, alloc *fluxmemory.Allocator, spec *STLProcedureSpec) *stlTransformation {
+	return &stlTransformation{
+		d:              d,
+		cache:          cache,
+		alloc:          alloc,
+		column:         spec.Column,
+		timeColumn:     spec.TimeColumn,
+		seasonality:    spec.Seasonality,
+		interval:       values.Duration(spec.Interval),
+		seasonalWindow: spec.SeasonalWindow,
+		trendWindow:    spec.TrendWindow,
+		robust:         spec.Robust,
+	}
+}
+
+func (t *stlTransformation) Process(id execute.DatasetID, tbl flux.Table) error {
+	builder, created := t.cache.TableBuilder(tbl.Key())
+	if !created {
+		return errors.Newf(codes.FailedPrecondition, "stl found duplicate table with key: %v", tbl.Key())
+	}
+	cols := tbl.Cols()
+	timeIdx := execute.ColIdx(t.timeColumn, cols)
+	if timeIdx < 0 {
+		return errors.Newf(codes.FailedPrecondition, "cannot find time column %s", t.timeColumn)
+	}
+	colIdx := execute.ColIdx(t.column, cols)
+	if colIdx < 0 {
+		return errors.Newf(codes.FailedPrecondition, "cannot find column %s", t.column)
+	}
+	typ := cols[colIdx].Type
+	if typ != flux.TInt &&
+		typ != flux.TUInt &&
+		typ != flux.TFloat {
+		return errors.Newf(codes.FailedPrecondition, "stl can work only on numerical types, got %s", typ.String())
+	}
+
+	// Building schema.
+	if err := execute.AddTableKeyCols(tbl.Key(), builder); err != nil {
+		return err
+	}
+	outCols := []flux.ColMeta{
+		{Label: t.timeColumn, Type: flux.TTime},
+		{Label: t.column, Type: flux.TFloat},
+		{Label: stlTrendColumn, Type: flux.TFloat},
+		{Label: stlSeasonalColumn, Type: flux.TFloat},
+		{Label: stlResidualColumn, Type: flux.TFloat},
+		{Label: stlAnomalyScoreColumn, Type: flux.TFloat},
+	}
+	outIdx := make([]int, len(outCols))
+	for i, c := range outCols {
+		if tbl.Key().HasCol(c.Label) {
+			return errors.Newf(codes.FailedPrecondition, "stl cannot output column %q, it is part of the group key", c.Label)
+		}
+		j, err := builder.AddCol(c)
+		if err != nil {
+			return err
+		}
+		outIdx[i] = j
+	}
+
+	vs, start, _, err := holt_winters.CleanData(tbl, colIdx, timeIdx, t.interval, t.alloc)
+	if err != nil {
+		return err
+	}
+	defer vs.Release()
+	n := vs.Len()
+	if n == 0 {
+		return nil
+	}
+	if min := 2 * int(t.seasonality); n < min {
+		return errors.Newf(codes.FailedPrecondition, "stl requires at least two seasons of %d values, got %d values", t.seasonality, n)
+	}
+
+	stl := holt_winters.NewSTL(int(t.seasonality), int(t.seasonalWindow), int(t.trendWindow), t.robust)
+	trend, seasonal, residual := stl.Do(vs)
+
+	// Missing values have no residual and are not scored.
+	var valid []float64
+	for i := 0; i < n; i++ {
+		if vs.IsValid(i) {
+			valid = append(valid, residual[i])
+		}
+	}
+	scores := holt_winters.AnomalyScores(valid)
+
+	ts := start
+	for i, j := 0, 0; i < n; i++ {
+		if err := builder.AppendTime(outIdx[0], ts); err != nil {
+			return err
+		}
+		ts = ts.Add(t.interval)
+		if err := builder.AppendFloat(outIdx[2], trend[i]); err != nil {
+			return err
+		}
+		if err := builder.AppendFloat(outIdx[3], seasonal[i]); err != nil {
+			return err
+		}
+		if vs.IsNull(i) {
+			for _, k := range []int{outIdx[1], outIdx[4], outIdx[5]} {
+				if err := builder.AppendNil(k); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := builder.AppendFloat(outIdx[1], vs.Value(i)); err != nil {
+			return err
+		}
+		if err := builder.AppendFloat(outIdx[4], residual[i]); err != nil {
+			return err
+		}
+		if err := builder.AppendFloat(outIdx[5], scores[j]); err != nil {
+			return err
+		}
+		j++
+	}
+	return execute.AppendKeyValuesN(tbl.Key(), builder, n)
+}
+
+func (t *stlTransformation) RetractTable(id execute.DatasetID, key flux.GroupKey) error {
+	return t.d.RetractTable(key)
+}
+
+func (t *stlTransformation) UpdateWatermark(id execute.DatasetID, mark execute.Time) error {
+	return t.d.UpdateWatermark(mark)
+}
+
+func (t *stlTransformation) UpdateProcessingTime(id execute.DatasetID, pt execute.Time) error {
+	return t.d.UpdateProcessingTime(pt)
+}
+
+func (t *stlTransformation) Finish(id execute.DatasetID, err error) {
+	t.d.Finish(err)
+}
@@ -0,0 +1,211 @@
+package experimental_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/execute/executetest"
+	"github.com/influxdata/flux/memory"
+	"github.com/influxdata/flux/plan"
+	"github.com/influxdata/flux/querytest"
+	"github.com/influxdata/flux/stdlib/experimental"
+)
+
+func TestSTL_Marshaling(t *testing.T) {
+	data := []byte(`{"id":"stl","kind":"experimental-stl","spec":{"column":"v","timeColumn":"t","seasonality":24,"interval":"1h","seasonalWindow":9,"trendWindow":37,"robust":true}}`)
+	op := &flux.Operation{
+		ID: "stl",
+		Spec: &experimental.STLOpSpec{
+			Column:         "v",
+			TimeColumn:     "t",
+			Seasonality:    24,
+			Interval:       flux.ConvertDuration(time.Hour),
+			SeasonalWindow: 9,
+			TrendWindow:    37,
+			Robust:         true,
+		},
+	}
+
+	querytest.OperationMarshalingTestHelper(t, data, op)
+}
+
+func TestSTL_PassThrough(t *testing.T) {
+	executetest.TransformationPassThroughTestHelper(t, func(d execute.Dataset, c execute.TableBuilderCache) execute.Transformation {
+		s := experimental.NewSTLTransformation(
+			d,
+			c,
+			&memory.Allocator{},
+			&experimental.STLProcedureSpec{},
+		)
+		return s
+	})
+}
+
+// stlSeries returns a table with a linear trend, a seasonal pattern of four values
+// and a little noise at every minute. The value at outlier is increased by 20
+// and there is no value at missing.
+func stlSeries(n, outlier, missing int) *executetest.Table {
+	pattern := []float64{3, -1, -4, 2}
+	tbl := &executetest.Table{
+		KeyCols: []string{"t0"},
+		ColMeta: []flux.ColMeta{
+			{Label: "_time", Type: flux.TTime},
+			{Label: "_value", Type: flux.TFloat},
+			{Label: "t0", Type: flux.TString},
+		},
+	}
+	for i := 0; i < n; i++ {
+		if i == missing {
+			continue
+		}
+		v := 10 + 0.5*float64(i) + pattern[i%len(pattern)] + 0.3*math.Sin(1.7*float64(i))
+		if i == outlier {
+			v += 20
+		}
+		tbl.Data = append(tbl.Data, []interface{}{
+			execute.Time(int64(i) * int64(time.Minute)),
+			v,
+			"a",
+		})
+	}
+	return tbl
+}
+
+func TestSTL_Process(t *testing.T) {
+	const (
+		n       = 24
+		outlier = 13
+		missing = 6
+	)
+	for _, robust := range []bool{false, true} {
+		d := executetest.NewDataset(executetest.RandomDatasetID())
+		c := execute.NewTableBuilderCache(executetest.UnlimitedAllocator)
+		c.SetTriggerSpec(plan.DefaultTriggerSpec)
+		tx := experimental.NewSTLTransformation(d, c, &memory.Allocator{}, &experimental.STLProcedureSpec{
+			Column:         "_value",
+			TimeColumn:     "_time",
+			Seasonality:    4,
+			Interval:       flux.ConvertDuration(time.Minute),
+			SeasonalWindow: 7,
+			Robust:         robust,
+		})
+		if err := tx.Process(executetest.RandomDatasetID(), stlSeries(n, outlier, missing)); err != nil {
+			t.Fatal(err)
+		}
+		got, err := executetest.TablesFromCache(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 {
+			t.Fatalf("expected one table, got %d", len(got))
+		}
+		tbl := got[0]
+		wantCols := []string{"t0", "_time", "_value", "trend", "seasonal", "residual", "anomalyScore"}
+		if len(tbl.ColMeta) != len(wantCols) {
+			t.Fatalf("unexpected columns: %v", tbl.ColMeta)
+		}
+		for i, label := range wantCols {
+			if tbl.ColMeta[i].Label != label {
+				t.Fatalf("unexpected column %d: got %q, want %q", i, tbl.ColMeta[i].Label, label)
+			}
+		}
+		if len(tbl.Data) != n {
+			t.Fatalf("expected %d rows, got %d", n, len(tbl.Data))
+		}
+
+		maxScore, maxIdx := -1.0, -1
+		for i, row := range tbl.Data {
+			if got, want := row[1], execute.Time(int64(i)*int64(time.Minute)); got != want {
+				t.Errorf("unexpected time in row %d: got %v, want %v", i, got, want)
+			}
+			if i == missing {
+				if row[2] != nil || row[5] != nil || row[6] != nil {
+					t.Errorf("expected null value, residual and score in row %d, got %v", i, row)
+				}
+				continue
+			}
+			v, trend, seasonal, residual := row[2].(float64), row[3].(float64), row[4].(float64), row[5].(float64)
+			if math.Abs(trend+seasonal+residual-v) > 1e-9 {
+				t.Errorf("components of row %d do not add up to %v: %v + %v + %v", i, v, trend, seasonal, residual)
+			}
+			if score := row[6].(float64); score > maxScore {
+				maxScore, maxIdx = score, i
+			}
+		}
+		if maxIdx != outlier {
+			t.Errorf("robust=%v: expected the highest anomaly score at row %d, got row %d", robust, outlier, maxIdx)
+		}
+		if robust {
+			if r := tbl.Data[outlier][5].(float64); math.Abs(r-20) > 2 {
+				t.Errorf("expected a residual of about 20 for the outlier, got %v", r)
+			}
+			if maxScore < 10 {
+				t.Errorf("expected an anomaly score of at least 10 for the outlier, got %v", maxScore)
+			}
+		}
+	}
+}
+
+func TestSTL_Errors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		spec    *experimental.STLProcedureSpec
+		data    []flux.Table
+		wantErr error
+	}{
+		{
+			name: "too few values",
+			spec: &experimental.STLProcedureSpec{
+				Column:         "_value",
+				TimeColumn:     "_time",
+				Seasonality:    8,
+				Interval:       flux.ConvertDuration(time.Minute),
+				SeasonalWindow: 7,
+			},
+			data:    []flux.Table{stlSeries(12, -1, -1)},
+			wantErr: errors.New("stl requires at least two seasons of 8 values, got 12 values"),
+		},
+		{
+			name: "non numeric column",
+			spec: &experimental.STLProcedureSpec{
+				Column:         "t0",
+				TimeColumn:     "_time",
+				Seasonality:    4,
+				Interval:       flux.ConvertDuration(time.Minute),
+				SeasonalWindow: 7,
+			},
+			data:    []flux.Table{stlSeries(12, -1, -1)},
+			wantErr: errors.New("stl can work only on numerical types, got string"),
+		},
+		{
+			name: "missing column",
+			spec: &experimental.STLProcedureSpec{
+				Column:         "x",
+				TimeColumn:     "_time",
+				Seasonality:    4,
+				Interval:       flux.ConvertDuration(time.Minute),
+				SeasonalWindow: 7,
+			},
+			data:    []flux.Table{stlSeries(12, -1, -1)},
+			wantErr: errors.New("cannot find column x"),
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			executetest.ProcessTestHelper(
+				t,
+				tc.data,
+				nil,
+				tc.wantErr,
+				func(d execute.Dataset, c execute.TableBuilderCache) execute.Transformation {
+					return experimental.NewSTLTransformation(d, c, &memory.Allocator{}, tc.spec)
+				},
+			)
+		})
+	}
+}
@@ -0,0 +1,424 @@
+package experimental
+
+import (
+	"sort"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/execute/table"
+	"github.com/influxdata/flux/internal/arrowutil"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/interpreter"
+	"github.com/influxdata/flux/plan"
+	"github.com/influxdata/flux/runtime"
+	"github.com/influxdata/flux/semantic"
+	"github.com/influxdata/flux/values"
+)
+
+const WindowFunctionKind = "experimental-windowFunction"
+
+// The window functions supported by the window function transformation.
+// Each one is exposed as a Flux function of the same name.
+const (
+	LagFunction         = "lag"
+	LeadFunction        = "lead"
+	RowNumberFunction   = "rowNumber"
+	RankFunction        = "rank"
+	DenseRankFunction   = "denseRank"
+	PercentRankFunction = "percentRank"
+	NtileFunction       = "ntile"
+)
+
+type WindowFunctionOpSpec struct {
+	Function string       `json:"function"`
+	OrderBy  []string     `json:"orderBy"`
+	Desc     bool         `json:"desc"`
+	As       string       `json:"as"`
+	Column   string       `json:"column,omitempty"`
+	Offset   int64        `json:"offset,omitempty"`
+	Default  values.Value `json:"default,omitempty"`
+	N        int64        `json:"n,omitempty"`
+}
+
+func init() {
+	for _, name := range []string{
+		LagFunction,
+		LeadFunction,
+		RowNumberFunction,
+		RankFunction,
+		DenseRankFunction,
+		PercentRankFunction,
+		NtileFunction,
+	} {
+		signature := runtime.MustLookupBuiltinType("experimental", name)
+		runtime.RegisterPackageValue("experimental", name, flux.MustValue(flux.FunctionValue(name, newWindowFunctionCreator(name), signature)))
+	}
+	flux.RegisterOpSpec(WindowFunctionKind, newWindowFunctionOp)
+	plan.RegisterProcedureSpec(WindowFunctionKind, newWindowFunctionProcedure, WindowFunctionKind)
+	execute.RegisterTransformation(WindowFunctionKind, createWindowFunctionTransformation)
+}
+
+// newWindowFunctionCreator returns the function that creates
+// the operation spec of the named window function.
+func newWindowFunctionCreator(function string) flux.CreateOperationSpec {
+	return func(args flux.Arguments, a *flux.Administration) (flux.OperationSpec, error) {
+		if err := a.AddParentFromArgs(args); err != nil {
+			return nil, err
+		}
+
+		spec := &WindowFunctionOpSpec{
+			Function: function,
+			OrderBy:  []string{execute.DefaultTimeColLabel},
+		}
+
+		if orderBy, ok, err := args.GetArray("orderBy", semantic.String); err != nil {
+			return nil, err
+		} else if ok {
+			if spec.OrderBy, err = interpreter.ToStringArray(orderBy); err != nil {
+				return nil, err
+			}
+		}
+
+		if desc, ok, err := args.GetBool("desc"); err != nil {
+			return nil, err
+		} else if ok {
+			spec.Desc = desc
+		}
+
+		if label, ok, err := args.GetString("as"); err != nil {
+			return nil, err
+		} else if ok {
+			spec.As = label
+		} else {
+			spec.As = function
+		}
+
+		switch function {
+		case LagFunction, LeadFunction:
+			if label, ok, err := args.GetString("column"); err != nil {
+				return nil, err
+			} else if ok {
+				spec.Column = label
+			} else {
+				spec.Column = execute.DefaultValueColLabel
+			}
+
+			if offset, ok, err := args.GetInt("offset"); err != nil {
+				return nil, err
+			} else if ok {
+				if offset < 0 {
+					return nil, errors.New(codes.Invalid, "offset must be non-negative")
+				}
+				spec.Offset = offset
+			} else {
+				spec.Offset = 1
+			}
+
+			if v, ok := args.Get("default"); ok {
+				switch v.Type().Nature() {
+				case semantic.Bool, semantic.Int, semantic.UInt, semantic.Float, semantic.String, semantic.Time:
+					spec.Default = v
+				default:
+					return nil, errors.New(codes.Invalid, "default must be a valid primitive type (bool, int, uint, float, string, time)")
+				}
+			}
+		case NtileFunction:
+			n, err := args.GetRequiredInt("n")
+			if err != nil {
+				return nil, err
+			}
+			if n <= 0 {
+				return nil, errors.New(codes.Invalid, "n must be greater than zero")
+			}
+			spec.N = n
+		}
+		return spec, nil
+	}
+}
+
+func newWindowFunctionOp() flux.OperationSpec {
+	return new(WindowFunctionOpSpec)
+}
+
+func (s *WindowFunctionOpSpec) Kind() flux.OperationKind {
+	return WindowFunctionKind
+}
+
+type WindowFunctionProcedureSpec struct {
+	plan.DefaultCost
+	Function string
+	OrderBy  []string
+	Desc     bool
+	As       string
+	Column   string
+	Offset   int64
+	Default  values.Value
+	N        int64
+}
+
+func newWindowFunctionProcedure(qs flux.OperationSpec, pa plan.Administration) (plan.ProcedureSpec, error) {
+	spec, ok := qs.(*WindowFunctionOpSpec)
+	if !ok {
+		return nil, errors.Newf(codes.Internal, "invalid spec type %T", qs)
+	}
+
+	return &WindowFunctionProcedureSpec{
+		Function: spec.Function,
+		OrderBy:  spec.OrderBy,
+		Desc:     spec.Desc,
+		As:       spec.As,
+		Column:   spec.Column,
+		Offset:   spec.Offset,
+		Default:  spec.Default,
+		N:        spec.N,
+	}, nil
+}
+
+func (s *WindowFunctionProcedureSpec) Kind() plan.ProcedureKind {
+	return WindowFunctionKind
+}
+
+func (s *WindowFunctionProcedureSpec) Copy() plan.ProcedureSpec {
+	ns := *s
+	ns.OrderBy = append([]string(nil), s.OrderBy...)
+	return &ns
+}
+
+func createWindowFunctionTransformation(id execute.DatasetID, mode execute.AccumulationMode, spec plan.ProcedureSpec, a execute.Administration) (execute.Transformation, execute.Dataset, error) {
+	s, ok := spec.(*WindowFunctionProcedureSpec)
+	if !ok {
+		return nil, nil, errors.Newf(codes.Internal, "invalid spec type %T", spec)
+	}
+	cache := execute.NewTableBuilderCache(a.Allocator())
+	d := execute.NewDataset(id, mode, cache)
+	t := NewWindowFunctionTransformation(d, cache, s)
+	return t, d, nil
+}
+
+type windowFunctionTransformation struct {
+	execute.ExecutionNode
+	d     execute.Dataset
+	cache execute.TableBuilderCache
+	spec  WindowFunctionProcedureSpec
+
+	compare arrowutil.CompareFunc
+}
+
+// NewWindowFunctionTransformation creates a transformation that computes
+// a window function over the rows of each table ordered by the order columns
+// and stores the result in a new column.
+// The rows keep their input order.
+func NewWindowFunctionTransformation(d execute.Dataset, cache execute.TableBuilderCache, spec *WindowFunctionProcedureSpec) *windowFunctionTransformation {
+	t := &windowFunctionTransformation{
+		d:       d,
+		cache:   cache,
+		spec:    *spec,
+		compare: arrowutil.Compare,
+	}
+	if spec.Desc {
+		t.compare = arrowutil.CompareDesc
+	}
+	return t
+}
+
+func (t *windowFunctionTransformation) RetractTable(id execute.DatasetID, key flux.GroupKey) error {
+	return t.d.RetractTable(key)
+}
+
+// windowRow locates a row within a buffered table.
+type windowRow struct {
+	buffer int
+	row    int
+}
+
+func (t *windowFunctionTransformation) Process(id execute.DatasetID, tbl flux.Table) error {
+	if tbl.Key().HasCol(t.spec.As) {
+		return errors.Newf(codes.FailedPrecondition, "cannot overwrite group key column %q", t.spec.As)
+	}
+	orderCols := make([]int, len(t.spec.OrderBy))
+	for i, label := range t.spec.OrderBy {
+		if orderCols[i] = execute.ColIdx(label, tbl.Cols()); orderCols[i] < 0 {
+			return errors.Newf(codes.FailedPrecondition, "missing order column %q", label)
+		}
+	}
+	typ, err := t.resultType(tbl.Cols())
+	if err != nil {
+		return err
+	}
+
+	buf, err := execute.CopyTable(tbl)
+	if err != nil {
+		return err
+	}
+	defer buf.Done()
+
+	var rows []windowRow
+	for i, n := 0, buf.BufferN(); i < n; i++ {
+		for j, l := 0, buf.Buffer(i).Len(); j < l; j++ {
+			rows = append(rows, windowRow{buffer: i, row: j})
+		}
+	}
+	order := make([]int, len(rows))
+	for i := range order {
+		order[i] = i
+	}
+	compare := func(x, y windowRow) int {
+		for _, j := range orderCols {
+			l := table.Values(buf.Buffer(x.buffer), j)
+			r := table.Values(buf.Buffer(y.buffer), j)
+			if cmp := t.compare(l, r, x.row, y.row); cmp != 0 {
+				return cmp
+			}
+		}
+		return 0
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return compare(rows[order[i]], rows[order[j]]) < 0
+	})
+
+	results := t.compute(buf, rows, order, compare)
+	return t.appendRows(tbl.Key(), buf, rows, results, typ)
+}
+
+// resultType returns the type of the column that stores the window function result.
+func (t *windowFunctionTransformation) resultType(cols []flux.ColMeta) (flux.ColType, error) {
+	switch t.spec.Function {
+	case LagFunction, LeadFunction:
+		idx := execute.ColIdx(t.spec.Column, cols)
+		if idx < 0 {
+			return flux.TInvalid, errors.Newf(codes.FailedPrecondition, "missing column %q", t.spec.Column)
+		}
+		typ := cols[idx].Type
+		if t.spec.Default != nil {
+			if dt := execute.ConvertFromKind(t.spec.Default.Type().Nature()); dt != typ {
+				return flux.TInvalid, errors.Newf(codes.FailedPrecondition, "default of type %v does not match column %q of type %v", dt, t.spec.Column, typ)
+			}
+		}
+		return typ, nil
+	case PercentRankFunction:
+		return flux.TFloat, nil
+	default:
+		return flux.TInt, nil
+	}
+}
+
+// compute returns the window function result for every row.
+// The order lists the indexes of the rows sorted by the order columns.
+func (t *windowFunctionTransformation) compute(buf flux.BufferedTable, rows []windowRow, order []int, compare func(x, y windowRow) int) []values.Value {
+	n := len(rows)
+	results := make([]values.Value, n)
+
+	switch t.spec.Function {
+	case LagFunction, LeadFunction:
+		colIdx := execute.ColIdx(t.spec.Column, buf.Cols())
+		offset := int(t.spec.Offset)
+		if t.spec.Function == LagFunction {
+			offset = -offset
+		}
+		for pos, i := range order {
+			if src := pos + offset; src >= 0 && src < n {
+				r := rows[order[src]]
+				results[i] = execute.ValueForRow(buf.Buffer(r.buffer), r.row, colIdx)
+			} else if t.spec.Default != nil {
+				results[i] = t.spec.Default
+			} else {
+				results[i] = values.Null
+			}
+		}
+	case RowNumberFunction:
+		for pos, i := range order {
+			results[i] = values.NewInt(int64(pos + 1))
+		}
+	case RankFunction, DenseRankFunction, PercentRankFunction:
+		var rank, dense int64
+		for pos, i := range order {
+			// Rows that are equal in all order columns share the same rank.
+			if pos == 0 || compare(rows[order[pos-1]], rows[i]) != 0 {
+				rank = int64(pos + 1)
+				dense++
+			}
+			switch t.spec.Function {
+			case RankFunction:
+				results[i] = values.NewInt(rank)
+			case DenseRankFunction:
+				results[i] = values.NewInt(dense)
+			default:
+				var pr float64
+				if n > 1 {
+					pr = float64(rank-1) / float64(n-1)
+				}
+				results[i] = values.NewFloat(pr)
+			}
+		}
+	case NtileFunction:
+		// The rows are split into n buckets whose sizes differ by at most one,
+		// with the larger buckets first.
+		buckets := int(t.spec.N)
+		size, extra := n/buckets, n%buckets
+		bucket, left := 1, size
+		if extra > 0 {
+			left++
+		}
+		for _, i := range order {
+			if left == 0 {
+				bucket++
+				left = size
+				if bucket <= extra {
+					left++
+				}
+			}
+			results[i] = values.NewInt(int64(bucket))
+			left--
+		}
+	}
+	return results
+}
+
+func (t *windowFunctionTransformation) appendRows(key flux.GroupKey, buf flux.BufferedTable, rows []windowRow, results []values.Value, typ flux.ColType) error {
+	builder, created := t.cache.TableBuilder(key)
+	if !created {
+		return errors.Newf(codes.FailedPrecondition, "found duplicate table with key: %v", key)
+	}
+
+	// The result replaces an existing column with the same label.
+	var colMap []int
+	for j, c := range buf.Cols() {
+		if c.Label == t.spec.As {
+			continue
+		}
+		if _, err := builder.AddCol(c); err != nil {
+			return err
+		}
+		colMap = append(colMap, j)
+	}
+	resultIdx, err := builder.AddCol(flux.ColMeta{Label: t.spec.As, Type: typ})
+	if err != nil {
+		return err
+	}
+
+	for i, r := range rows {
+		cr := buf.Buffer(r.buffer)
+		for j, src := range colMap {
+			if err := builder.AppendValue(j, execute.ValueForRow(cr, r.row, src)); err != nil {
+				return err
+			}
+		}
+		if err := builder.AppendValue(resultIdx, results[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *windowFunctionTransformation) UpdateWatermark(id execute.DatasetID, mark execute.Time) error {
+	return t.d.UpdateWatermark(mark)
+}
+
+func (t *windowFunctionTransformation) UpdateProcessingTime(id execute.DatasetID, pt execute.Time) error {
+	return t.d.UpdateProcessingTime(pt)
+}
+
+func (t *windowFunctionTransformation) Finish(id execute.DatasetID, err error) {
+	t.d.Finish(err)
+}
@@ -0,0 +1,224 @@
+package experimental_test
+
+import (
+	"testing"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/execute/executetest"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/stdlib/experimental"
+	"github.com/influxdata/flux/values"
+)
+
+func TestWindowFunction_Process(t *testing.T) {
+	input := func() []flux.Table {
+		return []flux.Table{&executetest.Table{
+			KeyCols: []string{"id"},
+			ColMeta: []flux.ColMeta{
+				{Label: "id", Type: flux.TString},
+				{Label: "_time", Type: flux.TTime},
+				{Label: "_value", Type: flux.TInt},
+			},
+			Data: [][]interface{}{
+				{"a", execute.Time(3), int64(20)},
+				{"a", execute.Time(1), int64(10)},
+				{"a", execute.Time(2), int64(30)},
+				{"a", execute.Time(4), int64(20)},
+				{"a", execute.Time(5), nil},
+			},
+		}}
+	}
+	output := func(typ flux.ColType, label string, results ...interface{}) []*executetest.Table {
+		tbl := &executetest.Table{
+			KeyCols: []string{"id"},
+			ColMeta: []flux.ColMeta{
+				{Label: "id", Type: flux.TString},
+				{Label: "_time", Type: flux.TTime},
+				{Label: "_value", Type: flux.TInt},
+				{Label: label, Type: typ},
+			},
+		}
+		for i, row := range input()[0].(*executetest.Table).Data {
+			tbl.Data = append(tbl.Data, append(row, results[i]))
+		}
+		return []*executetest.Table{tbl}
+	}
+	testCases := []struct {
+		name string
+		spec *experimental.WindowFunctionProcedureSpec
+		want []*executetest.Table
+	}{
+		{
+			name: "lag",
+			spec: &experimental.WindowFunctionProcedureSpec{
+				Function: experimental.LagFunction,
+				OrderBy:  []string{"_time"},
+				As:       "lag",
+				Column:   "_value",
+				Offset:   1,
+			},
+			want: output(flux.TInt, "lag", int64(30), nil, int64(10), int64(20), int64(20)),
+		},
+		{
+			name: "lead with default",
+			spec: &experimental.WindowFunctionProcedureSpec{
+				Function: experimental.LeadFunction,
+				OrderBy:  []string{"_time"},
+				As:       "lead",
+				Column:   "_value",
+				Offset:   2,
+				Default:  values.NewInt(-1),
+			},
+			want: output(flux.TInt, "lead", nil, int64(20), int64(20), int64(-1), int64(-1)),
+		},
+		{
+			name: "row number descending",
+			spec: &experimental.WindowFunctionProcedureSpec{
+				Function: experimental.RowNumberFunction,
+				OrderBy:  []string{"_time"},
+				Desc:     true,
+				As:       "rowNumber",
+			},
+			want: output(flux.TInt, "rowNumber", int64(3), int64(5), int64(4), int64(2), int64(1)),
+		},
+		{
+			name: "rank",
+			spec: &experimental.WindowFunctionProcedureSpec{
+				Function: experimental.RankFunction,
+				OrderBy:  []string{"_value"},
+				As:       "rank",
+			},
+			want: output(flux.TInt, "rank", int64(3), int64(2), int64(5), int64(3), int64(1)),
+		},
+		{
+			name: "dense rank",
+			spec: &experimental.WindowFunctionProcedureSpec{
+				Function: experimental.DenseRankFunction,
+				OrderBy:  []string{"_value"},
+				As:       "denseRank",
+			},
+			want: output(flux.TInt, "denseRank", int64(3), int64(2), int64(4), int64(3), int64(1)),
+		},
+		{
+			name: "percent rank",
+			spec: &experimental.WindowFunctionProcedureSpec{
+				Function: experimental.PercentRankFunction,
+				OrderBy:  []string{"_value"},
+				As:       "percentRank",
+			},
+			want: output(flux.TFloat, "percentRank", 0.5, 0.25, 1.0, 0.5, 0.0),
+		},
+		{
+			name: "ntile",
+			spec: &experimental.WindowFunctionProcedureSpec{
+				Function: experimental.NtileFunction,
+				OrderBy:  []string{"_time"},
+				As:       "ntile",
+				N:        2,
+			},
+			want: output(flux.TInt, "ntile", int64(1), int64(1), int64(1), int64(2), int64(2)),
+		},
+		{
+			name: "replace column",
+			spec: &experimental.WindowFunctionProcedureSpec{
+				Function: experimental.RowNumberFunction,
+				OrderBy:  []string{"_time"},
+				As:       "_value",
+			},
+			want: []*executetest.Table{{
+				KeyCols: []string{"id"},
+				ColMeta: []flux.ColMeta{
+					{Label: "id", Type: flux.TString},
+					{Label: "_time", Type: flux.TTime},
+					{Label: "_value", Type: flux.TInt},
+				},
+				Data: [][]interface{}{
+					{"a", execute.Time(3), int64(3)},
+					{"a", execute.Time(1), int64(1)},
+					{"a", execute.Time(2), int64(2)},
+					{"a", execute.Time(4), int64(4)},
+					{"a", execute.Time(5), int64(5)},
+				},
+			}},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			executetest.ProcessTestHelper(
+				t,
+				input(),
+				tc.want,
+				nil,
+				func(d execute.Dataset, c execute.TableBuilderCache) execute.Transformation {
+					return experimental.NewWindowFunctionTransformation(d, c, tc.spec)
+				},
+			)
+		})
+	}
+}
+
+func TestWindowFunction_Errors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		spec    *experimental.WindowFunctionProcedureSpec
+		wantErr string
+	}{
+		{
+			name: "missing order column",
+			spec: &experimental.WindowFunctionProcedureSpec{
+				Function: experimental.RankFunction,
+				OrderBy:  []string{"host"},
+				As:       "rank",
+			},
+			wantErr: `missing order column "host"`,
+		},
+		{
+			name: "default type mismatch",
+			spec: &experimental.WindowFunctionProcedureSpec{
+				Function: experimental.LagFunction,
+				OrderBy:  []string{"_time"},
+				As:       "lag",
+				Column:   "_value",
+				Offset:   1,
+				Default:  values.NewString("none"),
+			},
+			wantErr: `default of type string does not match column "_value" of type int`,
+		},
+		{
+			name: "group key column",
+			spec: &experimental.WindowFunctionProcedureSpec{
+				Function: experimental.RowNumberFunction,
+				OrderBy:  []string{"_time"},
+				As:       "id",
+			},
+			wantErr: `cannot overwrite group key column "id"`,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			executetest.ProcessTestHelper(
+				t,
+				[]flux.Table{&executetest.Table{
+					KeyCols: []string{"id"},
+					ColMeta: []flux.ColMeta{
+						{Label: "id", Type: flux.TString},
+						{Label: "_time", Type: flux.TTime},
+						{Label: "_value", Type: flux.TInt},
+					},
+					Data: [][]interface{}{
+						{"a", execute.Time(1), int64(10)},
+					},
+				}},
+				nil,
+				errors.New(codes.FailedPrecondition, tc.wantErr),
+				func(d execute.Dataset, c execute.TableBuilderCache) execute.Transformation {
+					return experimental.NewWindowFunctionTransformation(d, c, tc.spec)
+				},
+			)
+		})
+	}
+}
@@ -60,8 +60,17 @@ func init() {
 				}
 			}
 
-			// Perform request
+			// Validate the url before performing the request
 			deps := flux.GetDependencies(ctx)
+			validator, err := deps.URLValidator()
+			if err != nil {
+				return nil, err
+			}
+			if err := validator.Validate(req.URL); err != nil {
+				return nil, err
+			}
+
+			// Perform request
 			dc, err := deps.HTTPClient()
 			if err != nil {
 				return nil, errors.Wrap(err, codes.Aborted, "missing client in http.post")
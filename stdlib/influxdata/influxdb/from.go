@@ -5,13 +5,19 @@
 package influxdb
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/ast"
+	"github.com/influxdata/flux/ast/astutil"
 	"github.com/influxdata/flux/codes"
 	"github.com/influxdata/flux/dependencies/influxdb"
 	"github.com/influxdata/flux/execute"
 	"github.com/influxdata/flux/internal/errors"
 	"github.com/influxdata/flux/plan"
 	"github.com/influxdata/flux/runtime"
+	"github.com/influxdata/flux/semantic"
 	"github.com/influxdata/flux/values"
 )
 
@@ -34,6 +40,12 @@ type (
 
 	// PredicateSet holds a set of predicates that will filter the results.
 	PredicateSet = influxdb.PredicateSet
+
+	// Transformation is a transformation that is applied by the remote query.
+	Transformation = influxdb.Transformation
+
+	// Transformations holds the transformations that are applied by the remote query.
+	Transformations = influxdb.Transformations
 )
 
 type FromOpSpec struct {
@@ -54,6 +66,11 @@ func init() {
 		FromRemoteRule{},
 		MergeRemoteRangeRule{},
 		MergeRemoteFilterRule{},
+		MergeRemoteGroupRule{},
+		MergeRemoteWindowRule{},
+		MergeRemoteAggregateRule{},
+		MergeRemoteLimitRule{},
+		MergeRemoteSchemaMutationRule{},
 	)
 }
 
@@ -173,6 +190,9 @@ type FromRemoteProcedureSpec struct {
 	influxdb.Config
 	Bounds       flux.Bounds
 	PredicateSet influxdb.PredicateSet
+	// Transformations are applied by the remote query
+	// after the bounds and predicates.
+	Transformations Transformations
 }
 
 func (s *FromRemoteProcedureSpec) Kind() plan.ProcedureKind {
@@ -198,9 +218,55 @@ func (s *FromRemoteProcedureSpec) Copy() plan.ProcedureSpec {
 	ns := new(FromRemoteProcedureSpec)
 	*ns = *s
 	ns.PredicateSet = s.PredicateSet.Copy()
+	ns.Transformations = s.Transformations.Copy()
 	return ns
 }
 
+// PlanDetails reports the bucket and the bounds, predicates
+// and transformations that are pushed down to the remote read.
+func (s *FromRemoteProcedureSpec) PlanDetails() string {
+	var b strings.Builder
+	bucket := s.Bucket.Name
+	if bucket == "" {
+		bucket = s.Bucket.ID
+	}
+	fmt.Fprintf(&b, "bucket: %s\n", bucket)
+	if !s.Bounds.HasZero() {
+		start, _ := s.Bounds.Start.MarshalText()
+		stop, _ := s.Bounds.Stop.MarshalText()
+		fmt.Fprintf(&b, "bounds: [%s, %s)\n", start, stop)
+	}
+	for _, p := range s.PredicateSet {
+		expr, ok := p.Fn.GetFunctionBodyExpression()
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(&b, "predicate: %v", semantic.Formatted(expr))
+		if p.KeepEmpty {
+			b.WriteString(" (keep empty)")
+		}
+		b.WriteString("\n")
+	}
+	for _, t := range s.Transformations {
+		fmt.Fprintf(&b, "transformation: %s\n", formatCall(t.Call))
+	}
+	return b.String()
+}
+
+// formatCall formats the call of a transformation.
+// It falls back to the name of the function if the call cannot be formatted.
+func formatCall(call *ast.CallExpression) string {
+	if s, err := astutil.Format(&ast.File{
+		Body: []ast.Statement{&ast.ExpressionStatement{Expression: call}},
+	}); err == nil {
+		return strings.TrimSpace(s)
+	}
+	if id, ok := call.Callee.(*ast.Identifier); ok {
+		return id.Name + "()"
+	}
+	return "<call>"
+}
+
 func (s *FromRemoteProcedureSpec) PostPhysicalValidate(id plan.NodeID) error {
 	if s.Bounds.IsEmpty() {
 		var bucket string
@@ -221,7 +287,19 @@ func createFromSource(ps plan.ProcedureSpec, id execute.DatasetID, a execute.Adm
 	}
 
 	provider := influxdb.GetProvider(a.Context())
-	reader, err := provider.ReaderFor(a.Context(), spec.Config, spec.Bounds, spec.PredicateSet)
+	var (
+		reader influxdb.Reader
+		err    error
+	)
+	if len(spec.Transformations) > 0 {
+		tp, ok := provider.(influxdb.TransformedReaderProvider)
+		if !ok {
+			return nil, errors.New(codes.Unimplemented, "influxdb provider cannot apply transformations to a read")
+		}
+		reader, err = tp.TransformedReaderFor(a.Context(), spec.Config, spec.Bounds, spec.PredicateSet, spec.Transformations)
+	} else {
+		reader, err = provider.ReaderFor(a.Context(), spec.Config, spec.Bounds, spec.PredicateSet)
+	}
 	if err != nil {
 		return nil, err
 	}
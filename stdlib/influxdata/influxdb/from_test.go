@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/ast"
+	influxdeps "github.com/influxdata/flux/dependencies/influxdb"
 	"github.com/influxdata/flux/execute"
 	"github.com/influxdata/flux/execute/executetest"
 	"github.com/influxdata/flux/interpreter"
@@ -392,6 +394,61 @@ from(bucket: "telegraf")
 				Tables: defaultTablesFn,
 			},
 		},
+		{
+			name: "transformations query",
+			spec: &influxdb.FromRemoteProcedureSpec{
+				Config: influxdb.Config{
+					Org:    influxdb.NameOrID{Name: "influxdata"},
+					Bucket: influxdb.NameOrID{Name: "telegraf"},
+					Token:  "mytoken",
+				},
+				Bounds: flux.Bounds{
+					Start: flux.Time{
+						IsRelative: true,
+						Relative:   -time.Minute,
+					},
+					Stop: flux.Time{
+						IsRelative: true,
+					},
+					Now: now,
+				},
+				Transformations: influxdb.Transformations{
+					{Call: &ast.CallExpression{
+						Callee: &ast.Identifier{Name: "group"},
+						Arguments: []ast.Expression{&ast.ObjectExpression{
+							Properties: []*ast.Property{{
+								Key: &ast.Identifier{Name: "columns"},
+								Value: &ast.ArrayExpression{Elements: []ast.Expression{
+									ast.StringLiteralFromValue("_measurement"),
+								}},
+							}},
+						}},
+					}},
+					{Call: &ast.CallExpression{
+						Callee: &ast.Identifier{Name: "limit"},
+						Arguments: []ast.Expression{&ast.ObjectExpression{
+							Properties: []*ast.Property{{
+								Key:   &ast.Identifier{Name: "n"},
+								Value: ast.IntegerLiteralFromValue(10),
+							}},
+						}},
+					}},
+				},
+			},
+			want: testutil.Want{
+				Params: url.Values{
+					"org": []string{"influxdata"},
+				},
+				Query: `package main
+
+
+from(bucket: "telegraf")
+    |> range(start: 2020-10-22T09:29:00Z, stop: 2020-10-22T09:30:00Z)
+    |> group(columns: ["_measurement"])
+    |> limit(n: 10)`,
+				Tables: defaultTablesFn,
+			},
+		},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
 			testutil.RunSourceTestHelper(t, tt.spec, tt.want)
@@ -456,6 +513,52 @@ func TestFrom_HTTPClient(t *testing.T) {
 	})
 }
 
+func TestFromRemoteProcedureSpec_PlanDetails(t *testing.T) {
+	spec := &influxdb.FromRemoteProcedureSpec{
+		Config: influxdb.Config{
+			Org:    influxdb.NameOrID{Name: "influxdata"},
+			Bucket: influxdb.NameOrID{Name: "telegraf"},
+			Token:  "mytoken",
+		},
+		Bounds: flux.Bounds{
+			Start: flux.Time{
+				IsRelative: true,
+				Relative:   -time.Minute,
+			},
+			Stop: flux.Time{
+				IsRelative: true,
+			},
+		},
+		PredicateSet: influxdeps.PredicateSet{{
+			ResolvedFunction: interpreter.ResolvedFunction{
+				Fn:    executetest.FunctionExpression(t, `(r) => r._measurement == "cpu"`),
+				Scope: valuestest.Scope(),
+			},
+			KeepEmpty: true,
+		}},
+		Transformations: influxdeps.Transformations{{
+			Call: &ast.CallExpression{
+				Callee: &ast.Identifier{Name: "limit"},
+				Arguments: []ast.Expression{&ast.ObjectExpression{
+					Properties: []*ast.Property{{
+						Key:   &ast.Identifier{Name: "n"},
+						Value: ast.IntegerLiteralFromValue(10),
+					}},
+				}},
+			},
+		}},
+	}
+
+	want := `bucket: telegraf
+bounds: [-1m0s, now)
+predicate: r._measurement == "cpu" (keep empty)
+transformation: limit(n: 10)
+`
+	if got := spec.PlanDetails(); got != want {
+		t.Fatalf("unexpected plan details -want/+got:\n\t- %q\n\t+ %q", want, got)
+	}
+}
+
 func stringPtr(v string) *string {
 	return &v
 }
@@ -0,0 +1,194 @@
+package influxdb
+
+import (
+	"sort"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/ast"
+	"github.com/influxdata/flux/dependencies/influxdb"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/plan"
+	"github.com/influxdata/flux/stdlib/universe"
+)
+
+// remoteTransformation returns the call that applies the procedure
+// in a remote query. It returns false if the procedure cannot
+// be expressed as a call to a transformation.
+func remoteTransformation(spec plan.ProcedureSpec) (influxdb.Transformation, bool) {
+	var call *ast.CallExpression
+	switch spec := spec.(type) {
+	case *universe.GroupProcedureSpec:
+		call = groupCall(spec)
+	case *universe.WindowProcedureSpec:
+		call = windowCall(spec)
+	case *universe.LimitProcedureSpec:
+		call = newCall(universe.LimitKind,
+			property("n", ast.IntegerLiteralFromValue(spec.N)),
+			property("offset", ast.IntegerLiteralFromValue(spec.Offset)),
+		)
+	case *universe.CountProcedureSpec:
+		call = aggregateCall(universe.CountKind, spec.SimpleAggregateConfig)
+	case *universe.SumProcedureSpec:
+		call = aggregateCall(universe.SumKind, spec.SimpleAggregateConfig)
+	case *universe.MeanProcedureSpec:
+		call = aggregateCall(universe.MeanKind, spec.SimpleAggregateConfig)
+	case *universe.MinProcedureSpec:
+		call = selectorCall(universe.MinKind, spec.SelectorConfig)
+	case *universe.MaxProcedureSpec:
+		call = selectorCall(universe.MaxKind, spec.SelectorConfig)
+	case *universe.FirstProcedureSpec:
+		call = selectorCall(universe.FirstKind, spec.SelectorConfig)
+	case *universe.LastProcedureSpec:
+		call = selectorCall(universe.LastKind, spec.SelectorConfig)
+	case *universe.SchemaMutationProcedureSpec:
+		// A schema mutation with more than one mutation
+		// is sent as a pipeline of calls, which is not
+		// a single transformation.
+		if len(spec.Mutations) != 1 {
+			return influxdb.Transformation{}, false
+		}
+		call = schemaMutationCall(spec.Mutations[0])
+	}
+	if call == nil {
+		return influxdb.Transformation{}, false
+	}
+	return influxdb.Transformation{Call: call}, true
+}
+
+func groupCall(spec *universe.GroupProcedureSpec) *ast.CallExpression {
+	var mode string
+	switch spec.GroupMode {
+	case flux.GroupModeBy:
+		mode = "by"
+	case flux.GroupModeExcept:
+		mode = "except"
+	default:
+		return nil
+	}
+	return newCall(universe.GroupKind,
+		property("columns", stringArray(spec.GroupKeys)),
+		property("mode", ast.StringLiteralFromValue(mode)),
+	)
+}
+
+func windowCall(spec *universe.WindowProcedureSpec) *ast.CallExpression {
+	properties := []*ast.Property{
+		property("every", durationLiteral(spec.Window.Every)),
+		property("period", durationLiteral(spec.Window.Period)),
+		property("offset", durationLiteral(spec.Window.Offset)),
+	}
+	if loc := spec.Window.Location; !loc.IsUTC() {
+		zone := loc.Name
+		if zone == "" {
+			zone = "UTC"
+		}
+		properties = append(properties, property("location", &ast.ObjectExpression{
+			Properties: []*ast.Property{
+				property("zone", ast.StringLiteralFromValue(zone)),
+				property("offset", durationLiteral(loc.Offset)),
+			},
+		}))
+	}
+	properties = append(properties,
+		property("timeColumn", ast.StringLiteralFromValue(spec.TimeColumn)),
+		property("startColumn", ast.StringLiteralFromValue(spec.StartColumn)),
+		property("stopColumn", ast.StringLiteralFromValue(spec.StopColumn)),
+		property("createEmpty", ast.BooleanLiteralFromValue(spec.CreateEmpty)),
+	)
+	return newCall(universe.WindowKind, properties...)
+}
+
+// aggregateCall returns the call of an aggregate.
+// The aggregate functions only take a single column.
+func aggregateCall(name string, config execute.SimpleAggregateConfig) *ast.CallExpression {
+	if len(config.Columns) != 1 {
+		return nil
+	}
+	return newCall(name, property("column", ast.StringLiteralFromValue(config.Columns[0])))
+}
+
+func selectorCall(name string, config execute.SelectorConfig) *ast.CallExpression {
+	return newCall(name, property("column", ast.StringLiteralFromValue(config.Column)))
+}
+
+// schemaMutationCall returns the call of a mutation that uses a list of columns.
+// Mutations that use a function are not sent, since the function
+// may reference values that only exist in the local query.
+func schemaMutationCall(m universe.SchemaMutation) *ast.CallExpression {
+	switch m := m.(type) {
+	case *universe.KeepOpSpec:
+		if m.Predicate.Fn != nil {
+			return nil
+		}
+		return newCall(universe.KeepKind, property("columns", stringArray(m.Columns)))
+	case *universe.DropOpSpec:
+		if m.Predicate.Fn != nil {
+			return nil
+		}
+		return newCall(universe.DropKind, property("columns", stringArray(m.Columns)))
+	case *universe.RenameOpSpec:
+		if m.Fn.Fn != nil {
+			return nil
+		}
+		from := make([]string, 0, len(m.Columns))
+		for k := range m.Columns {
+			from = append(from, k)
+		}
+		sort.Strings(from)
+		columns := make([]*ast.Property, len(from))
+		for i, k := range from {
+			columns[i] = &ast.Property{
+				Key:   &ast.StringLiteral{Value: k},
+				Value: ast.StringLiteralFromValue(m.Columns[k]),
+			}
+		}
+		return newCall(universe.RenameKind, property("columns", &ast.ObjectExpression{Properties: columns}))
+	case *universe.DuplicateOpSpec:
+		return newCall(universe.DuplicateKind,
+			property("column", ast.StringLiteralFromValue(m.Column)),
+			property("as", ast.StringLiteralFromValue(m.As)),
+		)
+	default:
+		return nil
+	}
+}
+
+func newCall(name string, properties ...*ast.Property) *ast.CallExpression {
+	return &ast.CallExpression{
+		Callee: &ast.Identifier{Name: name},
+		Arguments: []ast.Expression{
+			&ast.ObjectExpression{Properties: properties},
+		},
+	}
+}
+
+func property(key string, value ast.Expression) *ast.Property {
+	return &ast.Property{
+		Key:   &ast.Identifier{Name: key},
+		Value: value,
+	}
+}
+
+func stringArray(vs []string) *ast.ArrayExpression {
+	elements := make([]ast.Expression, len(vs))
+	for i, v := range vs {
+		elements[i] = ast.StringLiteralFromValue(v)
+	}
+	return &ast.ArrayExpression{Elements: elements}
+}
+
+func durationLiteral(d flux.Duration) ast.Expression {
+	if d.IsZero() {
+		return &ast.DurationLiteral{
+			Values: []ast.Duration{{Magnitude: 0, Unit: ast.SecondUnit}},
+		}
+	}
+	lit := &ast.DurationLiteral{Values: d.AsValues()}
+	if d.IsNegative() {
+		return &ast.UnaryExpression{
+			Operator: ast.SubtractionOperator,
+			Argument: lit,
+		}
+	}
+	return lit
+}
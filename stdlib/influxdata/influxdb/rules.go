@@ -85,6 +85,11 @@ func (p MergeRemoteFilterRule) Rewrite(ctx context.Context, node plan.Node) (pla
 	if fromSpec.Bounds.IsEmpty() {
 		return node, false, nil
 	}
+	// The predicates are applied before the transformations,
+	// so a filter that follows a transformation cannot be merged.
+	if len(fromSpec.Transformations) > 0 {
+		return node, false, nil
+	}
 	filterSpec := node.ProcedureSpec().(*universe.FilterProcedureSpec)
 
 	// Attempt to construct the new from procedure spec and see
@@ -111,6 +116,126 @@ func (p MergeRemoteFilterRule) Rewrite(ctx context.Context, node plan.Node) (pla
 	return n, true, nil
 }
 
+// MergeRemoteGroupRule pushes a group into the remote query.
+type MergeRemoteGroupRule struct{}
+
+func (p MergeRemoteGroupRule) Name() string {
+	return "influxdata/influxdb.MergeRemoteGroupRule"
+}
+
+func (p MergeRemoteGroupRule) Pattern() plan.Pattern {
+	return plan.Pat(universe.GroupKind, plan.Pat(FromRemoteKind))
+}
+
+func (p MergeRemoteGroupRule) Rewrite(ctx context.Context, node plan.Node) (plan.Node, bool, error) {
+	return mergeRemoteTransformation(ctx, node)
+}
+
+// MergeRemoteWindowRule pushes a window into the remote query.
+// Together with the MergeRemoteAggregateRule, this pushes
+// the window and aggregate of aggregateWindow.
+type MergeRemoteWindowRule struct{}
+
+func (p MergeRemoteWindowRule) Name() string {
+	return "influxdata/influxdb.MergeRemoteWindowRule"
+}
+
+func (p MergeRemoteWindowRule) Pattern() plan.Pattern {
+	return plan.Pat(universe.WindowKind, plan.Pat(FromRemoteKind))
+}
+
+func (p MergeRemoteWindowRule) Rewrite(ctx context.Context, node plan.Node) (plan.Node, bool, error) {
+	return mergeRemoteTransformation(ctx, node)
+}
+
+// MergeRemoteAggregateRule pushes an aggregate or a selector
+// into the remote query.
+type MergeRemoteAggregateRule struct{}
+
+func (p MergeRemoteAggregateRule) Name() string {
+	return "influxdata/influxdb.MergeRemoteAggregateRule"
+}
+
+func (p MergeRemoteAggregateRule) Pattern() plan.Pattern {
+	return plan.OneOf([]plan.ProcedureKind{
+		universe.CountKind,
+		universe.SumKind,
+		universe.MeanKind,
+		universe.MinKind,
+		universe.MaxKind,
+		universe.FirstKind,
+		universe.LastKind,
+	}, plan.Pat(FromRemoteKind))
+}
+
+func (p MergeRemoteAggregateRule) Rewrite(ctx context.Context, node plan.Node) (plan.Node, bool, error) {
+	return mergeRemoteTransformation(ctx, node)
+}
+
+// MergeRemoteLimitRule pushes a limit into the remote query.
+type MergeRemoteLimitRule struct{}
+
+func (p MergeRemoteLimitRule) Name() string {
+	return "influxdata/influxdb.MergeRemoteLimitRule"
+}
+
+func (p MergeRemoteLimitRule) Pattern() plan.Pattern {
+	return plan.Pat(universe.LimitKind, plan.Pat(FromRemoteKind))
+}
+
+func (p MergeRemoteLimitRule) Rewrite(ctx context.Context, node plan.Node) (plan.Node, bool, error) {
+	return mergeRemoteTransformation(ctx, node)
+}
+
+// MergeRemoteSchemaMutationRule pushes a keep, drop, rename
+// or duplicate into the remote query.
+type MergeRemoteSchemaMutationRule struct{}
+
+func (p MergeRemoteSchemaMutationRule) Name() string {
+	return "influxdata/influxdb.MergeRemoteSchemaMutationRule"
+}
+
+func (p MergeRemoteSchemaMutationRule) Pattern() plan.Pattern {
+	return plan.Pat(universe.SchemaMutationKind, plan.Pat(FromRemoteKind))
+}
+
+func (p MergeRemoteSchemaMutationRule) Rewrite(ctx context.Context, node plan.Node) (plan.Node, bool, error) {
+	return mergeRemoteTransformation(ctx, node)
+}
+
+// mergeRemoteTransformation merges the node into the remote read that
+// precedes it if the remote query can apply the node as a transformation.
+func mergeRemoteTransformation(ctx context.Context, node plan.Node) (plan.Node, bool, error) {
+	fromNode := node.Predecessors()[0]
+	fromSpec := fromNode.ProcedureSpec().(*FromRemoteProcedureSpec)
+	if fromSpec.Bounds.IsEmpty() {
+		return node, false, nil
+	}
+	// The output of the read cannot change
+	// when other nodes also consume it.
+	if len(fromNode.Successors()) != 1 {
+		return node, false, nil
+	}
+
+	// Only push the transformation if the provider
+	// can apply transformations within the read.
+	if _, ok := influxdb.GetProvider(ctx).(influxdb.TransformedReaderProvider); !ok {
+		return node, false, nil
+	}
+	t, ok := remoteTransformation(node.ProcedureSpec())
+	if !ok {
+		return node, false, nil
+	}
+	fromSpec = fromSpec.Copy().(*FromRemoteProcedureSpec)
+	fromSpec.Transformations = append(fromSpec.Transformations, t)
+
+	n, err := plan.MergeToPhysicalNode(node, fromNode, fromSpec)
+	if err != nil {
+		return nil, false, err
+	}
+	return n, true, nil
+}
+
 type BucketsRemoteRule struct{}
 
 func (p BucketsRemoteRule) Name() string {
@@ -2,12 +2,16 @@ package influxdb_test
 
 import (
 	"context"
+	"flag"
 	"testing"
 	"time"
 
 	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/ast"
 	"github.com/influxdata/flux/codes"
 	influxdeps "github.com/influxdata/flux/dependencies/influxdb"
+	ftesting "github.com/influxdata/flux/dependencies/testing"
+	"github.com/influxdata/flux/execute"
 	"github.com/influxdata/flux/execute/executetest"
 	"github.com/influxdata/flux/internal/errors"
 	"github.com/influxdata/flux/interpreter"
@@ -440,3 +444,333 @@ func TestDefaultFromAttributes(t *testing.T) {
 		})
 	}
 }
+
+func TestMergeRemoteTransformationRules(t *testing.T) {
+	deps := flux.NewDefaultDependencies()
+	ctx := deps.Inject(context.Background())
+	ctx = influxdeps.Dependency{
+		Provider: influxdeps.HttpProvider{},
+	}.Inject(ctx)
+
+	rules := []plan.Rule{
+		influxdb.FromRemoteRule{},
+		influxdb.MergeRemoteRangeRule{},
+		influxdb.MergeRemoteGroupRule{},
+		influxdb.MergeRemoteWindowRule{},
+		influxdb.MergeRemoteAggregateRule{},
+		influxdb.MergeRemoteLimitRule{},
+		influxdb.MergeRemoteSchemaMutationRule{},
+	}
+	fromSpec := &influxdb.FromProcedureSpec{
+		Bucket: influxdb.NameOrID{Name: "telegraf"},
+		Host:   stringPtr("http://localhost:8086"),
+	}
+	rangeSpec := &universe.RangeProcedureSpec{
+		Bounds: flux.Bounds{
+			Start: flux.Time{
+				IsRelative: true,
+				Relative:   -time.Hour,
+			},
+			Stop: flux.Time{
+				IsRelative: true,
+			},
+		},
+	}
+	groupSpec := &universe.GroupProcedureSpec{
+		GroupMode: flux.GroupModeBy,
+		GroupKeys: []string{"host"},
+	}
+	windowSpec := &universe.WindowProcedureSpec{
+		Window: plan.WindowSpec{
+			Every:  flux.ConvertDuration(time.Minute),
+			Period: flux.ConvertDuration(time.Minute),
+		},
+		TimeColumn:  "_time",
+		StartColumn: "_start",
+		StopColumn:  "_stop",
+	}
+	meanSpec := &universe.MeanProcedureSpec{
+		SimpleAggregateConfig: execute.DefaultSimpleAggregateConfig,
+	}
+	limitSpec := &universe.LimitProcedureSpec{N: 10}
+	keepSpec := &universe.SchemaMutationProcedureSpec{
+		Mutations: []universe.SchemaMutation{
+			&universe.KeepOpSpec{Columns: []string{"_time", "_value"}},
+		},
+	}
+
+	call := func(name string, properties ...*ast.Property) influxdeps.Transformation {
+		return influxdeps.Transformation{Call: &ast.CallExpression{
+			Callee:    &ast.Identifier{Name: name},
+			Arguments: []ast.Expression{&ast.ObjectExpression{Properties: properties}},
+		}}
+	}
+	property := func(key string, value ast.Expression) *ast.Property {
+		return &ast.Property{Key: &ast.Identifier{Name: key}, Value: value}
+	}
+	duration := func(magnitude int64, unit string) *ast.DurationLiteral {
+		return &ast.DurationLiteral{Values: []ast.Duration{{Magnitude: magnitude, Unit: unit}}}
+	}
+	remoteSpec := func(ts ...influxdeps.Transformation) *influxdb.FromRemoteProcedureSpec {
+		return &influxdb.FromRemoteProcedureSpec{
+			Config: influxdb.Config{
+				Bucket: fromSpec.Bucket,
+				Host:   *fromSpec.Host,
+			},
+			Bounds:          rangeSpec.Bounds,
+			Transformations: ts,
+		}
+	}
+
+	for _, tc := range []plantest.RuleTestCase{
+		{
+			Name:    "group window mean",
+			Context: ctx,
+			Rules:   rules,
+			Before: &plantest.PlanSpec{
+				Nodes: []plan.Node{
+					plan.CreateLogicalNode("from", fromSpec),
+					plan.CreateLogicalNode("range", rangeSpec),
+					plan.CreateLogicalNode("group", groupSpec),
+					plan.CreateLogicalNode("window", windowSpec),
+					plan.CreateLogicalNode("mean", meanSpec),
+				},
+				Edges: [][2]int{{0, 1}, {1, 2}, {2, 3}, {3, 4}},
+			},
+			After: &plantest.PlanSpec{
+				Nodes: []plan.Node{
+					plan.CreatePhysicalNode("merged_fromRemote_range_group_window_mean", remoteSpec(
+						call("group",
+							property("columns", &ast.ArrayExpression{Elements: []ast.Expression{ast.StringLiteralFromValue("host")}}),
+							property("mode", ast.StringLiteralFromValue("by")),
+						),
+						call("window",
+							property("every", duration(1, ast.MinuteUnit)),
+							property("period", duration(1, ast.MinuteUnit)),
+							property("offset", duration(0, ast.SecondUnit)),
+							property("timeColumn", ast.StringLiteralFromValue("_time")),
+							property("startColumn", ast.StringLiteralFromValue("_start")),
+							property("stopColumn", ast.StringLiteralFromValue("_stop")),
+							property("createEmpty", ast.BooleanLiteralFromValue(false)),
+						),
+						call("mean", property("column", ast.StringLiteralFromValue("_value"))),
+					)),
+				},
+			},
+		},
+		{
+			Name:    "keep limit",
+			Context: ctx,
+			Rules:   rules,
+			Before: &plantest.PlanSpec{
+				Nodes: []plan.Node{
+					plan.CreateLogicalNode("from", fromSpec),
+					plan.CreateLogicalNode("range", rangeSpec),
+					plan.CreateLogicalNode("keep", keepSpec),
+					plan.CreateLogicalNode("limit", limitSpec),
+				},
+				Edges: [][2]int{{0, 1}, {1, 2}, {2, 3}},
+			},
+			After: &plantest.PlanSpec{
+				Nodes: []plan.Node{
+					plan.CreatePhysicalNode("merged_fromRemote_range_keep_limit", remoteSpec(
+						call("keep", property("columns", &ast.ArrayExpression{Elements: []ast.Expression{
+							ast.StringLiteralFromValue("_time"),
+							ast.StringLiteralFromValue("_value"),
+						}})),
+						call("limit",
+							property("n", ast.IntegerLiteralFromValue(10)),
+							property("offset", ast.IntegerLiteralFromValue(0)),
+						),
+					)),
+				},
+			},
+		},
+		{
+			Name:    "read with multiple successors",
+			Context: ctx,
+			Rules:   rules,
+			Before: &plantest.PlanSpec{
+				Nodes: []plan.Node{
+					plan.CreateLogicalNode("from", fromSpec),
+					plan.CreateLogicalNode("range", rangeSpec),
+					plan.CreateLogicalNode("mean", meanSpec),
+					plan.CreateLogicalNode("limit", limitSpec),
+				},
+				Edges: [][2]int{{0, 1}, {1, 2}, {1, 3}},
+			},
+			After: &plantest.PlanSpec{
+				Nodes: []plan.Node{
+					plan.CreatePhysicalNode("merged_fromRemote_range", remoteSpec()),
+					plan.CreateLogicalNode("mean", meanSpec),
+					plan.CreateLogicalNode("limit", limitSpec),
+				},
+				Edges: [][2]int{{0, 1}, {0, 2}},
+			},
+		},
+		{
+			Name: "provider without transformations",
+			Context: influxdeps.Dependency{
+				Provider: influxdeps.UnimplementedProvider{},
+			}.Inject(ctx),
+			Rules: rules,
+			Before: &plantest.PlanSpec{
+				Nodes: []plan.Node{
+					plan.CreateLogicalNode("from", fromSpec),
+					plan.CreateLogicalNode("range", rangeSpec),
+					plan.CreateLogicalNode("limit", limitSpec),
+				},
+				Edges: [][2]int{{0, 1}, {1, 2}},
+			},
+			After: &plantest.PlanSpec{
+				Nodes: []plan.Node{
+					plan.CreatePhysicalNode("merged_fromRemote_range", remoteSpec()),
+					plan.CreateLogicalNode("limit", limitSpec),
+				},
+				Edges: [][2]int{{0, 1}},
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			plantest.PhysicalRuleTestHelper(t, &tc)
+		})
+	}
+}
+
+var updateSnapshots = flag.Bool("update-snapshots", false, "Save the physical plans of the plan snapshot tests as their snapshots.")
+
+// TestRemoteRules_PlanSnapshot compares the physical plans of queries
+// that read from a remote host with the snapshots in the testdata directory.
+// Run the test with -update-snapshots to save the current plans.
+func TestRemoteRules_PlanSnapshot(t *testing.T) {
+	deps := flux.NewDefaultDependencies()
+	ctx := deps.Inject(context.Background())
+	ctx = influxdeps.Dependency{
+		Provider: influxdeps.HttpProvider{},
+	}.Inject(ctx)
+
+	rules := []plan.Rule{
+		influxdb.FromRemoteRule{},
+		influxdb.MergeRemoteRangeRule{},
+		influxdb.MergeRemoteFilterRule{},
+		influxdb.MergeRemoteGroupRule{},
+		influxdb.MergeRemoteWindowRule{},
+		influxdb.MergeRemoteAggregateRule{},
+		influxdb.MergeRemoteLimitRule{},
+		influxdb.MergeRemoteSchemaMutationRule{},
+	}
+	fromSpec := &influxdb.FromProcedureSpec{
+		Bucket: influxdb.NameOrID{Name: "telegraf"},
+		Host:   stringPtr("http://localhost:8086"),
+	}
+	rangeSpec := &universe.RangeProcedureSpec{
+		Bounds: flux.Bounds{
+			Start: flux.Time{
+				IsRelative: true,
+				Relative:   -time.Hour,
+			},
+			Stop: flux.Time{
+				IsRelative: true,
+			},
+		},
+	}
+	groupSpec := &universe.GroupProcedureSpec{
+		GroupMode: flux.GroupModeBy,
+		GroupKeys: []string{"host"},
+	}
+	windowSpec := &universe.WindowProcedureSpec{
+		Window: plan.WindowSpec{
+			Every:  flux.ConvertDuration(time.Minute),
+			Period: flux.ConvertDuration(time.Minute),
+		},
+		TimeColumn:  "_time",
+		StartColumn: "_start",
+		StopColumn:  "_stop",
+	}
+	meanSpec := &universe.MeanProcedureSpec{
+		SimpleAggregateConfig: execute.DefaultSimpleAggregateConfig,
+	}
+	limitSpec := &universe.LimitProcedureSpec{N: 10}
+	keepSpec := &universe.SchemaMutationProcedureSpec{
+		Mutations: []universe.SchemaMutation{
+			&universe.KeepOpSpec{Columns: []string{"_time", "_value"}},
+		},
+	}
+
+	for _, tc := range []struct {
+		name   string
+		before *plantest.PlanSpec
+	}{
+		{
+			name: "remote_range",
+			before: &plantest.PlanSpec{
+				Nodes: []plan.Node{
+					plan.CreateLogicalNode("from", fromSpec),
+					plan.CreateLogicalNode("range", rangeSpec),
+				},
+				Edges: [][2]int{{0, 1}},
+			},
+		},
+		{
+			name: "remote_group_window_mean",
+			before: &plantest.PlanSpec{
+				Nodes: []plan.Node{
+					plan.CreateLogicalNode("from", fromSpec),
+					plan.CreateLogicalNode("range", rangeSpec),
+					plan.CreateLogicalNode("group", groupSpec),
+					plan.CreateLogicalNode("window", windowSpec),
+					plan.CreateLogicalNode("mean", meanSpec),
+				},
+				Edges: [][2]int{{0, 1}, {1, 2}, {2, 3}, {3, 4}},
+			},
+		},
+		{
+			name: "remote_keep_limit",
+			before: &plantest.PlanSpec{
+				Nodes: []plan.Node{
+					plan.CreateLogicalNode("from", fromSpec),
+					plan.CreateLogicalNode("range", rangeSpec),
+					plan.CreateLogicalNode("keep", keepSpec),
+					plan.CreateLogicalNode("limit", limitSpec),
+				},
+				Edges: [][2]int{{0, 1}, {1, 2}, {2, 3}},
+			},
+		},
+		{
+			name: "remote_multiple_successors",
+			before: &plantest.PlanSpec{
+				Nodes: []plan.Node{
+					plan.CreateLogicalNode("from", fromSpec),
+					plan.CreateLogicalNode("range", rangeSpec),
+					plan.CreateLogicalNode("mean", meanSpec),
+					plan.CreateLogicalNode("limit", limitSpec),
+				},
+				Edges: [][2]int{{0, 1}, {1, 2}, {1, 3}},
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := ftesting.FrameworkConfig{
+				Name:            tc.name,
+				Snapshots:       ftesting.SnapshotDir("testdata"),
+				UpdateSnapshots: *updateSnapshots,
+			}.Inject(ctx)
+			if err := ftesting.ExpectPlanSnapshot(ctx, ""); err != nil {
+				t.Fatal(err)
+			}
+
+			planner := plan.NewPhysicalPlanner(
+				plan.OnlyPhysicalRules(rules...),
+				plan.DisableValidation(),
+			)
+			if _, err := planner.Plan(ctx, plantest.CreatePlanSpec(tc.before)); err != nil {
+				t.Fatal(err)
+			}
+			if err := ftesting.Check(ctx); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -459,3 +459,173 @@ func TestLinearInterpolate(t *testing.T) {
 		})
 	}
 }
+
+func TestInterpolateMethods(t *testing.T) {
+	intData := func() []flux.Table {
+		return []flux.Table{&executetest.Table{
+			ColMeta: []flux.ColMeta{
+				{Label: "_time", Type: flux.TTime},
+				{Label: "_value", Type: flux.TInt},
+			},
+			Data: [][]interface{}{
+				{execute.Time(0), int64(1)},
+				{execute.Time(10), int64(2)},
+			},
+		}}
+	}
+	floatData := func() []flux.Table {
+		return []flux.Table{&executetest.Table{
+			ColMeta: []flux.ColMeta{
+				{Label: "_time", Type: flux.TTime},
+				{Label: "_value", Type: flux.TFloat},
+			},
+			Data: [][]interface{}{
+				{execute.Time(0), 0.0},
+				{execute.Time(10), 10.0},
+				{execute.Time(20), 0.0},
+			},
+		}}
+	}
+	testCases := []struct {
+		name    string
+		method  string
+		every   time.Duration
+		data    []flux.Table
+		want    []*executetest.Table
+		wantErr error
+	}{
+		{
+			name:   "previous",
+			method: interpolate.PreviousMethod,
+			every:  4 * time.Nanosecond,
+			data:   intData(),
+			want: []*executetest.Table{{
+				ColMeta: []flux.ColMeta{
+					{Label: "_time", Type: flux.TTime},
+					{Label: "_value", Type: flux.TInt},
+				},
+				Data: [][]interface{}{
+					{execute.Time(0), int64(1)},
+					{execute.Time(4), int64(1)},
+					{execute.Time(8), int64(1)},
+					{execute.Time(10), int64(2)},
+				},
+			}},
+		},
+		{
+			name:   "next",
+			method: interpolate.NextMethod,
+			every:  4 * time.Nanosecond,
+			data:   intData(),
+			want: []*executetest.Table{{
+				ColMeta: []flux.ColMeta{
+					{Label: "_time", Type: flux.TTime},
+					{Label: "_value", Type: flux.TInt},
+				},
+				Data: [][]interface{}{
+					{execute.Time(0), int64(1)},
+					{execute.Time(4), int64(2)},
+					{execute.Time(8), int64(2)},
+					{execute.Time(10), int64(2)},
+				},
+			}},
+		},
+		{
+			name:   "nearest",
+			method: interpolate.NearestMethod,
+			every:  4 * time.Nanosecond,
+			data:   intData(),
+			want: []*executetest.Table{{
+				ColMeta: []flux.ColMeta{
+					{Label: "_time", Type: flux.TTime},
+					{Label: "_value", Type: flux.TInt},
+				},
+				Data: [][]interface{}{
+					{execute.Time(0), int64(1)},
+					{execute.Time(4), int64(1)},
+					{execute.Time(8), int64(2)},
+					{execute.Time(10), int64(2)},
+				},
+			}},
+		},
+		{
+			name:   "spline",
+			method: interpolate.SplineMethod,
+			every:  5 * time.Nanosecond,
+			data:   floatData(),
+			want: []*executetest.Table{{
+				ColMeta: []flux.ColMeta{
+					{Label: "_time", Type: flux.TTime},
+					{Label: "_value", Type: flux.TFloat},
+				},
+				Data: [][]interface{}{
+					{execute.Time(0), 0.0},
+					{execute.Time(5), 6.875},
+					{execute.Time(10), 10.0},
+					{execute.Time(15), 6.875},
+					{execute.Time(20), 0.0},
+				},
+			}},
+		},
+		{
+			name:   "akima",
+			method: interpolate.AkimaMethod,
+			every:  5 * time.Nanosecond,
+			data:   floatData(),
+			want: []*executetest.Table{{
+				ColMeta: []flux.ColMeta{
+					{Label: "_time", Type: flux.TTime},
+					{Label: "_value", Type: flux.TFloat},
+				},
+				Data: [][]interface{}{
+					{execute.Time(0), 0.0},
+					{execute.Time(5), 7.5},
+					{execute.Time(10), 10.0},
+					{execute.Time(15), 7.5},
+					{execute.Time(20), 0.0},
+				},
+			}},
+		},
+		{
+			name:    "spline of ints",
+			method:  interpolate.SplineMethod,
+			every:   5 * time.Nanosecond,
+			data:    intData(),
+			wantErr: fmt.Errorf("cannot interpolate int values; expected float values"),
+		},
+		{
+			name:   "akima unsorted",
+			method: interpolate.AkimaMethod,
+			every:  5 * time.Nanosecond,
+			data: []flux.Table{&executetest.Table{
+				ColMeta: []flux.ColMeta{
+					{Label: "_time", Type: flux.TTime},
+					{Label: "_value", Type: flux.TFloat},
+				},
+				Data: [][]interface{}{
+					{execute.Time(10), 1.0},
+					{execute.Time(0), 2.0},
+				},
+			}},
+			wantErr: fmt.Errorf("interpolate.akima requires strictly increasing _time values"),
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			spec := &interpolate.LinearInterpolateProcedureSpec{
+				Every:  flux.ConvertDuration(tc.every),
+				Method: tc.method,
+			}
+			executetest.ProcessTestHelper(
+				t,
+				tc.data,
+				tc.want,
+				tc.wantErr,
+				func(d execute.Dataset, c execute.TableBuilderCache) execute.Transformation {
+					return interpolate.NewInterpolateTransformation(d, c, spec)
+				},
+			)
+		})
+	}
+}
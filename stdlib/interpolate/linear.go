@@ -12,17 +12,38 @@ import (
 
 const LinearInterpolateKind = "linearInterpolateKind"
 
+// The interpolation methods. Each one is exposed as a Flux function of the same name
+// and shares the transformation of interpolate.linear.
+const (
+	LinearMethod   = "linear"
+	PreviousMethod = "previous"
+	NextMethod     = "next"
+	NearestMethod  = "nearest"
+	SplineMethod   = "spline"
+	AkimaMethod    = "akima"
+)
+
 type LinearInterpolateOpSpec struct {
-	Every flux.Duration `json:"every"`
+	Every  flux.Duration `json:"every"`
+	Method string        `json:"method,omitempty"`
 }
 
 func init() {
-	runtime.RegisterPackageValue("interpolate", "linear",
-		flux.MustValue(flux.FunctionValue("linear",
-			createInterpolateOpSpec,
-			runtime.MustLookupBuiltinType("interpolate", "linear"),
-		)),
-	)
+	for _, method := range []string{
+		LinearMethod,
+		PreviousMethod,
+		NextMethod,
+		NearestMethod,
+		SplineMethod,
+		AkimaMethod,
+	} {
+		runtime.RegisterPackageValue("interpolate", method,
+			flux.MustValue(flux.FunctionValue(method,
+				newInterpolateOpSpecCreator(method),
+				runtime.MustLookupBuiltinType("interpolate", method),
+			)),
+		)
+	}
 	flux.RegisterOpSpec(LinearInterpolateKind,
 		func() flux.OperationSpec {
 			return new(LinearInterpolateOpSpec)
@@ -39,19 +60,22 @@ func init() {
 	)
 }
 
-func createInterpolateOpSpec(args flux.Arguments, a *flux.Administration) (flux.OperationSpec, error) {
-	if err := a.AddParentFromArgs(args); err != nil {
-		return nil, err
-	}
+func newInterpolateOpSpecCreator(method string) flux.CreateOperationSpec {
+	return func(args flux.Arguments, a *flux.Administration) (flux.OperationSpec, error) {
+		if err := a.AddParentFromArgs(args); err != nil {
+			return nil, err
+		}
 
-	every, err := args.GetRequiredDuration("every")
-	if err != nil {
-		return nil, err
-	}
+		every, err := args.GetRequiredDuration("every")
+		if err != nil {
+			return nil, err
+		}
 
-	return &LinearInterpolateOpSpec{
-		Every: every,
-	}, nil
+		return &LinearInterpolateOpSpec{
+			Every:  every,
+			Method: method,
+		}, nil
+	}
 }
 
 func (s *LinearInterpolateOpSpec) Kind() flux.OperationKind {
@@ -61,6 +85,8 @@ func (s *LinearInterpolateOpSpec) Kind() flux.OperationKind {
 type LinearInterpolateProcedureSpec struct {
 	plan.DefaultCost
 	Every flux.Duration `json:"every"`
+	// Method is the interpolation method. The default is linear.
+	Method string `json:"method,omitempty"`
 }
 
 func newInterpolateProcedure(qs flux.OperationSpec, pa plan.Administration) (plan.ProcedureSpec, error) {
@@ -70,7 +96,8 @@ func newInterpolateProcedure(qs flux.OperationSpec, pa plan.Administration) (pla
 	}
 
 	return &LinearInterpolateProcedureSpec{
-		Every: spec.Every,
+		Every:  spec.Every,
+		Method: spec.Method,
 	}, nil
 }
 
@@ -79,7 +106,8 @@ func (s *LinearInterpolateProcedureSpec) Kind() plan.ProcedureKind {
 }
 func (s *LinearInterpolateProcedureSpec) Copy() plan.ProcedureSpec {
 	return &LinearInterpolateProcedureSpec{
-		Every: s.Every,
+		Every:  s.Every,
+		Method: s.Method,
 	}
 }
 
@@ -112,7 +140,7 @@ type interpolateTransformation struct {
 }
 
 func NewInterpolateTransformation(d execute.Dataset, cache execute.TableBuilderCache, spec *LinearInterpolateProcedureSpec) *interpolateTransformation {
-	return &interpolateTransformation{
+	t := &interpolateTransformation{
 		d:     d,
 		cache: cache,
 		spec:  *spec,
@@ -121,14 +149,24 @@ func NewInterpolateTransformation(d execute.Dataset, cache execute.TableBuilderC
 			Period: spec.Every,
 		},
 	}
+	if t.spec.Method == "" {
+		t.spec.Method = LinearMethod
+	}
+	return t
 }
 
 func (t *interpolateTransformation) RetractTable(id execute.DatasetID, key flux.GroupKey) error {
 	return t.d.RetractTable(key)
 }
 
+// interpolator appends the value at time x, which lies
+// between the known points i and i+1, to column j of a builder.
+type interpolator interface {
+	appendValue(b execute.TableBuilder, j, i int, x int64) error
+}
+
 func (t *interpolateTransformation) Process(id execute.DatasetID, tbl flux.Table) error {
-	key, columns, firstPoint := tbl.Key(), tbl.Cols(), true
+	key, columns := tbl.Key(), tbl.Cols()
 
 	for _, c := range columns {
 		if key.HasCol(c.Label) {
@@ -141,7 +179,7 @@ func (t *interpolateTransformation) Process(id execute.DatasetID, tbl flux.Table
 			continue
 		}
 		return errors.Newf(codes.FailedPrecondition,
-			"interpolate.linear requires column %q to be in group key", c.Label,
+			"interpolate.%s requires column %q to be in group key", t.spec.Method, c.Label,
 		)
 	}
 
@@ -170,106 +208,133 @@ func (t *interpolateTransformation) Process(id execute.DatasetID, tbl flux.Table
 		)
 	}
 
-	if ty := columns[vi].Type; ty != flux.TFloat {
+	stepped := t.spec.Method == PreviousMethod || t.spec.Method == NextMethod || t.spec.Method == NearestMethod
+	if ty := columns[vi].Type; ty != flux.TFloat && !stepped {
 		return errors.Newf(codes.FailedPrecondition,
 			"cannot interpolate %v values; expected float values", ty,
 		)
 	}
 
-	fn := appendFn(b, ti, vi)
-
-	var x0 int64
-	var y0 float64
-
+	// The known points are collected first because the spline methods
+	// need all of them to compute the value of any inserted point.
+	var (
+		xs []int64
+		ys []float64
+		vs []values.Value
+	)
 	if err := tbl.Do(func(cr flux.ColReader) error {
-
-		if err := execute.AppendKeyValuesN(key, b, cr.Len()); err != nil {
-			return err
-		}
-
 		tc := cr.Times(ti)
-		vc := cr.Floats(vi)
-
-		i := 0
-
-		if firstPoint && cr.Len() > 0 {
+		for i := 0; i < cr.Len(); i++ {
 			if tc.IsNull(i) {
-				return errors.New(codes.FailedPrecondition,
-					"null _time found during linear interpolation",
+				return errors.Newf(codes.FailedPrecondition,
+					"null _time found during %s interpolation", t.spec.Method,
 				)
 			}
-			x0 = tc.Value(i)
-
-			if vc.IsNull(i) {
-				return errors.New(codes.FailedPrecondition,
-					"null _value found during linear interpolation",
+			v := execute.ValueForRow(cr, i, vi)
+			if v.IsNull() {
+				return errors.Newf(codes.FailedPrecondition,
+					"null _value found during %s interpolation", t.spec.Method,
 				)
 			}
-			y0 = vc.Value(i)
-
-			i++
-
-			if err := fn(x0, y0); err != nil {
-				return err
+			xs = append(xs, tc.Value(i))
+			if stepped {
+				vs = append(vs, v)
+			} else {
+				ys = append(ys, v.Float())
 			}
 		}
-		for ; i < cr.Len(); i++ {
-
-			if tc.IsNull(i) {
-				return errors.New(codes.FailedPrecondition,
-					"null _time found during linear interpolation",
-				)
-			}
-			xn := tc.Value(i)
+		return nil
+	}); err != nil {
+		return err
+	}
 
-			if vc.IsNull(i) {
-				return errors.New(codes.FailedPrecondition,
-					"null _value found during linear interpolation",
+	var interp interpolator
+	switch t.spec.Method {
+	case PreviousMethod, NextMethod, NearestMethod:
+		interp = &stepInterpolator{method: t.spec.Method, xs: xs, vs: vs}
+	case SplineMethod, AkimaMethod:
+		for i := 1; i < len(xs); i++ {
+			if xs[i] <= xs[i-1] {
+				return errors.Newf(codes.FailedPrecondition,
+					"interpolate.%s requires strictly increasing _time values", t.spec.Method,
 				)
 			}
-			yn := vc.Value(i)
-
-			xi := int64(t.window.GetEarliestBounds(values.Time(x0)).Stop)
-
-			m := (yn - y0) / float64(xn-x0)
-
-			for xi < xn {
-				yi := float64(y0) + m*float64(xi-x0)
-
-				if err := fn(xi, yi); err != nil {
-					return err
-				}
+		}
+		if t.spec.Method == SplineMethod {
+			interp = newSplineInterpolator(xs, ys)
+		} else {
+			interp = newAkimaInterpolator(xs, ys)
+		}
+	default:
+		interp = &linearInterpolator{xs: xs, ys: ys}
+	}
 
-				if err := execute.AppendKeyValues(key, b); err != nil {
-					return err
-				}
+	for i := range xs {
+		if err := b.AppendTime(ti, execute.Time(xs[i])); err != nil {
+			return err
+		}
+		var err error
+		if stepped {
+			err = b.AppendValue(vi, vs[i])
+		} else {
+			err = b.AppendFloat(vi, ys[i])
+		}
+		if err != nil {
+			return err
+		}
+		if err := execute.AppendKeyValues(key, b); err != nil {
+			return err
+		}
+		if i+1 == len(xs) {
+			break
+		}
 
-				xi = int64(execute.Time(xi).Add(t.window.Every))
+		xi := int64(t.window.GetEarliestBounds(values.Time(xs[i])).Stop)
+		for xi < xs[i+1] {
+			if err := b.AppendTime(ti, execute.Time(xi)); err != nil {
+				return err
 			}
-
-			if err := fn(xn, yn); err != nil {
+			if err := interp.appendValue(b, vi, i, xi); err != nil {
 				return err
 			}
-
-			x0, y0, firstPoint = xn, yn, false
+			if err := execute.AppendKeyValues(key, b); err != nil {
+				return err
+			}
+			xi = int64(execute.Time(xi).Add(t.window.Every))
 		}
-		return nil
-	}); err != nil {
-		return err
 	}
 	return nil
 }
 
-func appendFn(b execute.TableBuilder, timeIdx, valueIdx int) func(int64, float64) error {
-	return func(t int64, v float64) error {
-		if err := b.AppendTime(timeIdx, execute.Time(t)); err != nil {
-			return err
-		}
-		if err := b.AppendFloat(valueIdx, v); err != nil {
-			return err
+type linearInterpolator struct {
+	xs []int64
+	ys []float64
+}
+
+func (l *linearInterpolator) appendValue(b execute.TableBuilder, j, i int, x int64) error {
+	x0, y0 := l.xs[i], l.ys[i]
+	m := (l.ys[i+1] - y0) / float64(l.xs[i+1]-x0)
+	return b.AppendFloat(j, y0+m*float64(x-x0))
+}
+
+// stepInterpolator fills inserted points with the value of the previous,
+// the next or the nearest known point. Ties go to the previous point.
+type stepInterpolator struct {
+	method string
+	xs     []int64
+	vs     []values.Value
+}
+
+func (s *stepInterpolator) appendValue(b execute.TableBuilder, j, i int, x int64) error {
+	switch s.method {
+	case NextMethod:
+		i++
+	case NearestMethod:
+		if s.xs[i+1]-x < x-s.xs[i] {
+			i++
 		}
-		return nil
 	}
+	return b.AppendValue(j, s.vs[i])
 }
 
 func (t *interpolateTransformation) UpdateWatermark(id execute.DatasetID, mark execute.Time) error {
@@ -0,0 +1,102 @@
+package interpolate
+
+import (
+	"math"
+
+	"github.com/influxdata/flux/execute"
+)
+
+// splineInterpolator interpolates with a natural cubic spline.
+// The second derivative of the spline is zero at the first and the last point.
+type splineInterpolator struct {
+	xs []int64
+	ys []float64
+	// m holds the second derivative of the spline at each point.
+	m []float64
+}
+
+func newSplineInterpolator(xs []int64, ys []float64) *splineInterpolator {
+	n := len(xs)
+	m := make([]float64, n)
+	if n > 2 {
+		// Solve the tridiagonal system for the second derivatives
+		// of the inner points with the Thomas algorithm.
+		c := make([]float64, n)
+		d := make([]float64, n)
+		for i := 1; i < n-1; i++ {
+			h0 := float64(xs[i] - xs[i-1])
+			h1 := float64(xs[i+1] - xs[i])
+			r := 6 * ((ys[i+1]-ys[i])/h1 - (ys[i]-ys[i-1])/h0)
+			diag := 2*(h0+h1) - h0*c[i-1]
+			c[i] = h1 / diag
+			d[i] = (r - h0*d[i-1]) / diag
+		}
+		for i := n - 2; i > 0; i-- {
+			m[i] = d[i] - c[i]*m[i+1]
+		}
+	}
+	return &splineInterpolator{xs: xs, ys: ys, m: m}
+}
+
+func (s *splineInterpolator) appendValue(b execute.TableBuilder, j, i int, x int64) error {
+	h := float64(s.xs[i+1] - s.xs[i])
+	a := float64(s.xs[i+1]-x) / h
+	c := float64(x-s.xs[i]) / h
+	y := a*s.ys[i] + c*s.ys[i+1] + ((a*a*a-a)*s.m[i]+(c*c*c-c)*s.m[i+1])*h*h/6
+	return b.AppendFloat(j, y)
+}
+
+// akimaInterpolator interpolates with the Akima spline which,
+// unlike a cubic spline, only depends on the points near an interval
+// and does not overshoot around outliers.
+type akimaInterpolator struct {
+	xs []int64
+	ys []float64
+	// m holds the slope of each interval and t the slope of the spline at each point.
+	m, t []float64
+}
+
+func newAkimaInterpolator(xs []int64, ys []float64) *akimaInterpolator {
+	n := len(xs)
+	a := &akimaInterpolator{xs: xs, ys: ys, t: make([]float64, n)}
+	if n < 2 {
+		return a
+	}
+
+	// The slopes are extended by two intervals on either side
+	// so every point has two slopes before and after it.
+	a.m = make([]float64, n+3)
+	for i := 0; i < n-1; i++ {
+		a.m[i+2] = (ys[i+1] - ys[i]) / float64(xs[i+1]-xs[i])
+	}
+	if n == 2 {
+		for i := range a.m {
+			a.m[i] = a.m[2]
+		}
+	} else {
+		a.m[1] = 2*a.m[2] - a.m[3]
+		a.m[0] = 2*a.m[1] - a.m[2]
+		a.m[n+1] = 2*a.m[n] - a.m[n-1]
+		a.m[n+2] = 2*a.m[n+1] - a.m[n]
+	}
+
+	for i := 0; i < n; i++ {
+		m0, m1, m2, m3 := a.m[i], a.m[i+1], a.m[i+2], a.m[i+3]
+		w0, w1 := math.Abs(m3-m2), math.Abs(m1-m0)
+		if w0+w1 == 0 {
+			a.t[i] = (m1 + m2) / 2
+		} else {
+			a.t[i] = (w0*m1 + w1*m2) / (w0 + w1)
+		}
+	}
+	return a
+}
+
+func (a *akimaInterpolator) appendValue(b execute.TableBuilder, j, i int, x int64) error {
+	h := float64(a.xs[i+1] - a.xs[i])
+	d := float64(x - a.xs[i])
+	m := a.m[i+2]
+	p2 := (3*m - 2*a.t[i] - a.t[i+1]) / h
+	p3 := (a.t[i] + a.t[i+1] - 2*m) / (h * h)
+	return b.AppendFloat(j, a.ys[i]+a.t[i]*d+p2*d*d+p3*d*d*d)
+}
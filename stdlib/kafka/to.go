@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/url"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/cespare/xxhash/v2"
@@ -246,6 +247,10 @@ func NewToKafkaTransformation(d execute.Dataset, deps flux.Dependencies, cache e
 		return nil, err
 	}
 	for _, b := range spec.Spec.Brokers {
+		// Brokers are usually given as host:port which does not parse as a url.
+		if !strings.Contains(b, "://") {
+			b = "tcp://" + b
+		}
 		u, err := url.Parse(b)
 		if err != nil {
 			return nil, errors.Newf(codes.Invalid, "invalid kafka broker url: %v", err)
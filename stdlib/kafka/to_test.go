@@ -566,19 +566,19 @@ func TestToKafka_NewTransformation(t *testing.T) {
 				Name: "invalid url",
 				Spec: &fkafka.ToKafkaProcedureSpec{
 					Spec: &fkafka.ToKafkaOpSpec{
-						Brokers: []string{":this:is:invalid:"},
+						Brokers: []string{"broker:port"},
 					},
 				},
-				WantErr: "invalid kafka broker url: parse :this:is:invalid:: missing protocol scheme",
+				WantErr: `invalid kafka broker url: parse "tcp://broker:port": invalid port ":port" after host`,
 			}, {
 				Name: "no lookup",
 				Spec: &fkafka.ToKafkaProcedureSpec{
 					Spec: &fkafka.ToKafkaOpSpec{
-						Brokers: []string{"notfound"},
+						Brokers: []string{"notfound.invalid:9092"},
 					},
 				},
 				Validator: url.PrivateIPValidator{},
-				WantErr:   "kafka broker url did not pass validation: lookup : no such host",
+				WantErr:   "kafka broker url did not pass validation: lookup notfound.invalid: no such host",
 			}, {
 				Name: "private url",
 				Spec: &fkafka.ToKafkaProcedureSpec{
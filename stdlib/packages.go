@@ -38,11 +38,13 @@ import (
 	_ "github.com/influxdata/flux/stdlib/experimental/http"
 	_ "github.com/influxdata/flux/stdlib/experimental/influxdb"
 	_ "github.com/influxdata/flux/stdlib/experimental/json"
+	_ "github.com/influxdata/flux/stdlib/experimental/materialized"
 	_ "github.com/influxdata/flux/stdlib/experimental/mqtt"
 	_ "github.com/influxdata/flux/stdlib/experimental/oee"
 	_ "github.com/influxdata/flux/stdlib/experimental/prometheus"
 	_ "github.com/influxdata/flux/stdlib/experimental/query"
 	_ "github.com/influxdata/flux/stdlib/experimental/record"
+	_ "github.com/influxdata/flux/stdlib/experimental/sketch"
 	_ "github.com/influxdata/flux/stdlib/experimental/table"
 	_ "github.com/influxdata/flux/stdlib/experimental/usage"
 	_ "github.com/influxdata/flux/stdlib/generate"
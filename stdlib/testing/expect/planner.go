@@ -25,6 +25,17 @@ func init() {
 			true,
 		),
 	)
+
+	signature = runtime.MustLookupBuiltinType(pkgpath, "plan")
+	runtime.RegisterPackageValue(pkgpath, "plan",
+		values.NewFunction("plan",
+			signature,
+			func(ctx context.Context, args values.Object) (values.Value, error) {
+				return interpreter.DoFunctionCallContext(Plan, ctx, args)
+			},
+			true,
+		),
+	)
 }
 
 func Planner(ctx context.Context, args interpreter.Arguments) (values.Value, error) {
@@ -57,3 +68,14 @@ func Planner(ctx context.Context, args interpreter.Arguments) (values.Value, err
 	}
 	return values.Void, nil
 }
+
+func Plan(ctx context.Context, args interpreter.Arguments) (values.Value, error) {
+	name, _, err := args.GetString("name")
+	if err != nil {
+		return nil, err
+	}
+	if err := testing.ExpectPlanSnapshot(ctx, name); err != nil {
+		return nil, err
+	}
+	return values.Void, nil
+}
@@ -0,0 +1,117 @@
+package holt_winters
+
+import (
+	"fmt"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/array"
+	"github.com/influxdata/flux/arrow"
+	fluxmemory "github.com/influxdata/flux/memory"
+	"github.com/influxdata/flux/values"
+)
+
+// CleanData returns cleaned data (using the value and time column), and the first and last valid timestamps.
+// Below are the cleaning criteria.
+// Rows that have a null timestamp get discarded.
+// Rows that have a null value are considered invalid, but used by the algorithm.
+// HoltWinters and STL suppose to work with evenly spaced values in time, so:
+//  - the interval passed to the transformation is used to divide the data in time buckets;
+//  - if many values are in the same bucket, the first one is selected, the others are skipped;
+//  - if no value is present for a bucket, that is considered as an invalid value (treated like null values).
+// HoltWinters and STL will only be provided with the values returned.
+// Timestamps can be deduced by summing interval to the first/last valid timestamp.
+func CleanData(tbl flux.Table, colIdx, timeIdx int, interval values.Duration, alloc *fluxmemory.Allocator) (*array.Float, values.Time, values.Time, error) {
+	vs := array.NewFloatBuilder(arrow.NewAllocator(alloc))
+	var start, stop int64
+	bucketEnd := int64(-1)
+	bucketFilled := false
+	roundTime := func(t int64) int64 {
+		return int64(values.Time(t).Round(interval))
+	}
+	nextBucket := func() {
+		bucketEnd += int64(interval.Duration())
+		bucketFilled = false
+	}
+	appendV := func(cr flux.ColReader, i int) {
+		switch typ := tbl.Cols()[colIdx].Type; typ {
+		case flux.TInt:
+			c := cr.Ints(colIdx)
+			if c.IsNull(i) {
+				vs.AppendNull()
+			} else {
+				vs.Append(float64(c.Value(i)))
+			}
+		case flux.TUInt:
+			c := cr.UInts(colIdx)
+			if c.IsNull(i) {
+				vs.AppendNull()
+			} else {
+				vs.Append(float64(c.Value(i)))
+			}
+		case flux.TFloat:
+			c := cr.Floats(colIdx)
+			if c.IsNull(i) {
+				vs.AppendNull()
+			} else {
+				vs.Append(float64(c.Value(i)))
+			}
+		default:
+			panic(fmt.Sprintf("cannot append non-numerical type %s", typ.String()))
+		}
+		bucketFilled = true
+	}
+	isNull := func(cr flux.ColReader, i int) bool {
+		switch typ := tbl.Cols()[colIdx].Type; typ {
+		case flux.TInt:
+			return cr.Ints(colIdx).IsNull(i)
+		case flux.TUInt:
+			return cr.UInts(colIdx).IsNull(i)
+		case flux.TFloat:
+			return cr.Floats(colIdx).IsNull(i)
+		default:
+			panic(fmt.Sprintf("cannot check non-numerical type %s", typ.String()))
+		}
+	}
+	isFirst := func() bool {
+		return bucketEnd == -1
+	}
+	if err := tbl.Do(func(cr flux.ColReader) error {
+		// we work row-wise
+		for i := 0; i < cr.Len(); i++ {
+			// drop values with invalid timestamp
+			if cts := cr.Times(timeIdx); cts.IsValid(i) {
+				// the first value must be valid, skip it if it isn't so
+				if isFirst() && isNull(cr, i) {
+					continue
+				}
+				trueT := cts.Value(i)
+				roundT := roundTime(trueT)
+				// if this is the first valid ts, directly append the value and continue
+				if isFirst() {
+					start = trueT
+					bucketEnd = roundT
+					appendV(cr, i)
+					continue
+				}
+				if roundT <= bucketEnd && bucketFilled {
+					// drop values that occur for the same time bucket
+					continue
+				}
+				// ok, this value is for a new bucket
+				nextBucket()
+				// append null for each empty bucket found
+				for roundT > bucketEnd {
+					vs.AppendNull()
+					nextBucket()
+				}
+				// this is the first value for the bucket
+				appendV(cr, i)
+				stop = trueT
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, 0, 0, err
+	}
+	return vs.NewFloatArray(), values.Time(start), values.Time(stop), nil
+}
@@ -0,0 +1,332 @@
+package holt_winters
+
+import (
+	"math"
+	"sort"
+
+	"github.com/influxdata/flux/array"
+)
+
+const (
+	// Iterations of the inner loop, that updates the seasonal and trend components,
+	// with and without robustness iterations.
+	stlInnerIterations       = 2
+	stlRobustInnerIterations = 1
+	// Iterations of the outer loop, that updates the robustness weights.
+	stlRobustOuterIterations = 15
+)
+
+// STL decomposes a seasonal series into trend, seasonal and remainder components.
+// This is done using the seasonal-trend decomposition procedure based on LOESS
+// described by Cleveland et al. (1990).
+//    1. The inner loop smooths the cycle-subseries of the detrended series
+//       into the seasonal component and the deseasonalized series into the trend.
+//    2. The outer loop, if robust, down-weights values with large remainders
+//       so outliers do not distort the trend and seasonal components.
+type STL struct {
+	period   int
+	seasonal int
+	trend    int
+	lowPass  int
+	inner    int
+	outer    int
+}
+
+// NewSTL creates a new STL for series with period values per season.
+// seasonal and trend are the number of values used by the LOESS smoothers
+// of the seasonal and the trend component. A trend less than 1 selects
+// the smallest odd number that is at least 1.5 * period / (1 - 1.5 / seasonal).
+func NewSTL(period, seasonal, trend int, robust bool) *STL {
+	if trend < 1 {
+		trend = nextOdd(int(math.Ceil(1.5 * float64(period) / (1 - 1.5/float64(seasonal)))))
+	}
+	s := &STL{
+		period:   period,
+		seasonal: seasonal,
+		trend:    trend,
+		lowPass:  nextOdd(period),
+		inner:    stlInnerIterations,
+	}
+	if robust {
+		s.inner = stlRobustInnerIterations
+		s.outer = stlRobustOuterIterations
+	}
+	return s
+}
+
+// Do returns the trend, seasonal and remainder components of the given series,
+// which add up to the series.
+// Null values are linearly interpolated from the nearest valid values before
+// decomposing the series. The series must have at least one valid value.
+func (s *STL) Do(vs *array.Float) (trend, seasonal, remainder []float64) {
+	y := interpolateNulls(vs)
+	n := len(y)
+	np := s.period
+
+	trend = make([]float64, n)
+	seasonal = make([]float64, n)
+	remainder = make([]float64, n)
+	rw := make([]float64, n)
+	for i := range rw {
+		rw[i] = 1
+	}
+
+	detrended := make([]float64, n)
+	deseasonalized := make([]float64, n)
+	cycle := make([]float64, n+2*np)
+	var sub, subw []float64
+	for o := 0; o <= s.outer; o++ {
+		for k := 0; k < s.inner; k++ {
+			for i := range y {
+				detrended[i] = y[i] - trend[i]
+			}
+
+			// Smooth each cycle-subseries and extend it by one value on either side.
+			// The value at time t is stored at cycle[t+np].
+			for j := 0; j < np; j++ {
+				sub, subw = sub[:0], subw[:0]
+				for i := j; i < n; i += np {
+					sub = append(sub, detrended[i])
+					subw = append(subw, rw[i])
+				}
+				for i := -1; i <= len(sub); i++ {
+					v, ok := loess(sub, subw, s.seasonal, 0, float64(i))
+					if !ok {
+						v = 0
+					}
+					cycle[j+(i+1)*np] = v
+				}
+			}
+
+			// Remove the low frequencies from the smoothed cycle-subseries
+			// so they end up in the trend.
+			low := movingAverage(movingAverage(movingAverage(cycle, np), np), 3)
+			for i := range seasonal {
+				l, ok := loess(low, nil, s.lowPass, 1, float64(i))
+				if !ok {
+					l = low[i]
+				}
+				seasonal[i] = cycle[i+np] - l
+			}
+
+			for i := range y {
+				deseasonalized[i] = y[i] - seasonal[i]
+			}
+			for i := range trend {
+				t, ok := loess(deseasonalized, rw, s.trend, 1, float64(i))
+				if !ok {
+					t = deseasonalized[i]
+				}
+				trend[i] = t
+			}
+		}
+
+		for i := range y {
+			remainder[i] = y[i] - seasonal[i] - trend[i]
+		}
+		if o < s.outer {
+			robustnessWeights(remainder, rw)
+		}
+	}
+	return trend, seasonal, remainder
+}
+
+// AnomalyScores returns the absolute robust z-score of each value,
+// which is its distance to the median in units of the scaled median absolute deviation.
+// If more than half of the values are equal, the mean absolute deviation is used instead.
+func AnomalyScores(xs []float64) []float64 {
+	scores := make([]float64, len(xs))
+	if len(xs) == 0 {
+		return scores
+	}
+	m := median(xs)
+	dev := make([]float64, len(xs))
+	var sum float64
+	for i, x := range xs {
+		dev[i] = math.Abs(x - m)
+		sum += dev[i]
+	}
+	// Scale the deviations so they estimate the standard deviation of normally distributed values.
+	scale := 1.4826 * median(dev)
+	if scale == 0 {
+		scale = 1.2533 * sum / float64(len(xs))
+	}
+	if scale == 0 {
+		return scores
+	}
+	for i, d := range dev {
+		scores[i] = d / scale
+	}
+	return scores
+}
+
+// loess estimates the value at x with a locally weighted regression of degree 0 or 1
+// over the q values of ys nearest to x. The values are at the positions 0 to len(ys)-1.
+// rw holds the robustness weight of every value; nil weights all values equally.
+// It returns false if all neighbors have a weight of zero.
+func loess(ys, rw []float64, q, degree int, x float64) (float64, bool) {
+	n := len(ys)
+	if n == 0 {
+		return 0, false
+	}
+
+	var l, r int
+	var h float64
+	if q >= n {
+		l, r = 0, n-1
+		h = math.Max(x, float64(n-1)-x) + float64(q-n)/2
+	} else {
+		// Slide the window of q values to the values nearest to x.
+		l = int(math.Floor(x)) - (q-1)/2
+		if l < 0 {
+			l = 0
+		} else if l > n-q {
+			l = n - q
+		}
+		for l > 0 && x-float64(l-1) < float64(l+q-1)-x {
+			l--
+		}
+		for l < n-q && float64(l+q)-x < x-float64(l) {
+			l++
+		}
+		r = l + q - 1
+		h = math.Max(x-float64(l), float64(r)-x)
+	}
+
+	w := make([]float64, r-l+1)
+	var a float64
+	for i := l; i <= r; i++ {
+		d := math.Abs(float64(i) - x)
+		var wi float64
+		switch {
+		case d <= 0.001*h:
+			wi = 1
+		case d <= 0.999*h:
+			u := d / h
+			u = 1 - u*u*u
+			wi = u * u * u
+		}
+		if rw != nil {
+			wi *= rw[i]
+		}
+		w[i-l] = wi
+		a += wi
+	}
+	if a <= 0 {
+		return 0, false
+	}
+	for i := range w {
+		w[i] /= a
+	}
+
+	if degree > 0 && h > 0 {
+		var mean float64
+		for i := l; i <= r; i++ {
+			mean += w[i-l] * float64(i)
+		}
+		var c float64
+		for i := l; i <= r; i++ {
+			d := float64(i) - mean
+			c += w[i-l] * d * d
+		}
+		if math.Sqrt(c) > 0.001*float64(n-1) {
+			b := (x - mean) / c
+			for i := l; i <= r; i++ {
+				w[i-l] *= b*(float64(i)-mean) + 1
+			}
+		}
+	}
+
+	var v float64
+	for i := l; i <= r; i++ {
+		v += w[i-l] * ys[i]
+	}
+	return v, true
+}
+
+// movingAverage returns the averages of every n consecutive values.
+func movingAverage(xs []float64, n int) []float64 {
+	if len(xs) < n {
+		return nil
+	}
+	out := make([]float64, len(xs)-n+1)
+	var sum float64
+	for i := 0; i < n; i++ {
+		sum += xs[i]
+	}
+	out[0] = sum / float64(n)
+	for i := 1; i < len(out); i++ {
+		sum += xs[i+n-1] - xs[i-1]
+		out[i] = sum / float64(n)
+	}
+	return out
+}
+
+// robustnessWeights sets the bisquare weight of every remainder into rw.
+func robustnessWeights(remainder, rw []float64) {
+	abs := make([]float64, len(remainder))
+	for i, r := range remainder {
+		abs[i] = math.Abs(r)
+	}
+	h := 6 * median(abs)
+	for i, r := range abs {
+		switch {
+		case h == 0 || r <= 0.001*h:
+			rw[i] = 1
+		case r <= 0.999*h:
+			u := r / h
+			u = 1 - u*u
+			rw[i] = u * u
+		default:
+			rw[i] = 0
+		}
+	}
+}
+
+// interpolateNulls returns the values of vs with null values
+// linearly interpolated from the nearest valid values.
+func interpolateNulls(vs *array.Float) []float64 {
+	y := make([]float64, vs.Len())
+	prev := -1
+	for i := range y {
+		if vs.IsNull(i) {
+			continue
+		}
+		y[i] = vs.Value(i)
+		switch {
+		case prev < 0:
+			for j := 0; j < i; j++ {
+				y[j] = y[i]
+			}
+		case prev < i-1:
+			for j := prev + 1; j < i; j++ {
+				y[j] = y[prev] + (y[i]-y[prev])*float64(j-prev)/float64(i-prev)
+			}
+		}
+		prev = i
+	}
+	if prev >= 0 {
+		for j := prev + 1; j < len(y); j++ {
+			y[j] = y[prev]
+		}
+	}
+	return y
+}
+
+func median(xs []float64) float64 {
+	sorted := append([]float64(nil), xs...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// nextOdd returns the smallest odd number that is at least n.
+func nextOdd(n int) int {
+	if n%2 == 0 {
+		return n + 1
+	}
+	return n
+}
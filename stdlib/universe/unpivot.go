@@ -0,0 +1,307 @@
+package universe
+
+import (
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/interpreter"
+	"github.com/influxdata/flux/plan"
+	"github.com/influxdata/flux/runtime"
+	"github.com/influxdata/flux/semantic"
+	"github.com/influxdata/flux/values"
+)
+
+const UnpivotKind = "unpivot"
+
+type UnpivotOpSpec struct {
+	Columns     []string `json:"columns"`
+	KeyColumn   string   `json:"keyColumn"`
+	ValueColumn string   `json:"valueColumn"`
+}
+
+func init() {
+	unpivotSignature := runtime.MustLookupBuiltinType("universe", "unpivot")
+
+	runtime.RegisterPackageValue("universe", UnpivotKind, flux.MustValue(flux.FunctionValue(UnpivotKind, createUnpivotOpSpec, unpivotSignature)))
+	flux.RegisterOpSpec(UnpivotKind, newUnpivotOp)
+	plan.RegisterProcedureSpec(UnpivotKind, newUnpivotProcedure, UnpivotKind)
+	execute.RegisterTransformation(UnpivotKind, createUnpivotTransformation)
+}
+
+func createUnpivotOpSpec(args flux.Arguments, a *flux.Administration) (flux.OperationSpec, error) {
+	if err := a.AddParentFromArgs(args); err != nil {
+		return nil, err
+	}
+
+	spec := &UnpivotOpSpec{}
+
+	array, err := args.GetRequiredArray("columns", semantic.String)
+	if err != nil {
+		return nil, err
+	}
+	spec.Columns, err = interpreter.ToStringArray(array)
+	if err != nil {
+		return nil, err
+	}
+
+	if label, ok, err := args.GetString("keyColumn"); err != nil {
+		return nil, err
+	} else if ok {
+		spec.KeyColumn = label
+	} else {
+		spec.KeyColumn = "_field"
+	}
+
+	if label, ok, err := args.GetString("valueColumn"); err != nil {
+		return nil, err
+	} else if ok {
+		spec.ValueColumn = label
+	} else {
+		spec.ValueColumn = execute.DefaultValueColLabel
+	}
+
+	if spec.KeyColumn == spec.ValueColumn {
+		return nil, errors.Newf(codes.Invalid, "keyColumn and valueColumn must be different, both are %q", spec.KeyColumn)
+	}
+	for _, c := range spec.Columns {
+		if c == spec.KeyColumn || c == spec.ValueColumn {
+			return nil, errors.Newf(codes.Invalid, "cannot unpivot column %q into itself", c)
+		}
+	}
+	return spec, nil
+}
+
+func newUnpivotOp() flux.OperationSpec {
+	return new(UnpivotOpSpec)
+}
+
+func (s *UnpivotOpSpec) Kind() flux.OperationKind {
+	return UnpivotKind
+}
+
+type UnpivotProcedureSpec struct {
+	plan.DefaultCost
+	Columns     []string
+	KeyColumn   string
+	ValueColumn string
+}
+
+func newUnpivotProcedure(qs flux.OperationSpec, pa plan.Administration) (plan.ProcedureSpec, error) {
+	spec, ok := qs.(*UnpivotOpSpec)
+	if !ok {
+		return nil, errors.Newf(codes.Internal, "invalid spec type %T", qs)
+	}
+
+	return &UnpivotProcedureSpec{
+		Columns:     spec.Columns,
+		KeyColumn:   spec.KeyColumn,
+		ValueColumn: spec.ValueColumn,
+	}, nil
+}
+
+func (s *UnpivotProcedureSpec) Kind() plan.ProcedureKind {
+	return UnpivotKind
+}
+
+func (s *UnpivotProcedureSpec) Copy() plan.ProcedureSpec {
+	ns := *s
+	ns.Columns = append([]string(nil), s.Columns...)
+	return &ns
+}
+
+func createUnpivotTransformation(id execute.DatasetID, mode execute.AccumulationMode, spec plan.ProcedureSpec, a execute.Administration) (execute.Transformation, execute.Dataset, error) {
+	s, ok := spec.(*UnpivotProcedureSpec)
+	if !ok {
+		return nil, nil, errors.Newf(codes.Internal, "invalid spec type %T", spec)
+	}
+	cache := execute.NewTableBuilderCache(a.Allocator())
+	d := execute.NewDataset(id, mode, cache)
+	t := NewUnpivotTransformation(d, cache, s)
+	return t, d, nil
+}
+
+type unpivotTransformation struct {
+	execute.ExecutionNode
+	d     execute.Dataset
+	cache execute.TableBuilderCache
+
+	columns     []string
+	keyColumn   string
+	valueColumn string
+}
+
+// NewUnpivotTransformation creates a transformation that turns columns into rows.
+//
+// Every unpivoted column of a table produces an output table whose group key is
+// the group key of the input table without the unpivoted columns and with the key column,
+// so the values of columns with different types end up in different tables.
+// Rows with a null value are dropped.
+func NewUnpivotTransformation(d execute.Dataset, cache execute.TableBuilderCache, spec *UnpivotProcedureSpec) *unpivotTransformation {
+	return &unpivotTransformation{
+		d:           d,
+		cache:       cache,
+		columns:     spec.Columns,
+		keyColumn:   spec.KeyColumn,
+		valueColumn: spec.ValueColumn,
+	}
+}
+
+func (t *unpivotTransformation) RetractTable(id execute.DatasetID, key flux.GroupKey) error {
+	return t.d.RetractTable(key)
+}
+
+// unpivotColumn holds the output table of an unpivoted column
+// and the mapping of the builder columns to the input columns.
+// The key and value columns are mapped to -1.
+type unpivotColumn struct {
+	idx      int
+	builder  execute.TableBuilder
+	colMap   []int
+	keyIdx   int
+	valueIdx int
+}
+
+func (t *unpivotTransformation) Process(id execute.DatasetID, tbl flux.Table) error {
+	cols := tbl.Cols()
+	unpivoted := make(map[string]bool, len(t.columns))
+	for _, label := range t.columns {
+		unpivoted[label] = true
+	}
+	var others []int
+	for j, c := range cols {
+		if unpivoted[c.Label] {
+			continue
+		}
+		if c.Label == t.keyColumn || c.Label == t.valueColumn {
+			return errors.Newf(codes.FailedPrecondition, "column %q already exists and is not unpivoted", c.Label)
+		}
+		others = append(others, j)
+	}
+
+	var outputs []*unpivotColumn
+	for _, label := range t.columns {
+		idx := execute.ColIdx(label, cols)
+		if idx < 0 {
+			// Tables without the column do not produce rows for it.
+			continue
+		}
+		out, err := t.output(tbl.Key(), cols, others, idx)
+		if err != nil {
+			return err
+		}
+		outputs = append(outputs, out)
+	}
+	if len(outputs) == 0 {
+		return tbl.Do(func(flux.ColReader) error { return nil })
+	}
+
+	return tbl.Do(func(cr flux.ColReader) error {
+		for _, out := range outputs {
+			for i, l := 0, cr.Len(); i < l; i++ {
+				v := execute.ValueForRow(cr, i, out.idx)
+				if v.IsNull() {
+					continue
+				}
+				for j, src := range out.colMap {
+					var err error
+					switch {
+					case j == out.keyIdx:
+						err = out.builder.AppendString(j, cols[out.idx].Label)
+					case j == out.valueIdx:
+						err = out.builder.AppendValue(j, v)
+					case src < 0:
+						err = out.builder.AppendNil(j)
+					default:
+						err = out.builder.AppendValue(j, execute.ValueForRow(cr, i, src))
+					}
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// output returns the output table for the unpivoted column at idx.
+func (t *unpivotTransformation) output(key flux.GroupKey, cols []flux.ColMeta, others []int, idx int) (*unpivotColumn, error) {
+	label := cols[idx].Label
+
+	var (
+		keyCols []flux.ColMeta
+		keyVals []values.Value
+	)
+	for j, c := range key.Cols() {
+		if execute.ContainsStr(t.columns, c.Label) {
+			continue
+		}
+		keyCols = append(keyCols, c)
+		keyVals = append(keyVals, key.Value(j))
+	}
+	keyCols = append(keyCols, flux.ColMeta{Label: t.keyColumn, Type: flux.TString})
+	keyVals = append(keyVals, values.NewString(label))
+	outKey := execute.NewGroupKey(keyCols, keyVals)
+
+	builder, created := t.cache.TableBuilder(outKey)
+	if created {
+		for _, j := range others {
+			if _, err := builder.AddCol(cols[j]); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := builder.AddCol(flux.ColMeta{Label: t.keyColumn, Type: flux.TString}); err != nil {
+			return nil, err
+		}
+		if _, err := builder.AddCol(flux.ColMeta{Label: t.valueColumn, Type: cols[idx].Type}); err != nil {
+			return nil, err
+		}
+	} else {
+		// Input tables that only differ by an unpivoted group key column share
+		// an output table. Columns that are missing from it are added.
+		if valueIdx := execute.ColIdx(t.valueColumn, builder.Cols()); builder.Cols()[valueIdx].Type != cols[idx].Type {
+			return nil, errors.Newf(codes.FailedPrecondition, "column %q has type %v in one table and %v in another with the same unpivoted group key", label, builder.Cols()[valueIdx].Type, cols[idx].Type)
+		}
+		for _, j := range others {
+			if execute.ColIdx(cols[j].Label, builder.Cols()) < 0 {
+				if _, err := builder.AddCol(cols[j]); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	out := &unpivotColumn{
+		idx:     idx,
+		builder: builder,
+		colMap:  make([]int, len(builder.Cols())),
+	}
+	for j, c := range builder.Cols() {
+		switch c.Label {
+		case t.keyColumn:
+			out.keyIdx, out.colMap[j] = j, -1
+		case t.valueColumn:
+			out.valueIdx, out.colMap[j] = j, -1
+		default:
+			src := execute.ColIdx(c.Label, cols)
+			if src >= 0 && cols[src].Type != c.Type {
+				return nil, errors.Newf(codes.FailedPrecondition, "schema collision detected: column %q is both of type %v and %v", c.Label, c.Type, cols[src].Type)
+			}
+			out.colMap[j] = src
+		}
+	}
+	return out, nil
+}
+
+func (t *unpivotTransformation) UpdateWatermark(id execute.DatasetID, mark execute.Time) error {
+	return t.d.UpdateWatermark(mark)
+}
+
+func (t *unpivotTransformation) UpdateProcessingTime(id execute.DatasetID, pt execute.Time) error {
+	return t.d.UpdateProcessingTime(pt)
+}
+
+func (t *unpivotTransformation) Finish(id execute.DatasetID, err error) {
+	t.d.Finish(err)
+}
@@ -0,0 +1,222 @@
+package universe_test
+
+import (
+	"testing"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/execute/executetest"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/querytest"
+	"github.com/influxdata/flux/stdlib/universe"
+)
+
+func TestUnpivotOperation_Marshaling(t *testing.T) {
+	data := []byte(`{
+		"id":"unpivot",
+		"kind":"unpivot",
+		"spec":{
+			"columns":["temp", "hum"],
+			"keyColumn":"_field",
+			"valueColumn":"_value"
+		}
+	}`)
+	op := &flux.Operation{
+		ID: "unpivot",
+		Spec: &universe.UnpivotOpSpec{
+			Columns:     []string{"temp", "hum"},
+			KeyColumn:   "_field",
+			ValueColumn: "_value",
+		},
+	}
+	querytest.OperationMarshalingTestHelper(t, data, op)
+}
+
+func TestUnpivot_Process(t *testing.T) {
+	testCases := []struct {
+		name    string
+		spec    *universe.UnpivotProcedureSpec
+		data    []flux.Table
+		want    []*executetest.Table
+		wantErr error
+	}{
+		{
+			name: "mixed types",
+			spec: &universe.UnpivotProcedureSpec{
+				Columns:     []string{"temp", "ok"},
+				KeyColumn:   "_field",
+				ValueColumn: "_value",
+			},
+			data: []flux.Table{
+				&executetest.Table{
+					KeyCols: []string{"_measurement"},
+					ColMeta: []flux.ColMeta{
+						{Label: "_time", Type: flux.TTime},
+						{Label: "_measurement", Type: flux.TString},
+						{Label: "temp", Type: flux.TFloat},
+						{Label: "ok", Type: flux.TBool},
+					},
+					Data: [][]interface{}{
+						{execute.Time(1), "m", 20.5, true},
+						{execute.Time(2), "m", nil, false},
+						{execute.Time(3), "m", 21.0, nil},
+					},
+				},
+			},
+			want: []*executetest.Table{
+				{
+					KeyCols: []string{"_measurement", "_field"},
+					ColMeta: []flux.ColMeta{
+						{Label: "_time", Type: flux.TTime},
+						{Label: "_measurement", Type: flux.TString},
+						{Label: "_field", Type: flux.TString},
+						{Label: "_value", Type: flux.TFloat},
+					},
+					Data: [][]interface{}{
+						{execute.Time(1), "m", "temp", 20.5},
+						{execute.Time(3), "m", "temp", 21.0},
+					},
+				},
+				{
+					KeyCols: []string{"_measurement", "_field"},
+					ColMeta: []flux.ColMeta{
+						{Label: "_time", Type: flux.TTime},
+						{Label: "_measurement", Type: flux.TString},
+						{Label: "_field", Type: flux.TString},
+						{Label: "_value", Type: flux.TBool},
+					},
+					Data: [][]interface{}{
+						{execute.Time(1), "m", "ok", true},
+						{execute.Time(2), "m", "ok", false},
+					},
+				},
+			},
+		},
+		{
+			name: "unpivoted group key column",
+			spec: &universe.UnpivotProcedureSpec{
+				Columns:     []string{"host", "load"},
+				KeyColumn:   "name",
+				ValueColumn: "value",
+			},
+			data: []flux.Table{
+				&executetest.Table{
+					KeyCols: []string{"host"},
+					ColMeta: []flux.ColMeta{
+						{Label: "_time", Type: flux.TTime},
+						{Label: "host", Type: flux.TString},
+						{Label: "load", Type: flux.TFloat},
+					},
+					Data: [][]interface{}{
+						{execute.Time(1), "a", 1.5},
+					},
+				},
+				&executetest.Table{
+					KeyCols: []string{"host"},
+					ColMeta: []flux.ColMeta{
+						{Label: "_time", Type: flux.TTime},
+						{Label: "host", Type: flux.TString},
+						{Label: "load", Type: flux.TFloat},
+					},
+					Data: [][]interface{}{
+						{execute.Time(1), "b", 2.5},
+					},
+				},
+			},
+			want: []*executetest.Table{
+				{
+					KeyCols: []string{"name"},
+					ColMeta: []flux.ColMeta{
+						{Label: "_time", Type: flux.TTime},
+						{Label: "name", Type: flux.TString},
+						{Label: "value", Type: flux.TString},
+					},
+					Data: [][]interface{}{
+						{execute.Time(1), "host", "a"},
+						{execute.Time(1), "host", "b"},
+					},
+				},
+				{
+					KeyCols: []string{"name"},
+					ColMeta: []flux.ColMeta{
+						{Label: "_time", Type: flux.TTime},
+						{Label: "name", Type: flux.TString},
+						{Label: "value", Type: flux.TFloat},
+					},
+					Data: [][]interface{}{
+						{execute.Time(1), "load", 1.5},
+						{execute.Time(1), "load", 2.5},
+					},
+				},
+			},
+		},
+		{
+			name: "missing column",
+			spec: &universe.UnpivotProcedureSpec{
+				Columns:     []string{"temp", "hum"},
+				KeyColumn:   "_field",
+				ValueColumn: "_value",
+			},
+			data: []flux.Table{
+				&executetest.Table{
+					ColMeta: []flux.ColMeta{
+						{Label: "_time", Type: flux.TTime},
+						{Label: "temp", Type: flux.TInt},
+					},
+					Data: [][]interface{}{
+						{execute.Time(1), int64(20)},
+					},
+				},
+			},
+			want: []*executetest.Table{
+				{
+					KeyCols: []string{"_field"},
+					ColMeta: []flux.ColMeta{
+						{Label: "_time", Type: flux.TTime},
+						{Label: "_field", Type: flux.TString},
+						{Label: "_value", Type: flux.TInt},
+					},
+					Data: [][]interface{}{
+						{execute.Time(1), "temp", int64(20)},
+					},
+				},
+			},
+		},
+		{
+			name: "value column exists",
+			spec: &universe.UnpivotProcedureSpec{
+				Columns:     []string{"temp"},
+				KeyColumn:   "_field",
+				ValueColumn: "_value",
+			},
+			data: []flux.Table{
+				&executetest.Table{
+					ColMeta: []flux.ColMeta{
+						{Label: "_time", Type: flux.TTime},
+						{Label: "_value", Type: flux.TInt},
+						{Label: "temp", Type: flux.TInt},
+					},
+					Data: [][]interface{}{
+						{execute.Time(1), int64(1), int64(20)},
+					},
+				},
+			},
+			wantErr: errors.New(codes.FailedPrecondition, `column "_value" already exists and is not unpivoted`),
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			executetest.ProcessTestHelper(
+				t,
+				tc.data,
+				tc.want,
+				tc.wantErr,
+				func(d execute.Dataset, c execute.TableBuilderCache) execute.Transformation {
+					return universe.NewUnpivotTransformation(d, c, tc.spec)
+				},
+			)
+		})
+	}
+}
@@ -212,8 +212,8 @@ func (w *windowTransformation2) clipBounds(bs []execute.Bounds) {
 
 func (w *windowTransformation2) determineSchemaTemplate(tbl flux.Table) windowSchemaTemplate {
 	// Determine the shared key and column metadata.
-	keyCols, keyValues := w.newGroupKeyTemplate(tbl.Key())
-	cols := w.createSchema(tbl.Cols())
+	keyCols, keyValues := WindowGroupKeyTemplate(tbl.Key(), w.startCol, w.stopCol)
+	cols := WindowSchema(tbl.Cols(), w.startCol, w.stopCol)
 	return windowSchemaTemplate{
 		keyCols:   keyCols,
 		keyValues: keyValues,
@@ -263,14 +263,15 @@ func (w *windowTransformation2) createEmptyWindows(t *windowSchemaTemplate) {
 	}
 }
 
-// newGroupKeyTemplate creates the template for the group key columns and values.
+// WindowGroupKeyTemplate creates the template for the group key columns and values
+// of the windows of a table with the given key.
 // The columns are consistent across all group keys and the values only
 // need to be copied into a new array with the start and stop values set.
-func (w *windowTransformation2) newGroupKeyTemplate(key flux.GroupKey) ([]flux.ColMeta, []values.Value) {
-	cols := w.createSchema(key.Cols())
+func WindowGroupKeyTemplate(key flux.GroupKey, startCol, stopCol string) ([]flux.ColMeta, []values.Value) {
+	cols := WindowSchema(key.Cols(), startCol, stopCol)
 	vs := make([]values.Value, len(cols))
 	for i, col := range cols {
-		if col.Label == w.startCol || col.Label == w.stopCol {
+		if col.Label == startCol || col.Label == stopCol {
 			continue
 		}
 		vs[i] = key.LabelValue(col.Label)
@@ -278,23 +279,15 @@ func (w *windowTransformation2) newGroupKeyTemplate(key flux.GroupKey) ([]flux.C
 	return cols, vs
 }
 
-// newWindowGroupKey constructs a group key by combining the template
+// NewWindowGroupKey constructs a group key by combining the template
 // with the boundary values.
-func (w *windowTransformation2) newWindowGroupKey(cols []flux.ColMeta, vs []values.Value, bound execute.Bounds) flux.GroupKey {
+func NewWindowGroupKey(cols []flux.ColMeta, vs []values.Value, startCol, stopCol string, bound execute.Bounds) flux.GroupKey {
 	newValues := make([]values.Value, len(vs))
 	for i, col := range cols {
-		if col.Label == w.startCol {
-			start := bound.Start
-			if w.bounds != nil && start < w.bounds.Start {
-				start = w.bounds.Start
-			}
-			newValues[i] = values.NewTime(start)
-		} else if col.Label == w.stopCol {
-			stop := bound.Stop
-			if w.bounds != nil && stop > w.bounds.Stop {
-				stop = w.bounds.Stop
-			}
-			newValues[i] = values.NewTime(stop)
+		if col.Label == startCol {
+			newValues[i] = values.NewTime(bound.Start)
+		} else if col.Label == stopCol {
+			newValues[i] = values.NewTime(bound.Stop)
 		} else {
 			newValues[i] = vs[i]
 		}
@@ -302,34 +295,36 @@ func (w *windowTransformation2) newWindowGroupKey(cols []flux.ColMeta, vs []valu
 	return execute.NewGroupKey(cols, newValues)
 }
 
-// createSchema constructs the table schema for the new tables.
-func (w *windowTransformation2) createSchema(cols []flux.ColMeta) []flux.ColMeta {
+// WindowSchema constructs the schema of the windows of a table with the given columns.
+// The start and stop columns are time columns
+// and are appended to the schema when they do not exist.
+func WindowSchema(cols []flux.ColMeta, startCol, stopCol string) []flux.ColMeta {
 	ncols := len(cols)
-	if execute.ColIdx(w.startCol, cols) < 0 {
+	if execute.ColIdx(startCol, cols) < 0 {
 		ncols++
 	}
-	if execute.ColIdx(w.stopCol, cols) < 0 {
+	if execute.ColIdx(stopCol, cols) < 0 {
 		ncols++
 	}
 
 	newCols := make([]flux.ColMeta, 0, ncols)
 	for _, col := range cols {
-		if col.Label == w.startCol || col.Label == w.stopCol {
+		if col.Label == startCol || col.Label == stopCol {
 			col.Type = flux.TTime
 		}
 		newCols = append(newCols, col)
 	}
 
-	if execute.ColIdx(w.startCol, newCols) < 0 {
+	if execute.ColIdx(startCol, newCols) < 0 {
 		newCols = append(newCols, flux.ColMeta{
-			Label: w.startCol,
+			Label: startCol,
 			Type:  flux.TTime,
 		})
 	}
 
-	if execute.ColIdx(w.stopCol, newCols) < 0 {
+	if execute.ColIdx(stopCol, newCols) < 0 {
 		newCols = append(newCols, flux.ColMeta{
-			Label: w.stopCol,
+			Label: stopCol,
 			Type:  flux.TTime,
 		})
 	}
@@ -338,7 +333,17 @@ func (w *windowTransformation2) createSchema(cols []flux.ColMeta) []flux.ColMeta
 
 // getBuilder returns the builder for the given bounds.
 func (w *windowTransformation2) getBuilder(t *windowSchemaTemplate, bound execute.Bounds) *table.ArrowBuilder {
-	key := w.newWindowGroupKey(t.keyCols, t.keyValues, bound)
+	// The bounds in the group key are clipped to the range of the query.
+	keyBound := bound
+	if w.bounds != nil {
+		if keyBound.Start < w.bounds.Start {
+			keyBound.Start = w.bounds.Start
+		}
+		if keyBound.Stop > w.bounds.Stop {
+			keyBound.Stop = w.bounds.Stop
+		}
+	}
+	key := NewWindowGroupKey(t.keyCols, t.keyValues, w.startCol, w.stopCol, keyBound)
 	builder, created := table.GetArrowBuilder(key, w.cache)
 	if created {
 		// Establish the table schema and initialize the builders.